@@ -202,11 +202,19 @@ func (b *SimulatedBackend) CallContract(ctx context.Context, call cpchain.CallMs
 	if blockNumber != nil && blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) != 0 {
 		return nil, errBlockNumberUnsupported
 	}
-	state, err := b.blockchain.State()
+	var (
+		statedb *state.StateDB
+		err     error
+	)
+	if call.IsPrivate {
+		statedb, err = b.blockchain.StatePriv()
+	} else {
+		statedb, err = b.blockchain.State()
+	}
 	if err != nil {
 		return nil, err
 	}
-	rval, _, _, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), state)
+	rval, _, _, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), statedb)
 	return rval, err
 }
 
@@ -473,6 +481,9 @@ func (fb *filterBackend) HeaderByNumber(ctx context.Context, block rpc.BlockNumb
 	if block == rpc.LatestBlockNumber {
 		return fb.bc.CurrentHeader(), nil
 	}
+	if block == rpc.FinalizedBlockNumber || block == rpc.SafeBlockNumber {
+		return fb.bc.CurrentFinalizedBlock().Header(), nil
+	}
 	return fb.bc.GetHeaderByNumber(uint64(block.Int64())), nil
 }
 
@@ -74,7 +74,8 @@ func (c *Client) BlockByHash(ctx context.Context, hash common.Hash) (*types.Bloc
 }
 
 // BlockByNumber returns a block from the current canonical chain. If number is nil, the
-// latest known block is returned.
+// latest known block is returned. Pass big.NewInt(int64(rpc.FinalizedBlockNumber)) to
+// get the latest finalized block.
 //
 // Note that loading full blocks requires two requests. Use HeaderByNumber
 // if you don't need all transactions or uncle headers.
@@ -134,7 +135,8 @@ func (c *Client) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Hea
 }
 
 // HeaderByNumber returns a block header from the current canonical chain. If number is
-// nil, the latest known header is returned.
+// nil, the latest known header is returned. Pass big.NewInt(int64(rpc.FinalizedBlockNumber))
+// to get the latest finalized header.
 func (c *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
 	var head *types.Header
 	err := c.c.CallContext(ctx, &head, "eth_getBlockByNumber", toBlockNumArg(number), false)
@@ -278,6 +280,18 @@ func toBlockNumArg(number *big.Int) string {
 	if number == nil {
 		return "latest"
 	}
+	if number.Sign() < 0 {
+		switch rpc.BlockNumber(number.Int64()) {
+		case rpc.LatestBlockNumber:
+			return "latest"
+		case rpc.PendingBlockNumber:
+			return "pending"
+		case rpc.FinalizedBlockNumber:
+			return "finalized"
+		case rpc.SafeBlockNumber:
+			return "safe"
+		}
+	}
 	return hexutil.EncodeBig(number)
 }
 
@@ -342,6 +356,30 @@ func (c *Client) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header)
 	return c.c.EthSubscribe(ctx, ch, "newHeads")
 }
 
+// SubscribeNewTerm subscribes to notifications about the start of new terms
+// on the given channel.
+func (c *Client) SubscribeNewTerm(ctx context.Context, ch chan<- *types.TermEvent) (cpchain.Subscription, error) {
+	return c.c.Subscribe(ctx, "dpor", ch, "newTerm")
+}
+
+// SubscribeCommitteeUpdate subscribes to notifications about proposers newly
+// elected for a future term on the given channel.
+func (c *Client) SubscribeCommitteeUpdate(ctx context.Context, ch chan<- *types.CommitteeEvent) (cpchain.Subscription, error) {
+	return c.c.Subscribe(ctx, "dpor", ch, "committeeUpdate")
+}
+
+// SubscribeImpeachment subscribes to notifications about committed impeach
+// blocks on the given channel.
+func (c *Client) SubscribeImpeachment(ctx context.Context, ch chan<- *types.ImpeachEvent) (cpchain.Subscription, error) {
+	return c.c.Subscribe(ctx, "dpor", ch, "impeachment")
+}
+
+// SubscribeRoleChange subscribes to notifications about role changes of the
+// connected node on the given channel.
+func (c *Client) SubscribeRoleChange(ctx context.Context, ch chan<- *types.RoleEvent) (cpchain.Subscription, error) {
+	return c.c.Subscribe(ctx, "dpor", ch, "roleChange")
+}
+
 // State Access
 
 // NetworkID returns the network ID (also known as the chain ID) for this chain.
@@ -408,6 +446,28 @@ func (c *Client) GetValidatorsByBlockNumber(ctx context.Context, blockNumber *bi
 	return result, err
 }
 
+// GetProposerStats returns how many blocks each proposer produced or was impeached for in the given term
+func (c *Client) GetProposerStats(ctx context.Context, term uint64) ([]*types.ProposerStats, error) {
+	var result []*types.ProposerStats
+	err := c.c.CallContext(ctx, &result, "dpor_getProposerStats", term)
+	return result, err
+}
+
+// GetValidatorParticipation returns how many blocks each validator signed or missed in the given term
+func (c *Client) GetValidatorParticipation(ctx context.Context, term uint64) ([]*types.ValidatorParticipation, error) {
+	var result []*types.ValidatorParticipation
+	err := c.c.CallContext(ctx, &result, "dpor_getValidatorParticipation", term)
+	return result, err
+}
+
+// GetRptBreakdown returns the components of the reputation of a candidate at the given block.
+// The block number can be nil, in which case the reputation is calculated at the latest known block.
+func (c *Client) GetRptBreakdown(ctx context.Context, account common.Address, blockNumber *big.Int) (*types.RptBreakdown, error) {
+	var result *types.RptBreakdown
+	err := c.c.CallContext(ctx, &result, "dpor_getRptBreakdown", account, toBlockNumArg(blockNumber))
+	return result, err
+}
+
 // BalanceAt returns the wei balance of the given account.
 // The block number can be nil, in which case the balance is taken from the latest known block.
 func (c *Client) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
@@ -600,5 +660,8 @@ func toCallArg(msg cpchain.CallMsg) interface{} {
 	if msg.GasPrice != nil {
 		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
 	}
+	if msg.IsPrivate {
+		arg["isPrivate"] = true
+	}
 	return arg
 }
@@ -0,0 +1,24 @@
+package rpc
+
+import (
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// Metrics are registered on first use rather than at package initialization,
+// which happens before the command line enables the metrics system.
+
+func rpcRequestMeter() metrics.Meter {
+	return metrics.GetOrRegisterMeter("rpc/requests", nil)
+}
+
+func successfulRequestMeter() metrics.Meter {
+	return metrics.GetOrRegisterMeter("rpc/success", nil)
+}
+
+func failedRequestMeter() metrics.Meter {
+	return metrics.GetOrRegisterMeter("rpc/failure", nil)
+}
+
+func rpcServingTimer() metrics.Timer {
+	return metrics.GetOrRegisterTimer("rpc/duration/all", nil)
+}
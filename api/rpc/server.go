@@ -24,6 +24,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"bitbucket.org/cpchain/chain/commons/log"
 	set "gopkg.in/fatih/set.v0"
@@ -309,17 +310,24 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	}
 
 	// execute RPC method and return result
+	start := time.Now()
+	rpcRequestMeter().Mark(1)
 	reply := req.callb.method.Func.Call(arguments)
+	rpcServingTimer().UpdateSince(start)
+
 	if len(reply) == 0 {
+		successfulRequestMeter().Mark(1)
 		return codec.CreateResponse(req.id, nil), nil
 	}
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
+			failedRequestMeter().Mark(1)
 			e := reply[req.callb.errPos].Interface().(error)
 			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
 			return res, nil
 		}
 	}
+	successfulRequestMeter().Mark(1)
 	return codec.CreateResponse(req.id, reply[0].Interface()), nil
 }
 
@@ -0,0 +1,130 @@
+// Copyright 2019 The cpchain authors
+// This file is part of cpchain.
+//
+// cpchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// cpchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with cpchain. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"bitbucket.org/cpchain/chain/cmd/cpchain/flags"
+	"bitbucket.org/cpchain/chain/commons/crypto/blskey"
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus/dpor"
+	"bitbucket.org/cpchain/chain/consensus/dpor/backend"
+	"bitbucket.org/cpchain/chain/database"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/urfave/cli"
+)
+
+var consensusCommand = cli.Command{
+	Name:  "consensus",
+	Usage: "Debug the consensus protocol",
+	Subcommands: []cli.Command{
+		{
+			Name:      "replay",
+			Usage:     "Replay a consensus trace through the LBFT2 state machine",
+			Flags:     flags.LogFlags,
+			Action:    replayTrace,
+			ArgsUsage: "<tracefile>",
+			Description: fmt.Sprintf(`Feed the inbound msgs of a trace recorded with --%v to a new state machine
+in order, and print the state sequence. Transitions diverging from the recorded
+ones are marked, the command fails if there is any.`, flags.TraceFlagName),
+		},
+		{
+			Name:      "blskey",
+			Usage:     "Generate a bls key for a validator to sign with after the BLS fork",
+			Action:    generateBLSKey,
+			ArgsUsage: "<address> <keyfile>",
+			Description: fmt.Sprintf(`Generate a bls key into the key file, to be passed with --%v, and print the
+public key with a proof of possession, to be registered in blsKeys of the dpor
+chain config for the validator's address.`, flags.BLSKeyFlagName),
+		},
+	},
+}
+
+func generateBLSKey(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 || !common.IsHexAddress(ctx.Args().Get(0)) {
+		log.Fatalf("This command requires an address and a key file as arguments")
+	}
+
+	file := ctx.Args().Get(1)
+	if _, err := os.Stat(file); err == nil {
+		log.Fatalf("Key file %v already exists", file)
+	}
+
+	key, err := blskey.GenerateKey(nil)
+	if err != nil {
+		log.Fatalf("Failed to generate bls key: %v", err)
+	}
+	if err := blskey.SaveSecretKey(file, key); err != nil {
+		log.Fatalf("Failed to save bls key: %v", err)
+	}
+
+	registration, _ := json.MarshalIndent(&configs.BLSKey{
+		Address:    common.HexToAddress(ctx.Args().Get(0)),
+		PublicKey:  key.PublicKey().Bytes(),
+		Possession: key.ProvePossession().Bytes(),
+	}, "", "  ")
+	fmt.Println(string(registration))
+	return nil
+}
+
+func replayTrace(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		log.Fatalf("This command requires a single argument for the trace file")
+	}
+
+	file, err := os.Open(ctx.Args().First())
+	if err != nil {
+		log.Fatalf("Failed to open trace file: %v", err)
+	}
+	defer file.Close()
+
+	meta, records, err := backend.ReadTrace(file)
+	if err != nil {
+		log.Fatalf("Failed to read trace file: %v", err)
+	}
+	fmt.Printf("trace of %v from block #%d, %d records\n", meta.Coinbase.Hex(), meta.Head.Number.Uint64(), len(records))
+
+	// signatures are recovered by the dpor engine, keys and chain are not needed
+	recoverer := dpor.New(&configs.DporConfig{TermLen: meta.TermLen, ViewLen: meta.ViewLen, FaultyNumber: meta.Faulty}, database.NewMemDatabase())
+
+	diverged := 0
+	for i, step := range backend.Replay(meta, records, recoverer) {
+		r := step.Record
+		mark := ""
+		if !step.Matches() {
+			mark = fmt.Sprintf(" DIVERGED, recorded %v #%d %v %q", r.State, r.Number, r.OutputMsgCode, r.Err)
+			diverged++
+		}
+		errString := ""
+		if step.Err != nil {
+			errString = step.Err.Error()
+		}
+		fmt.Printf("%5d %s %v #%d from %s -> %v #%d %v %q%s\n", i,
+			time.Unix(0, int64(r.Time)).Format("15:04:05.000"), r.MsgCode, r.Input().Number(), r.Peer.Hex(),
+			step.Status.State, step.Status.Number, step.MsgCode, errString, mark)
+	}
+
+	if diverged > 0 {
+		return fmt.Errorf("%d transitions diverged from the trace", diverged)
+	}
+	return nil
+}
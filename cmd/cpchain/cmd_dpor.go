@@ -0,0 +1,196 @@
+// Copyright 2019 The cpchain authors
+// This file is part of cpchain.
+//
+// cpchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// cpchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with cpchain. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"bitbucket.org/cpchain/chain/cmd/cpchain/commons"
+	"bitbucket.org/cpchain/chain/cmd/cpchain/flags"
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus/dpor"
+	"bitbucket.org/cpchain/chain/consensus/dpor/election"
+	"bitbucket.org/cpchain/chain/consensus/dpor/rpt"
+	"github.com/urfave/cli"
+)
+
+var dporCommand = cli.Command{
+	Name:  "dpor",
+	Usage: "Inspect the dpor consensus of a local chain",
+	Subcommands: []cli.Command{
+		{
+			Name:      "simulate",
+			Usage:     "Replay elections of proposers with overridden parameters",
+			Action:    simulateElections,
+			ArgsUsage: "<firstTerm> [lastTerm]",
+			Flags: append(append([]cli.Flag{
+				flags.GetByName(flags.DataDirFlagName),
+				flags.GetByName(flags.RunModeFlagName),
+				flags.GetByName(flags.CacheFlagName),
+				flags.GetByName(flags.CacheDatabaseFlagName),
+				flags.GetByName(flags.CacheGCFlagName),
+			}, flags.SimulateFlags...), flags.LogFlags...),
+			Description: `Elect proposers of the terms again with rpts, candidates and states of the
+local chain database, and print the elected committees with seats differing
+from the ones on chain marked. Rpt weights, seats, the term length and the
+election algorithm given by flags replace the ones of the chain, the chain
+is not changed.`,
+		},
+	},
+}
+
+// simulatedConfig returns the dpor config of the chain with the term length
+// and the elector replaced if given
+func simulatedConfig(ctx *cli.Context, actual *configs.DporConfig) (*configs.DporConfig, error) {
+	config := *actual
+	if ctx.IsSet(flags.TermLenFlagName) {
+		config.TermLen = ctx.Uint64(flags.TermLenFlagName)
+	}
+	if ctx.IsSet(flags.ElectorFlagName) {
+		name := ctx.String(flags.ElectorFlagName)
+		if _, err := election.ElectorOf(name); err != nil {
+			return nil, err
+		}
+
+		forks := configs.DefaultDporForks()
+		if actual.Forks != nil {
+			cpy := *actual.Forks
+			forks = &cpy
+		}
+		forks.Electors = []*configs.ElectorFork{{Block: big.NewInt(0), Name: name}}
+		config.Forks = forks
+	}
+	return &config, nil
+}
+
+// simulatedOverride returns the rpt parameters and seats given by flags
+func simulatedOverride(ctx *cli.Context) *rpt.Override {
+	override := &rpt.Override{}
+	for name, value := range map[string]**int64{
+		flags.RptAlphaFlagName:         &override.Alpha,
+		flags.RptBetaFlagName:          &override.Beta,
+		flags.RptGammaFlagName:         &override.Gamma,
+		flags.RptPsiFlagName:           &override.Psi,
+		flags.RptOmegaFlagName:         &override.Omega,
+		flags.RptWindowFlagName:        &override.WindowSize,
+		flags.TotalSeatsFlagName:       &override.TotalSeats,
+		flags.LowRptSeatsFlagName:      &override.LowRptSeats,
+		flags.LowRptPercentageFlagName: &override.LowRptPercentage,
+	} {
+		if ctx.IsSet(name) {
+			v := ctx.Int64(name)
+			*value = &v
+		}
+	}
+	return override
+}
+
+func simulateElections(ctx *cli.Context) error {
+	argc := len(ctx.Args())
+	if argc != 1 && argc != 2 {
+		log.Fatalf("This command requires the first term and optionally the last one as arguments")
+	}
+	first, ferr := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	last, lerr := first, error(nil)
+	if argc == 2 {
+		last, lerr = strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	}
+	if ferr != nil || lerr != nil || first > last {
+		log.Fatalf("Invalid terms to simulate: %v", ctx.Args())
+	}
+
+	cfg, node := newConfigNode(ctx)
+	chain, chainDb := commons.OpenChain(ctx, node, &cfg.Cpc)
+	defer chainDb.Close()
+
+	client := commons.NewChainClient(chain)
+	client.RegisterPrimitiveContracts()
+
+	actual := chain.Config().Dpor
+	config, err := simulatedConfig(ctx, actual)
+	if err != nil {
+		log.Fatalf("Invalid elector: %v", err)
+	}
+	contracts := configs.ChainConfigInfo().Dpor.Contracts
+	rptService, err := rpt.NewRptServiceWithOverride(config, client, contracts[configs.ContractRpt], contracts[configs.ContractRpt2], simulatedOverride(ctx))
+	if err != nil {
+		log.Fatalf("Failed to create rpt service: %v", err)
+	}
+	candidateService, err := rpt.NewCandidateService(config, client)
+	if err != nil {
+		log.Fatalf("Failed to create candidate service: %v", err)
+	}
+
+	simulator := dpor.NewSimulator(config, actual, chain, candidateService, rptService)
+	if !simulator.Comparable() {
+		fmt.Printf("terms of %d blocks differ from the ones of %d blocks on chain, committees are not compared\n\n",
+			config.TermLen*config.ViewLen, actual.TermLen*actual.ViewLen)
+	}
+
+	differed, failed := 0, 0
+	for term := first; term <= last; term++ {
+		result, err := simulator.Simulate(term)
+		if err != nil {
+			fmt.Printf("term %d: %v\n\n", term, err)
+			failed++
+			continue
+		}
+		if printSimulatedElection(result) {
+			differed++
+		}
+	}
+	fmt.Printf("%d terms simulated, %d failed, %d elected different committees\n", last-first+1, failed, differed)
+	return nil
+}
+
+// printSimulatedElection prints the rpts and the committee of a simulated election,
+// and returns if it differs from the one on chain
+func printSimulatedElection(result *dpor.SimulatedElection) bool {
+	fmt.Printf("term %d elected at #%d with %s\n", result.Term, result.Number, result.Elector)
+	for _, r := range result.Rpts {
+		fmt.Printf("  rpt %s %d\n", r.Address.Hex(), r.Rpt)
+	}
+
+	differed := make(map[int]bool)
+	if result.Actual != nil {
+		for _, seat := range result.Diff() {
+			differed[seat] = true
+		}
+	}
+	for seat := 0; seat < len(result.Proposers) || seat < len(result.Actual); seat++ {
+		simulated, actual := "-", "-"
+		if seat < len(result.Proposers) {
+			simulated = result.Proposers[seat].Hex()
+		}
+		if seat < len(result.Actual) {
+			actual = result.Actual[seat].Hex()
+		}
+		mark := ""
+		if differed[seat] {
+			mark = " DIFFERS"
+		}
+		fmt.Printf("  seat %2d %s %s%s\n", seat, simulated, actual, mark)
+	}
+	if result.Actual == nil {
+		fmt.Println("  proposers on chain are not available")
+	}
+	fmt.Println()
+	return len(differed) > 0
+}
@@ -35,6 +35,7 @@ import (
 	"bitbucket.org/cpchain/chain/internal/profile"
 	"bitbucket.org/cpchain/chain/node"
 	"bitbucket.org/cpchain/chain/protocols/cpc"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/urfave/cli"
 )
 
@@ -57,6 +58,11 @@ func init() {
 		Flags:  runFlags,
 		Usage:  "Run a cpchain node",
 		Before: func(ctx *cli.Context) error {
+			// the prometheus endpoint needs the metrics collected, this is done
+			// before any service creates its meters
+			if ctx.IsSet(flags.MetricsAddrFlagName) {
+				metrics.Enabled = true
+			}
 			return nil
 		},
 		After: func(ctx *cli.Context) error {
@@ -281,6 +287,9 @@ func bootstrap(ctx *cli.Context, n *node.Node) {
 	if ctx.IsSet(flags.MetricGatewayFlagName) {
 		chainmetrics.InitMetrics(ctx.String(flags.PortFlagName), ctx.String(flags.MetricGatewayFlagName))
 	}
+	if ctx.IsSet(flags.MetricsAddrFlagName) {
+		chainmetrics.StartPrometheusServer(ctx.String(flags.MetricsAddrFlagName))
+	}
 
 	startNode(n)
 	key := unlockAccounts(ctx, n)
@@ -0,0 +1,234 @@
+// Copyright 2019 The cpchain authors
+// This file is part of cpchain.
+//
+// cpchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// cpchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with cpchain. If not, see <http://www.gnu.org/licenses/>.
+
+package commons
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"bitbucket.org/cpchain/chain"
+	"bitbucket.org/cpchain/chain/api/rpc"
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/contracts/dpor/primitive_register"
+	"bitbucket.org/cpchain/chain/contracts/dpor/rpt_backend_holder"
+	"bitbucket.org/cpchain/chain/core"
+	"bitbucket.org/cpchain/chain/core/state"
+	"bitbucket.org/cpchain/chain/core/vm"
+	"bitbucket.org/cpchain/chain/internal/cpcapi"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+var (
+	errUnknownBlock = errors.New("unknown block")
+	errReadOnly     = errors.New("local chain client is read only")
+)
+
+// ChainClient reads and calls contracts in the states of a local blockchain
+// without running a node, as a client backend of dpor services.
+type ChainClient struct {
+	chain *core.BlockChain
+}
+
+// NewChainClient creates a client of the local blockchain
+func NewChainClient(chain *core.BlockChain) *ChainClient {
+	return &ChainClient{chain: chain}
+}
+
+// RegisterPrimitiveContracts registers primitive contracts the rpt contract calls,
+// reading the local chain instead of the api backend of a running node.
+func (c *ChainClient) RegisterPrimitiveContracts() {
+	backend := apiBackend{c}
+	apiClient := &rpt_backend_holder.RptApiClient{ChainBackend: backend, ContractBackend: backend}
+	for addr, contract := range primitive_register.MakePrimitiveContracts(c, apiClient) {
+		if err := vm.RegisterPrimitiveContract(addr, contract); err != nil {
+			log.Fatal("register primitive contract error", "error", err, "addr", addr)
+		}
+	}
+}
+
+// HeaderByNumber returns the canonical header of the block number, or the head
+// if it is nil.
+func (c *ChainClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		return c.chain.CurrentHeader(), nil
+	}
+	header := c.chain.GetHeaderByNumber(number.Uint64())
+	if header == nil {
+		return nil, fmt.Errorf("%v: %d", errUnknownBlock, number.Uint64())
+	}
+	return header, nil
+}
+
+// stateAt returns the state and the header of the block number
+func (c *ChainClient) stateAt(ctx context.Context, number *big.Int) (*state.StateDB, *types.Header, error) {
+	header, err := c.HeaderByNumber(ctx, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	statedb, err := c.chain.StateAt(header.StateRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+	return statedb, header, nil
+}
+
+// BalanceAt returns the balance of the account in the state of the block number
+func (c *ChainClient) BalanceAt(ctx context.Context, account common.Address, number *big.Int) (*big.Int, error) {
+	statedb, _, err := c.stateAt(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return statedb.GetBalance(account), statedb.Error()
+}
+
+// NonceAt returns the nonce of the account in the state of the block number
+func (c *ChainClient) NonceAt(ctx context.Context, account common.Address, number *big.Int) (uint64, error) {
+	statedb, _, err := c.stateAt(ctx, number)
+	if err != nil {
+		return 0, err
+	}
+	return statedb.GetNonce(account), statedb.Error()
+}
+
+// CodeAt returns the code of the contract in the state of the block number
+func (c *ChainClient) CodeAt(ctx context.Context, contract common.Address, number *big.Int) ([]byte, error) {
+	statedb, _, err := c.stateAt(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return statedb.GetCode(contract), statedb.Error()
+}
+
+// CallContract executes the call in the state of the block number, the state is
+// not changed.
+func (c *ChainClient) CallContract(ctx context.Context, call cpchain.CallMsg, number *big.Int) ([]byte, error) {
+	statedb, header, err := c.stateAt(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+
+	if call.GasPrice == nil {
+		call.GasPrice = big.NewInt(1)
+	}
+	if call.Gas == 0 {
+		call.Gas = 50000000
+	}
+	if call.Value == nil {
+		call.Value = new(big.Int)
+	}
+	// the caller pays for the call whatever its balance is
+	statedb.GetOrNewStateObject(call.From).SetBalance(math.MaxBig256)
+
+	msg := callMsg{call}
+	evm := vm.NewEVM(core.NewEVMContext(msg, header, c.chain, nil), statedb, c.chain.Config(), vm.Config{})
+	ret, _, _, err := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(math.MaxUint64)).TransitionDb()
+	return ret, err
+}
+
+// PendingCodeAt returns the code of the contract at the head, there is no pending state.
+func (c *ChainClient) PendingCodeAt(ctx context.Context, contract common.Address) ([]byte, error) {
+	return c.CodeAt(ctx, contract, nil)
+}
+
+// PendingCallContract executes the call at the head, there is no pending state.
+func (c *ChainClient) PendingCallContract(ctx context.Context, call cpchain.CallMsg) ([]byte, error) {
+	return c.CallContract(ctx, call, nil)
+}
+
+func (c *ChainClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return c.NonceAt(ctx, account, nil)
+}
+
+func (c *ChainClient) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return nil, errReadOnly
+}
+
+func (c *ChainClient) EstimateGas(ctx context.Context, call cpchain.CallMsg) (uint64, error) {
+	return 0, errReadOnly
+}
+
+func (c *ChainClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	return errReadOnly
+}
+
+func (c *ChainClient) FilterLogs(ctx context.Context, query cpchain.FilterQuery) ([]types.Log, error) {
+	return nil, errReadOnly
+}
+
+func (c *ChainClient) SubscribeFilterLogs(ctx context.Context, query cpchain.FilterQuery, ch chan<- types.Log) (cpchain.Subscription, error) {
+	return nil, errReadOnly
+}
+
+// callMsg implements core.Message to execute a call
+type callMsg struct {
+	cpchain.CallMsg
+}
+
+func (m callMsg) From() common.Address { return m.CallMsg.From }
+func (m callMsg) Nonce() uint64        { return 0 }
+func (m callMsg) CheckNonce() bool     { return false }
+func (m callMsg) To() *common.Address  { return m.CallMsg.To }
+func (m callMsg) GasPrice() *big.Int   { return m.CallMsg.GasPrice }
+func (m callMsg) Gas() uint64          { return m.CallMsg.Gas }
+func (m callMsg) Value() *big.Int      { return m.CallMsg.Value }
+func (m callMsg) Data() []byte         { return m.CallMsg.Data }
+
+// apiBackend serves primitive contracts with the local chain as the api backend
+// of a running node does
+type apiBackend struct {
+	client *ChainClient
+}
+
+// numberOf returns the block number of an rpc one, nil for the latest or pending one
+func numberOf(blockNr rpc.BlockNumber) *big.Int {
+	if blockNr < 0 {
+		return nil
+	}
+	return big.NewInt(blockNr.Int64())
+}
+
+func (b apiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber, isPrivate bool) (*state.StateDB, *types.Header, error) {
+	return b.client.stateAt(ctx, numberOf(blockNr))
+}
+
+func (b apiBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error) {
+	header, err := b.HeaderByNumber(ctx, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	return b.client.chain.GetBlock(header.Hash(), header.Number.Uint64()), nil
+}
+
+func (b apiBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
+	return b.client.HeaderByNumber(ctx, numberOf(blockNr))
+}
+
+func (b apiBackend) Call(ctx context.Context, args cpcapi.CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
+	return b.client.CallContract(ctx, cpchain.CallMsg{
+		From:     args.From,
+		To:       args.To,
+		Gas:      uint64(args.Gas),
+		GasPrice: args.GasPrice.ToInt(),
+		Value:    args.Value.ToInt(),
+		Data:     args.Data,
+	}, numberOf(blockNr))
+}
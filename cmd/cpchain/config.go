@@ -235,6 +235,27 @@ func updateBaseAccount(ctx *cli.Context, ks *keystore.KeyStore, cfg *cpc.Config)
 	}
 }
 
+// Updates the external signer for cfg.Signer
+func updateSigner(ctx *cli.Context, cfg *cpc.Config) {
+	if ctx.IsSet(flags.SignerFlagName) {
+		cfg.Signer = ctx.String(flags.SignerFlagName)
+	}
+}
+
+// Updates the consensus trace file for cfg.ConsensusTrace
+func updateConsensusTrace(ctx *cli.Context, cfg *cpc.Config) {
+	if ctx.IsSet(flags.TraceFlagName) {
+		cfg.ConsensusTrace = ctx.String(flags.TraceFlagName)
+	}
+}
+
+// Updates the bls key file for cfg.BLSKey
+func updateBLSKey(ctx *cli.Context, cfg *cpc.Config) {
+	if ctx.IsSet(flags.BLSKeyFlagName) {
+		cfg.BLSKey = ctx.String(flags.BLSKeyFlagName)
+	}
+}
+
 // Updates transaction pool configurations
 func updateTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.IsSet(flags.MaxTxMapSizeFlagName) {
@@ -275,6 +296,9 @@ func updateChainConfig(ctx *cli.Context, cfg *cpc.Config, n *node.Node) {
 	// passing in a node, all for this.  a pity.
 	ks := n.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
 	updateBaseAccount(ctx, ks, cfg)
+	updateSigner(ctx, cfg)
+	updateConsensusTrace(ctx, cfg)
+	updateBLSKey(ctx, cfg)
 	// setGPO(ctx, &cfg.GPO)
 	updateTxPool(ctx, &cfg.TxPool)
 	updateDatabaseCache(ctx, cfg)
@@ -19,6 +19,7 @@ package flags
 import (
 	"fmt"
 
+	"bitbucket.org/cpchain/chain/commons/chainmetrics/prometheus"
 	"bitbucket.org/cpchain/chain/commons/log"
 	"bitbucket.org/cpchain/chain/node"
 	"github.com/urfave/cli"
@@ -169,6 +170,9 @@ var ChainFlags = []cli.Flag{
 const (
 	MineFlagName      = "mine"
 	ValidatorFlagName = "validator"
+	SignerFlagName    = "signer"
+	TraceFlagName     = "consensus.trace"
+	BLSKeyFlagName    = "consensus.blskey"
 )
 
 var MinerFlags = []cli.Flag{
@@ -180,6 +184,18 @@ var MinerFlags = []cli.Flag{
 		Name:  ValidatorFlagName,
 		Usage: "Enable validator",
 	},
+	cli.StringFlag{
+		Name:  SignerFlagName,
+		Usage: "External signer url (http, ws or ipc path) to sign seals and signatures with, instead of the local keystore",
+	},
+	cli.StringFlag{
+		Name:  TraceFlagName,
+		Usage: "File to record consensus messages and state transitions into, replayable by 'cpchain consensus replay'",
+	},
+	cli.StringFlag{
+		Name:  BLSKeyFlagName,
+		Usage: "File of the bls key to sign with as a validator after the BLS fork, generated by 'cpchain consensus blskey'",
+	},
 }
 
 const (
@@ -270,6 +286,7 @@ const (
 	ProfileFlagName        = "profile"
 	ProfileAddressFlagName = "profileaddr"
 	MetricGatewayFlagName  = "metricgateway"
+	MetricsAddrFlagName    = prometheus.MetricsAddrFlag
 )
 
 var NodeFlags = []cli.Flag{
@@ -290,6 +307,10 @@ var NodeFlags = []cli.Flag{
 		Usage: "Metric Gateway Address",
 		Value: "",
 	},
+	cli.StringFlag{
+		Name:  MetricsAddrFlagName,
+		Usage: "Enable metrics collection and serve them in prometheus format at http://<addr>/metrics, e.g. 127.0.0.1:6060",
+	},
 }
 
 var MiscFlags = []cli.Flag{}
@@ -312,3 +333,66 @@ var LogFlags = []cli.Flag{
 		EnvVar: "CPC_VERBOSITY",
 	},
 }
+
+const (
+	RptAlphaFlagName         = "rpt.alpha"
+	RptBetaFlagName          = "rpt.beta"
+	RptGammaFlagName         = "rpt.gamma"
+	RptPsiFlagName           = "rpt.psi"
+	RptOmegaFlagName         = "rpt.omega"
+	RptWindowFlagName        = "rpt.window"
+	TotalSeatsFlagName       = "seats.total"
+	LowRptSeatsFlagName      = "seats.lowrpt"
+	LowRptPercentageFlagName = "seats.lowrptpct"
+	TermLenFlagName          = "termlen"
+	ElectorFlagName          = "elector"
+)
+
+// SimulateFlags override parameters of simulated elections, values not given
+// are read from the chain
+var SimulateFlags = []cli.Flag{
+	cli.Int64Flag{
+		Name:  RptAlphaFlagName,
+		Usage: "Weight of balances in rpts",
+	},
+	cli.Int64Flag{
+		Name:  RptBetaFlagName,
+		Usage: "Weight of transaction counts in rpts",
+	},
+	cli.Int64Flag{
+		Name:  RptGammaFlagName,
+		Usage: "Weight of blockchain maintenance in rpts, i.e. proposed blocks",
+	},
+	cli.Int64Flag{
+		Name:  RptPsiFlagName,
+		Usage: "Weight of uploaded files in rpts",
+	},
+	cli.Int64Flag{
+		Name:  RptOmegaFlagName,
+		Usage: "Weight of proxy information in rpts",
+	},
+	cli.Int64Flag{
+		Name:  RptWindowFlagName,
+		Usage: "Number of blocks rpts are calculated over",
+	},
+	cli.Int64Flag{
+		Name:  TotalSeatsFlagName,
+		Usage: "Seats of elected proposers in a term, restricted to 8",
+	},
+	cli.Int64Flag{
+		Name:  LowRptSeatsFlagName,
+		Usage: "Seats of low rpt candidates in a term, restricted to 2",
+	},
+	cli.Int64Flag{
+		Name:  LowRptPercentageFlagName,
+		Usage: "Percentage of candidates taken as low rpt ones, restricted to 50",
+	},
+	cli.Uint64Flag{
+		Name:  TermLenFlagName,
+		Usage: "Number of proposers in a term, terms are not compared with ones on chain if changed",
+	},
+	cli.StringFlag{
+		Name:  ElectorFlagName,
+		Usage: "Name of the election algorithm, e.g. elect or elect2",
+	},
+}
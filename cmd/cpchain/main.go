@@ -50,6 +50,8 @@ func newApp() *cli.App {
 		runCommand,
 		dumpConfigCommand,
 		chainCommand,
+		consensusCommand,
+		dporCommand,
 	}
 
 	// global flags
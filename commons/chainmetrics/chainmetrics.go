@@ -2,22 +2,36 @@ package chainmetrics
 
 import (
 	"net"
+	"net/http"
+	"time"
 
+	"bitbucket.org/cpchain/chain/commons/chainmetrics/prometheus"
 	"bitbucket.org/cpchain/chain/commons/log"
-	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ethereum/go-ethereum/metrics"
+	client "github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/push"
 )
 
 var (
-	// gauge items
-	blockNumberCounter = prometheus.NewGauge(prometheus.GaugeOpts{Name: "cpchain_block_number",
+	// gauge items pushed to the gateway
+	blockNumberCounter = client.NewGauge(client.GaugeOpts{Name: "cpchain_block_number",
 		Help: "current blockNumber."})
 
-	txsNumberCounter = prometheus.NewGauge(prometheus.GaugeOpts{Name: "cpchain_txs_number",
+	txsNumberCounter = client.NewGauge(client.GaugeOpts{Name: "cpchain_txs_number",
 		Help: "current txsNumber."})
 
-	insertionElapsedTime = prometheus.NewGauge(prometheus.GaugeOpts{Name: "cpchain_insertion_elapsed_time",
+	insertionElapsedTime = client.NewGauge(client.GaugeOpts{Name: "cpchain_insertion_elapsed_time",
 		Help: "current insertion elapsed time."})
+
+	// the same gauges in the metrics registry, served by the /metrics endpoint
+	blockNumberGauge          = metrics.NewRegisteredGauge("cpchain/block/number", nil)
+	txsNumberGauge            = metrics.NewRegisteredGauge("cpchain/txs/number", nil)
+	insertionElapsedTimeGauge = metrics.NewRegisteredGauge("cpchain/insertion/elapsed/time", nil)
+)
+
+const (
+	// processMetricsRefresh is the interval of collecting system metrics of the process
+	processMetricsRefresh = 3 * time.Second
 )
 
 // configuration items
@@ -36,26 +50,60 @@ func InitMetrics(port, gatewayURL string) {
 	log.Debug("InitMetrics", "chainId", chainId, "gatewayAddress", gatewayAddress)
 }
 
+// StartPrometheusServer serves all metrics of the registry in Prometheus text
+// format at http://addr/metrics, and starts collecting system metrics.
+func StartPrometheusServer(addr string) {
+	if !metrics.Enabled {
+		log.Warn("Metrics collection is disabled, the prometheus endpoint only serves empty metrics")
+	}
+	go metrics.CollectProcessMetrics(processMetricsRefresh)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", prometheus.Handler(metrics.DefaultRegistry))
+
+	log.Info("Starting prometheus metrics server", "addr", "http://"+addr+"/metrics")
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Error("Failure in running prometheus metrics server", "err", err)
+		}
+	}()
+}
+
+// NeedMetrics returns true if the chain metrics are either pushed to the
+// gateway or served by the prometheus endpoint
 func NeedMetrics() bool {
+	return gatewayAddress != "" || metrics.Enabled
+}
+
+func needPush() bool {
 	return gatewayAddress != ""
 }
 
 func ReportBlockNumberGauge(exportedJob string, blockNumber float64) {
-	blockNumberCounter.Set(blockNumber)
-	reportGauge(gatewayAddress, exportedJob, chainId, blockNumberCounter)
+	blockNumberGauge.Update(int64(blockNumber))
+	if needPush() {
+		blockNumberCounter.Set(blockNumber)
+		reportGauge(gatewayAddress, exportedJob, chainId, blockNumberCounter)
+	}
 }
 
 func ReportTxsNumberGauge(exportedJob string, txsNumber float64) {
-	txsNumberCounter.Set(txsNumber)
-	reportGauge(gatewayAddress, exportedJob, chainId, txsNumberCounter)
+	txsNumberGauge.Update(int64(txsNumber))
+	if needPush() {
+		txsNumberCounter.Set(txsNumber)
+		reportGauge(gatewayAddress, exportedJob, chainId, txsNumberCounter)
+	}
 }
 
 func ReportInsertionElapsedTime(exportedJob string, elapsed float64) {
-	insertionElapsedTime.Set(elapsed)
-	reportGauge(gatewayAddress, exportedJob, chainId, insertionElapsedTime)
+	insertionElapsedTimeGauge.Update(int64(elapsed))
+	if needPush() {
+		insertionElapsedTime.Set(elapsed)
+		reportGauge(gatewayAddress, exportedJob, chainId, insertionElapsedTime)
+	}
 }
 
-func reportGauge(monitorURL, exportedJob, host string, gauge prometheus.Gauge) {
+func reportGauge(monitorURL, exportedJob, host string, gauge client.Gauge) {
 	if err := push.New(monitorURL, exportedJob).
 		Collector(gauge).
 		Grouping("host", host).
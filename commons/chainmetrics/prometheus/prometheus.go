@@ -0,0 +1,122 @@
+// Package prometheus exposes the go-ethereum metrics registry in Prometheus
+// text exposition format.
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// MetricsAddrFlag is the CLI flag name of the Prometheus endpoint address.
+const MetricsAddrFlag = "metrics.addr"
+
+var (
+	// quantiles of histograms and timers, in the format of the registry
+	quantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
+
+	// quantiles of resetting timers, which are expressed in percents
+	resettingQuantiles = []float64{50, 95, 99}
+)
+
+// Handler returns an HTTP handler which writes all metrics of the registry
+// in Prometheus text format.
+func Handler(reg metrics.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write(Gather(reg))
+	})
+}
+
+// Gather collects all metrics of the registry in Prometheus text format,
+// metrics are sorted by name.
+func Gather(reg metrics.Registry) []byte {
+	var names []string
+	reg.Each(func(name string, _ interface{}) {
+		names = append(names, name)
+	})
+	sort.Strings(names)
+
+	c := new(collector)
+	for _, name := range names {
+		switch m := reg.Get(name).(type) {
+		case metrics.Counter:
+			c.addCounter(name, m.Count())
+		case metrics.Gauge:
+			c.addGauge(name, float64(m.Value()))
+		case metrics.GaugeFloat64:
+			c.addGauge(name, m.Value())
+		case metrics.Meter:
+			c.addCounter(name, m.Count())
+		case metrics.Histogram:
+			snapshot := m.Snapshot()
+			c.addSummary(name, quantiles, snapshot.Percentiles(quantiles), snapshot.Count(), float64(snapshot.Sum()))
+		case metrics.Timer:
+			snapshot := m.Snapshot()
+			c.addSummary(name, quantiles, snapshot.Percentiles(quantiles), snapshot.Count(), float64(snapshot.Sum()))
+		case metrics.ResettingTimer:
+			snapshot := m.Snapshot()
+			values := snapshot.Values()
+			if len(values) == 0 {
+				continue
+			}
+
+			var sum int64
+			for _, v := range values {
+				sum += v
+			}
+
+			percentiles := snapshot.Percentiles(resettingQuantiles)
+			scaled := make([]float64, len(percentiles))
+			for i, p := range percentiles {
+				scaled[i] = float64(p)
+			}
+			qs := make([]float64, len(resettingQuantiles))
+			for i, q := range resettingQuantiles {
+				qs[i] = q / 100
+			}
+			c.addSummary(name, qs, scaled, int64(len(values)), float64(sum))
+		}
+	}
+	return c.buf.Bytes()
+}
+
+// collector writes metrics in Prometheus text format
+type collector struct {
+	buf bytes.Buffer
+}
+
+func (c *collector) addCounter(name string, value int64) {
+	name = mangle(name)
+	fmt.Fprintf(&c.buf, "# TYPE %s counter\n%s %d\n", name, name, value)
+}
+
+func (c *collector) addGauge(name string, value float64) {
+	name = mangle(name)
+	fmt.Fprintf(&c.buf, "# TYPE %s gauge\n%s %v\n", name, name, value)
+}
+
+func (c *collector) addSummary(name string, qs []float64, values []float64, count int64, sum float64) {
+	name = mangle(name)
+	fmt.Fprintf(&c.buf, "# TYPE %s summary\n", name)
+	for i, q := range qs {
+		fmt.Fprintf(&c.buf, "%s{quantile=\"%v\"} %v\n", name, q, values[i])
+	}
+	fmt.Fprintf(&c.buf, "%s_sum %v\n%s_count %d\n", name, sum, name, count)
+}
+
+// mangle converts a registry name like "p2p/InboundTraffic" to a valid
+// Prometheus metric name like "p2p_InboundTraffic"
+func mangle(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == ':':
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,53 @@
+package prometheus
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+func TestGather(t *testing.T) {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	defer func() { metrics.Enabled = enabled }()
+
+	reg := metrics.NewRegistry()
+	metrics.NewRegisteredCounter("txpool/invalid", reg).Inc(3)
+	metrics.NewRegisteredGauge("chain/head-block", reg).Update(42)
+	metrics.NewRegisteredMeter("p2p/InboundTraffic", reg).Mark(7)
+	metrics.NewRegisteredTimer("chain/inserts", reg).Update(2 * time.Millisecond)
+
+	want := []string{
+		"# TYPE chain_head_block gauge\nchain_head_block 42\n",
+		"# TYPE chain_inserts summary\n",
+		"chain_inserts{quantile=\"0.5\"} 2e+06\n",
+		"chain_inserts_count 1\n",
+		"# TYPE p2p_InboundTraffic counter\np2p_InboundTraffic 7\n",
+		"# TYPE txpool_invalid counter\ntxpool_invalid 3\n",
+	}
+
+	out := string(Gather(reg))
+	last := -1
+	for _, w := range want {
+		idx := strings.Index(out, w)
+		if idx < 0 {
+			t.Fatalf("missing %q in output:\n%s", w, out)
+		}
+		if idx < last {
+			t.Errorf("metrics are not sorted by name:\n%s", out)
+		}
+		last = idx
+	}
+
+	rec := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("content type mismatch: have %s", ct)
+	}
+	if rec.Body.String() != out {
+		t.Errorf("handler output mismatch:\n%s", rec.Body.String())
+	}
+}
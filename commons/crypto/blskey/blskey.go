@@ -0,0 +1,272 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package blskey implements BLS signatures over the bn256 curve.
+//
+// Public keys are in G2 and signatures are in G1, so that signatures are short.
+// Signatures of the same msg by different keys aggregate into one signature,
+// verified against the sum of the public keys with a single pairing check.
+//
+// Aggregating public keys is only safe with keys whose possession is proven,
+// otherwise one can register a rogue key cancelling others' keys out.
+package blskey
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+)
+
+const (
+	// SecretKeyLength is the length of an encoded secret key
+	SecretKeyLength = 32
+
+	// PublicKeyLength is the length of an encoded public key
+	PublicKeyLength = 128
+
+	// SignatureLength is the length of an encoded signature
+	SignatureLength = 64
+)
+
+var (
+	// domains separating hashes of msgs to sign from proofs of possession
+	signDomain       = []byte("cpchain-bls-sign")
+	possessionDomain = []byte("cpchain-bls-pop")
+)
+
+var (
+	errInvalidSecretKey = errors.New("invalid bls secret key")
+	errInvalidPublicKey = errors.New("invalid bls public key")
+	errInvalidSignature = errors.New("invalid bls signature")
+)
+
+var (
+	g2Generator = new(bn256.G2).ScalarBaseMult(big.NewInt(1))
+
+	// sqrtExponent is (P+1)/4, P is 3 mod 4 so x^sqrtExponent is a square root of x if any
+	sqrtExponent = new(big.Int).Rsh(new(big.Int).Add(bn256.P, big.NewInt(1)), 2)
+)
+
+// SecretKey is a bls secret key
+type SecretKey struct {
+	k *big.Int
+}
+
+// PublicKey is a bls public key
+type PublicKey struct {
+	p *bn256.G2
+}
+
+// Signature is a bls signature, or an aggregate of signatures
+type Signature struct {
+	p *bn256.G1
+}
+
+// GenerateKey generates a secret key with randomness from r, crypto/rand if r is nil
+func GenerateKey(r io.Reader) (*SecretKey, error) {
+	if r == nil {
+		r = rand.Reader
+	}
+	for {
+		k, err := rand.Int(r, bn256.Order)
+		if err != nil {
+			return nil, err
+		}
+		if k.Sign() > 0 {
+			return &SecretKey{k: k}, nil
+		}
+	}
+}
+
+// SecretKeyFromBytes decodes a secret key
+func SecretKeyFromBytes(b []byte) (*SecretKey, error) {
+	if len(b) != SecretKeyLength {
+		return nil, errInvalidSecretKey
+	}
+	k := new(big.Int).SetBytes(b)
+	if k.Sign() == 0 || k.Cmp(bn256.Order) >= 0 {
+		return nil, errInvalidSecretKey
+	}
+	return &SecretKey{k: k}, nil
+}
+
+// Bytes encodes the secret key
+func (sk *SecretKey) Bytes() []byte {
+	b := make([]byte, SecretKeyLength)
+	kb := sk.k.Bytes()
+	copy(b[SecretKeyLength-len(kb):], kb)
+	return b
+}
+
+// LoadSecretKey loads a hex encoded secret key from a file
+func LoadSecretKey(file string) (*SecretKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	b, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, errInvalidSecretKey
+	}
+	return SecretKeyFromBytes(b)
+}
+
+// SaveSecretKey saves a secret key to a file hex encoded, readable only by the owner
+func SaveSecretKey(file string, sk *SecretKey) error {
+	return ioutil.WriteFile(file, []byte(hex.EncodeToString(sk.Bytes())), 0600)
+}
+
+// PublicKey returns the public key of the secret key
+func (sk *SecretKey) PublicKey() *PublicKey {
+	return &PublicKey{p: new(bn256.G2).ScalarBaseMult(sk.k)}
+}
+
+// Sign signs a msg
+func (sk *SecretKey) Sign(msg []byte) *Signature {
+	return &Signature{p: new(bn256.G1).ScalarMult(hashToG1(signDomain, msg), sk.k)}
+}
+
+// ProvePossession returns a proof of possession of the secret key, which is to
+// be registered along with the public key
+func (sk *SecretKey) ProvePossession() *Signature {
+	return &Signature{p: new(bn256.G1).ScalarMult(hashToG1(possessionDomain, sk.PublicKey().Bytes()), sk.k)}
+}
+
+// PublicKeyFromBytes decodes a public key, it must not be the identity
+func PublicKeyFromBytes(b []byte) (*PublicKey, error) {
+	if len(b) != PublicKeyLength {
+		return nil, errInvalidPublicKey
+	}
+	p := new(bn256.G2)
+	if _, err := p.Unmarshal(b); err != nil {
+		return nil, errInvalidPublicKey
+	}
+	if isZero(p.Marshal()) || !isZero(new(bn256.G2).ScalarMult(p, bn256.Order).Marshal()) {
+		return nil, errInvalidPublicKey
+	}
+	return &PublicKey{p: p}, nil
+}
+
+// Bytes encodes the public key
+func (pk *PublicKey) Bytes() []byte {
+	return pk.p.Marshal()
+}
+
+// Verify returns if the signature is the signature of the msg by the public key
+func (pk *PublicKey) Verify(msg []byte, sig *Signature) bool {
+	return verify(pk, hashToG1(signDomain, msg), sig)
+}
+
+// VerifyPossession returns if the proof proves possession of the public key's secret key
+func (pk *PublicKey) VerifyPossession(proof *Signature) bool {
+	return verify(pk, hashToG1(possessionDomain, pk.Bytes()), proof)
+}
+
+// SignatureFromBytes decodes a signature, it must not be the identity
+func SignatureFromBytes(b []byte) (*Signature, error) {
+	if len(b) != SignatureLength || isZero(b) {
+		return nil, errInvalidSignature
+	}
+	p := new(bn256.G1)
+	if _, err := p.Unmarshal(b); err != nil {
+		return nil, errInvalidSignature
+	}
+	return &Signature{p: p}, nil
+}
+
+// Bytes encodes the signature
+func (sig *Signature) Bytes() []byte {
+	return sig.p.Marshal()
+}
+
+// AggregateSignatures aggregates signatures into one
+func AggregateSignatures(sigs []*Signature) *Signature {
+	p := new(bn256.G1).ScalarBaseMult(new(big.Int))
+	for _, sig := range sigs {
+		p.Add(p, sig.p)
+	}
+	return &Signature{p: p}
+}
+
+// AggregatePublicKeys aggregates public keys into one, an aggregate signature of
+// a msg is verified against it
+func AggregatePublicKeys(pks []*PublicKey) *PublicKey {
+	p := new(bn256.G2).ScalarBaseMult(new(big.Int))
+	for _, pk := range pks {
+		p.Add(p, pk.p)
+	}
+	return &PublicKey{p: p}
+}
+
+// verify checks e(sig, g2) == e(h, pk) with a single pairing check, the
+// identity is never a valid signature or key
+func verify(pk *PublicKey, h *bn256.G1, sig *Signature) bool {
+	if isZero(sig.p.Marshal()) || isZero(pk.p.Marshal()) {
+		return false
+	}
+	return bn256.PairingCheck([]*bn256.G1{sig.p, new(bn256.G1).Neg(h)}, []*bn256.G2{g2Generator, pk.p})
+}
+
+// hashToG1 hashes a msg to a point of G1 by try-and-increment, the cofactor of
+// G1 is 1 so that any point on the curve is in G1
+func hashToG1(domain, msg []byte) *bn256.G1 {
+	var (
+		three = big.NewInt(3)
+		x     = new(big.Int)
+		rhs   = new(big.Int)
+		y     = new(big.Int)
+	)
+	for counter := 0; ; counter++ {
+		x.SetBytes(crypto.Keccak256(domain, []byte{byte(counter >> 8), byte(counter)}, msg))
+		x.Mod(x, bn256.P)
+
+		// y^2 = x^3 + 3
+		rhs.Exp(x, three, bn256.P)
+		rhs.Add(rhs, three)
+		rhs.Mod(rhs, bn256.P)
+
+		y.Exp(rhs, sqrtExponent, bn256.P)
+		if new(big.Int).Exp(y, big.NewInt(2), bn256.P).Cmp(rhs) != 0 {
+			continue
+		}
+
+		b := make([]byte, 64)
+		xb, yb := x.Bytes(), y.Bytes()
+		copy(b[32-len(xb):32], xb)
+		copy(b[64-len(yb):], yb)
+
+		p := new(bn256.G1)
+		if _, err := p.Unmarshal(b); err == nil && !isZero(b) {
+			return p
+		}
+	}
+}
+
+func isZero(b []byte) bool {
+	for _, x := range b {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
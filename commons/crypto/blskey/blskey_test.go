@@ -0,0 +1,135 @@
+package blskey
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestKeys(t *testing.T, n int) []*SecretKey {
+	keys := make([]*SecretKey, n)
+	for i := range keys {
+		key, err := GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func TestSignVerify(t *testing.T) {
+	keys := newTestKeys(t, 2)
+	msg := []byte("block hash")
+
+	sig := keys[0].Sign(msg)
+	if !keys[0].PublicKey().Verify(msg, sig) {
+		t.Fatal("failed to verify signature")
+	}
+	if keys[1].PublicKey().Verify(msg, sig) {
+		t.Error("signature is verified with another key")
+	}
+	if keys[0].PublicKey().Verify([]byte("another msg"), sig) {
+		t.Error("signature is verified with another msg")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	keys := newTestKeys(t, 4)
+	msg := []byte("block hash")
+
+	var (
+		sigs []*Signature
+		pks  []*PublicKey
+	)
+	for _, key := range keys[:3] {
+		sigs = append(sigs, key.Sign(msg))
+		pks = append(pks, key.PublicKey())
+	}
+
+	agg := AggregateSignatures(sigs)
+	if !AggregatePublicKeys(pks).Verify(msg, agg) {
+		t.Fatal("failed to verify aggregate signature")
+	}
+	if AggregatePublicKeys(append(pks, keys[3].PublicKey())).Verify(msg, agg) {
+		t.Error("aggregate signature is verified with a key not signing")
+	}
+	if AggregatePublicKeys(pks[:2]).Verify(msg, agg) {
+		t.Error("aggregate signature is verified without a key signing")
+	}
+	if AggregatePublicKeys(nil).Verify(msg, AggregateSignatures(nil)) {
+		t.Error("identity signature is verified")
+	}
+}
+
+func TestPossession(t *testing.T) {
+	keys := newTestKeys(t, 2)
+
+	proof := keys[0].ProvePossession()
+	if !keys[0].PublicKey().VerifyPossession(proof) {
+		t.Fatal("failed to verify proof of possession")
+	}
+	if keys[1].PublicKey().VerifyPossession(proof) {
+		t.Error("proof of possession is verified with another key")
+	}
+
+	// a proof of possession is not a signature of the public key
+	if keys[0].PublicKey().Verify(keys[0].PublicKey().Bytes(), proof) {
+		t.Error("proof of possession is verified as a signature")
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	key := newTestKeys(t, 1)[0]
+	msg := []byte("block hash")
+
+	decodedKey, err := SecretKeyFromBytes(key.Bytes())
+	if err != nil || !bytes.Equal(decodedKey.Bytes(), key.Bytes()) {
+		t.Fatalf("failed to decode secret key: %v", err)
+	}
+
+	pk, err := PublicKeyFromBytes(key.PublicKey().Bytes())
+	if err != nil {
+		t.Fatalf("failed to decode public key: %v", err)
+	}
+	sig, err := SignatureFromBytes(key.Sign(msg).Bytes())
+	if err != nil {
+		t.Fatalf("failed to decode signature: %v", err)
+	}
+	if !pk.Verify(msg, sig) {
+		t.Error("failed to verify decoded signature")
+	}
+
+	if _, err := SignatureFromBytes(make([]byte, SignatureLength)); err == nil {
+		t.Error("identity signature is decoded")
+	}
+	if _, err := PublicKeyFromBytes(make([]byte, PublicKeyLength)); err == nil {
+		t.Error("identity public key is decoded")
+	}
+	if _, err := SecretKeyFromBytes(make([]byte, SecretKeyLength)); err == nil {
+		t.Error("zero secret key is decoded")
+	}
+}
+
+func TestSaveLoadSecretKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blskey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := newTestKeys(t, 1)[0]
+	file := filepath.Join(dir, "blskey")
+	if err := SaveSecretKey(file, key); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSecretKey(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(loaded.Bytes(), key.Bytes()) {
+		t.Error("loaded secret key mismatch")
+	}
+}
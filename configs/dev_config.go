@@ -51,6 +51,7 @@ var (
 			ProxyContractRegister: devProxyContractRegister,
 			Contracts:             devContractAddressMap,
 			ImpeachTimeout:        time.Millisecond * DefaultBlockPeriod * 10,
+			Forks:                 DefaultDporForks(),
 		},
 	}
 
@@ -4,14 +4,17 @@
 package configs
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
 	"net"
+	"sort"
 	"strings"
 	"time"
 
 	"bitbucket.org/cpchain/chain/commons/log"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
 var Version string
@@ -80,6 +83,13 @@ const (
 	DefaultFailbackTimestampSampleSpace = 2 * time.Minute
 )
 
+// Default policy of adaptive impeach timeouts
+const (
+	DefaultImpeachBackoffMultiplier  = 2  // the impeach timeout is doubled after each consecutive impeachment
+	DefaultImpeachBackoffMaxFactor   = 8  // the impeach timeout grows up to 8 times of the configured one
+	DefaultImpeachBackoffDecayBlocks = 12 // the impeach timeout is halved after every 12 healthy blocks
+)
+
 // DefaultFullSyncPivot is a number that full sync is triggered from it. (head - DefaultFullSyncPivot)
 const (
 	DefaultFullSyncPivot = 1024
@@ -117,7 +127,8 @@ const (
 	CampaignVersion = 1
 )
 
-// pivot blocks numbers
+// pivot blocks numbers, every network used them before the fork schedule became
+// a part of DporConfig, see DefaultDporForks.
 const (
 	RptCalcMethod2BlockNumber = 343000
 	RptCalcMethod3BlockNumber = 372400
@@ -132,6 +143,33 @@ const (
 	Election2BlockNumber = 454700
 )
 
+// names of the proposer election algorithms registered in consensus/dpor/election
+const (
+	ElectorElect  = "elect"  // proposers are elected with election.Elect
+	ElectorElect2 = "elect2" // proposers are elected with election.Elect2 and rpt seats
+)
+
+// defaultDporForks is the fork schedule of networks not configuring one, it is read only.
+var defaultDporForks = DefaultDporForks()
+
+// DefaultDporForks returns a copy of the fork schedule of networks not configuring
+// one, which is the one every network used before the schedule became configurable.
+// Pinned rpt, BLS, election seed and adaptive impeach are never activated by it, a
+// network schedules them explicitly.
+func DefaultDporForks() *DporForks {
+	return &DporForks{
+		RptCalcMethod2Block: big.NewInt(RptCalcMethod2BlockNumber),
+		RptCalcMethod3Block: big.NewInt(RptCalcMethod3BlockNumber),
+		RptCalcMethod4Block: big.NewInt(RptCalcMethod4BlockNumber),
+		RptCalcMethod5Block: big.NewInt(RptCalcMethod5BlockNumber),
+		RptCalcMethod6Block: big.NewInt(RptCalcMethod6BlockNumber),
+		Campaign2Block:      big.NewInt(Campaign2BlockNumber),
+		Campaign3Block:      big.NewInt(Campaign3BlockNumber),
+		Campaign4Block:      big.NewInt(Campaign4BlockNumber),
+		Election2Block:      big.NewInt(Election2BlockNumber),
+	}
+}
+
 var (
 	chainConfigMap = map[RunMode]*ChainConfig{
 		Dev:      devChainConfig,
@@ -330,6 +368,98 @@ type DporConfig struct {
 	Contracts             map[string]common.Address `json:"contracts"             toml:"contracts"`
 	ProxyContractRegister common.Address            `json:"proxyContractRegister" toml:"proxyContractRegister"`
 	ImpeachTimeout        time.Duration             `json:"impeachTimeout" toml:"impeachTimeout"`
+
+	// ImpeachBackoff is the policy of adaptive impeach timeouts after the AdaptiveImpeach fork,
+	// nil means the default policy. It is a consensus rule and must not change once the fork is passed.
+	ImpeachBackoff *ImpeachBackoff `json:"impeachBackoff,omitempty" toml:"impeachBackoff,omitempty"`
+
+	// BLSKeys are bls public keys registered in the genesis by validators to sign with after
+	// the BLS fork, and by proposers to contribute election seeds with after the ElectionSeed
+	// fork. They must not change once either fork is passed.
+	BLSKeys []*BLSKey `json:"blsKeys,omitempty" toml:"blsKeys,omitempty"`
+
+	// Forks is the hard fork schedule of this network, nil means DefaultDporForks
+	Forks *DporForks `json:"forks,omitempty" toml:"forks,omitempty"`
+}
+
+// DporForks is the hard fork schedule of dpor consensus.
+//
+// Each field is the block number at which the corresponding change takes effect,
+// nil means the change is never activated.
+type DporForks struct {
+	RptCalcMethod2Block *big.Int `json:"rptCalcMethod2Block,omitempty" toml:"rptCalcMethod2Block,omitempty"`
+	RptCalcMethod3Block *big.Int `json:"rptCalcMethod3Block,omitempty" toml:"rptCalcMethod3Block,omitempty"`
+	RptCalcMethod4Block *big.Int `json:"rptCalcMethod4Block,omitempty" toml:"rptCalcMethod4Block,omitempty"`
+	RptCalcMethod5Block *big.Int `json:"rptCalcMethod5Block,omitempty" toml:"rptCalcMethod5Block,omitempty"`
+	RptCalcMethod6Block *big.Int `json:"rptCalcMethod6Block,omitempty" toml:"rptCalcMethod6Block,omitempty"`
+
+	Campaign2Block *big.Int `json:"campaign2Block,omitempty" toml:"campaign2Block,omitempty"` // candidates of terms after this block are read from campaign2
+	Campaign3Block *big.Int `json:"campaign3Block,omitempty" toml:"campaign3Block,omitempty"` // candidates of terms after this block are read from campaign3
+	Campaign4Block *big.Int `json:"campaign4Block,omitempty" toml:"campaign4Block,omitempty"` // candidates of terms after this block are read from campaign4
+
+	Election2Block *big.Int `json:"election2Block,omitempty" toml:"election2Block,omitempty"` // proposers are elected with ElectorElect2 from this block
+
+	PinnedRptBlock *big.Int `json:"pinnedRptBlock,omitempty" toml:"pinnedRptBlock,omitempty"` // rpt values are read in the state at the election block, elections fail without them from this block
+
+	ElectionSeedBlock *big.Int `json:"electionSeedBlock,omitempty" toml:"electionSeedBlock,omitempty"` // headers carry an election seed contributed by proposers with bls keys from this block
+
+	AdaptiveImpeachBlock *big.Int `json:"adaptiveImpeachBlock,omitempty" toml:"adaptiveImpeachBlock,omitempty"` // impeach timeouts back off after impeachments from this block
+
+	BLSBlock *big.Int `json:"blsBlock,omitempty" toml:"blsBlock,omitempty"` // validators sign with bls keys and headers carry aggregate signatures from this block
+
+	PrivatePayloadV1Block *big.Int `json:"privatePayloadV1Block,omitempty" toml:"privatePayloadV1Block,omitempty"` // private txs must carry version 1 sealed payloads from this block
+
+	// Electors is the schedule of election algorithms, Election2Block is used to
+	// build it if empty
+	Electors []*ElectorFork `json:"electors,omitempty" toml:"electors,omitempty"`
+
+	// RptFormulas is the schedule of rpt formulas, RptCalcMethod2Block to
+	// RptCalcMethod6Block are used to build it if empty
+	RptFormulas []*RptFormulaFork `json:"rptFormulas,omitempty" toml:"rptFormulas,omitempty"`
+}
+
+// ElectorFork activates an election algorithm of proposers at a block.
+type ElectorFork struct {
+	Block *big.Int `json:"block" toml:"block"` // first block number electing with it
+	Name  string   `json:"name"  toml:"name"`  // name of the registered elector
+}
+
+// RptFormulaFork activates a formula of rpt calculation at a block.
+type RptFormulaFork struct {
+	Block   *big.Int `json:"block"   toml:"block"`   // first block number calculating rpts with it
+	Version int      `json:"version" toml:"version"` // version of the registered rpt formula, 1 reads rpts from the rpt contract
+}
+
+// ImpeachBackoff is the policy of adaptive impeach timeouts.
+//
+// The impeach timeout of a height is multiplied by Multiplier after each consecutive
+// impeach block, up to MaxTimeout, and divided by it after every DecayBlocks healthy
+// blocks, down to ImpeachTimeout.
+type ImpeachBackoff struct {
+	Multiplier  uint64        `json:"multiplier"  toml:"multiplier"`
+	MaxTimeout  time.Duration `json:"maxTimeout"  toml:"maxTimeout"` // zero means DefaultImpeachBackoffMaxFactor times of ImpeachTimeout
+	DecayBlocks uint64        `json:"decayBlocks" toml:"decayBlocks"`
+}
+
+// BLSKey is the bls public key of a validator or a proposer, with a proof of possession of its
+// secret key so that keys can be aggregated safely.
+type BLSKey struct {
+	Address    common.Address `json:"address"    toml:"address"`
+	PublicKey  hexutil.Bytes  `json:"publicKey"  toml:"publicKey"`
+	Possession hexutil.Bytes  `json:"possession" toml:"possession"`
+}
+
+// blsKeysEqual returns if two lists of registered bls keys are the same
+func blsKeysEqual(a, b []*BLSKey) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Address != b[i].Address || !bytes.Equal(a[i].PublicKey, b[i].PublicKey) || !bytes.Equal(a[i].Possession, b[i].Possession) {
+			return false
+		}
+	}
+	return true
 }
 
 // String implements the stringer interface, returning the consensus engine details.
@@ -372,6 +502,216 @@ func (c *DporConfig) BlockDelay() time.Duration {
 	return time.Duration(0)
 }
 
+// forks returns the fork schedule of the config, falling back to the default one.
+func (c *DporConfig) forks() *DporForks {
+	if c == nil || c.Forks == nil {
+		return defaultDporForks
+	}
+	return c.Forks
+}
+
+// TermOf returns the term of a given block number.
+func (c *DporConfig) TermOf(number uint64) uint64 {
+	if number == 0 || c.TermLen*c.ViewLen == 0 {
+		return 0
+	}
+	return (number - 1) / (c.TermLen * c.ViewLen)
+}
+
+// RptCalcMethod returns the version of rpt calculation method used at the given block number.
+func (c *DporConfig) RptCalcMethod(number uint64) int {
+	// the last forked one wins, the legacy schedule is in order of versions
+	version := 1
+	for _, f := range c.rptFormulas() {
+		if isForked(f.Block, number) {
+			version = f.Version
+		}
+	}
+	return version
+}
+
+// RptFormulaForks returns the schedule of rpt formulas in effect, sorted by activation block.
+func (c *DporConfig) RptFormulaForks() []*RptFormulaFork {
+	return c.rptFormulas()
+}
+
+// rptFormulas returns the schedule of rpt formulas. The configured one is sorted by
+// activation block, the legacy one built from RptCalcMethodNBlock is in order of versions.
+func (c *DporConfig) rptFormulas() []*RptFormulaFork {
+	forks := c.forks()
+	if len(forks.RptFormulas) == 0 {
+		formulas := []*RptFormulaFork{{Block: big.NewInt(0), Version: 1}}
+		for i, block := range []*big.Int{
+			forks.RptCalcMethod2Block,
+			forks.RptCalcMethod3Block,
+			forks.RptCalcMethod4Block,
+			forks.RptCalcMethod5Block,
+			forks.RptCalcMethod6Block,
+		} {
+			if block != nil {
+				formulas = append(formulas, &RptFormulaFork{Block: block, Version: i + 2})
+			}
+		}
+		return formulas
+	}
+
+	formulas := make([]*RptFormulaFork, 0, len(forks.RptFormulas))
+	for _, f := range forks.RptFormulas {
+		if f != nil && f.Block != nil {
+			formulas = append(formulas, f)
+		}
+	}
+	sort.SliceStable(formulas, func(i, j int) bool {
+		return formulas[i].Block.Cmp(formulas[j].Block) < 0
+	})
+	return formulas
+}
+
+// CampaignVersionOf returns the version of campaign contract which provides candidates of the given term.
+func (c *DporConfig) CampaignVersionOf(term uint64) int {
+	forks := c.forks()
+	switch {
+	case c.isForkedAtTerm(forks.Campaign4Block, term):
+		return 4
+	case c.isForkedAtTerm(forks.Campaign3Block, term):
+		return 3
+	case c.isForkedAtTerm(forks.Campaign2Block, term):
+		return 2
+	}
+	return 1
+}
+
+// IsElection2 returns whether proposers elected at the given block number use the second election method.
+func (c *DporConfig) IsElection2(number uint64) bool {
+	return isForked(c.forks().Election2Block, number)
+}
+
+// electors returns the schedule of election algorithms, sorted by activation block.
+func (c *DporConfig) electors() []*ElectorFork {
+	forks := c.forks()
+	if len(forks.Electors) == 0 {
+		electors := []*ElectorFork{{Block: big.NewInt(0), Name: ElectorElect}}
+		if forks.Election2Block != nil {
+			electors = append(electors, &ElectorFork{Block: forks.Election2Block, Name: ElectorElect2})
+		}
+		return electors
+	}
+
+	electors := make([]*ElectorFork, 0, len(forks.Electors))
+	for _, e := range forks.Electors {
+		if e != nil && e.Block != nil {
+			electors = append(electors, e)
+		}
+	}
+	sort.SliceStable(electors, func(i, j int) bool {
+		return electors[i].Block.Cmp(electors[j].Block) < 0
+	})
+	return electors
+}
+
+// ElectorOf returns the name of the election algorithm electing proposers at the given block number.
+func (c *DporConfig) ElectorOf(number uint64) string {
+	name := ElectorElect
+	for _, e := range c.electors() {
+		if !isForked(e.Block, number) {
+			break
+		}
+		name = e.Name
+	}
+	return name
+}
+
+// IsPinnedRpt returns whether rpt parameters, seats and candidates of the given block number
+// are read in the state at it, and elections fail if they can not be read. Before the fork
+// they are read in the latest state, and default ones are used if they can not be read.
+func (c *DporConfig) IsPinnedRpt(number uint64) bool {
+	return isForked(c.forks().PinnedRptBlock, number)
+}
+
+// IsElectionSeed returns whether the header of the given block number carries an election seed,
+// which proposers are elected with instead of the block hash.
+func (c *DporConfig) IsElectionSeed(number uint64) bool {
+	return isForked(c.forks().ElectionSeedBlock, number)
+}
+
+// IsAdaptiveImpeach returns whether the impeach timeout of the given block number
+// backs off after impeachments.
+func (c *DporConfig) IsAdaptiveImpeach(number uint64) bool {
+	return isForked(c.forks().AdaptiveImpeachBlock, number)
+}
+
+// IsBLS returns whether validators of the given block number sign with bls keys,
+// and their signatures are aggregated in the header.
+func (c *DporConfig) IsBLS(number uint64) bool {
+	return isForked(c.forks().BLSBlock, number)
+}
+
+// IsPrivatePayloadV1 returns whether private transactions of the given block number must
+// carry sealed payloads of version 1, legacy payloads are only accepted before the fork.
+func (c *DporConfig) IsPrivatePayloadV1(number uint64) bool {
+	return isForked(c.forks().PrivatePayloadV1Block, number)
+}
+
+// impeachBackoff returns the policy of adaptive impeach timeouts with defaults filled.
+func (c *DporConfig) impeachBackoff() ImpeachBackoff {
+	policy := ImpeachBackoff{
+		Multiplier:  DefaultImpeachBackoffMultiplier,
+		DecayBlocks: DefaultImpeachBackoffDecayBlocks,
+	}
+	if c.ImpeachBackoff != nil {
+		policy = *c.ImpeachBackoff
+	}
+	if policy.Multiplier < 2 {
+		policy.Multiplier = DefaultImpeachBackoffMultiplier
+	}
+	if policy.DecayBlocks == 0 {
+		policy.DecayBlocks = DefaultImpeachBackoffDecayBlocks
+	}
+	if policy.MaxTimeout < c.ImpeachTimeout {
+		policy.MaxTimeout = c.ImpeachTimeout * DefaultImpeachBackoffMaxFactor
+	}
+	return policy
+}
+
+// ImpeachBackoffLevels returns the max back-off level of impeach timeouts and the
+// number of healthy blocks to decay one level.
+func (c *DporConfig) ImpeachBackoffLevels() (maxLevel uint64, decayBlocks uint64) {
+	policy := c.impeachBackoff()
+	for timeout := c.ImpeachTimeout; timeout > 0 && timeout < policy.MaxTimeout; timeout *= time.Duration(policy.Multiplier) {
+		maxLevel++
+	}
+	return maxLevel, policy.DecayBlocks
+}
+
+// ImpeachTimeoutOf returns the impeach timeout at the given back-off level.
+func (c *DporConfig) ImpeachTimeoutOf(level uint64) time.Duration {
+	policy := c.impeachBackoff()
+	timeout := c.ImpeachTimeout
+	for i := uint64(0); i < level && timeout < policy.MaxTimeout; i++ {
+		timeout *= time.Duration(policy.Multiplier)
+	}
+	if timeout > policy.MaxTimeout {
+		timeout = policy.MaxTimeout
+	}
+	return timeout
+}
+
+// isForkedAtTerm returns whether a fork scheduled at block s is active in the given term.
+func (c *DporConfig) isForkedAtTerm(s *big.Int, term uint64) bool {
+	if s == nil {
+		return false
+	}
+	return term >= c.TermOf(s.Uint64())
+}
+
+// isForked returns whether a fork scheduled at block s is active at the given head block.
+func isForked(s *big.Int, head uint64) bool {
+	if s == nil {
+		return false
+	}
+	return s.Cmp(new(big.Int).SetUint64(head)) <= 0
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var engine interface{}
@@ -417,10 +757,121 @@ type ConfigCompatError struct {
 	RewindTo uint64
 }
 
+func newCompatError(what string, storedblock, newblock *big.Int) *ConfigCompatError {
+	var rew *big.Int
+	switch {
+	case storedblock == nil:
+		rew = newblock
+	case newblock == nil || storedblock.Cmp(newblock) < 0:
+		rew = storedblock
+	default:
+		rew = newblock
+	}
+	err := &ConfigCompatError{what, storedblock, newblock, 0}
+	if rew != nil && rew.Sign() > 0 {
+		err.RewindTo = rew.Uint64() - 1
+	}
+	return err
+}
+
 func (err *ConfigCompatError) Error() string {
 	return fmt.Sprintf("mismatching %s in database (have %d, want %d, rewindto %d)", err.What, err.StoredConfig, err.NewConfig, err.RewindTo)
 }
 
+// CheckCompatible checks whether scheduled fork transitions have been imported
+// with a mismatching chain configuration.
+func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *ConfigCompatError {
+	if c.Dpor == nil || newcfg.Dpor == nil {
+		return nil
+	}
+	stored, next := c.Dpor.forks(), newcfg.Dpor.forks()
+
+	type fork struct {
+		what              string
+		storedBlock, next *big.Int
+	}
+	var forks []fork
+	// legacy rpt forks are not used if either schedule of rpt formulas is configured,
+	// schedules in effect are compared below
+	if len(stored.RptFormulas) == 0 && len(next.RptFormulas) == 0 {
+		forks = append(forks, []fork{
+			{"RptCalcMethod2 fork block", stored.RptCalcMethod2Block, next.RptCalcMethod2Block},
+			{"RptCalcMethod3 fork block", stored.RptCalcMethod3Block, next.RptCalcMethod3Block},
+			{"RptCalcMethod4 fork block", stored.RptCalcMethod4Block, next.RptCalcMethod4Block},
+			{"RptCalcMethod5 fork block", stored.RptCalcMethod5Block, next.RptCalcMethod5Block},
+			{"RptCalcMethod6 fork block", stored.RptCalcMethod6Block, next.RptCalcMethod6Block},
+		}...)
+	}
+	forks = append(forks, []fork{
+		{"Campaign2 fork block", stored.Campaign2Block, next.Campaign2Block},
+		{"Campaign3 fork block", stored.Campaign3Block, next.Campaign3Block},
+		{"Campaign4 fork block", stored.Campaign4Block, next.Campaign4Block},
+		{"Election2 fork block", stored.Election2Block, next.Election2Block},
+		{"PinnedRpt fork block", stored.PinnedRptBlock, next.PinnedRptBlock},
+		{"ElectionSeed fork block", stored.ElectionSeedBlock, next.ElectionSeedBlock},
+		{"AdaptiveImpeach fork block", stored.AdaptiveImpeachBlock, next.AdaptiveImpeachBlock},
+		{"BLS fork block", stored.BLSBlock, next.BLSBlock},
+		{"PrivatePayloadV1 fork block", stored.PrivatePayloadV1Block, next.PrivatePayloadV1Block},
+	}...)
+	for _, f := range forks {
+		if isForkIncompatible(f.storedBlock, f.next, height) {
+			return newCompatError(f.what, f.storedBlock, f.next)
+		}
+	}
+
+	// impeach timeouts of passed blocks must not change after the adaptive impeach fork
+	if isForked(stored.AdaptiveImpeachBlock, height) && c.Dpor.impeachBackoff() != newcfg.Dpor.impeachBackoff() {
+		return newCompatError("impeach back-off policy", stored.AdaptiveImpeachBlock, next.AdaptiveImpeachBlock)
+	}
+
+	// signatures and seeds of passed blocks are verified with the registered bls keys
+	if isForked(stored.BLSBlock, height) && !blsKeysEqual(c.Dpor.BLSKeys, newcfg.Dpor.BLSKeys) {
+		return newCompatError("bls keys", stored.BLSBlock, next.BLSBlock)
+	}
+	if isForked(stored.ElectionSeedBlock, height) && !blsKeysEqual(c.Dpor.BLSKeys, newcfg.Dpor.BLSKeys) {
+		return newCompatError("bls keys", stored.ElectionSeedBlock, next.ElectionSeedBlock)
+	}
+
+	// the election algorithm must not change at any passed block
+	electors := append(c.Dpor.electors(), newcfg.Dpor.electors()...)
+	sort.SliceStable(electors, func(i, j int) bool {
+		return electors[i].Block.Cmp(electors[j].Block) < 0
+	})
+	for _, e := range electors {
+		if isForked(e.Block, height) && c.Dpor.ElectorOf(e.Block.Uint64()) != newcfg.Dpor.ElectorOf(e.Block.Uint64()) {
+			return newCompatError("elector fork block", e.Block, e.Block)
+		}
+	}
+
+	// neither may the rpt formula
+	formulas := append(c.Dpor.rptFormulas(), newcfg.Dpor.rptFormulas()...)
+	sort.SliceStable(formulas, func(i, j int) bool {
+		return formulas[i].Block.Cmp(formulas[j].Block) < 0
+	})
+	for _, f := range formulas {
+		if isForked(f.Block, height) && c.Dpor.RptCalcMethod(f.Block.Uint64()) != newcfg.Dpor.RptCalcMethod(f.Block.Uint64()) {
+			return newCompatError("rpt formula fork block", f.Block, f.Block)
+		}
+	}
+	return nil
+}
+
+// isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
+// block s2 because head is already past the fork.
+func isForkIncompatible(s1, s2 *big.Int, head uint64) bool {
+	return (isForked(s1, head) || isForked(s2, head)) && !configNumEqual(s1, s2)
+}
+
+func configNumEqual(x, y *big.Int) bool {
+	if x == nil {
+		return y == nil
+	}
+	if y == nil {
+		return x == nil
+	}
+	return x.Cmp(y) == 0
+}
+
 // Rules wraps ChainConfig and is merely syntatic sugar or can be used for functions
 // that do not have or require information about the block.
 //
@@ -429,6 +880,17 @@ func (err *ConfigCompatError) Error() string {
 type Rules struct {
 	ChainID   *big.Int
 	IsCpchain bool
+
+	// dpor fork rules, zero values if the chain is not driven by dpor
+	RptCalcMethod     int
+	CampaignVersion   int
+	IsElection2       bool
+	IsPinnedRpt       bool
+	IsElectionSeed    bool
+	IsAdaptiveImpeach bool
+	IsBLS             bool
+
+	IsPrivatePayloadV1 bool
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -437,5 +899,17 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 	if chainID == nil {
 		chainID = new(big.Int)
 	}
-	return Rules{ChainID: new(big.Int).Set(chainID), IsCpchain: c.IsCpchain()}
+	rules := Rules{ChainID: new(big.Int).Set(chainID), IsCpchain: c.IsCpchain()}
+	if c.Dpor != nil && num != nil {
+		number := num.Uint64()
+		rules.RptCalcMethod = c.Dpor.RptCalcMethod(number)
+		rules.CampaignVersion = c.Dpor.CampaignVersionOf(c.Dpor.TermOf(number))
+		rules.IsElection2 = c.Dpor.IsElection2(number)
+		rules.IsPinnedRpt = c.Dpor.IsPinnedRpt(number)
+		rules.IsElectionSeed = c.Dpor.IsElectionSeed(number)
+		rules.IsAdaptiveImpeach = c.Dpor.IsAdaptiveImpeach(number)
+		rules.IsBLS = c.Dpor.IsBLS(number)
+		rules.IsPrivatePayloadV1 = c.Dpor.IsPrivatePayloadV1(number)
+	}
+	return rules
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/assert"
@@ -212,3 +213,220 @@ func TestResolveUrl(t *testing.T) {
 		t.Skip("skip if no hosts mapping")
 	}
 }
+
+func TestDporForks(t *testing.T) {
+	dc := &DporConfig{TermLen: 4, ViewLen: 3, Forks: DefaultDporForks()}
+	assert.Equal(t, 1, dc.RptCalcMethod(RptCalcMethod2BlockNumber-1))
+	assert.Equal(t, 2, dc.RptCalcMethod(RptCalcMethod2BlockNumber))
+	assert.Equal(t, 6, dc.RptCalcMethod(RptCalcMethod6BlockNumber))
+	assert.False(t, dc.IsElection2(Election2BlockNumber-1))
+	assert.True(t, dc.IsElection2(Election2BlockNumber))
+	assert.Equal(t, 1, dc.CampaignVersionOf(dc.TermOf(Campaign2BlockNumber)-1))
+	assert.Equal(t, 2, dc.CampaignVersionOf(dc.TermOf(Campaign2BlockNumber)))
+	assert.Equal(t, 4, dc.CampaignVersionOf(dc.TermOf(Campaign4BlockNumber)))
+
+	dc.Forks = &DporForks{
+		RptCalcMethod6Block: big.NewInt(0),
+		Campaign4Block:      big.NewInt(0),
+		Election2Block:      big.NewInt(10),
+	}
+	assert.Equal(t, 6, dc.RptCalcMethod(1))
+	assert.Equal(t, 4, dc.CampaignVersionOf(0))
+	assert.False(t, dc.IsElection2(9))
+	assert.True(t, dc.IsElection2(10))
+	assert.False(t, dc.IsElectionSeed(10))
+
+	dc.Forks = &DporForks{}
+	assert.Equal(t, 1, dc.RptCalcMethod(RptCalcMethod6BlockNumber))
+	assert.Equal(t, 1, dc.CampaignVersionOf(dc.TermOf(Campaign4BlockNumber)))
+	assert.False(t, dc.IsElection2(Election2BlockNumber))
+}
+
+func TestElectorOf(t *testing.T) {
+	dc := &DporConfig{TermLen: 4, ViewLen: 3, Forks: DefaultDporForks()}
+	assert.Equal(t, ElectorElect, dc.ElectorOf(Election2BlockNumber-1))
+	assert.Equal(t, ElectorElect2, dc.ElectorOf(Election2BlockNumber))
+
+	dc.Forks = &DporForks{
+		Election2Block: big.NewInt(10),
+		Electors: []*ElectorFork{
+			{Block: big.NewInt(20), Name: "vrf"},
+			{Block: big.NewInt(5), Name: ElectorElect2},
+		},
+	}
+	assert.Equal(t, ElectorElect, dc.ElectorOf(4))
+	assert.Equal(t, ElectorElect2, dc.ElectorOf(5))
+	assert.Equal(t, ElectorElect2, dc.ElectorOf(19))
+	assert.Equal(t, "vrf", dc.ElectorOf(20))
+
+	// changing a passed elector is incompatible
+	stored := &ChainConfig{Dpor: &DporConfig{Forks: &DporForks{Election2Block: big.NewInt(10), Electors: dc.Forks.Electors[1:]}}}
+	assert.Nil(t, stored.CheckCompatible(&ChainConfig{Dpor: dc}, 19))
+	err := stored.CheckCompatible(&ChainConfig{Dpor: dc}, 20)
+	assert.NotNil(t, err)
+	assert.Equal(t, "elector fork block", err.What)
+	assert.Equal(t, uint64(19), err.RewindTo)
+}
+
+func TestRptFormulas(t *testing.T) {
+	dc := &DporConfig{TermLen: 4, ViewLen: 3}
+	dc.Forks = &DporForks{
+		RptCalcMethod2Block: big.NewInt(10),
+		RptFormulas: []*RptFormulaFork{
+			{Block: big.NewInt(20), Version: 7},
+			{Block: big.NewInt(5), Version: 6},
+		},
+	}
+	assert.Equal(t, 1, dc.RptCalcMethod(4))
+	assert.Equal(t, 6, dc.RptCalcMethod(5))
+	assert.Equal(t, 6, dc.RptCalcMethod(19)) // legacy fork blocks are ignored with a schedule
+	assert.Equal(t, 7, dc.RptCalcMethod(20))
+
+	// changing a passed formula is incompatible
+	stored := &ChainConfig{Dpor: &DporConfig{Forks: &DporForks{RptCalcMethod2Block: big.NewInt(10), RptFormulas: dc.Forks.RptFormulas[1:]}}}
+	assert.Nil(t, stored.CheckCompatible(&ChainConfig{Dpor: dc}, 19))
+	err := stored.CheckCompatible(&ChainConfig{Dpor: dc}, 20)
+	assert.NotNil(t, err)
+	assert.Equal(t, "rpt formula fork block", err.What)
+	assert.Equal(t, uint64(19), err.RewindTo)
+
+	// legacy fork blocks are not compared with a schedule either
+	moved := &DporConfig{Forks: &DporForks{RptCalcMethod2Block: big.NewInt(12), RptFormulas: dc.Forks.RptFormulas}}
+	assert.Nil(t, (&ChainConfig{Dpor: dc}).CheckCompatible(&ChainConfig{Dpor: moved}, 30))
+}
+
+func TestImpeachTimeoutOf(t *testing.T) {
+	dc := &DporConfig{TermLen: 4, ViewLen: 3, ImpeachTimeout: 10 * time.Second}
+	maxLevel, decayBlocks := dc.ImpeachBackoffLevels()
+	assert.Equal(t, uint64(3), maxLevel)
+	assert.Equal(t, uint64(DefaultImpeachBackoffDecayBlocks), decayBlocks)
+	assert.Equal(t, 10*time.Second, dc.ImpeachTimeoutOf(0))
+	assert.Equal(t, 20*time.Second, dc.ImpeachTimeoutOf(1))
+	assert.Equal(t, 80*time.Second, dc.ImpeachTimeoutOf(3))
+	assert.Equal(t, 80*time.Second, dc.ImpeachTimeoutOf(10))
+
+	dc.ImpeachBackoff = &ImpeachBackoff{Multiplier: 3, MaxTimeout: time.Minute, DecayBlocks: 5}
+	maxLevel, decayBlocks = dc.ImpeachBackoffLevels()
+	assert.Equal(t, uint64(2), maxLevel)
+	assert.Equal(t, uint64(5), decayBlocks)
+	assert.Equal(t, 30*time.Second, dc.ImpeachTimeoutOf(1))
+	assert.Equal(t, time.Minute, dc.ImpeachTimeoutOf(2))
+
+	assert.False(t, dc.IsAdaptiveImpeach(100))
+	dc.Forks = &DporForks{AdaptiveImpeachBlock: big.NewInt(100)}
+	assert.False(t, dc.IsAdaptiveImpeach(99))
+	assert.True(t, dc.IsAdaptiveImpeach(100))
+
+	// changing the policy is incompatible once the fork is passed
+	next := *dc
+	next.ImpeachBackoff = &ImpeachBackoff{Multiplier: 2, MaxTimeout: time.Minute, DecayBlocks: 5}
+	stored := &ChainConfig{Dpor: dc}
+	assert.Nil(t, stored.CheckCompatible(&ChainConfig{Dpor: &next}, 99))
+	err := stored.CheckCompatible(&ChainConfig{Dpor: &next}, 100)
+	assert.NotNil(t, err)
+	assert.Equal(t, "impeach back-off policy", err.What)
+	assert.Equal(t, uint64(99), err.RewindTo)
+
+	// so is falling back to the default policy
+	next.ImpeachBackoff = nil
+	assert.NotNil(t, stored.CheckCompatible(&ChainConfig{Dpor: &next}, 100))
+}
+
+func TestBLSKeysCompatible(t *testing.T) {
+	key := &BLSKey{Address: common.HexToAddress("0x01"), PublicKey: []byte{1}, Possession: []byte{2}}
+	stored := &ChainConfig{Dpor: &DporConfig{BLSKeys: []*BLSKey{key}, Forks: &DporForks{BLSBlock: big.NewInt(100)}}}
+
+	// registered keys can not change once the bls fork is passed
+	changed := &BLSKey{Address: key.Address, PublicKey: []byte{3}, Possession: key.Possession}
+	next := &ChainConfig{Dpor: &DporConfig{BLSKeys: []*BLSKey{changed}, Forks: &DporForks{BLSBlock: big.NewInt(100)}}}
+	assert.Nil(t, stored.CheckCompatible(next, 99))
+	err := stored.CheckCompatible(next, 100)
+	assert.NotNil(t, err)
+	assert.Equal(t, "bls keys", err.What)
+
+	same := &ChainConfig{Dpor: &DporConfig{BLSKeys: []*BLSKey{{Address: key.Address, PublicKey: []byte{1}, Possession: []byte{2}}}, Forks: &DporForks{BLSBlock: big.NewInt(100)}}}
+	assert.Nil(t, stored.CheckCompatible(same, 100))
+
+	// nor once the election seed fork is passed, as seeds are signed with them
+	stored.Dpor.Forks = &DporForks{ElectionSeedBlock: big.NewInt(50), BLSBlock: big.NewInt(100)}
+	next.Dpor.Forks = &DporForks{ElectionSeedBlock: big.NewInt(50), BLSBlock: big.NewInt(100)}
+	assert.Nil(t, stored.CheckCompatible(next, 49))
+	err = stored.CheckCompatible(next, 50)
+	assert.NotNil(t, err)
+	assert.Equal(t, "bls keys", err.What)
+}
+
+func TestPrivatePayloadV1Fork(t *testing.T) {
+	cc := ChainConfig{ChainID: big.NewInt(DevChainId), Dpor: &DporConfig{TermLen: 4, ViewLen: 3, Forks: &DporForks{PrivatePayloadV1Block: big.NewInt(100)}}}
+	assert.False(t, cc.Rules(big.NewInt(99)).IsPrivatePayloadV1)
+	assert.True(t, cc.Rules(big.NewInt(100)).IsPrivatePayloadV1)
+
+	// rescheduling the fork is incompatible once it is passed
+	next := ChainConfig{ChainID: cc.ChainID, Dpor: &DporConfig{TermLen: 4, ViewLen: 3, Forks: &DporForks{PrivatePayloadV1Block: big.NewInt(200)}}}
+	assert.Nil(t, cc.CheckCompatible(&next, 99))
+	err := cc.CheckCompatible(&next, 100)
+	assert.NotNil(t, err)
+	assert.Equal(t, "PrivatePayloadV1 fork block", err.What)
+}
+
+func TestRulesDporForks(t *testing.T) {
+	cc := ChainConfig{ChainID: big.NewInt(DevChainId), Dpor: &DporConfig{TermLen: 4, ViewLen: 3, Forks: DefaultDporForks()}}
+	rule := cc.Rules(big.NewInt(Election2BlockNumber))
+	assert.Equal(t, 6, rule.RptCalcMethod)
+	assert.Equal(t, 4, rule.CampaignVersion)
+	assert.True(t, rule.IsElection2)
+
+	rule = cc.Rules(big.NewInt(1))
+	assert.Equal(t, 1, rule.RptCalcMethod)
+	assert.Equal(t, 1, rule.CampaignVersion)
+	assert.False(t, rule.IsElection2)
+
+	// a config without a schedule follows the default one, which never activates
+	// optional forks
+	cc.Dpor.Forks = nil
+	rule = cc.Rules(big.NewInt(Election2BlockNumber))
+	assert.Equal(t, 6, rule.RptCalcMethod)
+	assert.True(t, rule.IsElection2)
+	assert.False(t, rule.IsPinnedRpt)
+	assert.False(t, cc.Dpor.IsElectionSeed(Election2BlockNumber))
+	assert.False(t, cc.Dpor.IsAdaptiveImpeach(Election2BlockNumber))
+	assert.False(t, cc.Dpor.IsBLS(Election2BlockNumber))
+	assert.False(t, cc.Dpor.IsPrivatePayloadV1(Election2BlockNumber))
+
+	// the default schedule is a copy
+	forks := DefaultDporForks()
+	forks.BLSBlock = big.NewInt(0)
+	assert.Nil(t, DefaultDporForks().BLSBlock)
+}
+
+func TestShippedDporForks(t *testing.T) {
+	// shipped networks keep the schedule they were launched with
+	for _, cc := range []*ChainConfig{devChainConfig, testnetChainConfig, mainnetChainConfig} {
+		assert.NotNil(t, cc.Dpor.Forks)
+		assert.Nil(t, (&ChainConfig{Dpor: &DporConfig{Forks: DefaultDporForks()}}).CheckCompatible(cc, Election2BlockNumber))
+	}
+}
+
+func TestCheckCompatible(t *testing.T) {
+	stored := &ChainConfig{Dpor: &DporConfig{TermLen: 4, ViewLen: 3, Forks: DefaultDporForks()}}
+
+	// a config without a schedule equals to the mainnet one
+	next := &ChainConfig{Dpor: &DporConfig{TermLen: 4, ViewLen: 3}}
+	assert.Nil(t, stored.CheckCompatible(next, Election2BlockNumber))
+	assert.Nil(t, stored.CheckCompatible(mainnetChainConfig, Election2BlockNumber))
+
+	// rescheduling a future fork is allowed
+	next = &ChainConfig{Dpor: &DporConfig{TermLen: 4, ViewLen: 3, Forks: &DporForks{Election2Block: big.NewInt(20)}}}
+	assert.Nil(t, (&ChainConfig{Dpor: &DporConfig{Forks: &DporForks{}}}).CheckCompatible(next, 10))
+
+	// rescheduling a passed fork is not
+	err := (&ChainConfig{Dpor: &DporConfig{Forks: &DporForks{}}}).CheckCompatible(next, 30)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Election2 fork block", err.What)
+	assert.Equal(t, uint64(19), err.RewindTo)
+
+	err = stored.CheckCompatible(next, RptCalcMethod2BlockNumber)
+	assert.NotNil(t, err)
+	assert.Equal(t, "RptCalcMethod2 fork block", err.What)
+	assert.Equal(t, uint64(RptCalcMethod2BlockNumber-1), err.RewindTo)
+}
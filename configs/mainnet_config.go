@@ -65,6 +65,7 @@ var (
 			ProxyContractRegister: mainnetProxyContractRegister,
 			Contracts:             MainnetContractAddressMap,
 			ImpeachTimeout:        time.Millisecond * MainnetBlockPeriod,
+			Forks:                 DefaultDporForks(),
 		},
 	}
 	mainnetProposers = []common.Address{
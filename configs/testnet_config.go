@@ -48,6 +48,7 @@ var (
 			ProxyContractRegister: testnetProxyContractRegister,
 			Contracts:             testnetContractAddressMap,
 			ImpeachTimeout:        time.Millisecond * TestnetBlockPeriod * 2,
+			Forks:                 DefaultDporForks(),
 		},
 	}
 
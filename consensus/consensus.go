@@ -18,6 +18,8 @@
 package consensus
 
 import (
+	"time"
+
 	"bitbucket.org/cpchain/chain/api/rpc"
 	"bitbucket.org/cpchain/chain/configs"
 	"bitbucket.org/cpchain/chain/core/state"
@@ -201,8 +203,9 @@ func (s State) String() string {
 
 // PbftStatus represents a state of a dpor replica
 type PbftStatus struct {
-	State State
-	Head  *types.Header
+	State          State
+	Head           *types.Header
+	ImpeachTimeout time.Duration // impeach timeout of the height after head
 }
 
 // Protocol represents interfaces a protocol can provide
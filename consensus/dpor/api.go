@@ -1,12 +1,21 @@
 package dpor
 
 import (
+	"context"
+	"reflect"
+
 	"bitbucket.org/cpchain/chain/api/rpc"
 	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/consensus/dpor/backend"
 	"bitbucket.org/cpchain/chain/types"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
 )
 
+// subscriptionChanSize is the size of channels receiving events of subscriptions,
+// a slow subscriber blocks the feed once it is full.
+const subscriptionChanSize = 64
+
 // API is a user facing RPC API to allow controlling the signer and voting
 // mechanisms of the proof-of-authority scheme.
 type API struct {
@@ -15,15 +24,36 @@ type API struct {
 	dh    *defaultDporHelper
 }
 
+// finalizedChain is a chain tracking its latest finalized block.
+type finalizedChain interface {
+	CurrentFinalizedBlock() *types.Block
+}
+
+// headerByNumber retrieves the header at a given block number. Latest and pending
+// are the current header, as there is no pending block known to the engine, and
+// finalized and safe are the latest finalized header. A dpor block is final once
+// certified by validators, so safe is an alias of finalized.
+func (api *API) headerByNumber(number rpc.BlockNumber) *types.Header {
+	switch number {
+	case 0, rpc.LatestBlockNumber, rpc.PendingBlockNumber:
+		return api.chain.CurrentHeader()
+	case rpc.FinalizedBlockNumber, rpc.SafeBlockNumber:
+		chain, ok := api.chain.(finalizedChain)
+		if !ok {
+			return nil
+		}
+		if block := chain.CurrentFinalizedBlock(); block != nil {
+			return block.Header()
+		}
+		return nil
+	}
+	return api.chain.GetHeaderByNumber(uint64(number.Int64()))
+}
+
 // GetSnapshot retrieves the state Snapshot at a given block.
 func (api *API) GetSnapshot(number rpc.BlockNumber) (*DporSnapshot, error) {
 	// Retrieve the requested block number (or current if none requested)
-	var header *types.Header
-	if number == 0 || number == rpc.LatestBlockNumber {
-		header = api.chain.CurrentHeader()
-	} else {
-		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
-	}
+	header := api.headerByNumber(number)
 	// Ensure we have an actually valid block and return its Snapshot
 	if header == nil {
 		return nil, errUnknownBlock
@@ -43,12 +73,7 @@ func (api *API) GetSnapshotAtHash(hash common.Hash) (*DporSnapshot, error) {
 // GetProposers retrieves the Proposers at a given block.
 func (api *API) GetProposers(number rpc.BlockNumber) ([]common.Address, error) {
 	// Retrieve the requested block number (or current if none requested)
-	var header *types.Header
-	if number == 0 || number == rpc.LatestBlockNumber {
-		header = api.chain.CurrentHeader()
-	} else {
-		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
-	}
+	header := api.headerByNumber(number)
 	// Ensure we have an actually valid block and return its Proposers
 	if header == nil {
 		return nil, errUnknownBlock
@@ -58,10 +83,146 @@ func (api *API) GetProposers(number rpc.BlockNumber) ([]common.Address, error) {
 
 // GetValidators retrieves the Validators at a given block.
 func (api *API) GetValidators(number rpc.BlockNumber) ([]common.Address, error) {
+	// validators of future blocks are known, only tags are resolved to headers
+	if number < 0 {
+		header := api.headerByNumber(number)
+		if header == nil {
+			return nil, errUnknownBlock
+		}
+		return api.dpor.ValidatorsOf(header.Number.Uint64())
+	}
 	return api.dpor.ValidatorsOf(uint64(number))
 }
 
+// GetRptBreakdown retrieves how the reputation of a candidate is calculated at a
+// given block, ranked among the candidates of the block.
+func (api *API) GetRptBreakdown(address common.Address, number rpc.BlockNumber) (*types.RptBreakdown, error) {
+	// Retrieve the requested block number (or current if none requested)
+	header := api.headerByNumber(number)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+
+	rptService := api.dpor.GetRptBackend()
+	if rptService == nil {
+		return nil, errNoRptService
+	}
+
+	snap, err := api.dpor.dh.snapshot(api.dpor, api.chain, header.Number.Uint64(), header.Hash(), nil)
+	if err != nil {
+		return nil, err
+	}
+	candidates := snap.candidates()
+	for _, candidate := range candidates {
+		if candidate == address {
+			return rptService.CalcRptBreakdown(address, candidates, header.Number.Uint64())
+		}
+	}
+	return nil, errNotCandidate
+}
+
 // GetRNodes retrieves current RNodes.
 func (api *API) GetRNodes() ([]common.Address, error) {
 	return api.dpor.GetRNodes()
 }
+
+// GetEvidence retrieves the evidences of equivocating proposers or validators
+// detected at a given block number.
+func (api *API) GetEvidence(number rpc.BlockNumber) ([]*backend.Evidence, error) {
+	// equivocations mostly happen at the height being agreed on, i.e. the pending one
+	switch number {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber:
+		header := api.chain.CurrentHeader()
+		if header == nil {
+			return nil, errUnknownBlock
+		}
+		current := header.Number.Uint64()
+		if number == rpc.PendingBlockNumber {
+			current++
+		}
+		return api.dpor.Evidences(current)
+	case rpc.FinalizedBlockNumber, rpc.SafeBlockNumber:
+		header := api.headerByNumber(number)
+		if header == nil {
+			return nil, errUnknownBlock
+		}
+		return api.dpor.Evidences(header.Number.Uint64())
+	}
+	return api.dpor.Evidences(uint64(number.Int64()))
+}
+
+// Evidence creates a subscription that is triggered each time an equivocation
+// of a proposer or a validator is detected.
+func (api *API) Evidence(ctx context.Context) (*rpc.Subscription, error) {
+	evidences := make(chan *backend.Evidence, subscriptionChanSize)
+	return subscribe(ctx, evidences, func() event.Subscription {
+		return api.dpor.SubscribeEvidence(evidences)
+	})
+}
+
+// NewTerm creates a subscription that is triggered each time a new term starts.
+func (api *API) NewTerm(ctx context.Context) (*rpc.Subscription, error) {
+	events := make(chan *types.TermEvent, subscriptionChanSize)
+	return subscribe(ctx, events, func() event.Subscription {
+		return api.dpor.SubscribeTermEvent(events)
+	})
+}
+
+// CommitteeUpdate creates a subscription that is triggered each time a new
+// proposer list is elected for a future term.
+func (api *API) CommitteeUpdate(ctx context.Context) (*rpc.Subscription, error) {
+	events := make(chan *types.CommitteeEvent, subscriptionChanSize)
+	return subscribe(ctx, events, func() event.Subscription {
+		return api.dpor.SubscribeCommitteeEvent(events)
+	})
+}
+
+// Impeachment creates a subscription that is triggered each time an impeach
+// block is committed.
+func (api *API) Impeachment(ctx context.Context) (*rpc.Subscription, error) {
+	events := make(chan *types.ImpeachEvent, subscriptionChanSize)
+	return subscribe(ctx, events, func() event.Subscription {
+		return api.dpor.SubscribeImpeachEvent(events)
+	})
+}
+
+// RoleChange creates a subscription that is triggered each time the role of
+// the local node as a proposer, validator or candidate changes.
+func (api *API) RoleChange(ctx context.Context) (*rpc.Subscription, error) {
+	events := make(chan *types.RoleEvent, subscriptionChanSize)
+	return subscribe(ctx, events, func() event.Subscription {
+		return api.dpor.SubscribeRoleEvent(events)
+	})
+}
+
+// subscribe creates a subscription notifying every value received from ch,
+// which is a channel subscribed to a feed by calling sub.
+func subscribe(ctx context.Context, ch interface{}, sub func() event.Subscription) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+	feedSub := sub()
+
+	go func() {
+		defer feedSub.Unsubscribe()
+
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(rpcSub.Err())},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(notifier.Closed())},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(feedSub.Err())},
+		}
+		for {
+			chosen, value, ok := reflect.Select(cases)
+			if chosen != 0 || !ok {
+				return
+			}
+			notifier.Notify(rpcSub.ID, value.Interface())
+		}
+	}()
+
+	return rpcSub, nil
+}
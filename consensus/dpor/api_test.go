@@ -0,0 +1,57 @@
+package dpor
+
+import (
+	"math/big"
+	"testing"
+
+	"bitbucket.org/cpchain/chain/api/rpc"
+	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/types"
+)
+
+// finalizedHeaders is a chain of headers finalized up to a height
+type finalizedHeaders struct {
+	consensus.ChainReader
+	headers   simulatedHeaders
+	current   uint64
+	finalized uint64
+}
+
+func (c *finalizedHeaders) CurrentHeader() *types.Header { return c.headers[c.current] }
+func (c *finalizedHeaders) GetHeaderByNumber(number uint64) *types.Header {
+	return c.headers.GetHeaderByNumber(number)
+}
+func (c *finalizedHeaders) CurrentFinalizedBlock() *types.Block {
+	return types.NewBlockWithHeader(c.headers[c.finalized])
+}
+
+func TestAPIHeaderByNumber(t *testing.T) {
+	chain := &finalizedHeaders{headers: make(simulatedHeaders), current: 10, finalized: 8}
+	for i := uint64(0); i <= chain.current; i++ {
+		chain.headers[i] = &types.Header{Number: new(big.Int).SetUint64(i)}
+	}
+	api := &API{chain: chain}
+
+	tests := []struct {
+		number rpc.BlockNumber
+		want   uint64
+	}{
+		{rpc.LatestBlockNumber, 10},
+		{rpc.PendingBlockNumber, 10},
+		{rpc.FinalizedBlockNumber, 8},
+		{rpc.SafeBlockNumber, 8},
+		{rpc.BlockNumber(5), 5},
+	}
+	for _, tt := range tests {
+		header := api.headerByNumber(tt.number)
+		if header == nil || header.Number.Uint64() != tt.want {
+			t.Errorf("header of %d = %v, want #%d", tt.number, header, tt.want)
+		}
+	}
+
+	// a chain not tracking finalized blocks knows no finalized header
+	api = &API{chain: &struct{ consensus.ChainReader }{chain}}
+	if header := api.headerByNumber(rpc.FinalizedBlockNumber); header != nil {
+		t.Errorf("finalized header of a chain without finality = #%d, want none", header.Number)
+	}
+}
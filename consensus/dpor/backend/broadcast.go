@@ -173,9 +173,7 @@ func (h *Handler) PendingBlockBroadcastLoop() {
 	for {
 		select {
 		case pendingBlock := <-h.pendingBlockCh:
-
-			// broadcast mined pending block to remote signers
-			go h.ProposerBroadcastPreprepareBlock(pendingBlock)
+			h.broadcastPendingBlock(pendingBlock)
 
 		case <-h.quitCh:
 			return
@@ -183,39 +181,52 @@ func (h *Handler) PendingBlockBroadcastLoop() {
 	}
 }
 
+// broadcastPendingBlock broadcasts a mined pending block to remote signers
+func (h *Handler) broadcastPendingBlock(block *types.Block) {
+	h.traceOutbound(NewBOHFromBlock(block), PreprepareMsgCode)
+	h.clock.Go(func() { h.ProposerBroadcastPreprepareBlock(block) })
+}
+
 // PendingImpeachBlockBroadcastLoop loops to broadcasts pending impeachment block
 func (h *Handler) PendingImpeachBlockBroadcastLoop() {
 	for {
 		select {
 		case impeachBlock := <-h.pendingImpeachBlockCh:
+			h.handlePendingImpeachBlock(impeachBlock)
 
-			if h.mode == LBFT2Mode {
-				size, r, err := rlp.EncodeToReader(impeachBlock)
-				if err != nil {
-					log.Warn("failed to encode composed impeach block", "err", err)
-					continue
-				}
-				msg := p2p.Msg{Code: PreprepareImpeachBlockMsg, Size: uint32(size), Payload: r}
+		case <-h.quitCh:
+			return
+		}
+	}
+}
 
-				var (
-					number = impeachBlock.NumberU64()
-					hash   = impeachBlock.Hash()
-				)
+// handlePendingImpeachBlock handles an impeach block composed by the local state
+// machine as a preprepare msg from itself
+func (h *Handler) handlePendingImpeachBlock(impeachBlock *types.Block) {
+	if h.mode != LBFT2Mode {
+		return
+	}
 
-				// only validators can impeach
-				isValidator, err := h.dpor.VerifyValidatorOf(h.Coinbase(), h.dpor.TermOf(number))
+	size, r, err := rlp.EncodeToReader(impeachBlock)
+	if err != nil {
+		log.Warn("failed to encode composed impeach block", "err", err)
+		return
+	}
+	msg := p2p.Msg{Code: PreprepareImpeachBlockMsg, Size: uint32(size), Payload: r}
 
-				if !h.impeachmentRecord.ifImpeached(number, hash) && isValidator && err == nil {
+	var (
+		number = impeachBlock.NumberU64()
+		hash   = impeachBlock.Hash()
+	)
 
-					// handle the impeach block
-					go h.handleLBFT2Msg(msg, nil)
+	// only validators can impeach
+	isValidator, err := h.dpor.VerifyValidatorOf(h.Coinbase(), h.dpor.TermOf(number))
 
-					h.impeachmentRecord.markAsImpeached(number, hash)
-				}
-			}
+	if !h.impeachmentRecord.ifImpeached(number, hash) && isValidator && err == nil {
 
-		case <-h.quitCh:
-			return
-		}
+		// handle the impeach block
+		h.clock.Go(func() { h.handleLBFT2Msg(msg, nil) })
+
+		h.impeachmentRecord.markAsImpeached(number, hash)
 	}
 }
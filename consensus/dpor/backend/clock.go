@@ -0,0 +1,42 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import "time"
+
+// clock is the source of time and of asynchronous calls of the handler and the
+// state machine. The committee simulation replaces it to run every node on one
+// virtual clock in a single goroutine, so that a run is reproducible.
+type clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// AfterFunc calls f in its own goroutine after d elapsed
+	AfterFunc(d time.Duration, f func())
+
+	// Go calls f in its own goroutine
+	Go(f func())
+}
+
+// systemClock is the clock of the local system
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) { time.AfterFunc(d, f) }
+
+func (systemClock) Go(f func()) { go f() }
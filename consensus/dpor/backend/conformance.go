@@ -0,0 +1,174 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"bitbucket.org/cpchain/chain/consensus"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// This file maps transitions of the LBFT2 state machine to the state variables
+// of the TLA+ model in tlaplus/lbft.tla, so that a recorded run can be checked
+// against the model.
+
+// ModelState is the state of a validator in the TLA+ model, as seen for the block
+// a msg is about
+type ModelState int
+
+// Those are validator states in the TLA+ model
+const (
+	ModelIdle           ModelState = 0
+	ModelPrepare        ModelState = 1
+	ModelCommit         ModelState = 2
+	ModelImpeachPrepare ModelState = 3
+	ModelImpeachCommit  ModelState = 4
+
+	// ModelNextHeight is the idle state in the next block height
+	ModelNextHeight ModelState = 9
+)
+
+// ModelValidator is a validator record of the TLA+ model
+type ModelValidator struct {
+	State             ModelState
+	Sig               common.Address // empty if local node is not a validator
+	PrepareSig        []common.Address
+	CommitSig         []common.Address
+	ImpeachPrepareSig []common.Address
+	ImpeachCommitSig  []common.Address
+}
+
+// String returns the validator record as a TLA+ expression
+func (v *ModelValidator) String() string {
+	return fmt.Sprintf(`[state |-> %d, sig |-> "%s", prepareSig |-> %s, commitSig |-> %s, impeachPrepareSig |-> %s, impeachCommitSig |-> %s]`,
+		v.State, v.Sig.Hex(), tlaSet(v.PrepareSig), tlaSet(v.CommitSig), tlaSet(v.ImpeachPrepareSig), tlaSet(v.ImpeachCommitSig))
+}
+
+// ModelStep is a step of the state machine in terms of the TLA+ model
+type ModelStep struct {
+	Number    uint64
+	Hash      common.Hash
+	InputType string // input type of the fsm macro in the model
+	Pre       ModelValidator
+	Post      ModelValidator
+}
+
+// String returns the step as a TLA+ tuple of the input type, the pre-state and the post-state
+func (s *ModelStep) String() string {
+	return fmt.Sprintf(`<<"%s", %v, %v>>`, s.InputType, &s.Pre, &s.Post)
+}
+
+// ModelStepFn is called with every step of the state machine
+type ModelStepFn func(step *ModelStep)
+
+// modelInputTypes maps msg codes to input types of the model
+var modelInputTypes = map[MsgCode]string{
+	PreprepareMsgCode:        "block",
+	PrepareMsgCode:           "prepareMsg",
+	CommitMsgCode:            "commitMsg",
+	ValidateMsgCode:          "validateMsg",
+	ImpeachPreprepareMsgCode: "impeachBlock",
+	ImpeachPrepareMsgCode:    "impeachPrepareMsg",
+	ImpeachCommitMsgCode:     "impeachCommitMsg",
+	ImpeachValidateMsgCode:   "impeachValidateMsg",
+}
+
+// isImpeachMsgCode returns if the msg is about an impeach block
+func isImpeachMsgCode(msgCode MsgCode) bool {
+	switch msgCode {
+	case ImpeachPreprepareMsgCode, ImpeachPrepareMsgCode, ImpeachCommitMsgCode, ImpeachValidateMsgCode:
+		return true
+	}
+	return false
+}
+
+// SetModelStepFn sets the function to call with every step of the state machine
+// in terms of the TLA+ model, nil to stop
+func (p *LBFT2) SetModelStepFn(fn ModelStepFn) {
+	p.stateLock.Lock()
+	defer p.stateLock.Unlock()
+
+	p.modelStepFn = fn
+}
+
+// modelValidator returns current state of local validator for the block of a msg,
+// the caller must hold p.stateLock
+func (p *LBFT2) modelValidator(input *BlockOrHeader, msgCode MsgCode) ModelValidator {
+	var (
+		number = input.Number()
+		bi     = NewBlockIdentifier(number, input.Hash())
+		v      ModelValidator
+	)
+
+	switch {
+	case p.number > number:
+		v.State = ModelNextHeight
+	case p.number < number:
+		v.State = ModelIdle
+	default:
+		switch p.state {
+		case consensus.Prepare:
+			v.State = ModelPrepare
+		case consensus.Commit:
+			v.State = ModelCommit
+		case consensus.ImpeachPrepare:
+			v.State = ModelImpeachPrepare
+		case consensus.ImpeachCommit:
+			v.State = ModelImpeachCommit
+		case consensus.Validate:
+			v.State = ModelNextHeight
+		default:
+			v.State = ModelIdle
+		}
+	}
+
+	coinbase := p.dpor.Coinbase()
+	if validators, err := p.dpor.ValidatorsOf(number); err == nil {
+		for _, validator := range validators {
+			if validator == coinbase {
+				v.Sig = coinbase
+			}
+		}
+	}
+
+	prepareSig, commitSig := sortedAddresses(p.prepareSignatures.getSignersOf(bi)), sortedAddresses(p.commitSignatures.getSignersOf(bi))
+	if isImpeachMsgCode(msgCode) {
+		v.ImpeachPrepareSig, v.ImpeachCommitSig = prepareSig, commitSig
+	} else {
+		v.PrepareSig, v.CommitSig = prepareSig, commitSig
+	}
+	return v
+}
+
+func sortedAddresses(addrs []common.Address) []common.Address {
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+	return addrs
+}
+
+func tlaSet(addrs []common.Address) string {
+	elems := make([]string, len(addrs))
+	for i, addr := range addrs {
+		elems[i] = fmt.Sprintf(`"%s"`, addr.Hex())
+	}
+	return "{" + strings.Join(elems, ", ") + "}"
+}
@@ -0,0 +1,358 @@
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// modelSpec is the TLA+ model the state machine is checked against
+const modelSpec = "../../../tlaplus/lbft.tla"
+
+// modelAction is a branch of the fsm macro in the TLA+ model. It is enabled if
+// the validator is in one of the states and the input is of the type, as its
+// await statements require.
+type modelAction struct {
+	states      []ModelState
+	inputType   string
+	accumulate  string     // signature set the input's signatures are accumulated into, empty if none
+	certificate string     // certificate to collect before the transition, empty if unconditional
+	sign        string     // signature set the validator signs into on the transition
+	target      ModelState // state after the transition
+}
+
+func (a *modelAction) enabled(state ModelState, inputType string) bool {
+	if a.inputType != inputType {
+		return false
+	}
+	for _, s := range a.states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// modelCertificate is a certificate operator of the model, satisfied by a
+// signature set of at least weight*f+1 signatures. The model has 4 validators,
+// its thresholds are generalized from f = 1.
+type modelCertificate struct {
+	field  string
+	weight uint64
+}
+
+var (
+	modelBranchSep   = regexp.MustCompile(`(?m)^\s*(either|or)\b`)
+	modelStateGuard  = regexp.MustCompile(`await v\.state = (\d+);`)
+	modelStatesGuard = regexp.MustCompile(`await v\.state \\in \{([\d, ]+)\};`)
+	modelInputGuard  = regexp.MustCompile(`await inputType = "(\w+)";`)
+	modelAccumulate  = regexp.MustCompile(`v\.(\w+) := v\.\w+ \\union input\.\w+;`)
+	modelCondition   = regexp.MustCompile(`if (\w+)\(v\)`)
+	modelSign        = regexp.MustCompile(`v\.(\w+) := \{v\.sig\};`)
+	modelTarget      = regexp.MustCompile(`v\.state := (\d+);`)
+	modelCertDef     = regexp.MustCompile(`(\w+)\(v\) ==\s*Len\(v\.(\w+)\) >= (\d+)`)
+)
+
+// parseModel reads the actions of the fsm macro and the certificates they require
+// from the PlusCal algorithm of the TLA+ model
+func parseModel(spec string) ([]*modelAction, map[string]modelCertificate, error) {
+	section := func(begin, end string) (string, error) {
+		i := strings.Index(spec, begin)
+		if i < 0 {
+			return "", fmt.Errorf("no %q in the model", begin)
+		}
+		j := strings.Index(spec[i:], end)
+		if j < 0 {
+			return "", fmt.Errorf("no %q in the model", end)
+		}
+		return spec[i : i+j], nil
+	}
+
+	define, err := section("define", "end define;")
+	if err != nil {
+		return nil, nil, err
+	}
+	certificates := make(map[string]modelCertificate)
+	for _, m := range modelCertDef.FindAllStringSubmatch(define, -1) {
+		threshold, _ := strconv.ParseUint(m[3], 10, 64)
+		certificates[m[1]] = modelCertificate{field: m[2], weight: threshold - 1}
+	}
+
+	macro, err := section("macro fsm", "end macro;")
+	if err != nil {
+		return nil, nil, err
+	}
+	var actions []*modelAction
+	for _, branch := range modelBranchSep.Split(macro, -1)[1:] {
+		a := new(modelAction)
+		if m := modelStateGuard.FindStringSubmatch(branch); m != nil {
+			state, _ := strconv.Atoi(m[1])
+			a.states = []ModelState{ModelState(state)}
+		} else if m := modelStatesGuard.FindStringSubmatch(branch); m != nil {
+			for _, s := range strings.Split(m[1], ",") {
+				state, _ := strconv.Atoi(strings.TrimSpace(s))
+				a.states = append(a.states, ModelState(state))
+			}
+		}
+		if m := modelInputGuard.FindStringSubmatch(branch); m != nil {
+			a.inputType = m[1]
+		}
+		if m := modelAccumulate.FindStringSubmatch(branch); m != nil {
+			a.accumulate = m[1]
+		}
+		if m := modelCondition.FindStringSubmatch(branch); m != nil {
+			if _, ok := certificates[m[1]]; !ok {
+				return nil, nil, fmt.Errorf("unknown certificate %s in the model", m[1])
+			}
+			a.certificate = m[1]
+		}
+		if m := modelSign.FindStringSubmatch(branch); m != nil {
+			a.sign = m[1]
+		}
+		m := modelTarget.FindStringSubmatch(branch)
+		if len(a.states) == 0 || a.inputType == "" || m == nil {
+			return nil, nil, fmt.Errorf("unrecognized branch of the fsm macro: %s", branch)
+		}
+		target, _ := strconv.Atoi(m[1])
+		a.target = ModelState(target)
+		actions = append(actions, a)
+	}
+	return actions, certificates, nil
+}
+
+// modelChecker checks steps of a state machine against the actions of the TLA+
+// model in tlaplus/lbft.tla. The model is generalized from 4 validators to 3f+1.
+//
+// The state machine keeps signatures in caches instead of clearing the sets once
+// a certificate is collected as the model does, so the sets are only required to
+// grow, and an input may add its signatures to the caches even if no action of
+// the model takes it.
+type modelChecker struct {
+	faulty       uint64
+	actions      []*modelAction
+	certificates map[string]modelCertificate
+
+	lock  sync.Mutex
+	steps []*ModelStep
+}
+
+func newModelChecker(faulty uint64) (*modelChecker, error) {
+	spec, err := ioutil.ReadFile(modelSpec)
+	if err != nil {
+		return nil, err
+	}
+	actions, certificates, err := parseModel(string(spec))
+	if err != nil {
+		return nil, err
+	}
+	return &modelChecker{faulty: faulty, actions: actions, certificates: certificates}, nil
+}
+
+func (c *modelChecker) record(step *ModelStep) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.steps = append(c.steps, step)
+}
+
+// satisfied returns if the certificate is collected by the validator
+func (c *modelChecker) satisfied(certificate string, v *ModelValidator) bool {
+	cert := c.certificates[certificate]
+	return uint64(len(modelSigs(v, cert.field))) >= cert.weight*c.faulty+1
+}
+
+// action returns the action enabled by the state and the input type, nil if none
+func (c *modelChecker) action(state ModelState, inputType string) *modelAction {
+	for _, a := range c.actions {
+		if a.enabled(state, inputType) {
+			return a
+		}
+	}
+	return nil
+}
+
+// cached returns the signature set an input of the type adds its signatures to
+func (c *modelChecker) cached(inputType string) string {
+	for _, a := range c.actions {
+		if a.inputType == inputType && a.accumulate != "" {
+			return a.accumulate
+		}
+	}
+	return ""
+}
+
+var modelSigFields = []string{"prepareSig", "commitSig", "impeachPrepareSig", "impeachCommitSig"}
+
+func modelSigs(v *ModelValidator, field string) []common.Address {
+	switch field {
+	case "prepareSig":
+		return v.PrepareSig
+	case "commitSig":
+		return v.CommitSig
+	case "impeachPrepareSig":
+		return v.ImpeachPrepareSig
+	case "impeachCommitSig":
+		return v.ImpeachCommitSig
+	}
+	return nil
+}
+
+// signed returns if local validator signed, it always does if it is not a validator
+func signed(v *ModelValidator, sigs []common.Address) bool {
+	return v.Sig == (common.Address{}) || containsAddress(sigs, v.Sig)
+}
+
+func isSubset(sub, set []common.Address) bool {
+	for _, addr := range sub {
+		if !containsAddress(set, addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// check returns an error if the step is not a legal step of the model
+func (c *modelChecker) check(step *ModelStep) error {
+	var (
+		pre    = &step.Pre
+		post   = &step.Post
+		action = c.action(pre.State, step.InputType)
+	)
+
+	// signature sets may only grow, and only those the step is about
+	grown := make(map[string]bool)
+	switch {
+	case pre.State != post.State && action != nil:
+		grown[action.accumulate], grown[action.sign] = true, true
+	case action != nil:
+		grown[action.accumulate] = true
+	default:
+		grown[c.cached(step.InputType)] = true
+	}
+	for _, field := range modelSigFields {
+		before, after := modelSigs(pre, field), modelSigs(post, field)
+		if !isSubset(before, after) {
+			return fmt.Errorf("%s is lost", field)
+		}
+		if len(after) != len(before) && !grown[field] {
+			return fmt.Errorf("%s grows by a %s", field, step.InputType)
+		}
+	}
+
+	if action == nil {
+		// no action is enabled, the model stutters
+		if pre.State != post.State {
+			return fmt.Errorf("no action takes a %s in state %d, got state %d", step.InputType, pre.State, post.State)
+		}
+		return nil
+	}
+
+	if pre.State == post.State {
+		// the action is taken without a transition if its certificate is not collected yet
+		if action.certificate == "" || c.satisfied(action.certificate, post) {
+			return fmt.Errorf("a %s in state %d transfers to state %d, got state %d", step.InputType, pre.State, action.target, post.State)
+		}
+		return nil
+	}
+
+	if post.State != action.target {
+		return fmt.Errorf("a %s in state %d transfers to state %d, got state %d", step.InputType, pre.State, action.target, post.State)
+	}
+	if action.certificate != "" && !c.satisfied(action.certificate, post) {
+		return fmt.Errorf("transfer to state %d without %s", post.State, action.certificate)
+	}
+	if action.sign != "" && !signed(post, modelSigs(post, action.sign)) {
+		return fmt.Errorf("transfer to state %d without signing %s", post.State, action.sign)
+	}
+	return nil
+}
+
+func TestModelConformance(t *testing.T) {
+	config := defaultSimConfig()
+	config.checkModel = true
+	net := newSimNetwork(t, config)
+	defer net.stop()
+
+	// crash a proposer to have impeachments
+	net.crash(net.proposerNodes()[1])
+
+	if err := net.waitForHeight(net.validatorNodes(), 4, simTimeout); err != nil {
+		t.Fatal(err)
+	}
+	net.checkSafety()
+
+	for _, node := range net.validatorNodes() {
+		node.model.lock.Lock()
+		steps := node.model.steps
+		node.model.lock.Unlock()
+
+		if len(steps) == 0 {
+			t.Fatalf("no step is recorded by node %s", node.addr.Hex())
+		}
+		for i, step := range steps {
+			if err := node.model.check(step); err != nil {
+				t.Errorf("step %d of node %s at #%d: %v: %v", i, node.addr.Hex(), step.Number, err, step)
+			}
+		}
+	}
+}
+
+func TestModelCheckerRejects(t *testing.T) {
+	c, err := newModelChecker(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.actions) != 6 || len(c.certificates) != 4 {
+		t.Fatalf("the model is not parsed, got %d actions and %d certificates", len(c.actions), len(c.certificates))
+	}
+
+	var (
+		self   = common.HexToAddress("0x01")
+		others = []common.Address{common.HexToAddress("0x02"), common.HexToAddress("0x03")}
+		all    = append([]common.Address{self}, others...)
+	)
+
+	illegal := []*ModelStep{
+		// commit without a prepare certificate
+		{InputType: "prepareMsg", Pre: ModelValidator{State: ModelPrepare, Sig: self}, Post: ModelValidator{State: ModelCommit, Sig: self, PrepareSig: []common.Address{self}, CommitSig: []common.Address{self}}},
+		// prepare without signing
+		{InputType: "block", Pre: ModelValidator{State: ModelIdle, Sig: self}, Post: ModelValidator{State: ModelPrepare, Sig: self, PrepareSig: others}},
+		// back to prepare from commit
+		{InputType: "block", Pre: ModelValidator{State: ModelCommit, Sig: self}, Post: ModelValidator{State: ModelPrepare, Sig: self, PrepareSig: []common.Address{self}}},
+		// next height without a commit certificate
+		{InputType: "commitMsg", Pre: ModelValidator{State: ModelCommit, Sig: self}, Post: ModelValidator{State: ModelNextHeight, Sig: self, CommitSig: others}},
+		// next height from a block committed without a certificate
+		{InputType: "block", Pre: ModelValidator{State: ModelPrepare, Sig: self}, Post: ModelValidator{State: ModelNextHeight, Sig: self}},
+		// staying in commit state with a commit certificate
+		{InputType: "commitMsg", Pre: ModelValidator{State: ModelCommit, Sig: self}, Post: ModelValidator{State: ModelCommit, Sig: self, CommitSig: all}},
+		// signatures added by a msg of another kind
+		{InputType: "prepareMsg", Pre: ModelValidator{State: ModelCommit, Sig: self}, Post: ModelValidator{State: ModelCommit, Sig: self, CommitSig: others}},
+		// impeach commit from idle state
+		{InputType: "impeachPrepareMsg", Pre: ModelValidator{State: ModelIdle, Sig: self}, Post: ModelValidator{State: ModelImpeachCommit, Sig: self, ImpeachPrepareSig: all, ImpeachCommitSig: []common.Address{self}}},
+		// impeach prepare from impeach commit state
+		{InputType: "impeachBlock", Pre: ModelValidator{State: ModelImpeachCommit, Sig: self}, Post: ModelValidator{State: ModelImpeachPrepare, Sig: self, ImpeachPrepareSig: []common.Address{self}}},
+	}
+	for i, step := range illegal {
+		if err := c.check(step); err == nil {
+			t.Errorf("illegal step %d is accepted: %v", i, step)
+		}
+	}
+
+	legal := []*ModelStep{
+		{InputType: "prepareMsg", Pre: ModelValidator{State: ModelPrepare, Sig: self, PrepareSig: []common.Address{self}}, Post: ModelValidator{State: ModelCommit, Sig: self, PrepareSig: all, CommitSig: []common.Address{self}}},
+		{InputType: "impeachPrepareMsg", Pre: ModelValidator{State: ModelImpeachPrepare, Sig: self, ImpeachPrepareSig: []common.Address{self}}, Post: ModelValidator{State: ModelImpeachCommit, Sig: self, ImpeachPrepareSig: all[:2], ImpeachCommitSig: []common.Address{self}}},
+		// late signatures are cached in the next height
+		{InputType: "commitMsg", Pre: ModelValidator{State: ModelNextHeight, Sig: self, CommitSig: all[:2]}, Post: ModelValidator{State: ModelNextHeight, Sig: self, CommitSig: all}},
+	}
+	for i, step := range legal {
+		if err := c.check(step); err != nil {
+			t.Errorf("legal step %d is rejected: %v: %v", i, err, step)
+		}
+	}
+}
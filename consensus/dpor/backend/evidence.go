@@ -0,0 +1,285 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package backend
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rlp"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	maxSignedRecords = 1024
+)
+
+var (
+	evidencePrefix = []byte("dpor-evidence-")
+)
+
+// EvidenceType is the kind of misbehavior an evidence proves
+type EvidenceType uint8
+
+const (
+	// ProposerEquivocation means a proposer proposed two different blocks at the same height
+	ProposerEquivocation EvidenceType = iota
+
+	// PrepareEquivocation means a validator signed two different prepare headers at the same height
+	PrepareEquivocation
+
+	// CommitEquivocation means a validator signed two different commit headers at the same height
+	CommitEquivocation
+)
+
+var (
+	evidenceTypeName = map[EvidenceType]string{
+		ProposerEquivocation: "proposer",
+		PrepareEquivocation:  "prepare",
+		CommitEquivocation:   "commit",
+	}
+)
+
+func (et EvidenceType) String() string {
+	if name, ok := evidenceTypeName[et]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (et EvidenceType) MarshalText() ([]byte, error) {
+	return []byte(et.String()), nil
+}
+
+// Evidence proves that a committee member signed two conflicting headers at
+// the same height, both headers carry the offender's signature.
+type Evidence struct {
+	Type     EvidenceType   `json:"type"`
+	Number   uint64         `json:"number"`
+	Offender common.Address `json:"offender"`
+	First    *types.Header  `json:"first"`
+	Second   *types.Header  `json:"second"`
+}
+
+// Hash returns the identifier of the evidence, it does not depend on the
+// order of the two conflicting headers.
+func (e *Evidence) Hash() (hash common.Hash) {
+	first, second := e.First.Hash(), e.Second.Hash()
+	if second.Big().Cmp(first.Big()) < 0 {
+		first, second = second, first
+	}
+
+	hasher := sha3.NewKeccak256()
+	rlp.Encode(hasher, []interface{}{e.Type, e.Number, e.Offender, first, second})
+	hasher.Sum(hash[:0])
+	return hash
+}
+
+// signedRecordKey identifies what a signer signed at a height
+type signedRecordKey struct {
+	number uint64
+	signer common.Address
+	typ    EvidenceType
+}
+
+// EvidencePool detects equivocations from received consensus msgs, persists
+// the evidences and notifies subscribers about new ones.
+type EvidencePool struct {
+	db database.Database
+
+	signed *lru.ARCCache // signedRecordKey -> first seen *types.Header
+	known  *lru.ARCCache // evidence hash -> struct{}
+	lock   sync.Mutex
+
+	feed  event.Feed
+	scope event.SubscriptionScope
+}
+
+// NewEvidencePool creates an evidence pool backed by the given database
+func NewEvidencePool(db database.Database) *EvidencePool {
+	signed, _ := lru.NewARC(maxSignedRecords)
+	known, _ := lru.NewARC(maxSignedRecords)
+
+	return &EvidencePool{
+		db:     db,
+		signed: signed,
+		known:  known,
+	}
+}
+
+// CheckProposer records the proposer of a preprepare block and returns an
+// evidence if the proposer proposed a different block at the same height
+func (ep *EvidencePool) CheckProposer(proposer common.Address, header *types.Header) *Evidence {
+	return ep.check(ProposerEquivocation, proposer, header)
+}
+
+// CheckSigners records the signers of a prepare or commit header and returns
+// evidences for those who signed a different header at the same height
+func (ep *EvidencePool) CheckSigners(signers []common.Address, header *types.Header, state consensus.State) []*Evidence {
+	var typ EvidenceType
+	switch state {
+	case consensus.Prepare:
+		typ = PrepareEquivocation
+	case consensus.Commit:
+		typ = CommitEquivocation
+	default:
+		return nil
+	}
+
+	var evidences []*Evidence
+	for _, signer := range signers {
+		if ev := ep.check(typ, signer, header); ev != nil {
+			evidences = append(evidences, ev)
+		}
+	}
+	return evidences
+}
+
+func (ep *EvidencePool) check(typ EvidenceType, signer common.Address, header *types.Header) *Evidence {
+	if ep == nil || header == nil {
+		return nil
+	}
+
+	key := signedRecordKey{
+		number: header.Number.Uint64(),
+		signer: signer,
+		typ:    typ,
+	}
+
+	ep.lock.Lock()
+	defer ep.lock.Unlock()
+
+	first, ok := ep.signed.Get(key)
+	if !ok {
+		ep.signed.Add(key, types.CopyHeader(header))
+		return nil
+	}
+
+	firstHeader := first.(*types.Header)
+	if firstHeader.Hash() == header.Hash() {
+		return nil
+	}
+
+	return &Evidence{
+		Type:     typ,
+		Number:   key.number,
+		Offender: signer,
+		First:    types.CopyHeader(firstHeader),
+		Second:   types.CopyHeader(header),
+	}
+}
+
+// Add persists an evidence and sends it to subscribers, it ignores known
+// evidences
+func (ep *EvidencePool) Add(ev *Evidence) error {
+	if ep == nil || ev == nil {
+		return nil
+	}
+
+	hash := ev.Hash()
+
+	ep.lock.Lock()
+	if ep.known.Contains(hash) {
+		ep.lock.Unlock()
+		return nil
+	}
+
+	evidences, err := ReadEvidences(ep.db, ev.Number)
+	if err != nil {
+		ep.lock.Unlock()
+		return err
+	}
+	for _, e := range evidences {
+		if e.Hash() == hash {
+			ep.known.Add(hash, struct{}{})
+			ep.lock.Unlock()
+			return nil
+		}
+	}
+
+	if err := writeEvidences(ep.db, ev.Number, append(evidences, ev)); err != nil {
+		ep.lock.Unlock()
+		return err
+	}
+	ep.known.Add(hash, struct{}{})
+	ep.lock.Unlock()
+
+	evidenceMeter().Mark(1)
+	log.Warn("detected equivocation", "type", ev.Type, "number", ev.Number, "offender", ev.Offender.Hex(), "first", ev.First.Hash().Hex(), "second", ev.Second.Hash().Hex())
+
+	ep.feed.Send(ev)
+	return nil
+}
+
+// Evidences returns all persisted evidences at the given height
+func (ep *EvidencePool) Evidences(number uint64) ([]*Evidence, error) {
+	ep.lock.Lock()
+	defer ep.lock.Unlock()
+
+	return ReadEvidences(ep.db, number)
+}
+
+// SubscribeEvidence registers a subscription for new evidences
+func (ep *EvidencePool) SubscribeEvidence(ch chan<- *Evidence) event.Subscription {
+	return ep.scope.Track(ep.feed.Subscribe(ch))
+}
+
+// Close unsubscribes all subscribers
+func (ep *EvidencePool) Close() {
+	ep.scope.Close()
+}
+
+func evidenceKey(number uint64) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	return append(append([]byte{}, evidencePrefix...), enc...)
+}
+
+// ReadEvidences reads all evidences at the given height from db
+func ReadEvidences(db database.Database, number uint64) ([]*Evidence, error) {
+	key := evidenceKey(number)
+	if has, err := db.Has(key); err != nil || !has {
+		return nil, err
+	}
+
+	blob, err := db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var evidences []*Evidence
+	if err := rlp.DecodeBytes(blob, &evidences); err != nil {
+		return nil, err
+	}
+	return evidences, nil
+}
+
+func writeEvidences(db database.Database, number uint64, evidences []*Evidence) error {
+	blob, err := rlp.EncodeToBytes(evidences)
+	if err != nil {
+		return err
+	}
+	return db.Put(evidenceKey(number), blob)
+}
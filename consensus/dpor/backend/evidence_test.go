@@ -0,0 +1,132 @@
+package backend
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func newEvidenceHeader(number int64, extra string) *types.Header {
+	return &types.Header{
+		Number: big.NewInt(number),
+		Extra:  []byte(extra),
+	}
+}
+
+func TestEvidencePool_CheckProposer(t *testing.T) {
+	pool := NewEvidencePool(database.NewMemDatabase())
+	proposer := common.HexToAddress("0x01")
+
+	first, second := newEvidenceHeader(1, "a"), newEvidenceHeader(1, "b")
+
+	if ev := pool.CheckProposer(proposer, first); ev != nil {
+		t.Fatalf("unexpected evidence for the first proposal: %v", ev)
+	}
+	if ev := pool.CheckProposer(proposer, first); ev != nil {
+		t.Fatalf("unexpected evidence for a repeated proposal: %v", ev)
+	}
+	if ev := pool.CheckProposer(proposer, newEvidenceHeader(2, "b")); ev != nil {
+		t.Fatalf("unexpected evidence for a proposal at another height: %v", ev)
+	}
+
+	ev := pool.CheckProposer(proposer, second)
+	if ev == nil {
+		t.Fatal("expected evidence for two proposals at the same height")
+	}
+	if ev.Type != ProposerEquivocation || ev.Number != 1 || ev.Offender != proposer {
+		t.Errorf("unexpected evidence: type %v, number %d, offender %s", ev.Type, ev.Number, ev.Offender.Hex())
+	}
+	if ev.First.Hash() != first.Hash() || ev.Second.Hash() != second.Hash() {
+		t.Errorf("evidence does not carry the conflicting headers")
+	}
+}
+
+func TestEvidencePool_CheckSigners(t *testing.T) {
+	pool := NewEvidencePool(database.NewMemDatabase())
+	honest, faulty := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+
+	pool.CheckSigners([]common.Address{honest, faulty}, newEvidenceHeader(1, "a"), consensus.Prepare)
+
+	// a commit signature does not conflict with a prepare signature
+	if evs := pool.CheckSigners([]common.Address{faulty}, newEvidenceHeader(1, "b"), consensus.Commit); len(evs) != 0 {
+		t.Fatalf("unexpected evidences across states: %v", evs)
+	}
+
+	evs := pool.CheckSigners([]common.Address{faulty}, newEvidenceHeader(1, "b"), consensus.Prepare)
+	if len(evs) != 1 {
+		t.Fatalf("evidences count mismatch: have %d, want 1", len(evs))
+	}
+	if evs[0].Type != PrepareEquivocation || evs[0].Offender != faulty {
+		t.Errorf("unexpected evidence: type %v, offender %s", evs[0].Type, evs[0].Offender.Hex())
+	}
+
+	// impeach states are not checked, failback impeach blocks legitimately differ
+	if evs := pool.CheckSigners([]common.Address{honest}, newEvidenceHeader(1, "c"), consensus.ImpeachPrepare); len(evs) != 0 {
+		t.Fatalf("unexpected evidences for impeach state: %v", evs)
+	}
+}
+
+func TestEvidencePool_AddAndSubscribe(t *testing.T) {
+	db := database.NewMemDatabase()
+	pool := NewEvidencePool(db)
+	defer pool.Close()
+
+	ch := make(chan *Evidence, 2)
+	sub := pool.SubscribeEvidence(ch)
+	defer sub.Unsubscribe()
+
+	ev := &Evidence{
+		Type:     CommitEquivocation,
+		Number:   3,
+		Offender: common.HexToAddress("0x03"),
+		First:    newEvidenceHeader(3, "a"),
+		Second:   newEvidenceHeader(3, "b"),
+	}
+	swapped := &Evidence{
+		Type:     ev.Type,
+		Number:   ev.Number,
+		Offender: ev.Offender,
+		First:    ev.Second,
+		Second:   ev.First,
+	}
+	if ev.Hash() != swapped.Hash() {
+		t.Fatal("evidence hash depends on header order")
+	}
+
+	for _, e := range []*Evidence{ev, ev, swapped} {
+		if err := pool.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case received := <-ch:
+		if received.Hash() != ev.Hash() {
+			t.Errorf("received evidence mismatch")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("evidence not delivered to subscriber")
+	}
+	select {
+	case <-ch:
+		t.Fatal("duplicate evidence delivered to subscriber")
+	default:
+	}
+
+	// a fresh pool on the same db sees the persisted evidence
+	evidences, err := NewEvidencePool(db).Evidences(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evidences) != 1 || evidences[0].Hash() != ev.Hash() {
+		t.Fatalf("persisted evidences mismatch: %v", evidences)
+	}
+	if evidences, _ := ReadEvidences(db, 4); len(evidences) != 0 {
+		t.Fatalf("unexpected evidences at height 4: %v", evidences)
+	}
+}
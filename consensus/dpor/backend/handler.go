@@ -17,6 +17,9 @@ import (
 
 const (
 	maxPendingBlocks = 16
+
+	// unknownAncestorsInterval is the interval to handle unknown ancestor blocks again
+	unknownAncestorsInterval = 100 * time.Millisecond
 )
 
 var (
@@ -53,6 +56,11 @@ type Handler struct {
 
 	broadcastRecord   *broadcastRecord
 	impeachmentRecord *impeachmentRecord
+
+	trace     *TraceRecorder // records consensus msgs if set
+	traceLock sync.Mutex
+
+	clock clock // source of time and asynchronous calls
 }
 
 // NewHandler creates a new Handler
@@ -70,6 +78,7 @@ func NewHandler(config *configs.DporConfig, coinbase common.Address, db database
 		quitCh:                make(chan struct{}),
 		broadcastRecord:       newBroadcastRecord(),
 		impeachmentRecord:     newImpeachmentRecord(),
+		clock:                 systemClock{},
 	}
 
 	// h.mode = LBFTMode
@@ -93,6 +102,13 @@ func (h *Handler) Start() {
 
 	// unknown ancestor block handler
 	go h.procUnknownAncestorsLoop()
+
+	// restore the state machine from wal and re-broadcast pending msgs
+	if h.fsm != nil {
+		if output, msgCode := h.fsm.Restore(); output != nil {
+			h.broadcastFSMOutput(output, BroadcastMsgAction, msgCode)
+		}
+	}
 }
 
 // Stop stops all
@@ -249,46 +265,52 @@ func (h *Handler) Available() bool {
 
 func (h *Handler) procUnknownAncestorsLoop() {
 	for {
-		for _, bi := range h.unknownAncestorBlocks.GetBlockIdentifiers() {
+		h.procUnknownAncestors()
 
-			// if less than current number, drop it!
-			blk := h.dpor.GetCurrentBlock()
-			if blk == nil {
-				continue
-			}
+		time.Sleep(unknownAncestorsInterval)
+	}
+}
 
-			if bi.number <= blk.NumberU64() {
+// procUnknownAncestors handles cached unknown ancestor blocks again, dropping
+// ones below the current block
+func (h *Handler) procUnknownAncestors() {
+	for _, bi := range h.unknownAncestorBlocks.GetBlockIdentifiers() {
 
-				h.unknownAncestorBlocks.RemoveBlock(bi)
-				log.Debug("unknown ancestor block's number is less than current number, drop it!", "number", bi.number, "hash", bi.hash.Hex())
+		// if less than current number, drop it!
+		blk := h.dpor.GetCurrentBlock()
+		if blk == nil {
+			continue
+		}
 
-				continue
-			}
+		if bi.number <= blk.NumberU64() {
 
-			// handle this unknown ancestor block!
-			block, err := h.unknownAncestorBlocks.GetBlock(bi)
-			if block != nil && err == nil {
-				var msg p2p.Msg
-				size, r, err := rlp.EncodeToReader(block)
-				if err != nil {
-					log.Warn("failed to encode unknown ancestor block", "err", err)
-					continue
-				}
+			h.unknownAncestorBlocks.RemoveBlock(bi)
+			log.Debug("unknown ancestor block's number is less than current number, drop it!", "number", bi.number, "hash", bi.hash.Hex())
 
-				if block.Impeachment() {
-					// impeach block
-					msg = p2p.Msg{Code: PreprepareImpeachBlockMsg, Size: uint32(size), Payload: r}
+			continue
+		}
 
-				} else {
-					// not impeach block
-					msg = p2p.Msg{Code: PreprepareBlockMsg, Size: uint32(size), Payload: r}
+		// handle this unknown ancestor block!
+		block, err := h.unknownAncestorBlocks.GetBlock(bi)
+		if block != nil && err == nil {
+			var msg p2p.Msg
+			size, r, err := rlp.EncodeToReader(block)
+			if err != nil {
+				log.Warn("failed to encode unknown ancestor block", "err", err)
+				continue
+			}
+
+			if block.Impeachment() {
+				// impeach block
+				msg = p2p.Msg{Code: PreprepareImpeachBlockMsg, Size: uint32(size), Payload: r}
 
-				}
+			} else {
+				// not impeach block
+				msg = p2p.Msg{Code: PreprepareBlockMsg, Size: uint32(size), Payload: r}
 
-				go h.handleLBFT2Msg(msg, nil)
 			}
-		}
 
-		time.Sleep(100 * time.Millisecond)
+			h.clock.Go(func() { h.handleLBFT2Msg(msg, nil) })
+		}
 	}
 }
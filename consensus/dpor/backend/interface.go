@@ -136,6 +136,10 @@ type ConsensusStateMachine interface {
 	Status() DSMStatus
 	Faulty() uint64
 	FSM(input *BlockOrHeader, msgCode MsgCode) ([]*BlockOrHeader, Action, MsgCode, error)
+
+	// Restore restores the state machine to its last state before a restart,
+	// and returns the pending msgs to re-broadcast
+	Restore() ([]*BlockOrHeader, MsgCode)
 }
 
 // DporService provides functions used by dpor handler
@@ -238,6 +242,9 @@ type DporService interface {
 	// addresses if one of the sigs are illegal
 	ECRecoverSigs(header *types.Header, state consensus.State) ([]common.Address, []types.DporSignature, error)
 
+	// AggregateSigs returns the header with its commit signatures aggregated if it is after the BLS fork
+	AggregateSigs(header *types.Header) (*types.Header, error)
+
 	// Update the signature to prepare signature cache(two kinds of sigs, one for prepared, another for final)
 	UpdatePrepareSigsCache(validator common.Address, hash common.Hash, sig types.DporSignature)
 
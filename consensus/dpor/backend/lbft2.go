@@ -46,11 +46,19 @@ type LBFT2 struct {
 
 	validateMsgMap *lru.ARCCache
 
+	evidence *EvidencePool
+
+	wal *WAL // write-ahead log of state transitions and signed headers
+
+	modelStepFn ModelStepFn // called with every step in terms of the TLA+ model if set
+
+	clock clock // source of time and asynchronous calls
+
 	preprepareReceiveTimestamp time.Time
 }
 
 // NewLBFT2 create an LBFT2 instance
-func NewLBFT2(faulty uint64, dpor DporService, handleImpeachBlock HandleGeneratedImpeachBlock, db database.Database) *LBFT2 {
+func NewLBFT2(faulty uint64, dpor DporService, handleImpeachBlock HandleGeneratedImpeachBlock, evidence *EvidencePool, db database.Database) *LBFT2 {
 
 	validateMap, _ := lru.NewARC(1000)
 
@@ -67,6 +75,12 @@ func NewLBFT2(faulty uint64, dpor DporService, handleImpeachBlock HandleGenerate
 		handleImpeachBlock: handleImpeachBlock,
 
 		validateMsgMap: validateMap,
+
+		evidence: evidence,
+
+		wal: NewWAL(db),
+
+		clock: systemClock{},
 	}
 
 	// try to failback if reboot
@@ -115,6 +129,63 @@ func (p *LBFT2) SetNumber(number uint64) {
 	p.number = number
 }
 
+// Restore restores the state machine from the WAL to its last state before a restart,
+// it returns the msgs to re-broadcast.
+func (p *LBFT2) Restore() ([]*BlockOrHeader, MsgCode) {
+	p.stateLock.Lock()
+	defer p.stateLock.Unlock()
+
+	number, state, msgCode, headers, ok := p.wal.LastTransition()
+	if !ok || number != p.number || len(headers) == 0 {
+		return nil, NoMsgCode
+	}
+
+	log.Info("restoring lbft2 state from wal", "number", number, "state", state, "msg code", msgCode.String())
+
+	states := statesOfMsgCode(msgCode)
+	if len(states) != len(headers) {
+		log.Warn("mismatched headers in wal", "number", number, "msg code", msgCode.String(), "headers", len(headers))
+		return nil, NoMsgCode
+	}
+
+	p.state = state
+
+	lbft2StateGauge().Update(int64(p.state))
+	lbft2NumberGauge().Update(int64(p.number))
+
+	// msgs with signatures not restored are not re-broadcast, peers would reject them
+	output := make([]*BlockOrHeader, 0, len(headers))
+	for i, header := range headers {
+		if err := p.refreshSignatures(header, states[i]); err != nil {
+			log.Warn("failed to restore signatures of header in wal, not re-broadcasting msgs", "number", number,
+				"hash", header.Hash().Hex(), "state", states[i], "err", err)
+			return nil, NoMsgCode
+		}
+		output = append(output, NewBOHFromHeader(header))
+	}
+	return output, msgCode
+}
+
+// statesOfMsgCode returns the states in which the headers of a msg are signed
+func statesOfMsgCode(msgCode MsgCode) []consensus.State {
+	switch msgCode {
+	case PrepareMsgCode:
+		return []consensus.State{consensus.Prepare}
+	case CommitMsgCode:
+		return []consensus.State{consensus.Commit}
+	case PrepareAndCommitMsgCode:
+		return []consensus.State{consensus.Prepare, consensus.Commit}
+	case ImpeachPrepareMsgCode:
+		return []consensus.State{consensus.ImpeachPrepare}
+	case ImpeachCommitMsgCode:
+		return []consensus.State{consensus.ImpeachCommit}
+	case ImpeachPrepareAndCommitMsgCode:
+		return []consensus.State{consensus.ImpeachPrepare, consensus.ImpeachCommit}
+	default:
+		return nil
+	}
+}
+
 // Status returns current states
 func (p *LBFT2) Status() DSMStatus {
 	return DSMStatus{
@@ -133,11 +204,34 @@ func (p *LBFT2) FSM(input *BlockOrHeader, msgCode MsgCode) ([]*BlockOrHeader, Ac
 
 	log.Debug("current status", "state", state, "number", number, "msg code", msgCode.String(), "input number", input.Number())
 
+	var step *ModelStep
+	if p.modelStepFn != nil {
+		step = &ModelStep{
+			Number:    input.Number(),
+			Hash:      input.Hash(),
+			InputType: modelInputTypes[msgCode],
+			Pre:       p.modelValidator(input, msgCode),
+		}
+	}
+	inputMsgCode := msgCode
+
 	output, action, msgCode, state, err := p.realFSM(input, msgCode, state)
 
 	if output != nil && action != NoAction && msgCode != NoMsgCode && err == nil {
 		p.state = state
 		p.number = output[0].Number()
+
+		// log the transition with the headers to broadcast, validate msgs are not logged
+		// as the block will be inserted into the chain
+		var headers []*types.Header
+		for _, o := range output {
+			if o.IsHeader() {
+				headers = append(headers, o.header)
+			}
+		}
+		if werr := p.wal.WriteTransition(p.number, p.state, msgCode, headers); werr != nil {
+			log.Warn("failed to write state transition to wal", "number", p.number, "state", p.state, "err", werr)
+		}
 	}
 
 	log.Debug("result state", "state", state, "number", number, "msg code", msgCode.String(), "action", action)
@@ -148,6 +242,14 @@ func (p *LBFT2) FSM(input *BlockOrHeader, msgCode MsgCode) ([]*BlockOrHeader, Ac
 		p.state = consensus.Idle
 	}
 
+	lbft2StateGauge().Update(int64(p.state))
+	lbft2NumberGauge().Update(int64(p.number))
+
+	if step != nil {
+		step.Post = p.modelValidator(input, inputMsgCode)
+		p.modelStepFn(step)
+	}
+
 	if p.state == consensus.Idle {
 		p.tryToImpeach()
 	}
@@ -161,6 +263,9 @@ func (p *LBFT2) FSM(input *BlockOrHeader, msgCode MsgCode) ([]*BlockOrHeader, Ac
 		return output, action, msgCode, nil
 
 	default:
+		if err != nil {
+			lbft2ErrorMeter().Mark(1)
+		}
 		return output, action, msgCode, err
 	}
 }
@@ -173,6 +278,9 @@ func (p *LBFT2) realFSM(input *BlockOrHeader, msgCode MsgCode, state consensus.S
 
 	_, _ = hash, number
 
+	// record evidences if the msg conflicts with a msg signed by the same signer
+	p.detectEquivocation(input, msgCode)
+
 	// if already in chain, do nothing
 	if p.dpor.HasBlockInChain(hash, number) {
 		return nil, NoAction, NoMsgCode, state, ErrBlockAlreadyInChain
@@ -227,7 +335,7 @@ func (p *LBFT2) IdleHandler(input *BlockOrHeader, msgCode MsgCode, state consens
 
 		log.Debug("IdleHandler to call handlePreprepareMsg")
 
-		p.preprepareReceiveTimestamp = time.Now()
+		p.preprepareReceiveTimestamp = p.clock.Now()
 
 		return p.handlePreprepareMsg(input, state, func(block *types.Block) error {
 
@@ -311,7 +419,7 @@ func (p *LBFT2) ImpeachHandler(input *BlockOrHeader, msgCode MsgCode, state cons
 
 		log.Debug("ImpeachHandler to call handleImpeachPreprepareMsg")
 
-		p.preprepareReceiveTimestamp = time.Now()
+		p.preprepareReceiveTimestamp = p.clock.Now()
 
 		return p.handleImpeachPreprepareMsg(input, state, func(block *types.Block) error {
 
@@ -439,8 +547,8 @@ func (p *LBFT2) handlePreprepareMsg(input *BlockOrHeader, state consensus.State,
 
 	parent := p.dpor.GetBlockFromChain(block.ParentHash(), block.NumberU64()-1)
 	// if received a preprepare msg, and current time is after parent.timestamp+period+blockDelay, drop it!
-	if parent != nil && time.Now().After(parent.Timestamp().Add(p.dpor.Period()).Add(p.dpor.BlockDelay())) {
-		log.Debug("current time is after parent + period + blockdelay", "number", number, "hash", hash.Hex(), "time.now", time.Now(), "parent timestamp", parent.Timestamp())
+	if now := p.clock.Now(); parent != nil && now.After(parent.Timestamp().Add(p.dpor.Period()).Add(p.dpor.BlockDelay())) {
+		log.Debug("current time is after parent + period + blockdelay", "number", number, "hash", hash.Hex(), "time.now", now, "parent timestamp", parent.Timestamp())
 		return nil, NoAction, NoMsgCode, state, nil
 	}
 
@@ -457,7 +565,10 @@ func (p *LBFT2) handlePreprepareMsg(input *BlockOrHeader, state consensus.State,
 		bi := NewBlockIdentifier(number, hash)
 
 		// compose prepare msg
-		prepareHeader, _ := p.composePrepareMsg(block)
+		prepareHeader, err := p.composePrepareMsg(block)
+		if err == ErrConflictingSign {
+			return nil, NoAction, NoMsgCode, state, err
+		}
 
 		// if prepare certificate is satisfied
 		if p.prepareCertificate(bi) {
@@ -478,7 +589,7 @@ func (p *LBFT2) handlePreprepareMsg(input *BlockOrHeader, state consensus.State,
 
 		log.Debug("verified the block, there is an error", "error", err, "number", number, "hash", hash.Hex())
 
-		go p.unknownAncestorBlockHandler(block)
+		p.clock.Go(func() { p.unknownAncestorBlockHandler(block) })
 
 		return nil, NoAction, NoMsgCode, state, err
 
@@ -544,7 +655,7 @@ func (p *LBFT2) handleImpeachPreprepareMsg(input *BlockOrHeader, state consensus
 
 		log.Debug("verified the block, there is an error", "error", err)
 
-		go p.unknownAncestorBlockHandler(block)
+		p.clock.Go(func() { p.unknownAncestorBlockHandler(block) })
 
 		return nil, NoAction, NoMsgCode, state, err
 
@@ -564,10 +675,20 @@ func (p *LBFT2) composePrepareMsg(block *types.Block) (*types.Header, error) {
 		hash   = header.Hash()
 	)
 
+	// never sign a header conflicting with a signed one at the same height
+	if err := p.wal.CheckSign(number, consensus.Prepare, hash, header.ParentHash); err != nil {
+		return header, err
+	}
+
 	// sign the header with prepare state prefix
 	switch err := p.dpor.SignHeader(header, consensus.Prepare); err {
 	case nil:
 
+		// record the signed header before it is broadcast
+		if err := p.wal.WriteSign(number, consensus.Prepare, hash, header.ParentHash); err != nil {
+			return header, err
+		}
+
 		_ = p.refreshSignatures(header, consensus.Prepare)
 
 		log.Debug("succeed to sign the proposed block", "number", number, "hash", hash.Hex())
@@ -589,10 +710,20 @@ func (p *LBFT2) composeImpeachPrepareMsg(block *types.Block) (*types.Header, err
 		hash   = header.Hash()
 	)
 
+	// never sign a header conflicting with a signed one at the same height
+	if err := p.wal.CheckSign(number, consensus.ImpeachPrepare, hash, header.ParentHash); err != nil {
+		return header, err
+	}
+
 	// sign the header with impeach prepare state prefix
 	switch err := p.dpor.SignHeader(header, consensus.ImpeachPrepare); err {
 	case nil:
 
+		// record the signed header before it is broadcast
+		if err := p.wal.WriteSign(number, consensus.ImpeachPrepare, hash, header.ParentHash); err != nil {
+			return header, err
+		}
+
 		_ = p.refreshSignatures(header, consensus.ImpeachPrepare)
 
 		log.Debug("succeed to sign the proposed impeach block", "number", number, "hash", hash.Hex())
@@ -683,10 +814,20 @@ func (p *LBFT2) composeCommitMsg(h *types.Header) (*types.Header, error) {
 		hash   = header.Hash()
 	)
 
+	// never sign a header conflicting with a signed one at the same height
+	if err := p.wal.CheckSign(number, consensus.Commit, hash, header.ParentHash); err != nil {
+		return header, err
+	}
+
 	// prepare certificate is satisfied, sign the block with commit state
 	switch err := p.dpor.SignHeader(header, consensus.Commit); err {
 	case nil:
 
+		// record the signed header before it is broadcast
+		if err := p.wal.WriteSign(number, consensus.Commit, hash, header.ParentHash); err != nil {
+			return header, err
+		}
+
 		// refresh signatures both in the header and local signatures cache
 		_ = p.refreshSignatures(header, consensus.Commit)
 
@@ -710,10 +851,20 @@ func (p *LBFT2) composeImpeachCommitMsg(h *types.Header) (*types.Header, error)
 		hash   = header.Hash()
 	)
 
+	// never sign a header conflicting with a signed one at the same height
+	if err := p.wal.CheckSign(number, consensus.ImpeachCommit, hash, header.ParentHash); err != nil {
+		return header, err
+	}
+
 	// impeach prepare certificate is satisfied, sign the block with impeach commit state
 	switch err := p.dpor.SignHeader(header, consensus.ImpeachCommit); err {
 	case nil:
 
+		// record the signed header before it is broadcast
+		if err := p.wal.WriteSign(number, consensus.ImpeachCommit, hash, header.ParentHash); err != nil {
+			return header, err
+		}
+
 		// refresh signatures both in the header and local signatures cache
 		_ = p.refreshSignatures(header, consensus.ImpeachCommit)
 
@@ -821,6 +972,12 @@ func (p *LBFT2) composeValidateMsg(header *types.Header) (*types.Block, error) {
 		return nil, err
 	}
 
+	// aggregate commit signatures after the BLS fork
+	header, err = p.dpor.AggregateSigs(header)
+	if err != nil {
+		return nil, err
+	}
+
 	log.Debug("broadcasting the composed validate block to other validators...", "number", number, "hash", hash.Hex())
 
 	return block.WithSeal(header), nil
@@ -856,9 +1013,9 @@ func (p *LBFT2) handleValidateMsg(input *BlockOrHeader, state consensus.State) (
 
 	err := p.dpor.InsertChain(block)
 	if err == nil {
-		go p.dpor.BroadcastBlock(block, true)
+		p.clock.Go(func() { p.dpor.BroadcastBlock(block, true) })
 
-		log.Debug("finished lbft2 consensus about the block", "number", block.NumberU64(), "hash", block.Hash().Hex(), "elapsed", common.PrettyDuration(time.Since(p.preprepareReceiveTimestamp)))
+		log.Debug("finished lbft2 consensus about the block", "number", block.NumberU64(), "hash", block.Hash().Hex(), "elapsed", common.PrettyDuration(p.clock.Now().Sub(p.preprepareReceiveTimestamp)))
 
 		return []*BlockOrHeader{NewBOHFromBlock(block)}, BroadcastMsgAction, ValidateMsgCode, consensus.Idle, nil
 	}
@@ -899,9 +1056,9 @@ func (p *LBFT2) handleImpeachValidateMsg(input *BlockOrHeader, state consensus.S
 
 	err := p.dpor.InsertChain(block)
 	if err == nil {
-		go p.dpor.BroadcastBlock(block, true)
+		p.clock.Go(func() { p.dpor.BroadcastBlock(block, true) })
 
-		log.Debug("finished lbft2 consensus about the impeach block", "number", block.NumberU64(), "hash", block.Hash().Hex(), "elapsed", common.PrettyDuration(time.Since(p.preprepareReceiveTimestamp)))
+		log.Debug("finished lbft2 consensus about the impeach block", "number", block.NumberU64(), "hash", block.Hash().Hex(), "elapsed", common.PrettyDuration(p.clock.Now().Sub(p.preprepareReceiveTimestamp)))
 
 		return []*BlockOrHeader{NewBOHFromBlock(block)}, BroadcastMsgAction, ImpeachValidateMsgCode, consensus.Idle, nil
 	}
@@ -972,7 +1129,10 @@ func (p *LBFT2) oncePrepareCertificateSatisfied(prepareHeader *types.Header) ([]
 
 	// compose commit msg
 	commitHeader := types.CopyHeader(prepareHeader)
-	commitHeader, _ = p.composeCommitMsg(commitHeader)
+	commitHeader, err := p.composeCommitMsg(commitHeader)
+	if err == ErrConflictingSign {
+		return nil, NoAction, NoMsgCode, p.state, err
+	}
 
 	// if commit certificate is satisfied
 	if p.commitCertificate(bi) {
@@ -1054,7 +1214,7 @@ func (p *LBFT2) unknownAncestorBlockHandler(block *types.Block) {
 	// if term is larger than local, sync!
 	if p.dpor.TermOf(number) > p.dpor.TermOf(p.number) {
 		log.Debug("handling unknown ancestor block, term large than current, syncing", "number", number, "hash", block.Hash().Hex(), "lbft.number", p.number)
-		go p.dpor.Synchronize()
+		p.clock.Go(p.dpor.Synchronize)
 	}
 
 	// recover proposer's address
@@ -1079,6 +1239,72 @@ func (p *LBFT2) unknownAncestorBlockHandler(block *types.Block) {
 	}
 }
 
+// detectEquivocation checks if the proposer or signers of the msg have signed
+// a different block at the same height, and adds the evidences to the pool
+func (p *LBFT2) detectEquivocation(input *BlockOrHeader, msgCode MsgCode) {
+	if p.evidence == nil {
+		return
+	}
+
+	var evidences []*Evidence
+	switch msgCode {
+	case PreprepareMsgCode:
+		if !input.IsBlock() || input.block.Impeachment() {
+			return
+		}
+
+		header := input.block.Header()
+		proposer, err := p.dpor.ECRecoverProposer(header)
+		if err != nil {
+			return
+		}
+
+		if ev := p.evidence.CheckProposer(proposer, header); ev != nil {
+			evidences = append(evidences, ev)
+		}
+
+	case PrepareMsgCode, CommitMsgCode:
+		if !input.IsHeader() {
+			return
+		}
+
+		state := consensus.Prepare
+		if msgCode == CommitMsgCode {
+			state = consensus.Commit
+		}
+
+		signers, _, err := p.dpor.ECRecoverSigs(input.header, state)
+		if err != nil {
+			return
+		}
+
+		// only signatures of validators count, others are signatures of
+		// another state left in the header
+		validators, err := p.dpor.ValidatorsOf(input.Number())
+		if err != nil {
+			return
+		}
+
+		var committee []common.Address
+		for _, s := range signers {
+			for _, v := range validators {
+				if s == v {
+					committee = append(committee, s)
+					break
+				}
+			}
+		}
+
+		evidences = p.evidence.CheckSigners(committee, input.header, state)
+	}
+
+	for _, ev := range evidences {
+		if err := p.evidence.Add(ev); err != nil {
+			log.Warn("failed to add evidence", "number", ev.Number, "offender", ev.Offender.Hex(), "err", err)
+		}
+	}
+}
+
 func (p *LBFT2) tryToImpeach() {
 	log.Debug("try to start impeachment process")
 
@@ -1088,13 +1314,17 @@ func (p *LBFT2) tryToImpeach() {
 
 	if impeachBlock, err := p.dpor.CreateImpeachBlock(); impeachBlock != nil && impeachBlock.NumberU64() != failbackNumber && err == nil {
 
-		time.AfterFunc(
-			func() time.Duration {
-				return impeachBlock.Timestamp().Sub(time.Now())
-			}(),
+		// the impeach timeout adapts to recent impeachments, see Dpor.ImpeachTimeout
+		timeout := p.dpor.ImpeachTimeout()
+		lbft2ImpeachTimeoutGauge().Update(int64(timeout / time.Millisecond))
+		log.Debug("impeach timer is set", "number", impeachBlock.NumberU64(), "timeout", timeout, "timestamp", impeachBlock.Timestamp())
+
+		p.clock.AfterFunc(
+			impeachBlock.Timestamp().Sub(p.clock.Now()),
 			func() {
 				currentBlock := p.dpor.GetCurrentBlock()
 				if currentBlock != nil && impeachBlock.NumberU64() > currentBlock.NumberU64() {
+					lbft2ImpeachMeter().Mark(1)
 					p.handleImpeachBlock(impeachBlock)
 				}
 			})
@@ -1113,8 +1343,8 @@ func (p *LBFT2) tryToImpeachFailback() {
 		p.failbackNumber = firstImpeach.NumberU64()
 		p.lock.Unlock()
 
-		go time.AfterFunc(
-			firstImpeach.Timestamp().Sub(time.Now()),
+		p.clock.AfterFunc(
+			firstImpeach.Timestamp().Sub(p.clock.Now()),
 			func() {
 				currentBlock := p.dpor.GetCurrentBlock()
 				if currentBlock != nil && firstImpeach.NumberU64() > currentBlock.NumberU64() {
@@ -1122,8 +1352,8 @@ func (p *LBFT2) tryToImpeachFailback() {
 				}
 			})
 
-		go time.AfterFunc(
-			secondImpeach.Timestamp().Sub(time.Now()),
+		p.clock.AfterFunc(
+			secondImpeach.Timestamp().Sub(p.clock.Now()),
 			func() {
 				currentBlock := p.dpor.GetCurrentBlock()
 				if currentBlock != nil && secondImpeach.NumberU64() > currentBlock.NumberU64() {
@@ -1 +1,147 @@
 package backend
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// simTimeout is the timeout of simulations on their virtual clock
+const simTimeout = 20 * time.Second
+
+func TestLBFT2Simulation_Normal(t *testing.T) {
+	net := newSimNetwork(t, defaultSimConfig())
+	defer net.stop()
+
+	if err := net.waitForHeight(net.nodes, 6, simTimeout); err != nil {
+		t.Fatal(err)
+	}
+	net.checkSafety()
+
+	for _, block := range net.validatorNodes()[0].dpor.blocks()[1:] {
+		if block.Impeachment() {
+			t.Errorf("block #%d is an impeachment block in a fault free network", block.NumberU64())
+		}
+	}
+}
+
+func TestLBFT2Simulation_DelayAndReorder(t *testing.T) {
+	net := newSimNetwork(t, defaultSimConfig())
+	defer net.stop()
+
+	net.addRule(&simRule{delay: 10 * time.Millisecond, jitter: 40 * time.Millisecond})
+
+	if err := net.waitForHeight(net.validatorNodes(), 6, simTimeout); err != nil {
+		t.Fatal(err)
+	}
+	net.checkSafety()
+}
+
+func TestLBFT2Simulation_DropMessages(t *testing.T) {
+	net := newSimNetwork(t, defaultSimConfig())
+	defer net.stop()
+
+	net.addRule(&simRule{
+		codes: []uint64{PrepareHeaderMsg, CommitHeaderMsg, ValidateBlockMsg},
+		drop:  0.3,
+	})
+
+	if err := net.waitForHeight(net.validatorNodes(), 6, simTimeout); err != nil {
+		t.Fatal(err)
+	}
+	net.checkSafety()
+}
+
+func TestLBFT2Simulation_CrashedProposer(t *testing.T) {
+	net := newSimNetwork(t, defaultSimConfig())
+	defer net.stop()
+
+	// the proposer of block #2 never proposes it
+	net.crash(net.proposerOf(2))
+
+	validators := net.validatorNodes()
+	if err := net.waitForHeight(validators, 3, simTimeout); err != nil {
+		t.Fatal(err)
+	}
+	net.checkSafety()
+
+	for _, v := range validators {
+		if block := v.dpor.blocks()[2]; !block.Impeachment() {
+			t.Errorf("validator %s committed block #2 %s, want an impeachment block", v.addr.Hex(), block.Hash().Hex())
+		}
+	}
+}
+
+func TestLBFT2Simulation_CrashedValidator(t *testing.T) {
+	net := newSimNetwork(t, defaultSimConfig())
+	defer net.stop()
+
+	// the committee tolerates one faulty validator
+	validators := net.validatorNodes()
+	net.crash(validators[0])
+
+	if err := net.waitForHeight(validators[1:], 5, simTimeout); err != nil {
+		t.Fatal(err)
+	}
+	net.checkSafety()
+}
+
+func TestLBFT2Simulation_Partition(t *testing.T) {
+	net := newSimNetwork(t, defaultSimConfig())
+	defer net.stop()
+
+	validators := net.validatorNodes()
+	if err := net.waitForHeight(validators, 1, simTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	// neither side has a quorum for a normal block, both sides impeach
+	net.partition(append(net.proposerNodes(), validators[:2]...), validators[2:])
+	if err := net.waitForHeight(validators, 3, simTimeout); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range validators {
+		for _, block := range v.dpor.blocks()[2:] {
+			if !block.Impeachment() {
+				t.Errorf("validator %s committed normal block #%d during partition", v.addr.Hex(), block.NumberU64())
+			}
+		}
+	}
+
+	net.heal()
+	if err := net.waitForHeight(net.nodes, 6, simTimeout); err != nil {
+		t.Fatal(err)
+	}
+	net.checkSafety()
+}
+
+func TestLBFT2Simulation_Deterministic(t *testing.T) {
+	run := func() (uint64, []common.Hash) {
+		net := newSimNetwork(t, defaultSimConfig())
+		defer net.stop()
+
+		net.addRule(&simRule{delay: 10 * time.Millisecond, jitter: 40 * time.Millisecond})
+		net.addRule(&simRule{codes: []uint64{PrepareHeaderMsg, CommitHeaderMsg}, drop: 0.2})
+		net.crash(net.proposerOf(3))
+
+		if err := net.waitForHeight(net.validatorNodes(), 6, simTimeout); err != nil {
+			t.Fatal(err)
+		}
+		net.checkSafety()
+
+		var hashes []common.Hash
+		for _, block := range net.validatorNodes()[0].dpor.blocks() {
+			hashes = append(hashes, block.Hash())
+		}
+		return net.delivered.Sum64(), hashes
+	}
+
+	digest, chain := run()
+	for i := 0; i < 2; i++ {
+		if d, c := run(); d != digest || !reflect.DeepEqual(c, chain) {
+			t.Fatalf("run %d with the same seed diverged, digest %x != %x", i+1, d, digest)
+		}
+	}
+}
@@ -120,6 +120,27 @@ func (sc *signaturesForBlockCaches) addSignatureFor(bi BlockIdentifier, signer c
 	log.Debug("saved signatures to db", "number", bi.number, "hash", bi.hash.Hex())
 }
 
+// getSignersOf returns the signers of cached signatures for given block identifier
+func (sc *signaturesForBlockCaches) getSignersOf(bi BlockIdentifier) []common.Address {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+
+	sigs, ok := sc.signaturesForBlocks.Get(bi)
+	if sigs == nil || !ok {
+		return nil
+	}
+
+	sb := sigs.(*signaturesOfBlock)
+	sb.lock.RLock()
+	defer sb.lock.RUnlock()
+
+	signers := make([]common.Address, 0, len(sb.signatures))
+	for signer := range sb.signatures {
+		signers = append(signers, signer)
+	}
+	return signers
+}
+
 func (sc *signaturesForBlockCaches) getSignatureFor(bi BlockIdentifier, signer common.Address) (types.DporSignature, bool) {
 	sc.lock.RLock()
 	defer sc.lock.RUnlock()
@@ -0,0 +1,33 @@
+package backend
+
+import (
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// Metrics are registered on first use rather than at package initialization,
+// which happens before the command line enables the metrics system.
+
+func lbft2StateGauge() metrics.Gauge {
+	return metrics.GetOrRegisterGauge("dpor/lbft2/state", nil)
+}
+
+func lbft2NumberGauge() metrics.Gauge {
+	return metrics.GetOrRegisterGauge("dpor/lbft2/number", nil)
+}
+
+func lbft2ImpeachMeter() metrics.Meter {
+	return metrics.GetOrRegisterMeter("dpor/lbft2/impeach", nil)
+}
+
+// lbft2ImpeachTimeoutGauge is the impeach timeout in milliseconds
+func lbft2ImpeachTimeoutGauge() metrics.Gauge {
+	return metrics.GetOrRegisterGauge("dpor/lbft2/impeach/timeout", nil)
+}
+
+func lbft2ErrorMeter() metrics.Meter {
+	return metrics.GetOrRegisterMeter("dpor/lbft2/errors", nil)
+}
+
+func evidenceMeter() metrics.Meter {
+	return metrics.GetOrRegisterMeter("dpor/evidence", nil)
+}
@@ -0,0 +1,1054 @@
+package backend
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// This file implements an in-memory simulation of a dpor committee. Every
+// simulated node owns a real Handler, Dialer and LBFT2 state machine, and
+// only the DporService and the p2p links are replaced. Messages written to a
+// link are scheduled on a single network clock, where tests can delay, drop,
+// reorder or partition them, or crash nodes altogether.
+//
+// The simulation is deterministic. Handlers and state machines of all nodes run
+// on one virtual clock in the goroutine of the test: their timers and
+// asynchronous calls are events of an ordered queue, and so are messages, which
+// are written to the links by draining the send queues of remote validators in
+// a fixed order after each event. Keys of nodes and the fate of each message are
+// derived from the seed, so a given seed replays the same run, and the virtual
+// clock makes it as fast as the machine allows.
+
+const (
+	// simBlockMsg is the code used to propagate committed blocks among
+	// simulated nodes, standing in for the cpc protocol's block broadcast.
+	simBlockMsg = 0xff
+
+	simExtraSeal = 65
+
+	// simProposeInterval is the interval proposers check if they are in
+	// charge of the next height
+	simProposeInterval = 5 * time.Millisecond
+)
+
+var (
+	errSimNodeStopped   = errors.New("simulated node stopped")
+	errSimNotValidator  = errors.New("not a validator of the simulated committee")
+	errSimDoubleSign    = errors.New("refused to sign a second block at the same height")
+	errSimNotEnoughSigs = errors.New("not enough commit signatures in block")
+
+	// simEpoch is the time the virtual clock starts at
+	simEpoch = time.Unix(1546300800, 0)
+)
+
+// simConfig configures a simulated committee.
+type simConfig struct {
+	proposers      int
+	faulty         uint64
+	period         time.Duration
+	impeachTimeout time.Duration
+	seed           int64
+
+	// traceDir is the directory validators record their consensus traces
+	// into, named by their addresses, nothing is recorded if empty
+	traceDir string
+
+	// checkModel records steps of validators' state machines to check them
+	// against the TLA+ model
+	checkModel bool
+}
+
+func defaultSimConfig() simConfig {
+	return simConfig{
+		proposers:      3,
+		faulty:         1,
+		period:         200 * time.Millisecond,
+		impeachTimeout: 400 * time.Millisecond,
+		seed:           1,
+	}
+}
+
+// simRule describes a fault applied to messages matching it. Zero addresses
+// and an empty code list match everything.
+type simRule struct {
+	from  common.Address
+	to    common.Address
+	codes []uint64
+
+	drop   float64       // probability to drop a matched message
+	delay  time.Duration // fixed delay added to a matched message
+	jitter time.Duration // random delay in [0, jitter), reorders messages
+}
+
+func (r *simRule) match(from, to common.Address, code uint64) bool {
+	if r.from != (common.Address{}) && r.from != from {
+		return false
+	}
+	if r.to != (common.Address{}) && r.to != to {
+		return false
+	}
+	if len(r.codes) == 0 {
+		return true
+	}
+	for _, c := range r.codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// simEvent is a call scheduled on the virtual clock, events at the same time
+// are run in the order they are scheduled.
+type simEvent struct {
+	at  time.Time
+	seq uint64
+	fn  func()
+}
+
+type simEventQueue []*simEvent
+
+func (q simEventQueue) Len() int { return len(q) }
+func (q simEventQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+func (q simEventQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *simEventQueue) Push(x interface{}) { *q = append(*q, x.(*simEvent)) }
+func (q *simEventQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// simClock is the virtual clock of a simulated network, implementing clock.
+// It is only used in the goroutine running the network.
+type simClock struct {
+	now   time.Time
+	seq   uint64
+	queue simEventQueue
+}
+
+func newSimClock() *simClock {
+	return &simClock{now: simEpoch}
+}
+
+// Now implements clock.
+func (c *simClock) Now() time.Time { return c.now }
+
+// AfterFunc implements clock, f is called when the clock reaches d later.
+func (c *simClock) AfterFunc(d time.Duration, f func()) {
+	if d < 0 {
+		d = 0
+	}
+	c.seq++
+	heap.Push(&c.queue, &simEvent{at: c.now.Add(d), seq: c.seq, fn: f})
+}
+
+// Go implements clock, f is called after the events scheduled before.
+func (c *simClock) Go(f func()) { c.AfterFunc(0, f) }
+
+// next runs the next event no later than deadline, and returns false if there
+// is none.
+func (c *simClock) next(deadline time.Time) bool {
+	if len(c.queue) == 0 || c.queue[0].at.After(deadline) {
+		return false
+	}
+	e := heap.Pop(&c.queue).(*simEvent)
+	c.now = e.at
+	e.fn()
+	return true
+}
+
+// simNetwork connects simulated nodes and schedules their messages.
+type simNetwork struct {
+	t      *testing.T
+	config simConfig
+	clock  *simClock
+
+	dporConfig *configs.DporConfig
+	genesis    *types.Block
+
+	nodes      []*simNode
+	proposers  []common.Address
+	validators []common.Address
+
+	rules     []*simRule
+	groups    map[common.Address]int
+	stopped   bool
+	commits   map[uint64]common.Hash
+	conflicts []string
+
+	// delivered is the digest of delivered messages in order, runs with the
+	// same seed have the same digest
+	delivered hash.Hash64
+}
+
+// newSimNetwork creates a committee with config.proposers proposers and
+// 3*config.faulty+1 validators, wires every node to the others and starts
+// them on the virtual clock.
+func newSimNetwork(t *testing.T, config simConfig) *simNetwork {
+	n := &simNetwork{
+		t:         t,
+		config:    config,
+		clock:     newSimClock(),
+		commits:   make(map[uint64]common.Hash),
+		delivered: fnv.New64a(),
+	}
+
+	n.dporConfig = &configs.DporConfig{
+		Period:         uint64(config.period / time.Millisecond),
+		TermLen:        uint64(config.proposers),
+		ViewLen:        1,
+		FaultyNumber:   config.faulty,
+		ImpeachTimeout: config.impeachTimeout,
+	}
+
+	for i := 0; i < config.proposers; i++ {
+		node := newSimNode(n, len(n.nodes), true, false)
+		n.nodes = append(n.nodes, node)
+		n.proposers = append(n.proposers, node.addr)
+	}
+	for i := uint64(0); i < n.dporConfig.ValidatorsLen(); i++ {
+		node := newSimNode(n, len(n.nodes), false, true)
+		n.nodes = append(n.nodes, node)
+		n.validators = append(n.validators, node.addr)
+	}
+
+	header := &types.Header{
+		Number: big.NewInt(0),
+		Extra:  make([]byte, simExtraSeal),
+	}
+	header.SetTimestamp(n.clock.Now())
+	header.Dpor.Proposers = n.proposers
+	header.Dpor.Validators = n.validators
+	n.genesis = types.NewBlock(header, nil, nil)
+
+	for _, node := range n.nodes {
+		node.dpor.chain = []*types.Block{n.genesis}
+	}
+
+	// every node talks to all validators, validators also talk to proposers
+	for _, node := range n.nodes {
+		for _, remote := range n.nodes {
+			if node == remote {
+				continue
+			}
+			link := &simLink{net: n, from: node, to: remote}
+			if remote.isValidator {
+				rv := NewRemoteValidator(remote.addr)
+				rv.SetPeer(ProtocolVersion, remote.peer, link)
+				node.handler.dialer.setValidator(remote.addr.Hex(), rv)
+				node.remoteValidators = append(node.remoteValidators, rv)
+			}
+			if remote.isProposer && node.isValidator {
+				rp := NewRemoteProposer(remote.addr)
+				rp.SetPeer(ProtocolVersion, remote.peer, link)
+				node.handler.dialer.setProposer(remote.addr.Hex(), rp)
+			}
+		}
+	}
+
+	for _, node := range n.nodes {
+		node.start()
+	}
+	return n
+}
+
+// addRule installs a fault rule for all messages sent from now on.
+func (n *simNetwork) addRule(rule *simRule) {
+	n.rules = append(n.rules, rule)
+}
+
+// clearRules removes all installed fault rules.
+func (n *simNetwork) clearRules() {
+	n.rules = nil
+}
+
+// partition splits the network into the given groups, nodes not listed in any
+// group are put together in one more group.
+func (n *simNetwork) partition(groups ...[]*simNode) {
+	n.groups = make(map[common.Address]int)
+	for i, group := range groups {
+		for _, node := range group {
+			n.groups[node.addr] = i + 1
+		}
+	}
+}
+
+// heal removes any partition.
+func (n *simNetwork) heal() {
+	n.groups = nil
+}
+
+// crash halts a node, it neither sends nor receives messages nor proposes
+// blocks afterwards.
+func (n *simNetwork) crash(node *simNode) {
+	node.crashed = true
+}
+
+// stop stops all nodes, the clock is never run again.
+func (n *simNetwork) stop() {
+	n.stopped = true
+	for _, node := range n.nodes {
+		n.crash(node)
+	}
+}
+
+// connected reports if a message can travel between two nodes.
+func (n *simNetwork) connected(from, to *simNode) bool {
+	if from.crashed || to.crashed || n.stopped {
+		return false
+	}
+	return n.groups == nil || n.groups[from.addr] == n.groups[to.addr]
+}
+
+// send schedules a message according to the installed rules.
+func (n *simNetwork) send(from, to *simNode, code uint64, payload []byte, block *types.Block) error {
+	if from.crashed || n.stopped {
+		return errSimNodeStopped
+	}
+	if !n.connected(from, to) {
+		// the message is lost on the wire
+		return nil
+	}
+
+	var delay time.Duration
+	for i, rule := range n.rules {
+		if !rule.match(from.addr, to.addr, code) {
+			continue
+		}
+		r := n.random(from, to, code, payload, uint64(i))
+		if rule.drop > 0 && float64(r%1000000)/1000000 < rule.drop {
+			return nil
+		}
+		delay += rule.delay
+		if rule.jitter > 0 {
+			delay += time.Duration(r>>20) % rule.jitter
+		}
+	}
+
+	n.clock.AfterFunc(delay, func() {
+		n.deliver(from, to, code, payload, block)
+	})
+	return nil
+}
+
+// random returns a pseudo random number derived from the seed and the
+// message, so the same message always meets the same fate.
+func (n *simNetwork) random(from, to *simNode, code uint64, payload []byte, salt uint64) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(n.config.seed))
+	h.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], salt)
+	h.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], code)
+	h.Write(buf[:])
+	h.Write(from.addr.Bytes())
+	h.Write(to.addr.Bytes())
+	h.Write(payload)
+	return h.Sum64()
+}
+
+// run runs events on the virtual clock until done returns true, and fails if
+// timeout elapsed on the clock first.
+func (n *simNetwork) run(timeout time.Duration, done func() bool) error {
+	deadline := n.clock.Now().Add(timeout)
+	for !done() {
+		if n.stopped || !n.clock.next(deadline) {
+			return fmt.Errorf("simulation timeout after %v", timeout)
+		}
+		n.flush()
+	}
+	return nil
+}
+
+// flush writes msgs queued for remote validators to the links, in the order
+// of nodes and kinds of msgs, as their broadcast loops would.
+func (n *simNetwork) flush() {
+	for _, node := range n.nodes {
+		for _, rv := range node.remoteValidators {
+			rv.flush()
+		}
+	}
+}
+
+func (n *simNetwork) deliver(from, to *simNode, code uint64, payload []byte, block *types.Block) {
+	if !n.connected(from, to) {
+		return
+	}
+
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(n.clock.Now().UnixNano()))
+	n.delivered.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], code)
+	n.delivered.Write(buf[:])
+	n.delivered.Write(from.addr.Bytes())
+	n.delivered.Write(to.addr.Bytes())
+	n.delivered.Write(payload)
+
+	if code == simBlockMsg {
+		to.dpor.importBlock(block, from)
+		return
+	}
+
+	msg := p2p.Msg{
+		Code:       code,
+		Size:       uint32(len(payload)),
+		Payload:    bytes.NewReader(payload),
+		ReceivedAt: n.clock.Now(),
+	}
+	link := &simLink{net: n, from: to, to: from}
+	_, _ = to.handler.HandleMsg(from.addr.Hex(), ProtocolVersion, from.peer, link, msg)
+}
+
+// recordCommit records a block committed by a node and remembers any block
+// conflicting with one committed before at the same height.
+func (n *simNetwork) recordCommit(node *simNode, block *types.Block) {
+	number, hash := block.NumberU64(), block.Hash()
+	if committed, ok := n.commits[number]; ok && committed != hash {
+		n.conflicts = append(n.conflicts, fmt.Sprintf("node %s committed %s at height %d, but %s was committed before", node.addr.Hex(), hash.Hex(), number, committed.Hex()))
+		return
+	}
+	n.commits[number] = hash
+}
+
+// nodeOf returns the node with the given p2p peer.
+func (n *simNetwork) nodeOf(p *p2p.Peer) *simNode {
+	for _, node := range n.nodes {
+		if p != nil && node.peer.ID() == p.ID() {
+			return node
+		}
+	}
+	return nil
+}
+
+// proposerNodes returns all proposer nodes.
+func (n *simNetwork) proposerNodes() []*simNode {
+	return n.nodes[:n.config.proposers]
+}
+
+// validatorNodes returns all validator nodes.
+func (n *simNetwork) validatorNodes() []*simNode {
+	return n.nodes[n.config.proposers:]
+}
+
+// proposerOf returns the proposer node in charge of the given height.
+func (n *simNetwork) proposerOf(number uint64) *simNode {
+	return n.proposerNodes()[(number-1)%uint64(n.config.proposers)]
+}
+
+// waitForHeight runs the network until all given nodes reached the height,
+// timeout is on the virtual clock.
+func (n *simNetwork) waitForHeight(nodes []*simNode, number uint64, timeout time.Duration) error {
+	reached := func() bool {
+		for _, node := range nodes {
+			if node.dpor.GetCurrentBlock().NumberU64() < number {
+				return false
+			}
+		}
+		return true
+	}
+	if err := n.run(timeout, reached); err != nil {
+		var heights []uint64
+		for _, node := range nodes {
+			heights = append(heights, node.dpor.GetCurrentBlock().NumberU64())
+		}
+		return fmt.Errorf("timeout waiting for height %d, current heights %v", number, heights)
+	}
+	return nil
+}
+
+// checkSafety fails the test if two nodes committed different blocks at the
+// same height.
+func (n *simNetwork) checkSafety() {
+	n.t.Helper()
+
+	for _, c := range n.conflicts {
+		n.t.Error(c)
+	}
+
+	for _, a := range n.nodes {
+		for _, b := range n.nodes {
+			for i := 0; i < len(a.dpor.blocks()) && i < len(b.dpor.blocks()); i++ {
+				if ha, hb := a.dpor.blocks()[i].Hash(), b.dpor.blocks()[i].Hash(); ha != hb {
+					n.t.Errorf("nodes %s and %s diverged at height %d: %s != %s", a.addr.Hex(), b.addr.Hex(), i, ha.Hex(), hb.Hex())
+					return
+				}
+			}
+		}
+	}
+}
+
+// simLink is one direction of a connection between two nodes.
+type simLink struct {
+	net  *simNetwork
+	from *simNode
+	to   *simNode
+}
+
+// WriteMsg implements p2p.MsgWriter.
+func (l *simLink) WriteMsg(msg p2p.Msg) error {
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	return l.net.send(l.from, l.to, msg.Code, payload, nil)
+}
+
+// ReadMsg implements p2p.MsgReader, messages are pushed to the receiving
+// handler by the network instead.
+func (l *simLink) ReadMsg() (p2p.Msg, error) {
+	return p2p.Msg{}, errSimNodeStopped
+}
+
+// flush sends all msgs queued for the remote validator, standing in for its
+// broadcast loop.
+func (s *RemoteValidator) flush() {
+	for {
+		select {
+		case block := <-s.queuedPreprepareBlocks:
+			_ = s.SendPreprepareBlock(block)
+		case header := <-s.queuedPrepareHeaders:
+			_ = s.SendPrepareHeader(header)
+		case header := <-s.queuedCommitHeaders:
+			_ = s.SendCommitHeader(header)
+		case block := <-s.queuedValidateBlocks:
+			_ = s.SendValidateBlock(block)
+		case block := <-s.queuedPreprepareImpeachBlocks:
+			_ = s.SendPreprepareImpeachBlock(block)
+		case header := <-s.queuedPrepareImpeachHeaders:
+			_ = s.SendPrepareImpeachHeader(header)
+		case header := <-s.queuedCommitImpeachHeaders:
+			_ = s.SendCommitImpeachHeader(header)
+		case block := <-s.queuedValidateImpeachBlocks:
+			_ = s.SendImpeachValidateBlock(block)
+		default:
+			return
+		}
+	}
+}
+
+// simNode is a proposer or a validator of the simulated committee.
+type simNode struct {
+	net  *simNetwork
+	key  *ecdsa.PrivateKey
+	addr common.Address
+	peer *p2p.Peer
+
+	isProposer  bool
+	isValidator bool
+
+	handler          *Handler
+	dpor             *simDpor
+	model            *modelChecker
+	remoteValidators []*RemoteValidator
+
+	crashed bool
+}
+
+func newSimNode(n *simNetwork, index int, isProposer, isValidator bool) *simNode {
+	// derive the key from the seed, so are the hashes of blocks and msgs
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], uint64(n.config.seed))
+	binary.BigEndian.PutUint64(buf[8:], uint64(index))
+	key, err := crypto.ToECDSA(crypto.Keccak256(buf[:]))
+	if err != nil {
+		n.t.Fatal(err)
+	}
+
+	node := &simNode{
+		net:         n,
+		key:         key,
+		addr:        crypto.PubkeyToAddress(key.PublicKey),
+		peer:        p2p.NewPeer(discover.PubkeyID(&key.PublicKey), "sim", nil),
+		isProposer:  isProposer,
+		isValidator: isValidator,
+	}
+
+	db := database.NewMemDatabase()
+	node.dpor = &simDpor{
+		node:   node,
+		signed: make(map[uint64]common.Hash),
+	}
+	node.handler = NewHandler(n.dporConfig, node.addr, db)
+	node.handler.SetDporService(node.dpor)
+	node.handler.dialer.defaultValidators = nil
+	node.handler.clock = n.clock
+
+	return node
+}
+
+// start starts the node on the virtual clock. Loops of the handler are replaced
+// by events, remote validators are flushed by the network.
+func (node *simNode) start() {
+	db := database.NewMemDatabase()
+	fsm := NewLBFT2(node.net.config.faulty, node.dpor, node.receiveImpeachBlock, NewEvidencePool(db), db)
+	fsm.clock = node.net.clock
+	if node.net.config.checkModel && node.isValidator {
+		model, err := newModelChecker(node.net.config.faulty)
+		if err != nil {
+			node.net.t.Fatal(err)
+		}
+		node.model = model
+		fsm.SetModelStepFn(node.model.record)
+	}
+	node.handler.SetDporStateMachine(fsm)
+
+	if dir := node.net.config.traceDir; dir != "" && node.isValidator {
+		trace, err := NewTraceRecorder(filepath.Join(dir, node.addr.Hex()), &TraceMeta{
+			Coinbase: node.addr,
+			Faulty:   node.net.config.faulty,
+			TermLen:  node.net.dporConfig.TermLen,
+			ViewLen:  node.net.dporConfig.ViewLen,
+			Head:     node.dpor.GetCurrentBlock().Header(),
+		})
+		if err != nil {
+			node.net.t.Fatal(err)
+		}
+		node.handler.SetTraceRecorder(trace)
+	}
+
+	node.procUnknownAncestors()
+	if node.isProposer {
+		node.propose(0)
+	}
+}
+
+// receiveImpeachBlock handles an impeach block of the state machine, as the
+// handler's impeach block loop does.
+func (node *simNode) receiveImpeachBlock(block *types.Block) error {
+	node.handler.handlePendingImpeachBlock(block)
+	return node.handler.knownBlocks.AddBlock(block)
+}
+
+// procUnknownAncestors handles unknown ancestor blocks periodically, as the
+// handler's unknown ancestors loop does.
+func (node *simNode) procUnknownAncestors() {
+	if node.crashed {
+		return
+	}
+	node.handler.procUnknownAncestors()
+	node.net.clock.AfterFunc(unknownAncestorsInterval, node.procUnknownAncestors)
+}
+
+// propose proposes a block at its timestamp if the node is in charge of the
+// next height, and checks again later.
+func (node *simNode) propose(proposed uint64) {
+	if node.crashed {
+		return
+	}
+
+	parent := node.dpor.GetCurrentBlock()
+	if number := parent.NumberU64() + 1; number > proposed && node.net.proposerOf(number) == node {
+		proposed = number
+
+		block := node.dpor.createBlock(parent)
+		node.net.clock.AfterFunc(block.Timestamp().Sub(node.net.clock.Now()), func() {
+			if node.crashed {
+				return
+			}
+			if err := node.handler.knownBlocks.AddBlock(block); err == nil {
+				node.handler.broadcastPendingBlock(block)
+			}
+		})
+	}
+	node.net.clock.AfterFunc(simProposeInterval, func() { node.propose(proposed) })
+}
+
+// simDpor implements DporService on top of an in-memory chain.
+type simDpor struct {
+	node *simNode
+
+	lock   sync.RWMutex
+	chain  []*types.Block
+	signed map[uint64]common.Hash
+}
+
+func (s *simDpor) config() *configs.DporConfig { return s.node.net.dporConfig }
+
+func (s *simDpor) blocks() []*types.Block {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return append([]*types.Block{}, s.chain...)
+}
+
+func (s *simDpor) createBlock(parent *types.Block) *types.Block {
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		Coinbase:   s.node.addr,
+		Extra:      make([]byte, simExtraSeal),
+	}
+	timestamp := parent.Timestamp().Add(s.Period())
+	if now := s.node.net.clock.Now(); timestamp.Before(now) {
+		timestamp = now
+	}
+	header.SetTimestamp(timestamp)
+	header.Dpor.Proposers = s.node.net.proposers
+	header.Dpor.Sigs = make([]types.DporSignature, len(s.node.net.validators))
+
+	block := types.NewBlock(header, nil, nil)
+	header = block.Header()
+
+	sig, _ := crypto.Sign(header.Hash().Bytes(), s.node.key)
+	copy(header.Dpor.Seal[:], sig)
+
+	return block.WithSeal(header)
+}
+
+// importBlock imports a block broadcast by another node, falling back to
+// syncing from it if the block does not extend the local chain.
+func (s *simDpor) importBlock(block *types.Block, from *simNode) {
+	current := s.GetCurrentBlock()
+	switch {
+	case block.NumberU64() <= current.NumberU64():
+	case block.ParentHash() == current.Hash():
+		_ = s.InsertChain(block)
+	default:
+		s.syncFrom(from)
+	}
+}
+
+func (s *simDpor) syncFrom(from *simNode) {
+	if from == nil {
+		return
+	}
+
+	if !s.node.net.connected(from, s.node) {
+		return
+	}
+
+	for _, block := range from.dpor.blocks() {
+		if block.NumberU64() > s.GetCurrentBlock().NumberU64() {
+			if err := s.InsertChain(block); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *simDpor) Coinbase() common.Address { return s.node.addr }
+
+func (s *simDpor) TermLength() uint64 { return s.config().TermLen }
+
+func (s *simDpor) Faulty() uint64 { return s.config().FaultyNumber }
+
+func (s *simDpor) ViewLength() uint64 { return s.config().ViewLen }
+
+func (s *simDpor) ValidatorsNum() uint64 { return s.config().ValidatorsLen() }
+
+func (s *simDpor) Period() time.Duration { return s.config().PeriodDuration() }
+
+func (s *simDpor) BlockDelay() time.Duration { return s.config().BlockDelay() }
+
+func (s *simDpor) ImpeachTimeout() time.Duration { return s.config().ImpeachTimeout }
+
+func (s *simDpor) TermOf(number uint64) uint64 {
+	if number == 0 {
+		return 0
+	}
+	return (number - 1) / (s.config().TermLen * s.config().ViewLen)
+}
+
+func (s *simDpor) FutureTermOf(number uint64) uint64 { return s.TermOf(number) + 1 }
+
+func (s *simDpor) VerifyProposerOf(signer common.Address, term uint64) (bool, error) {
+	return containsAddress(s.node.net.proposers, signer), nil
+}
+
+func (s *simDpor) VerifyValidatorOf(signer common.Address, term uint64) (bool, error) {
+	return containsAddress(s.node.net.validators, signer), nil
+}
+
+func (s *simDpor) ValidatorsOf(number uint64) ([]common.Address, error) {
+	return s.node.net.validators, nil
+}
+
+func (s *simDpor) ProposersOf(number uint64) ([]common.Address, error) {
+	return s.node.net.proposers, nil
+}
+
+func (s *simDpor) ProposerOf(number uint64) (common.Address, error) {
+	return s.node.net.proposerOf(number).addr, nil
+}
+
+func (s *simDpor) ValidatorsOfTerm(term uint64) ([]common.Address, error) {
+	return s.node.net.validators, nil
+}
+
+func (s *simDpor) ProposersOfTerm(term uint64) ([]common.Address, error) {
+	return s.node.net.proposers, nil
+}
+
+func (s *simDpor) VerifyHeaderWithState(header *types.Header, state consensus.State) error {
+	return nil
+}
+
+func (s *simDpor) ValidateBlock(block *types.Block, verifySigs bool, verifyProposers bool) error {
+	parent := s.GetBlockFromChain(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+
+	if block.Impeachment() {
+		expected := parent.Timestamp().Add(s.Period()).Add(s.ImpeachTimeout())
+		if !block.Timestamp().Equal(expected) {
+			return consensus.ErrInvalidImpeachTimestamp
+		}
+		return nil
+	}
+
+	if block.Timestamp().Before(parent.Timestamp().Add(s.Period())) {
+		return consensus.ErrInvalidTimestamp
+	}
+
+	if verifyProposers {
+		proposer, err := s.ECRecoverProposer(block.Header())
+		if err != nil {
+			return err
+		}
+		if expected, _ := s.ProposerOf(block.NumberU64()); proposer != expected || block.Coinbase() != expected {
+			return consensus.ErrUnauthorized
+		}
+	}
+	return nil
+}
+
+func (s *simDpor) SignHeader(header *types.Header, state consensus.State) error {
+	idx := -1
+	for i, v := range s.node.net.validators {
+		if v == s.node.addr {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return errSimNotValidator
+	}
+
+	var (
+		number = header.Number.Uint64()
+		hash   = header.Hash()
+	)
+
+	s.lock.Lock()
+	if signed, ok := s.signed[number]; ok && signed != hash && state != consensus.ImpeachPrepare && state != consensus.ImpeachCommit {
+		s.lock.Unlock()
+		return errSimDoubleSign
+	}
+	if state == consensus.Prepare || state == consensus.Commit {
+		s.signed[number] = hash
+	}
+	s.lock.Unlock()
+
+	sig, err := crypto.Sign(simHashWithState(hash, state), s.node.key)
+	if err != nil {
+		return err
+	}
+
+	if len(header.Dpor.Sigs) != len(s.node.net.validators) {
+		header.Dpor.Sigs = make([]types.DporSignature, len(s.node.net.validators))
+	}
+	copy(header.Dpor.Sigs[idx][:], sig)
+	return nil
+}
+
+func (s *simDpor) BroadcastBlock(block *types.Block, prop bool) {
+	for _, node := range s.node.net.nodes {
+		if node != s.node {
+			_ = s.node.net.send(s.node, node, simBlockMsg, block.Hash().Bytes(), block)
+		}
+	}
+}
+
+func (s *simDpor) InsertChain(block *types.Block) error {
+	signers, _, err := s.ECRecoverSigs(block.Header(), consensus.Commit)
+	if err != nil {
+		return err
+	}
+	quorum := 2*s.Faulty() + 1
+	if block.Impeachment() {
+		quorum = s.Faulty() + 1
+	}
+	var count uint64
+	for _, signer := range signers {
+		if containsAddress(s.node.net.validators, signer) {
+			count++
+		}
+	}
+	if count < quorum {
+		return errSimNotEnoughSigs
+	}
+
+	s.lock.Lock()
+	current := s.chain[len(s.chain)-1]
+	switch {
+	case block.NumberU64() <= current.NumberU64() && s.chain[block.NumberU64()].Hash() == block.Hash():
+		s.lock.Unlock()
+		return nil
+	case block.ParentHash() != current.Hash():
+		s.lock.Unlock()
+		return consensus.ErrUnknownAncestor
+	}
+	s.chain = append(s.chain, block)
+	s.lock.Unlock()
+
+	s.node.net.recordCommit(s.node, block)
+	return nil
+}
+
+func (s *simDpor) Status() *consensus.PbftStatus {
+	return &consensus.PbftStatus{Head: s.GetCurrentBlock().Header()}
+}
+
+func (s *simDpor) StatusUpdate() error { return nil }
+
+func (s *simDpor) CreateImpeachBlock() (*types.Block, error) {
+	parent := s.GetCurrentBlock()
+
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		Extra:      make([]byte, simExtraSeal),
+		StateRoot:  parent.StateRoot(),
+	}
+	header.Dpor.Proposers = s.node.net.proposers
+	header.Dpor.Sigs = make([]types.DporSignature, len(s.node.net.validators))
+	header.SetTimestamp(parent.Timestamp().Add(s.Period()).Add(s.ImpeachTimeout()))
+
+	return types.NewBlock(header, nil, nil), nil
+}
+
+// CreateFailbackImpeachBlocks returns nothing, simulated nodes never reboot.
+func (s *simDpor) CreateFailbackImpeachBlocks() (firstImpeachment *types.Block, secondImpeachment *types.Block, err error) {
+	return nil, nil, nil
+}
+
+func (s *simDpor) GetCurrentBlock() *types.Block {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.chain[len(s.chain)-1]
+}
+
+func (s *simDpor) HasBlockInChain(hash common.Hash, number uint64) bool {
+	return s.GetBlockFromChain(hash, number) != nil
+}
+
+func (s *simDpor) GetBlockFromChain(hash common.Hash, number uint64) *types.Block {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if number < uint64(len(s.chain)) && s.chain[number].Hash() == hash {
+		return s.chain[number]
+	}
+	return nil
+}
+
+func (s *simDpor) ECRecoverProposer(header *types.Header) (common.Address, error) {
+	pubkey, err := crypto.SigToPub(header.Hash().Bytes(), header.Dpor.Seal[:])
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
+}
+
+func (s *simDpor) AggregateSigs(header *types.Header) (*types.Header, error) {
+	return header, nil
+}
+
+func (s *simDpor) ECRecoverSigs(header *types.Header, state consensus.State) ([]common.Address, []types.DporSignature, error) {
+	hash := simHashWithState(header.Hash(), state)
+
+	var (
+		signers    []common.Address
+		signatures []types.DporSignature
+	)
+	for _, sig := range header.Dpor.Sigs {
+		if sig.IsEmpty() {
+			continue
+		}
+		pubkey, err := crypto.SigToPub(hash, sig[:])
+		if err != nil {
+			return []common.Address{}, []types.DporSignature{}, err
+		}
+		signers = append(signers, crypto.PubkeyToAddress(*pubkey))
+		signatures = append(signatures, sig)
+	}
+	return signers, signatures, nil
+}
+
+func (s *simDpor) UpdatePrepareSigsCache(validator common.Address, hash common.Hash, sig types.DporSignature) {
+}
+
+func (s *simDpor) UpdateFinalSigsCache(validator common.Address, hash common.Hash, sig types.DporSignature) {
+}
+
+func (s *simDpor) GetMac() (string, []byte, error) { return "", nil, nil }
+
+func (s *simDpor) SyncFrom(p *p2p.Peer) { s.syncFrom(s.node.net.nodeOf(p)) }
+
+// Synchronize syncs from the highest node reachable.
+func (s *simDpor) Synchronize() {
+	var best *simNode
+	for _, node := range s.node.net.nodes {
+		if node == s.node {
+			continue
+		}
+		if best == nil || node.dpor.GetCurrentBlock().NumberU64() > best.dpor.GetCurrentBlock().NumberU64() {
+			best = node
+		}
+	}
+	s.syncFrom(best)
+}
+
+// simHashWithState returns the hash a validator signs for a header in the
+// given state, the same way dpor does.
+func simHashWithState(hash common.Hash, state consensus.State) []byte {
+	switch state {
+	case consensus.Prepare, consensus.ImpeachPrepare:
+		var signHash common.Hash
+		hasher := sha3.NewKeccak256()
+		hasher.Write(append([]byte("Prepare"), hash.Bytes()...))
+		hasher.Sum(signHash[:0])
+		return signHash.Bytes()
+	default:
+		return hash.Bytes()
+	}
+}
+
+func containsAddress(addrs []common.Address, addr common.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,655 @@
+package backend
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	// errTraceNotSigned is returned when replaying a signature that was not recorded in the trace
+	errTraceNotSigned = errors.New("signature is not recorded in the trace")
+)
+
+// TraceKind is the kind of a trace record
+type TraceKind uint8
+
+// Those are kinds of trace records
+const (
+	// TraceInbound is a msg fed to the state machine, with the resulting transition
+	TraceInbound TraceKind = iota
+
+	// TraceOutbound is a msg broadcast to other nodes
+	TraceOutbound
+)
+
+// TraceMeta is the first record of a trace file, describing the recording node
+type TraceMeta struct {
+	Coinbase common.Address
+	Faulty   uint64
+	TermLen  uint64
+	ViewLen  uint64
+	Head     *types.Header // current block header when the recording starts
+}
+
+// TraceRecord is a consensus msg recorded in a trace file
+type TraceRecord struct {
+	Kind    TraceKind
+	Time    uint64         // unix time in nanoseconds when the msg is recorded
+	Peer    common.Address // sender of an inbound msg, empty if generated by local node
+	MsgCode MsgCode
+	Header  *types.Header
+	Block   *types.Block
+
+	// context of an inbound msg
+	Head       *types.Header    // current block header when the msg is handled
+	Validators []common.Address // validators of the msg's block number
+
+	// transition caused by an inbound msg
+	OutputMsgCode MsgCode
+	State         consensus.State
+	Number        uint64
+	Err           string
+}
+
+// extTraceRecord is the external representation of a trace record, optional
+// headers and blocks are encoded as lists of at most one element
+type extTraceRecord struct {
+	Kind          TraceKind
+	Time          uint64
+	Peer          common.Address
+	MsgCode       MsgCode
+	Header        []*types.Header
+	Block         []*types.Block
+	Head          []*types.Header
+	Validators    []common.Address
+	OutputMsgCode MsgCode
+	State         consensus.State
+	Number        uint64
+	Err           string
+}
+
+// EncodeRLP implements rlp.Encoder
+func (r *TraceRecord) EncodeRLP(w io.Writer) error {
+	ext := &extTraceRecord{
+		Kind:          r.Kind,
+		Time:          r.Time,
+		Peer:          r.Peer,
+		MsgCode:       r.MsgCode,
+		Validators:    r.Validators,
+		OutputMsgCode: r.OutputMsgCode,
+		State:         r.State,
+		Number:        r.Number,
+		Err:           r.Err,
+	}
+	if r.Header != nil {
+		ext.Header = []*types.Header{r.Header}
+	}
+	if r.Block != nil {
+		ext.Block = []*types.Block{r.Block}
+	}
+	if r.Head != nil {
+		ext.Head = []*types.Header{r.Head}
+	}
+	return rlp.Encode(w, ext)
+}
+
+// DecodeRLP implements rlp.Decoder
+func (r *TraceRecord) DecodeRLP(s *rlp.Stream) error {
+	var ext extTraceRecord
+	if err := s.Decode(&ext); err != nil {
+		return err
+	}
+	*r = TraceRecord{
+		Kind:          ext.Kind,
+		Time:          ext.Time,
+		Peer:          ext.Peer,
+		MsgCode:       ext.MsgCode,
+		Validators:    ext.Validators,
+		OutputMsgCode: ext.OutputMsgCode,
+		State:         ext.State,
+		Number:        ext.Number,
+		Err:           ext.Err,
+	}
+	if len(ext.Header) > 0 {
+		r.Header = ext.Header[0]
+	}
+	if len(ext.Block) > 0 {
+		r.Block = ext.Block[0]
+	}
+	if len(ext.Head) > 0 {
+		r.Head = ext.Head[0]
+	}
+	return nil
+}
+
+// Input returns the msg of the record
+func (r *TraceRecord) Input() *BlockOrHeader {
+	if r.Block != nil {
+		return NewBOHFromBlock(r.Block)
+	}
+	return NewBOHFromHeader(r.Header)
+}
+
+func newTraceRecord(kind TraceKind, msg *BlockOrHeader, msgCode MsgCode) *TraceRecord {
+	r := &TraceRecord{
+		Kind:    kind,
+		Time:    uint64(time.Now().UnixNano()),
+		MsgCode: msgCode,
+	}
+	// copy the msg, the state machine writes signatures into it
+	if msg.IsBlock() {
+		r.Block = msg.block.WithSeal(types.CopyHeader(msg.block.Header()))
+	} else {
+		r.Header = types.CopyHeader(msg.header)
+	}
+	return r
+}
+
+// TraceRecorder writes consensus msgs and state transitions to a trace file
+type TraceRecorder struct {
+	file *os.File
+	w    *bufio.Writer
+	lock sync.Mutex
+}
+
+// NewTraceRecorder creates a trace file at the given path, truncating it if it exists
+func NewTraceRecorder(path string, meta *TraceMeta) (*TraceRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &TraceRecorder{
+		file: file,
+		w:    bufio.NewWriter(file),
+	}
+	if err := t.write(meta); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// Record appends a record to the trace file
+func (t *TraceRecorder) Record(r *TraceRecord) error {
+	return t.write(r)
+}
+
+func (t *TraceRecorder) write(v interface{}) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if err := rlp.Encode(t.w, v); err != nil {
+		return err
+	}
+	// flush every record, a trace is mostly read after a crash or a stall
+	return t.w.Flush()
+}
+
+// Close closes the trace file
+func (t *TraceRecorder) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if err := t.w.Flush(); err != nil {
+		t.file.Close()
+		return err
+	}
+	return t.file.Close()
+}
+
+// ReadTrace reads the meta and all records of a trace
+func ReadTrace(r io.Reader) (*TraceMeta, []*TraceRecord, error) {
+	stream := rlp.NewStream(bufio.NewReader(r), 0)
+
+	meta := &TraceMeta{}
+	if err := stream.Decode(meta); err != nil {
+		return nil, nil, err
+	}
+
+	var records []*TraceRecord
+	for {
+		record := &TraceRecord{}
+		switch err := stream.Decode(record); err {
+		case nil:
+			records = append(records, record)
+		case io.EOF:
+			return meta, records, nil
+		default:
+			// a truncated tail is left by a crash, keep what is read
+			if err == io.ErrUnexpectedEOF {
+				return meta, records, nil
+			}
+			return nil, nil, err
+		}
+	}
+}
+
+// SetTraceRecorder sets the recorder to trace consensus msgs with, nil to stop tracing
+func (h *Handler) SetTraceRecorder(trace *TraceRecorder) {
+	h.traceLock.Lock()
+	defer h.traceLock.Unlock()
+
+	h.trace = trace
+}
+
+// runFSM feeds a msg to the state machine, tracing the msg and the transition if
+// a recorder is set. The trace lock is only held across the state machine while
+// tracing, so that records are in the order the msgs are fed.
+func (h *Handler) runFSM(input *BlockOrHeader, msgCode MsgCode, p *RemoteSigner) ([]*BlockOrHeader, Action, MsgCode, error) {
+	h.traceLock.Lock()
+	if h.trace == nil {
+		h.traceLock.Unlock()
+		return h.fsm.FSM(input, msgCode)
+	}
+	defer h.traceLock.Unlock()
+
+	record := newTraceRecord(TraceInbound, input, msgCode)
+	if p != nil {
+		record.Peer = p.Coinbase()
+	}
+	if head := h.dpor.GetCurrentBlock(); head != nil {
+		record.Head = head.Header()
+	}
+	record.Validators, _ = h.dpor.ValidatorsOf(input.Number())
+
+	output, action, outputMsgCode, err := h.fsm.FSM(input, msgCode)
+
+	status := h.fsm.Status()
+	record.OutputMsgCode, record.State, record.Number = outputMsgCode, status.State, status.Number
+	if err != nil {
+		record.Err = err.Error()
+	}
+	if terr := h.trace.Record(record); terr != nil {
+		log.Warn("failed to record consensus trace", "number", input.Number(), "err", terr)
+	}
+
+	return output, action, outputMsgCode, err
+}
+
+// traceOutbound traces a msg broadcast by local node if a recorder is set
+func (h *Handler) traceOutbound(msg *BlockOrHeader, msgCode MsgCode) {
+	h.traceLock.Lock()
+	trace := h.trace
+	h.traceLock.Unlock()
+
+	if trace == nil {
+		return
+	}
+	if err := trace.Record(newTraceRecord(TraceOutbound, msg, msgCode)); err != nil {
+		log.Warn("failed to record consensus trace", "number", msg.Number(), "err", err)
+	}
+}
+
+// traceFSMOutput traces the msgs output by the state machine to broadcast
+func (h *Handler) traceFSMOutput(output []*BlockOrHeader, outputMsgCode MsgCode) {
+	msgCodes := []MsgCode{outputMsgCode}
+	switch outputMsgCode {
+	case PrepareAndCommitMsgCode:
+		msgCodes = []MsgCode{PrepareMsgCode, CommitMsgCode}
+	case ImpeachPrepareAndCommitMsgCode:
+		msgCodes = []MsgCode{ImpeachPrepareMsgCode, ImpeachCommitMsgCode}
+	}
+	for i, msg := range output {
+		if i < len(msgCodes) {
+			h.traceOutbound(msg, msgCodes[i])
+		}
+	}
+}
+
+// SigRecoverer recovers signers from headers, the dpor engine implements it
+type SigRecoverer interface {
+	ECRecoverProposer(header *types.Header) (common.Address, error)
+	ECRecoverSigs(header *types.Header, state consensus.State) ([]common.Address, []types.DporSignature, error)
+}
+
+// ReplayStep is the result of replaying an inbound msg of a trace
+type ReplayStep struct {
+	Record  *TraceRecord
+	Status  DSMStatus // status of the state machine after the msg
+	MsgCode MsgCode   // output msg code
+	Err     error
+}
+
+// Matches returns if the replayed transition is the same as the recorded one
+func (s *ReplayStep) Matches() bool {
+	errString := ""
+	if s.Err != nil {
+		errString = s.Err.Error()
+	}
+	return s.Status.State == s.Record.State && s.Status.Number == s.Record.Number &&
+		s.MsgCode == s.Record.OutputMsgCode && errString == s.Record.Err
+}
+
+// Replay feeds the inbound msgs of a trace to a new LBFT2 state machine in order,
+// and returns the resulting transitions.
+//
+// The chain and the keys of the recording node are not in the trace, so the head
+// and validators recorded with each msg are replayed, signatures of local node
+// are copied from the recorded outbound msgs and impeachments are never started
+// by timers, they are replayed as the recorded impeach preprepare msgs.
+func Replay(meta *TraceMeta, records []*TraceRecord, recoverer SigRecoverer) []*ReplayStep {
+	td := newTraceDpor(meta, records, recoverer)
+
+	db := database.NewMemDatabase()
+	fsm := NewLBFT2(meta.Faulty, td, func(*types.Block) error { return nil }, NewEvidencePool(db), db)
+
+	var steps []*ReplayStep
+	for _, record := range records {
+		if record.Kind != TraceInbound {
+			continue
+		}
+		td.setRecord(record)
+
+		_, _, msgCode, err := fsm.FSM(record.Input(), record.MsgCode)
+		steps = append(steps, &ReplayStep{
+			Record:  record,
+			Status:  fsm.Status(),
+			MsgCode: msgCode,
+			Err:     err,
+		})
+	}
+	return steps
+}
+
+// traceSigKey identifies a signature of local node in a trace
+type traceSigKey struct {
+	hash  common.Hash
+	state consensus.State
+}
+
+// traceDpor implements DporService for replaying a trace
+type traceDpor struct {
+	meta      *TraceMeta
+	recoverer SigRecoverer
+
+	validators map[uint64][]common.Address
+	sigs       map[traceSigKey]types.DporSignature
+
+	lock   sync.RWMutex
+	record *TraceRecord
+	head   *types.Block
+	known  map[common.Hash]bool
+}
+
+func newTraceDpor(meta *TraceMeta, records []*TraceRecord, recoverer SigRecoverer) *traceDpor {
+	td := &traceDpor{
+		meta:       meta,
+		recoverer:  recoverer,
+		validators: make(map[uint64][]common.Address),
+		sigs:       make(map[traceSigKey]types.DporSignature),
+		head:       types.NewBlockWithHeader(meta.Head),
+		known:      map[common.Hash]bool{meta.Head.Hash(): true},
+	}
+
+	for _, r := range records {
+		if r.Kind == TraceInbound && len(r.Validators) > 0 {
+			td.validators[r.Input().Number()] = r.Validators
+		}
+	}
+
+	// collect signatures of local node from the msgs it broadcast, validate msgs
+	// carry the commit signatures
+	for _, r := range records {
+		if r.Kind != TraceOutbound {
+			continue
+		}
+		var state consensus.State
+		switch r.MsgCode {
+		case PrepareMsgCode:
+			state = consensus.Prepare
+		case CommitMsgCode, ValidateMsgCode:
+			state = consensus.Commit
+		case ImpeachPrepareMsgCode:
+			state = consensus.ImpeachPrepare
+		case ImpeachCommitMsgCode, ImpeachValidateMsgCode:
+			state = consensus.ImpeachCommit
+		default:
+			continue
+		}
+		header := r.Header
+		if r.Block != nil {
+			header = r.Block.Header()
+		}
+		idx := td.indexOf(header.Number.Uint64())
+		if idx < 0 || idx >= len(header.Dpor.Sigs) || header.Dpor.Sigs[idx].IsEmpty() {
+			continue
+		}
+		// prepare and commit signatures share the slots of a header, keep only
+		// the ones recovering to local node with the state
+		if signer, err := td.recoverSig(header, idx, state); err != nil || signer != td.meta.Coinbase {
+			continue
+		}
+		td.sigs[traceSigKey{hash: header.Hash(), state: state}] = header.Dpor.Sigs[idx]
+	}
+	return td
+}
+
+// setRecord sets the inbound record being replayed, and the head recorded with it
+func (td *traceDpor) setRecord(record *TraceRecord) {
+	td.lock.Lock()
+	defer td.lock.Unlock()
+
+	td.record = record
+	if record.Head != nil && record.Head.Number.Uint64() >= td.head.NumberU64() {
+		td.head = types.NewBlockWithHeader(record.Head)
+		td.known[record.Head.Hash()] = true
+	}
+}
+
+// recoverSig returns the signer of the signature at the given index of a header
+func (td *traceDpor) recoverSig(header *types.Header, idx int, state consensus.State) (common.Address, error) {
+	h := types.CopyHeader(header)
+	h.Dpor.Sigs = make([]types.DporSignature, idx+1)
+	h.Dpor.Sigs[idx] = header.Dpor.Sigs[idx]
+	signers, _, err := td.recoverer.ECRecoverSigs(h, state)
+	if err != nil || len(signers) == 0 {
+		return common.Address{}, errTraceNotSigned
+	}
+	return signers[0], nil
+}
+
+// indexOf returns the index of local node in validators of the given block number
+func (td *traceDpor) indexOf(number uint64) int {
+	validators, _ := td.ValidatorsOf(number)
+	for i, v := range validators {
+		if v == td.meta.Coinbase {
+			return i
+		}
+	}
+	return -1
+}
+
+func (td *traceDpor) Coinbase() common.Address { return td.meta.Coinbase }
+
+func (td *traceDpor) TermLength() uint64 { return td.meta.TermLen }
+
+func (td *traceDpor) Faulty() uint64 { return td.meta.Faulty }
+
+func (td *traceDpor) ViewLength() uint64 { return td.meta.ViewLen }
+
+func (td *traceDpor) ValidatorsNum() uint64 {
+	validators, _ := td.ValidatorsOf(td.GetCurrentBlock().NumberU64() + 1)
+	return uint64(len(validators))
+}
+
+func (td *traceDpor) Period() time.Duration { return 0 }
+
+func (td *traceDpor) BlockDelay() time.Duration { return 0 }
+
+func (td *traceDpor) ImpeachTimeout() time.Duration { return 0 }
+
+func (td *traceDpor) TermOf(number uint64) uint64 {
+	if number == 0 {
+		return 0
+	}
+	return (number - 1) / (td.meta.TermLen * td.meta.ViewLen)
+}
+
+func (td *traceDpor) FutureTermOf(number uint64) uint64 { return td.TermOf(number) + 1 }
+
+// VerifyProposerOf accepts any proposer, proposers are not recorded in the trace
+func (td *traceDpor) VerifyProposerOf(signer common.Address, term uint64) (bool, error) {
+	return true, nil
+}
+
+func (td *traceDpor) VerifyValidatorOf(signer common.Address, term uint64) (bool, error) {
+	for number, validators := range td.validators {
+		if td.TermOf(number) == term {
+			for _, v := range validators {
+				if v == signer {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+	}
+	return false, nil
+}
+
+// ValidatorsOf returns the validators recorded with msgs of the given number, or
+// of the nearest lower number
+func (td *traceDpor) ValidatorsOf(number uint64) ([]common.Address, error) {
+	var (
+		nearest    uint64
+		validators []common.Address
+	)
+	for n, v := range td.validators {
+		if n == number {
+			return v, nil
+		}
+		if n < number && n >= nearest {
+			nearest, validators = n, v
+		}
+	}
+	return validators, nil
+}
+
+func (td *traceDpor) ProposersOf(number uint64) ([]common.Address, error) { return nil, nil }
+
+func (td *traceDpor) ProposerOf(number uint64) (common.Address, error) {
+	return common.Address{}, nil
+}
+
+func (td *traceDpor) ValidatorsOfTerm(term uint64) ([]common.Address, error) {
+	return td.ValidatorsOf(term*td.meta.TermLen*td.meta.ViewLen + 1)
+}
+
+func (td *traceDpor) ProposersOfTerm(term uint64) ([]common.Address, error) { return nil, nil }
+
+func (td *traceDpor) VerifyHeaderWithState(header *types.Header, state consensus.State) error {
+	return nil
+}
+
+// ValidateBlock replays the recorded verdict, blocks were validated against the
+// chain of the recording node, which is not in the trace
+func (td *traceDpor) ValidateBlock(block *types.Block, verifySigs bool, verifyProposers bool) error {
+	td.lock.RLock()
+	defer td.lock.RUnlock()
+
+	switch {
+	case td.record == nil || td.record.Err == "":
+		return nil
+	case td.record.Err == consensus.ErrUnknownAncestor.Error():
+		return consensus.ErrUnknownAncestor
+	default:
+		return errors.New(td.record.Err)
+	}
+}
+
+// SignHeader copies the signature local node broadcast for the header
+func (td *traceDpor) SignHeader(header *types.Header, state consensus.State) error {
+	sig, ok := td.sigs[traceSigKey{hash: header.Hash(), state: state}]
+	idx := td.indexOf(header.Number.Uint64())
+	if !ok || idx < 0 {
+		return errTraceNotSigned
+	}
+
+	if len(header.Dpor.Sigs) <= idx {
+		sigs := make([]types.DporSignature, idx+1)
+		copy(sigs, header.Dpor.Sigs)
+		header.Dpor.Sigs = sigs
+	}
+	header.Dpor.Sigs[idx] = sig
+	return nil
+}
+
+func (td *traceDpor) BroadcastBlock(block *types.Block, prop bool) {}
+
+func (td *traceDpor) InsertChain(block *types.Block) error {
+	td.lock.Lock()
+	defer td.lock.Unlock()
+
+	if block.NumberU64() > td.head.NumberU64() {
+		td.head = block
+	}
+	td.known[block.Hash()] = true
+	return nil
+}
+
+func (td *traceDpor) Status() *consensus.PbftStatus {
+	return &consensus.PbftStatus{Head: td.GetCurrentBlock().Header()}
+}
+
+func (td *traceDpor) StatusUpdate() error { return nil }
+
+// CreateImpeachBlock returns nothing, impeachments are replayed from the trace
+func (td *traceDpor) CreateImpeachBlock() (*types.Block, error) { return nil, nil }
+
+// CreateFailbackImpeachBlocks returns nothing, impeachments are replayed from the trace
+func (td *traceDpor) CreateFailbackImpeachBlocks() (firstImpeachment *types.Block, secondImpeachment *types.Block, err error) {
+	return nil, nil, nil
+}
+
+func (td *traceDpor) GetCurrentBlock() *types.Block {
+	td.lock.RLock()
+	defer td.lock.RUnlock()
+
+	return td.head
+}
+
+func (td *traceDpor) HasBlockInChain(hash common.Hash, number uint64) bool {
+	td.lock.RLock()
+	defer td.lock.RUnlock()
+
+	return td.known[hash]
+}
+
+// GetBlockFromChain returns nothing, so that msgs are not dropped by wall clock checks
+func (td *traceDpor) GetBlockFromChain(hash common.Hash, number uint64) *types.Block { return nil }
+
+func (td *traceDpor) ECRecoverProposer(header *types.Header) (common.Address, error) {
+	return td.recoverer.ECRecoverProposer(header)
+}
+
+// AggregateSigs returns the header as it is, blocks are not inserted in replays
+func (td *traceDpor) AggregateSigs(header *types.Header) (*types.Header, error) {
+	return header, nil
+}
+
+func (td *traceDpor) ECRecoverSigs(header *types.Header, state consensus.State) ([]common.Address, []types.DporSignature, error) {
+	return td.recoverer.ECRecoverSigs(header, state)
+}
+
+func (td *traceDpor) UpdatePrepareSigsCache(validator common.Address, hash common.Hash, sig types.DporSignature) {
+}
+
+func (td *traceDpor) UpdateFinalSigsCache(validator common.Address, hash common.Hash, sig types.DporSignature) {
+}
+
+func (td *traceDpor) GetMac() (string, []byte, error) { return "", nil, nil }
+
+func (td *traceDpor) SyncFrom(p *p2p.Peer) {}
+
+func (td *traceDpor) Synchronize() {}
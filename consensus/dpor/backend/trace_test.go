@@ -0,0 +1,68 @@
+package backend
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTraceReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lbft2-trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := defaultSimConfig()
+	config.traceDir = dir
+	net := newSimNetwork(t, config)
+
+	if err := net.waitForHeight(net.validatorNodes(), 4, simTimeout); err != nil {
+		net.stop()
+		t.Fatal(err)
+	}
+	net.stop()
+
+	node := net.validatorNodes()[0]
+	node.handler.SetTraceRecorder(nil)
+
+	file, err := os.Open(filepath.Join(dir, node.addr.Hex()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	meta, records, err := ReadTrace(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Coinbase != node.addr || meta.Head.Hash() != net.genesis.Hash() {
+		t.Fatalf("trace meta mismatch: got %+v", meta)
+	}
+
+	var inbound, outbound int
+	for _, r := range records {
+		switch r.Kind {
+		case TraceInbound:
+			inbound++
+		case TraceOutbound:
+			outbound++
+		}
+	}
+	if inbound == 0 || outbound == 0 {
+		t.Fatalf("trace should have both inbound and outbound msgs, got %d inbound, %d outbound", inbound, outbound)
+	}
+
+	steps := Replay(meta, records, node.dpor)
+	if len(steps) != inbound {
+		t.Fatalf("replayed steps mismatch: got %d, want %d", len(steps), inbound)
+	}
+	for i, step := range steps {
+		if !step.Matches() {
+			t.Errorf("step %d diverges from the trace: replayed %v %d %v %v, recorded %v %d %v %q", i,
+				step.Status.State, step.Status.Number, step.MsgCode, step.Err,
+				step.Record.State, step.Record.Number, step.Record.OutputMsgCode, step.Record.Err)
+		}
+	}
+}
@@ -201,7 +201,7 @@ func (vh *Handler) handleLBFT2Msg(msg p2p.Msg, p *RemoteSigner) error {
 
 	// if number is larger than local current number, sync from remote peer
 	if input.Number() > currentNumber+1 && p != nil {
-		go vh.dpor.SyncFrom(p.Peer)
+		vh.clock.Go(func() { vh.dpor.SyncFrom(p.Peer) })
 		log.Debug("I am slow, syncing with peer", "peer", p.address.Hex())
 	}
 
@@ -245,13 +245,13 @@ func (vh *Handler) handleLBFT2Msg(msg p2p.Msg, p *RemoteSigner) error {
 	}
 
 	// call fsm
-	output, action, outputMsgCode, err := vh.fsm.FSM(input, inputMsgCode)
+	output, action, outputMsgCode, err := vh.runFSM(input, inputMsgCode, p)
 	switch err {
 	case nil:
 		// rebroadcast the preprepare msg
 		switch inputMsgCode {
 		case PreprepareMsgCode:
-			go vh.reBroadcast(input, inputMsgCode)
+			vh.clock.Go(func() { vh.reBroadcast(input, inputMsgCode) })
 		}
 
 	case consensus.ErrUnknownAncestor:
@@ -267,6 +267,13 @@ func (vh *Handler) handleLBFT2Msg(msg p2p.Msg, p *RemoteSigner) error {
 	}
 
 	// handle fsm result
+	vh.broadcastFSMOutput(output, action, outputMsgCode)
+
+	return nil
+}
+
+// broadcastFSMOutput broadcasts the output msgs of the state machine
+func (vh *Handler) broadcastFSMOutput(output []*BlockOrHeader, action Action, outputMsgCode MsgCode) {
 	switch output {
 	case nil:
 		// nil output, do nothing
@@ -274,33 +281,34 @@ func (vh *Handler) handleLBFT2Msg(msg p2p.Msg, p *RemoteSigner) error {
 	default:
 		switch action {
 		case BroadcastMsgAction:
+			vh.traceFSMOutput(output, outputMsgCode)
 
 			switch outputMsgCode {
 			case PrepareMsgCode:
-				go vh.BroadcastPrepareHeader(output[0].header)
+				vh.clock.Go(func() { vh.BroadcastPrepareHeader(output[0].header) })
 
 			case CommitMsgCode:
-				go vh.BroadcastCommitHeader(output[0].header)
+				vh.clock.Go(func() { vh.BroadcastCommitHeader(output[0].header) })
 
 			case PrepareAndCommitMsgCode:
-				go vh.BroadcastPrepareHeader(output[0].header)
-				go vh.BroadcastCommitHeader(output[1].header)
+				vh.clock.Go(func() { vh.BroadcastPrepareHeader(output[0].header) })
+				vh.clock.Go(func() { vh.BroadcastCommitHeader(output[1].header) })
 
 			case ValidateMsgCode:
-				go vh.BroadcastValidateBlock(output[0].block)
+				vh.clock.Go(func() { vh.BroadcastValidateBlock(output[0].block) })
 
 			case ImpeachPrepareMsgCode:
-				go vh.BroadcastPrepareImpeachHeader(output[0].header)
+				vh.clock.Go(func() { vh.BroadcastPrepareImpeachHeader(output[0].header) })
 
 			case ImpeachCommitMsgCode:
-				go vh.BroadcastCommitImpeachHeader(output[0].header)
+				vh.clock.Go(func() { vh.BroadcastCommitImpeachHeader(output[0].header) })
 
 			case ImpeachPrepareAndCommitMsgCode:
-				go vh.BroadcastPrepareImpeachHeader(output[0].header)
-				go vh.BroadcastCommitImpeachHeader(output[1].header)
+				vh.clock.Go(func() { vh.BroadcastPrepareImpeachHeader(output[0].header) })
+				vh.clock.Go(func() { vh.BroadcastCommitImpeachHeader(output[1].header) })
 
 			case ImpeachValidateMsgCode:
-				go vh.BroadcastValidateImpeachBlock(output[0].block)
+				vh.clock.Go(func() { vh.BroadcastValidateImpeachBlock(output[0].block) })
 
 			// unknown msg code
 			default:
@@ -313,8 +321,6 @@ func (vh *Handler) handleLBFT2Msg(msg p2p.Msg, p *RemoteSigner) error {
 		}
 
 	}
-
-	return nil
 }
 
 // ReceiveImpeachPendingBlock receives a block to add to pending block channel
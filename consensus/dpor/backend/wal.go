@@ -0,0 +1,276 @@
+package backend
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	walEntryPrefix = []byte("lbft2-wal-entry-")
+	walHeadKey     = []byte("lbft2-wal-head")
+)
+
+var (
+	// ErrConflictingSign is returned if a validator is about to sign a header conflicting
+	// with a header it signed at the same height
+	ErrConflictingSign = errors.New("conflict with a signed header at the same height")
+
+	// errOutdatedEntry is returned if an entry is lower than the height of the WAL
+	errOutdatedEntry = errors.New("wal entry lower than the height of the wal")
+)
+
+// walEntryType is the kind of a WAL entry
+type walEntryType uint8
+
+const (
+	// walTransitionEntry records a state transition with the msgs to broadcast
+	walTransitionEntry walEntryType = iota
+
+	// walSignEntry records a header signed in a state
+	walSignEntry
+)
+
+// walEntry is an entry of the consensus WAL
+type walEntry struct {
+	Type    walEntryType
+	Number  uint64
+	State   consensus.State
+	Hash    common.Hash     // hash of the signed header, for sign entries
+	Parent  common.Hash     // parent hash of the signed header, for sign entries
+	MsgCode MsgCode         // code of the msgs to broadcast, for transition entries
+	Headers []*types.Header // headers to broadcast with all collected signatures, for transition entries
+}
+
+// walSigned is a header signed at current height
+type walSigned struct {
+	hash   common.Hash
+	parent common.Hash
+}
+
+// walHead is the position of the WAL, entries from First to Next-1 are of height Number
+type walHead struct {
+	First  uint64
+	Next   uint64
+	Number uint64
+}
+
+// WAL is a write-ahead log of LBFT2 consensus state. It records every state transition
+// and every signed header before it is broadcast, so that a restarted validator restores
+// its state machine and never signs a conflicting header at the same height.
+//
+// Only entries of the latest height are kept, entries of lower heights are discarded
+// once an entry of a higher height is written.
+type WAL struct {
+	db   database.Database
+	head walHead
+
+	signed map[consensus.State]walSigned // headers signed at current height
+	last   *walEntry                     // last state transition at current height
+
+	lock sync.Mutex
+}
+
+// NewWAL opens the WAL in given database and replays the entries of the latest height
+func NewWAL(db database.Database) *WAL {
+	w := &WAL{
+		db:     db,
+		signed: make(map[consensus.State]walSigned),
+	}
+
+	if enc, err := db.Get(walHeadKey); err == nil {
+		if err := rlp.DecodeBytes(enc, &w.head); err != nil {
+			log.Warn("failed to decode lbft2 wal head, discarding the wal", "err", err)
+			w.head = walHead{}
+		}
+	}
+
+	for seq := w.head.First; seq < w.head.Next; seq++ {
+		enc, err := db.Get(walEntryKey(seq))
+		if err != nil {
+			log.Warn("lbft2 wal entry is missing", "seq", seq, "err", err)
+			continue
+		}
+		entry := new(walEntry)
+		if err := rlp.DecodeBytes(enc, entry); err != nil {
+			log.Warn("failed to decode lbft2 wal entry", "seq", seq, "err", err)
+			continue
+		}
+		w.apply(entry)
+	}
+
+	return w
+}
+
+// Number returns the height of the entries in the WAL
+func (w *WAL) Number() uint64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.head.Number
+}
+
+// LastTransition returns the last state transition recorded at the latest height, ok is false if none
+func (w *WAL) LastTransition() (number uint64, state consensus.State, msgCode MsgCode, headers []*types.Header, ok bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.last == nil {
+		return 0, consensus.Idle, NoMsgCode, nil, false
+	}
+	return w.last.Number, w.last.State, w.last.MsgCode, w.last.Headers, true
+}
+
+// CheckSign checks if the header with given hash and parent can be signed in given state.
+// It returns ErrConflictingSign if a different header was signed at the same height in a
+// normal(not impeach) state, or if the height is lower than the WAL's, whose signed headers
+// are discarded.
+//
+// A validator may sign the failback impeach blocks at the same height with different
+// timestamps, so impeach headers are checked by their parent instead, as the remote signer
+// does, an impeach header on a different parent at the same height is refused.
+func (w *WAL) CheckSign(number uint64, state consensus.State, hash common.Hash, parent common.Hash) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.checkSign(number, state, hash, parent)
+}
+
+// WriteSign records that the header with given hash and parent is signed in given state,
+// it must be written before the signed header is broadcast. It returns ErrConflictingSign
+// as CheckSign does.
+func (w *WAL) WriteSign(number uint64, state consensus.State, hash common.Hash, parent common.Hash) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if err := w.checkSign(number, state, hash, parent); err != nil {
+		return err
+	}
+
+	return w.append(&walEntry{
+		Type:   walSignEntry,
+		Number: number,
+		State:  state,
+		Hash:   hash,
+		Parent: parent,
+	})
+}
+
+// checkSign checks a header to sign against the signed ones, the caller must hold w.lock
+func (w *WAL) checkSign(number uint64, state consensus.State, hash common.Hash, parent common.Hash) error {
+	if number < w.head.Number {
+		log.Warn("refused to sign a header lower than the wal", "number", number, "state", state, "hash", hash.Hex(), "wal", w.head.Number)
+		return ErrConflictingSign
+	}
+	if number > w.head.Number {
+		return nil
+	}
+
+	for s, signed := range w.signed {
+		if isImpeachState(s) != isImpeachState(state) {
+			continue
+		}
+		if isImpeachState(state) && signed.parent != parent {
+			log.Warn("refused to sign a conflicting impeach header", "number", number, "state", state, "hash", hash.Hex(), "parent", parent.Hex(), "signed", signed.parent.Hex())
+			return ErrConflictingSign
+		}
+		if !isImpeachState(state) && signed.hash != hash {
+			log.Warn("refused to sign a conflicting header", "number", number, "state", state, "hash", hash.Hex(), "signed", signed.hash.Hex())
+			return ErrConflictingSign
+		}
+	}
+	return nil
+}
+
+// WriteTransition records a state transition with the headers to broadcast
+func (w *WAL) WriteTransition(number uint64, state consensus.State, msgCode MsgCode, headers []*types.Header) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.append(&walEntry{
+		Type:    walTransitionEntry,
+		Number:  number,
+		State:   state,
+		MsgCode: msgCode,
+		Headers: headers,
+	})
+}
+
+// append writes an entry and the new head to the database atomically, the caller must hold w.lock
+func (w *WAL) append(entry *walEntry) error {
+	// entries of lower heights are outdated, they are discarded
+	if entry.Number < w.head.Number {
+		return errOutdatedEntry
+	}
+
+	enc, err := rlp.EncodeToBytes(entry)
+	if err != nil {
+		return err
+	}
+
+	var (
+		batch = w.db.NewBatch()
+		head  = w.head
+	)
+
+	// discard entries of the previous height
+	if entry.Number > head.Number {
+		for seq := head.First; seq < head.Next; seq++ {
+			batch.Delete(walEntryKey(seq))
+		}
+		head.First, head.Number = head.Next, entry.Number
+	}
+
+	batch.Put(walEntryKey(head.Next), enc)
+	head.Next++
+
+	encHead, err := rlp.EncodeToBytes(head)
+	if err != nil {
+		return err
+	}
+	batch.Put(walHeadKey, encHead)
+
+	if err := batch.Write(); err != nil {
+		return err
+	}
+
+	if head.Number != w.head.Number {
+		w.signed = make(map[consensus.State]walSigned)
+		w.last = nil
+	}
+	w.head = head
+	w.apply(entry)
+	return nil
+}
+
+// apply applies an entry of current height to the in memory state, the caller must hold w.lock
+func (w *WAL) apply(entry *walEntry) {
+	if entry.Number != w.head.Number {
+		return
+	}
+
+	switch entry.Type {
+	case walSignEntry:
+		w.signed[entry.State] = walSigned{hash: entry.Hash, parent: entry.Parent}
+	case walTransitionEntry:
+		w.last = entry
+	}
+}
+
+func walEntryKey(seq uint64) []byte {
+	key := make([]byte, len(walEntryPrefix)+8)
+	copy(key, walEntryPrefix)
+	binary.BigEndian.PutUint64(key[len(walEntryPrefix):], seq)
+	return key
+}
+
+func isImpeachState(state consensus.State) bool {
+	return state == consensus.ImpeachPrepare || state == consensus.ImpeachCommit
+}
@@ -0,0 +1,165 @@
+package backend
+
+import (
+	"math/big"
+	"testing"
+
+	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestWALConflictingSign(t *testing.T) {
+	wal := NewWAL(database.NewMemDatabase())
+
+	var (
+		hash   = common.HexToHash("0x01")
+		other  = common.HexToHash("0x02")
+		parent = common.HexToHash("0x10")
+	)
+
+	if err := wal.WriteSign(10, consensus.Prepare, hash, parent); err != nil {
+		t.Fatalf("Signing the first header should succeed, got %v", err)
+	}
+	if err := wal.WriteSign(10, consensus.Commit, hash, parent); err != nil {
+		t.Fatalf("Signing the same header in commit state should succeed, got %v", err)
+	}
+	if err := wal.WriteSign(10, consensus.Prepare, other, parent); err != ErrConflictingSign {
+		t.Fatalf("Signing a different header should fail with %v, got %v", ErrConflictingSign, err)
+	}
+	if err := wal.WriteSign(10, consensus.Commit, other, parent); err != ErrConflictingSign {
+		t.Fatalf("Signing a different header should fail with %v, got %v", ErrConflictingSign, err)
+	}
+
+	// impeach headers are checked by parent, they may differ in timestamps
+	for _, h := range []common.Hash{common.HexToHash("0x03"), common.HexToHash("0x04")} {
+		if err := wal.WriteSign(10, consensus.ImpeachPrepare, h, parent); err != nil {
+			t.Fatalf("Signing an impeach header should succeed, got %v", err)
+		}
+	}
+	if err := wal.WriteSign(10, consensus.ImpeachCommit, common.HexToHash("0x03"), parent); err != nil {
+		t.Fatalf("Signing an impeach header should succeed, got %v", err)
+	}
+	for _, state := range []consensus.State{consensus.ImpeachPrepare, consensus.ImpeachCommit} {
+		if err := wal.CheckSign(10, state, common.HexToHash("0x05"), common.HexToHash("0x11")); err != ErrConflictingSign {
+			t.Fatalf("Signing an impeach header on a different parent should fail with %v, got %v", ErrConflictingSign, err)
+		}
+	}
+
+	// a higher height is not affected
+	if err := wal.WriteSign(11, consensus.Prepare, other, parent); err != nil {
+		t.Fatalf("Signing a header at a higher height should succeed, got %v", err)
+	}
+	if wal.Number() != 11 {
+		t.Fatalf("WAL number should be 11, got %d", wal.Number())
+	}
+}
+
+func TestWALReopen(t *testing.T) {
+	db := database.NewMemDatabase()
+	wal := NewWAL(db)
+
+	header := &types.Header{Number: big.NewInt(5), ParentHash: common.HexToHash("0x10")}
+	hash, parent := header.Hash(), header.ParentHash
+
+	if err := wal.WriteSign(5, consensus.Prepare, hash, parent); err != nil {
+		t.Fatal(err)
+	}
+	if err := wal.WriteTransition(5, consensus.Prepare, PrepareMsgCode, []*types.Header{header}); err != nil {
+		t.Fatal(err)
+	}
+
+	wal = NewWAL(db)
+	if err := wal.WriteSign(5, consensus.Commit, common.HexToHash("0x02"), parent); err != ErrConflictingSign {
+		t.Fatalf("Signed headers should be restored, got %v", err)
+	}
+	if err := wal.WriteSign(5, consensus.ImpeachPrepare, common.HexToHash("0x04"), parent); err != nil {
+		t.Fatal(err)
+	}
+
+	wal = NewWAL(db)
+	if err := wal.WriteSign(5, consensus.ImpeachCommit, common.HexToHash("0x05"), common.HexToHash("0x11")); err != ErrConflictingSign {
+		t.Fatalf("Signed impeach headers should be restored, got %v", err)
+	}
+	number, state, msgCode, headers, ok := wal.LastTransition()
+	if !ok || number != 5 || state != consensus.Prepare || msgCode != PrepareMsgCode || len(headers) != 1 || headers[0].Hash() != hash {
+		t.Fatalf("Last transition is not restored, got %d %v %v %d %v", number, state, msgCode, len(headers), ok)
+	}
+
+	// entries of a lower height are discarded once a higher height is written
+	if err := wal.WriteTransition(6, consensus.Idle, NoMsgCode, nil); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := db.Has(walEntryKey(0)); has {
+		t.Fatal("Entries of a lower height should be deleted")
+	}
+	if err := wal.WriteSign(5, consensus.Prepare, common.HexToHash("0x03"), parent); err != ErrConflictingSign {
+		t.Fatalf("Signing below the wal should fail with %v, got %v", ErrConflictingSign, err)
+	}
+	if err := wal.WriteTransition(5, consensus.Prepare, PrepareMsgCode, nil); err != errOutdatedEntry {
+		t.Fatalf("Outdated transitions should fail with %v, got %v", errOutdatedEntry, err)
+	}
+
+	wal = NewWAL(db)
+	if number, _, _, _, _ := wal.LastTransition(); number != 6 || wal.Number() != 6 {
+		t.Fatalf("WAL should be at height 6, got %d", number)
+	}
+}
+
+func TestLBFT2Restore(t *testing.T) {
+	net := newSimNetwork(t, defaultSimConfig())
+	net.stop()
+
+	var (
+		validators = net.validatorNodes()
+		node       = validators[0]
+		parent     = node.dpor.GetCurrentBlock()
+		block      = net.proposerNodes()[0].dpor.createBlock(parent)
+		header     = block.Header()
+		db         = database.NewMemDatabase()
+	)
+
+	// collect a prepare certificate from other validators
+	for _, v := range validators[1 : 2*net.config.faulty+2] {
+		sig, err := crypto.Sign(simHashWithState(header.Hash(), consensus.Prepare), v.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, addr := range net.validators {
+			if addr == v.addr {
+				copy(header.Dpor.Sigs[i][:], sig)
+			}
+		}
+	}
+
+	fsm := NewLBFT2(net.config.faulty, node.dpor, node.handler.ReceiveImpeachPendingBlock, NewEvidencePool(db), db)
+	output, _, msgCode, err := fsm.FSM(NewBOHFromHeader(header), PrepareMsgCode)
+	if err != nil || msgCode != PrepareAndCommitMsgCode || fsm.State() != consensus.Commit {
+		t.Fatalf("FSM should sign the commit msg, got %v %v %v", msgCode, fsm.State(), err)
+	}
+
+	// restart the state machine
+	fsm = NewLBFT2(net.config.faulty, node.dpor, node.handler.ReceiveImpeachPendingBlock, NewEvidencePool(db), db)
+	restored, restoredMsgCode := fsm.Restore()
+	if restoredMsgCode != msgCode || len(restored) != len(output) || fsm.State() != consensus.Commit || fsm.Number() != header.Number.Uint64() {
+		t.Fatalf("FSM is not restored, got %v %d %v", restoredMsgCode, len(restored), fsm.State())
+	}
+	for i := range output {
+		if restored[i].Hash() != output[i].Hash() {
+			t.Fatalf("Restored msg %d mismatches, got %x, want %x", i, restored[i].Hash(), output[i].Hash())
+		}
+	}
+	bi := NewBlockIdentifier(header.Number.Uint64(), header.Hash())
+	if !fsm.prepareCertificate(bi) {
+		t.Fatal("Prepare certificate should be restored")
+	}
+
+	// never sign a conflicting header at the same height after restart
+	conflict := types.CopyHeader(header)
+	conflict.Time = new(big.Int).Add(header.Time, common.Big1)
+	if _, err := fsm.composeCommitMsg(conflict); err != ErrConflictingSign {
+		t.Fatalf("Signing a conflicting header should fail with %v, got %v", ErrConflictingSign, err)
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpor
+
+import (
+	"errors"
+
+	"bitbucket.org/cpchain/chain/commons/crypto/blskey"
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/consensus/dpor/signer"
+	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// After the BLS fork, validators sign with bls keys registered in the genesis
+// instead of their accounts. Keys are part of the chain config stored with the
+// genesis block, and they can not be changed once the fork is passed, as the
+// signatures of past blocks are verified with them.
+//
+// Each signature is put in the signer's position of Sigs as an ecdsa one is, so
+// LBFT2 collects them the same way. Once a commit certificate is collected, commit
+// signatures are aggregated into AggSig with a bitmap of the signers' positions and
+// Sigs is left empty, so that a header carries a single signature and is verified
+// with one pairing check. Proposers still seal blocks with their accounts, and sign
+// election seeds with their bls keys after the ElectionSeed fork, see seed.go.
+
+var (
+	// errNoBLSKey is returned if a validator signs a header without a bls key after the BLS fork
+	errNoBLSKey = errors.New("no bls key to sign with")
+
+	// errAggregatedSigs is returned if signatures of a header in consensus are aggregated
+	errAggregatedSigs = errors.New("signatures are aggregated")
+
+	// errInvalidAggregateSig is returned if the aggregate signature of a header is invalid
+	errInvalidAggregateSig = errors.New("invalid aggregate signature")
+)
+
+// blsRegistry is the bls public keys of validators and proposers
+type blsRegistry map[common.Address]*blskey.PublicKey
+
+// newBLSRegistry returns the registry of keys in the chain config, keys without a
+// valid proof of possession are ignored
+func newBLSRegistry(keys []*configs.BLSKey) blsRegistry {
+	registry := make(blsRegistry)
+	for _, key := range keys {
+		pk, err := blskey.PublicKeyFromBytes(key.PublicKey)
+		if err != nil {
+			log.Warn("invalid bls public key is registered", "address", key.Address.Hex(), "err", err)
+			continue
+		}
+		proof, err := blskey.SignatureFromBytes(key.Possession)
+		if err != nil || !pk.VerifyPossession(proof) {
+			log.Warn("invalid proof of possession of bls public key", "address", key.Address.Hex())
+			continue
+		}
+		registry[key.Address] = pk
+	}
+	return registry
+}
+
+// newBLSProtection returns the slashing protection of headers signed with the bls
+// key, the local signer protects ones signed with the account
+func newBLSProtection(db database.Database) *signer.SlashingProtection {
+	if db == nil {
+		return nil
+	}
+	return signer.NewSlashingProtection(db)
+}
+
+// SetBLSKey sets the bls key to sign with as a validator after the BLS fork, and
+// as a proposer after the ElectionSeed fork
+func (d *Dpor) SetBLSKey(key *blskey.SecretKey) {
+	d.coinbaseLock.Lock()
+	defer d.coinbaseLock.Unlock()
+
+	d.blsKey = key
+}
+
+// signBLS returns the bls signature of a header in given state, in the form of a
+// DporSignature. A different header at the same height and state is refused as the
+// account refuses to sign it.
+func (d *Dpor) signBLS(header *types.Header, state consensus.State) ([]byte, error) {
+	d.coinbaseLock.RLock()
+	key, coinbase := d.blsKey, d.coinbase
+	d.coinbaseLock.RUnlock()
+
+	if key == nil {
+		return nil, errNoBLSKey
+	}
+
+	kind, err := signer.KindOf(state)
+	if err != nil {
+		return nil, err
+	}
+	err = d.blsProtection.Check(&signer.Request{
+		Account: coinbase,
+		Kind:    kind,
+		Number:  header.Number.Uint64(),
+		Hash:    d.dh.sigHash(header),
+		Parent:  header.ParentHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hashToSign, err := hashBytesWithState(d.dh.sigHash(header).Bytes(), state)
+	if err != nil {
+		return nil, err
+	}
+
+	var sig types.DporSignature
+	copy(sig[:], key.Sign(hashToSign).Bytes())
+	return sig[:], nil
+}
+
+// recoverBLSSigs returns the validators with valid bls signatures of a header in
+// their positions and the signatures, invalid ones are ignored
+func (d *Dpor) recoverBLSSigs(header *types.Header, state consensus.State, validators []common.Address) ([]common.Address, []types.DporSignature, error) {
+	if header.Dpor.Aggregated() {
+		return nil, nil, errAggregatedSigs
+	}
+
+	hashToSign, err := hashBytesWithState(d.dh.sigHash(header).Bytes(), state)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		signers []common.Address
+		sigs    []types.DporSignature
+	)
+	for i, sig := range header.Dpor.Sigs {
+		if i >= len(validators) || sig.IsEmpty() {
+			continue
+		}
+		pk, ok := d.blsKeys[validators[i]]
+		if !ok {
+			continue
+		}
+		s, err := blskey.SignatureFromBytes(sig[:blskey.SignatureLength])
+		if err != nil || !pk.Verify(hashToSign, s) {
+			continue
+		}
+		signers = append(signers, validators[i])
+		sigs = append(sigs, sig)
+	}
+	return signers, sigs, nil
+}
+
+// AggregateSigs returns a copy of the header with its commit signatures aggregated
+// if the header is after the BLS fork, otherwise the header itself
+func (d *Dpor) AggregateSigs(header *types.Header) (*types.Header, error) {
+	number := header.Number.Uint64()
+	if !d.config.IsBLS(number) || header.Dpor.Aggregated() {
+		return header, nil
+	}
+
+	validators, err := d.ValidatorsOf(number)
+	if err != nil {
+		return nil, err
+	}
+	return d.aggregateSigs(header, validators)
+}
+
+// aggregateSigs returns a copy of the header with commit signatures of the validators aggregated
+func (d *Dpor) aggregateSigs(header *types.Header, validators []common.Address) (*types.Header, error) {
+	var (
+		sigs   []*blskey.Signature
+		bitmap = make([]byte, (len(validators)+7)/8)
+	)
+	for i, sig := range header.Dpor.Sigs {
+		if i >= len(validators) || sig.IsEmpty() {
+			continue
+		}
+		s, err := blskey.SignatureFromBytes(sig[:blskey.SignatureLength])
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, s)
+		bitmap[i/8] |= 1 << uint(i%8)
+	}
+
+	aggregated := types.CopyHeader(header)
+	aggregated.Dpor.Sigs = nil
+	aggregated.Dpor.AggSig = blskey.AggregateSignatures(sigs).Bytes()
+	aggregated.Dpor.AggBitmap = bitmap
+
+	// signatures are verified one by one when they are collected, check the
+	// aggregate once anyway before broadcasting it
+	if _, err := d.verifyAggregateSig(aggregated, validators); err != nil {
+		return nil, err
+	}
+	return aggregated, nil
+}
+
+// verifyAggregateSig verifies the aggregate commit signature of a header with one
+// pairing check, and returns the validators in it
+func (d *Dpor) verifyAggregateSig(header *types.Header, validators []common.Address) ([]common.Address, error) {
+	for _, sig := range header.Dpor.Sigs {
+		if !sig.IsEmpty() {
+			return nil, errInvalidAggregateSig
+		}
+	}
+	return d.verifyAggregate(header, header.Dpor.AggSig, header.Dpor.AggBitmap, validators)
+}
+
+// verifyAggregate verifies an aggregate commit signature of a header with the bitmap
+// of positions of validators in it, and returns the validators in it
+func (d *Dpor) verifyAggregate(header *types.Header, aggSig []byte, bitmap []byte, validators []common.Address) ([]common.Address, error) {
+	if len(bitmap) != (len(validators)+7)/8 {
+		return nil, errInvalidAggregateSig
+	}
+	signedBy := func(pos int) bool {
+		return bitmap[pos/8]&(1<<uint(pos%8)) != 0
+	}
+	for i := len(validators); i < len(bitmap)*8; i++ {
+		if signedBy(i) {
+			return nil, errInvalidAggregateSig
+		}
+	}
+
+	var (
+		signers []common.Address
+		pks     []*blskey.PublicKey
+	)
+	for i, validator := range validators {
+		if !signedBy(i) {
+			continue
+		}
+		pk, ok := d.blsKeys[validator]
+		if !ok {
+			return nil, errInvalidAggregateSig
+		}
+		signers = append(signers, validator)
+		pks = append(pks, pk)
+	}
+
+	sig, err := blskey.SignatureFromBytes(aggSig)
+	if err != nil {
+		return nil, errInvalidAggregateSig
+	}
+	hashToSign, err := hashBytesWithState(d.dh.sigHash(header).Bytes(), consensus.Commit)
+	if err != nil {
+		return nil, err
+	}
+	if !blskey.AggregatePublicKeys(pks).Verify(hashToSign, sig) {
+		return nil, errInvalidAggregateSig
+	}
+	return signers, nil
+}
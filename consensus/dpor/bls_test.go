@@ -0,0 +1,126 @@
+package dpor
+
+import (
+	"math/big"
+	"testing"
+
+	"bitbucket.org/cpchain/chain/commons/crypto/blskey"
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/consensus/dpor/signer"
+	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func newBLSTestDpor(t *testing.T) (*Dpor, []*blskey.SecretKey, []common.Address) {
+	var (
+		keys       []*blskey.SecretKey
+		validators []common.Address
+		registered []*configs.BLSKey
+	)
+	for i := 0; i < 4; i++ {
+		key, err := blskey.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		keys = append(keys, key)
+		validators = append(validators, addr)
+		registered = append(registered, &configs.BLSKey{
+			Address:    addr,
+			PublicKey:  key.PublicKey().Bytes(),
+			Possession: key.ProvePossession().Bytes(),
+		})
+	}
+
+	// a key without a valid proof of possession is not registered
+	registered[3].Possession = keys[0].ProvePossession().Bytes()
+
+	config := &configs.DporConfig{
+		TermLen:      4,
+		ViewLen:      1,
+		FaultyNumber: 1,
+		BLSKeys:      registered,
+		Forks:        &configs.DporForks{BLSBlock: big.NewInt(0)},
+	}
+	d := New(config, database.NewMemDatabase())
+	d.SetCurrentSnap(newSnapshot(config, 1, common.Hash{}, validators, validators, NormalMode))
+	return d, keys, validators
+}
+
+func TestBLSSigs(t *testing.T) {
+	d, keys, validators := newBLSTestDpor(t)
+
+	header := &types.Header{Number: big.NewInt(2), Coinbase: validators[0], Time: big.NewInt(0)}
+	header.Dpor.Sigs = make([]types.DporSignature, 4)
+
+	if _, err := d.signBLS(header, consensus.Commit); err != errNoBLSKey {
+		t.Fatalf("signed without a bls key, got %v", err)
+	}
+	for i, key := range keys {
+		d.SetBLSKey(key)
+		sig, err := d.signBLS(header, consensus.Commit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(header.Dpor.Sigs[i][:], sig)
+	}
+
+	// the key of the last validator is not registered
+	signers, _, err := d.ECRecoverSigs(header, consensus.Commit)
+	if err != nil || len(signers) != 3 || signers[2] != validators[2] {
+		t.Fatalf("failed to recover bls signers, got %v, %v", signers, err)
+	}
+	if signers, _, _ := d.ECRecoverSigs(header, consensus.Prepare); len(signers) != 0 {
+		t.Errorf("commit signatures are recovered as prepare ones, got %v", signers)
+	}
+
+	header.Dpor.Sigs[3] = types.DporSignature{}
+	aggregated, err := d.AggregateSigs(header)
+	if err != nil {
+		t.Fatalf("failed to aggregate signatures: %v", err)
+	}
+	if len(aggregated.Dpor.Sigs) != 0 || len(aggregated.Dpor.AggSig) != blskey.SignatureLength || aggregated.Hash() != header.Hash() {
+		t.Fatalf("aggregated header mismatch: %v", aggregated.Dpor)
+	}
+	if signers, err := d.verifyAggregateSig(aggregated, validators); err != nil || len(signers) != 3 {
+		t.Fatalf("failed to verify aggregate signature, got %v, %v", signers, err)
+	}
+	if _, _, err := d.ECRecoverSigs(aggregated, consensus.Commit); err != errAggregatedSigs {
+		t.Errorf("aggregated signatures are recovered, got %v", err)
+	}
+
+	// the aggregate signature is bound to its signers
+	aggregated.Dpor.AggBitmap[0] &^= 1
+	if _, err := d.verifyAggregateSig(aggregated, validators); err != errInvalidAggregateSig {
+		t.Errorf("aggregate signature with a wrong bitmap is verified, got %v", err)
+	}
+	aggregated.Dpor.AggBitmap[0] |= 1 | 1<<4
+	if _, err := d.verifyAggregateSig(aggregated, validators); err != errInvalidAggregateSig {
+		t.Errorf("aggregate signature with bits beyond validators is verified, got %v", err)
+	}
+}
+
+func TestBLSSlashingProtection(t *testing.T) {
+	d, keys, _ := newBLSTestDpor(t)
+	d.SetBLSKey(keys[0])
+
+	header := &types.Header{Number: big.NewInt(2), Time: big.NewInt(0)}
+	if _, err := d.signBLS(header, consensus.Commit); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.signBLS(header, consensus.Commit); err != nil {
+		t.Errorf("failed to sign the same header again: %v", err)
+	}
+
+	// a different header at the same height is refused in the same state
+	other := types.CopyHeader(header)
+	other.Time = big.NewInt(1)
+	if _, err := d.signBLS(other, consensus.Commit); err != signer.ErrDoubleSign {
+		t.Errorf("a different header is signed at the same height, got %v", err)
+	}
+	if _, err := d.signBLS(other, consensus.Prepare); err != nil {
+		t.Errorf("failed to sign in another state: %v", err)
+	}
+}
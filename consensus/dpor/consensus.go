@@ -27,6 +27,7 @@ import (
 	"bitbucket.org/cpchain/chain/configs"
 	"bitbucket.org/cpchain/chain/consensus"
 	"bitbucket.org/cpchain/chain/consensus/dpor/backend"
+	"bitbucket.org/cpchain/chain/consensus/dpor/signer"
 	"bitbucket.org/cpchain/chain/core/state"
 	"bitbucket.org/cpchain/chain/types"
 	"github.com/ethereum/go-ethereum/common"
@@ -49,6 +50,13 @@ var (
 	// that is not part of the local blockchain.
 	errUnknownBlock = errors.New("unknown block")
 
+	// errNoRptService is returned when rpts are requested before the rpt service is set up.
+	errNoRptService = errors.New("rpt service is not available")
+
+	// errNotCandidate is returned when the rpt of an address not campaigning at
+	// the requested block is requested.
+	errNotCandidate = errors.New("not a candidate at the block")
+
 	// errInvalidCheckpointBeneficiary is returned if a checkpoint/epoch transition
 	// block has a beneficiary set to non-zeroes.
 	errInvalidCheckpointBeneficiary = errors.New("beneficiary in checkpoint block non-zero")
@@ -180,7 +188,9 @@ func (d *Dpor) PrepareBlock(chain consensus.ChainReader, header *types.Header) e
 	if header.Timestamp().Before(time.Now()) {
 		header.SetTimestamp(time.Now())
 	}
-	return nil
+
+	// Set the election seed contributed by validators of parent
+	return d.prepareSeed(snap, header, parent)
 }
 
 // TryCampaign tries to start campaign
@@ -262,17 +272,23 @@ func (d *Dpor) Finalize(chain consensus.ChainReader, header *types.Header, state
 
 // Authorize injects a private key into the consensus engine to mint new blocks
 // with.
-func (d *Dpor) Authorize(signer common.Address, signFn backend.SignFn) {
+func (d *Dpor) Authorize(coinbase common.Address, signFn backend.SignFn) {
+	d.AuthorizeSigner(coinbase, signer.NewLocalSigner(signFn, nil))
+}
+
+// AuthorizeSigner injects a signer, e.g. a remote signer, into the consensus engine
+// to mint new blocks with.
+func (d *Dpor) AuthorizeSigner(coinbase common.Address, s signer.Signer) {
 	d.coinbaseLock.Lock()
-	d.coinbase = signer
-	d.signFn = signFn
+	d.coinbase = coinbase
+	d.signer = s
 	d.coinbaseLock.Unlock()
 
 	if d.handler == nil {
 		d.handler = backend.NewHandler(d.config, d.Coinbase(), d.db)
 	}
-	if d.handler.Coinbase() != signer {
-		d.handler.SetCoinbase(signer)
+	if d.handler.Coinbase() != coinbase {
+		d.handler.SetCoinbase(coinbase)
 	}
 }
 
@@ -285,7 +301,6 @@ func (d *Dpor) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan
 		number = header.Number.Uint64()
 
 		coinbase = d.Coinbase()
-		signFn   = d.SignHash
 	)
 
 	// Sealing the genesis block is not supported
@@ -332,7 +347,11 @@ func (d *Dpor) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan
 	}
 
 	// Proposer seals the block with signature
-	sighash, err := signFn(d.dh.sigHash(header).Bytes())
+	sighash, err := d.Sign(&signer.Request{
+		Kind:   signer.KindSeal,
+		Number: number,
+		Hash:   d.dh.sigHash(header),
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -394,6 +413,10 @@ func (d *Dpor) GetCalcRptInfo(address common.Address, addresses []common.Address
 	if rptService == nil {
 		log.Fatal("dpor rpt service is nil")
 	}
-	rp := rptService.CalcRptInfo(address, addresses, blockNum)
+	rp, err := rptService.CalcRptInfo(address, addresses, blockNum)
+	if err != nil {
+		log.Warn("failed to calc rpt", "addr", address.Hex(), "number", blockNum, "err", err)
+		return 0
+	}
 	return rp.Rpt
 }
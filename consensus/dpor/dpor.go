@@ -6,17 +6,19 @@ import (
 	"sync/atomic"
 	"time"
 
-	"bitbucket.org/cpchain/chain/accounts"
 	"bitbucket.org/cpchain/chain/admission"
+	"bitbucket.org/cpchain/chain/commons/crypto/blskey"
 	"bitbucket.org/cpchain/chain/commons/log"
 	"bitbucket.org/cpchain/chain/configs"
 	"bitbucket.org/cpchain/chain/consensus"
 	"bitbucket.org/cpchain/chain/consensus/dpor/backend"
 	"bitbucket.org/cpchain/chain/consensus/dpor/rpt"
+	"bitbucket.org/cpchain/chain/consensus/dpor/signer"
 	"bitbucket.org/cpchain/chain/contracts/dpor/rnode"
 	"bitbucket.org/cpchain/chain/database"
 	"bitbucket.org/cpchain/chain/types"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/p2p"
 	lru "github.com/hashicorp/golang-lru"
 )
@@ -57,17 +59,30 @@ type Dpor struct {
 	finalSigs   *lru.ARCCache // Final signatures of recent blocks to speed up mining
 	prepareSigs *lru.ARCCache // The signatures of recent blocks for 'prepared' state
 
+	impeachLevels *lru.ARCCache // Back-off levels of impeach timeouts of recent heights
+
 	signedBlocks *signedBlocksRecord // Record signed blocks.
 
+	evidence *backend.EvidencePool // Evidences of equivocating committee members
+
+	blsKeys       blsRegistry                // Bls public keys of validators registered in the genesis
+	blsProtection *signer.SlashingProtection // Records of headers signed with the bls key, nil without a database
+
+	events *consensusEvents // Consensus events posted on new chain heads
+
 	currentSnap     *DporSnapshot // Current snapshot
 	currentSnapLock sync.RWMutex
 
-	coinbase     common.Address // Coinbase of the miner(proposer or validator)
-	signFn       backend.SignFn // Sign function to authorize hashes with
-	coinbaseLock sync.RWMutex   // Protects the signer fields
+	coinbase     common.Address    // Coinbase of the miner(proposer or validator)
+	signer       signer.Signer     // Signer to sign seals and signatures with
+	blsKey       *blskey.SecretKey // Key to sign signatures with after the BLS fork
+	coinbaseLock sync.RWMutex      // Protects the signer fields
 
 	handler *backend.Handler
 
+	tracePath string                 // Path of the file to record consensus msgs into, no trace if empty
+	trace     *backend.TraceRecorder // Recorder of consensus msgs
+
 	isMiner     bool
 	isMinerLock sync.RWMutex
 
@@ -103,17 +118,13 @@ type Dpor struct {
 	validatorInitialized int32
 }
 
-// SignHash signs a hash msg with dpor coinbase account
-func (d *Dpor) SignHash(hash []byte) ([]byte, error) {
+// Sign signs a consensus msg with dpor coinbase account
+func (d *Dpor) Sign(req *signer.Request) ([]byte, error) {
 	d.coinbaseLock.Lock()
 	defer d.coinbaseLock.Unlock()
 
-	var (
-		coinbase = d.coinbase
-		account  = accounts.Account{Address: coinbase}
-	)
-
-	return d.signFn(account, hash)
+	req.Account = d.coinbase
+	return d.signer.Sign(req)
 }
 
 // IsMiner returns if local coinbase is a miner(proposer or validator)
@@ -199,18 +210,24 @@ func New(config *configs.DporConfig, db database.Database) *Dpor {
 	recentSnaps, _ := lru.NewARC(inMemorySnapshots)
 	finalSigs, _ := lru.NewARC(inMemorySignatures)
 	preparedSigs, _ := lru.NewARC(inMemorySignatures)
+	impeachLevels, _ := lru.NewARC(inMemoryImpeachLevels)
 
 	signedBlocks := newSignedBlocksRecord(db)
 
 	return &Dpor{
-		dh:           &defaultDporHelper{&defaultDporUtil{}},
-		config:       &conf,
-		handler:      backend.NewHandler(&conf, common.Address{}, db),
-		db:           db,
-		recentSnaps:  recentSnaps,
-		finalSigs:    finalSigs,
-		prepareSigs:  preparedSigs,
-		signedBlocks: signedBlocks,
+		dh:            &defaultDporHelper{&defaultDporUtil{}},
+		config:        &conf,
+		handler:       backend.NewHandler(&conf, common.Address{}, db),
+		db:            db,
+		recentSnaps:   recentSnaps,
+		finalSigs:     finalSigs,
+		prepareSigs:   preparedSigs,
+		impeachLevels: impeachLevels,
+		signedBlocks:  signedBlocks,
+		evidence:      backend.NewEvidencePool(db),
+		blsKeys:       newBLSRegistry(conf.BLSKeys),
+		blsProtection: newBLSProtection(db),
+		events:        &consensusEvents{},
 	}
 }
 
@@ -265,6 +282,16 @@ func (d *Dpor) MarkAsSigned(number uint64, hash common.Hash) error {
 	return d.signedBlocks.markAsSigned(number, hash)
 }
 
+// Evidences returns evidences of equivocation at given height
+func (d *Dpor) Evidences(number uint64) ([]*backend.Evidence, error) {
+	return d.evidence.Evidences(number)
+}
+
+// SubscribeEvidence subscribes to newly detected evidences of equivocation
+func (d *Dpor) SubscribeEvidence(ch chan<- *backend.Evidence) event.Subscription {
+	return d.evidence.SubscribeEvidence(ch)
+}
+
 // SetChain is called by test file to assign the value of Dpor.chain, as well as DPor.currentSnapshot
 func (d *Dpor) SetChain(blockchain consensus.ChainReadWriter) {
 	d.chain = blockchain
@@ -289,6 +316,12 @@ func (d *Dpor) SetupAsValidator(blockchain consensus.ChainReadWriter, server *p2
 	return
 }
 
+// SetConsensusTrace sets the path of the file to record consensus msgs and state
+// transitions into once the handler starts
+func (d *Dpor) SetConsensusTrace(path string) {
+	d.tracePath = path
+}
+
 // StartMining starts to create a handler and start it.
 func (d *Dpor) StartMining(blockchain consensus.ChainReadWriter, server *p2p.Server, pmBroadcastBlockFn BroadcastBlockFn, pmSyncFromPeerFn SyncFromPeerFn, pmSyncFromBestPeerFn SyncFromBestPeerFn) {
 	running := atomic.LoadInt32(&d.runningMiner) > 0
@@ -314,7 +347,7 @@ func (d *Dpor) initMinerAndValidator(blockchain consensus.ChainReadWriter, serve
 		handler = d.handler
 	)
 
-	fsm := backend.NewLBFT2(faulty, d, handler.ReceiveImpeachPendingBlock, d.db)
+	fsm := backend.NewLBFT2(faulty, d, handler.ReceiveImpeachPendingBlock, d.evidence, d.db)
 
 	handler.SetServer(server)
 	handler.SetDporService(d)
@@ -334,6 +367,24 @@ func (d *Dpor) initMinerAndValidator(blockchain consensus.ChainReadWriter, serve
 	snap, _ := d.dh.snapshot(d, d.chain, number, hash, nil)
 	d.SetCurrentSnap(snap)
 
+	// the trace is kept open across restarts of mining
+	if d.tracePath != "" && d.trace == nil {
+		trace, err := backend.NewTraceRecorder(d.tracePath, &backend.TraceMeta{
+			Coinbase: d.Coinbase(),
+			Faulty:   faulty,
+			TermLen:  d.config.TermLen,
+			ViewLen:  d.config.ViewLen,
+			Head:     header,
+		})
+		if err != nil {
+			log.Warn("failed to create consensus trace", "path", d.tracePath, "err", err)
+		}
+		d.trace = trace
+	}
+	if d.trace != nil {
+		handler.SetTraceRecorder(d.trace)
+	}
+
 	go d.handler.Start()
 
 	return
@@ -370,8 +421,9 @@ func (d *Dpor) PbftStatus() *consensus.PbftStatus {
 	state := d.State()
 	head := d.chain.CurrentHeader()
 	return &consensus.PbftStatus{
-		State: state,
-		Head:  head,
+		State:          state,
+		Head:           head,
+		ImpeachTimeout: d.ImpeachTimeout(),
 	}
 }
 
@@ -380,9 +432,16 @@ func (d *Dpor) HandleMinedBlock(block *types.Block) error {
 	return d.handler.ReceiveMinedPendingBlock(block)
 }
 
-// ImpeachTimeout returns impeach time out
+// ImpeachTimeout returns impeach time out of the height after current block
 func (d *Dpor) ImpeachTimeout() time.Duration {
-	return d.config.ImpeachTimeout
+	if d.chain == nil {
+		return d.config.ImpeachTimeout
+	}
+	head := d.chain.CurrentHeader()
+	if head == nil {
+		return d.config.ImpeachTimeout
+	}
+	return d.impeachTimeoutAt(head, d.chain.GetHeader)
 }
 
 // SetupAdmission setups admission backend
@@ -391,11 +450,17 @@ func (d *Dpor) SetupAdmission(ac admission.ApiBackend) {
 }
 
 func (d *Dpor) SetRptBackend(backend backend.ClientBackend) {
-	d.rptBackend, _ = rpt.NewRptService(
+	rptBackend, err := rpt.NewRptServiceWithDatabase(
+		d.config,
 		backend,
 		configs.ChainConfigInfo().Dpor.Contracts[configs.ContractRpt],
 		configs.ChainConfigInfo().Dpor.Contracts[configs.ContractRpt2],
+		d.db,
 	)
+	if err != nil {
+		log.Fatal("failed to create rpt service", "err", err)
+	}
+	d.rptBackend = rptBackend
 }
 
 func (d *Dpor) GetRptBackend() rpt.RptService {
@@ -403,7 +468,7 @@ func (d *Dpor) GetRptBackend() rpt.RptService {
 }
 
 func (d *Dpor) SetCandidateBackend(backend backend.ClientBackend) {
-	d.candidateBackend, _ = rpt.NewCandidateService(backend)
+	d.candidateBackend, _ = rpt.NewCandidateService(d.config, backend)
 }
 
 func (d *Dpor) GetCandidateBackend() rpt.CandidateService {
@@ -25,6 +25,7 @@ import (
 	"bitbucket.org/cpchain/chain/configs"
 	"bitbucket.org/cpchain/chain/consensus"
 	"bitbucket.org/cpchain/chain/consensus/dpor/backend"
+	"bitbucket.org/cpchain/chain/consensus/dpor/signer"
 	"bitbucket.org/cpchain/chain/types"
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -186,7 +187,8 @@ func (dh *defaultDporHelper) verifyBasic(dpor *Dpor, chain consensus.ChainReader
 	}
 
 	// If timestamp is in a valid field, wait for it, otherwise, return invalid timestamp.
-	log.Debug("timestamp related values", "parent timestamp", parent.Timestamp(), "block timestamp", header.Timestamp(), "period", dpor.config.PeriodDuration(), "timeout", dpor.config.ImpeachTimeout)
+	impeachTimeout := dpor.impeachTimeoutAt(parent, parentsGetter(chain, parents))
+	log.Debug("timestamp related values", "parent timestamp", parent.Timestamp(), "block timestamp", header.Timestamp(), "period", dpor.config.PeriodDuration(), "timeout", impeachTimeout)
 
 	// Ensure that the block's timestamp is valid
 	if dpor.Mode() == NormalMode && number > dpor.config.MaxInitBlockNumber && !isImpeach {
@@ -194,7 +196,7 @@ func (dh *defaultDporHelper) verifyBasic(dpor *Dpor, chain consensus.ChainReader
 		if header.Timestamp().Before(parent.Timestamp().Add(dpor.config.PeriodDuration())) {
 			return ErrInvalidTimestamp
 		}
-		if header.Timestamp().After(parent.Timestamp().Add(dpor.config.PeriodDuration()).Add(dpor.config.ImpeachTimeout)) {
+		if header.Timestamp().After(parent.Timestamp().Add(dpor.config.PeriodDuration()).Add(impeachTimeout)) {
 			return ErrInvalidTimestamp
 		}
 	}
@@ -204,6 +206,11 @@ func (dh *defaultDporHelper) verifyBasic(dpor *Dpor, chain consensus.ChainReader
 		return ErrInvalidGasLimit
 	}
 
+	// Ensure that the election seed is chained from parent
+	if err := dh.verifySeed(dpor, chain, header, parent, parents); err != nil {
+		return err
+	}
+
 	if isImpeach {
 		return dh.verifyBasicImpeach(dpor, chain, header, parent)
 	}
@@ -586,6 +593,16 @@ func (dh *defaultDporHelper) verifySignatures(dpor *Dpor, chain consensus.ChainR
 
 	expectValidators := snap.ValidatorsOf(number)
 
+	// after the BLS fork, signatures of validators are aggregated
+	if dpor.config.IsBLS(number) {
+		if !header.Dpor.Aggregated() {
+			return errInvalidAggregateSig
+		}
+		if validators, err = dpor.verifyAggregateSig(header, expectValidators); err != nil {
+			return err
+		}
+	}
+
 	// Some debug infos here
 	log.Debug("--------dpor.verifySigs--------")
 	log.Debug("hash", "hash", hash.Hex())
@@ -689,15 +706,24 @@ func (dh *defaultDporHelper) signHeader(dpor *Dpor, chain consensus.ChainReader,
 			return errMultiBlocksInOneHeight
 		}
 
-		// get hash with state
-		hashToSign, err := hashBytesWithState(dpor.dh.sigHash(header).Bytes(), state)
+		kind, err := signer.KindOf(state)
 		if err != nil {
-			log.Warn("failed to get hash bytes with state", "number", number, "hash", hash.Hex(), "state", state)
+			log.Warn("failed to get signature kind of state", "number", number, "hash", hash.Hex(), "state", state)
 			return err
 		}
 
-		// Sign it
-		sighash, err := dpor.SignHash(hashToSign)
+		// Sign it, with the bls key after the BLS fork
+		var sighash []byte
+		if dpor.config.IsBLS(number) {
+			sighash, err = dpor.signBLS(header, state)
+		} else {
+			sighash, err = dpor.Sign(&signer.Request{
+				Kind:   kind,
+				Number: number,
+				Hash:   dpor.dh.sigHash(header),
+				Parent: header.ParentHash,
+			})
+		}
 		if err != nil {
 			log.Warn("signing block header failed", "error", err)
 			return err
@@ -4,13 +4,12 @@ import (
 	"math/big"
 	"time"
 
-	"bitbucket.org/cpchain/chain/accounts"
 	"bitbucket.org/cpchain/chain/configs"
 	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/consensus/dpor/signer"
 	"bitbucket.org/cpchain/chain/types"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/crypto/sha3"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 )
@@ -258,8 +257,9 @@ func (d *Dpor) CreateImpeachBlock() (*types.Block, error) {
 		impeachHeader.Dpor.Proposers = append(impeachHeader.Dpor.Proposers, proposer)
 	}
 	impeachHeader.Dpor.Sigs = make([]types.DporSignature, d.config.ValidatorsLen())
+	impeachHeader.Dpor.Seed = d.impeachSeed(parent.Header())
 
-	timestamp := parent.Timestamp().Add(d.config.PeriodDuration()).Add(d.config.ImpeachTimeout)
+	timestamp := parent.Timestamp().Add(d.config.PeriodDuration()).Add(d.impeachTimeoutAt(parent.Header(), d.chain.GetHeader))
 	impeachHeader.SetTimestamp(timestamp)
 
 	impeach := types.NewBlock(impeachHeader, []*types.Transaction{}, []*types.Receipt{})
@@ -285,8 +285,9 @@ func (d *Dpor) CreateImpeachBlockAt(parentHeader *types.Header) (*types.Block, e
 		impeachHeader.Dpor.Proposers = append(impeachHeader.Dpor.Proposers, proposer)
 	}
 	impeachHeader.Dpor.Sigs = make([]types.DporSignature, d.config.ValidatorsLen())
+	impeachHeader.Dpor.Seed = d.impeachSeed(parent.Header())
 
-	timestamp := parent.Timestamp().Add(d.config.PeriodDuration()).Add(d.config.ImpeachTimeout)
+	timestamp := parent.Timestamp().Add(d.config.PeriodDuration()).Add(d.impeachTimeoutAt(parent.Header(), d.chain.GetHeader))
 	impeachHeader.SetTimestamp(timestamp)
 
 	impeach := types.NewBlock(impeachHeader, []*types.Transaction{}, []*types.Receipt{})
@@ -302,8 +303,17 @@ func (d *Dpor) CreateFailbackImpeachBlocks() (firstImpeachment *types.Block, sec
 		return nil, nil, err
 	}
 
-	failbackTimestamp1 := (time.Now().UnixNano()/int64(configs.DefaultFailbackTimestampSampleSpace) + 1) * int64(configs.DefaultFailbackTimestampSampleSpace)
-	failbackTimestamp2 := failbackTimestamp1 + int64(configs.DefaultFailbackTimestampSampleSpace)
+	// failback impeach blocks wait no shorter than the impeach timeout of the height
+	sampleSpace := configs.DefaultFailbackTimestampSampleSpace
+	number := impeachBlock.NumberU64()
+	if parent := d.chain.GetHeader(impeachBlock.ParentHash(), number-1); d.config.IsAdaptiveImpeach(number) && parent != nil {
+		if timeout := d.impeachTimeoutAt(parent, d.chain.GetHeader); timeout > sampleSpace {
+			sampleSpace = timeout
+		}
+	}
+
+	failbackTimestamp1 := (time.Now().UnixNano()/int64(sampleSpace) + 1) * int64(sampleSpace)
+	failbackTimestamp2 := failbackTimestamp1 + int64(sampleSpace)
 
 	firstImpeachment = types.NewBlock(impeachBlock.Header(), []*types.Transaction{}, []*types.Receipt{})
 	firstImpeachment.RefHeader().SetTimestamp(time.Unix(0, failbackTimestamp1))
@@ -319,6 +329,15 @@ func (d *Dpor) CreateFailbackImpeachBlocks() (firstImpeachment *types.Block, sec
 // TODO: refactor this, return a map[common.Address]dpor.Signature
 func (d *Dpor) ECRecoverSigs(header *types.Header, state consensus.State) ([]common.Address, []types.DporSignature, error) {
 
+	// bls signatures are verified with keys of validators in their positions
+	if number := header.Number.Uint64(); d.config.IsBLS(number) {
+		validators, err := d.ValidatorsOf(number)
+		if err != nil {
+			return nil, nil, err
+		}
+		return d.recoverBLSSigs(header, state, validators)
+	}
+
 	// get hash with state
 	hashToSign, err := hashBytesWithState(d.dh.sigHash(header).Bytes(), state)
 	if err != nil {
@@ -395,16 +414,13 @@ func (d *Dpor) GetMac() (mac string, sig []byte, err error) {
 	split := "|"
 	mac = prefix + split + t
 
-	// make a hash for it
-	var hash common.Hash
-	hasher := sha3.NewKeccak256()
-	hasher.Write([]byte(mac))
-	hasher.Sum(hash[:0])
-
 	log.Debug("generated mac", "mac", mac)
 
 	// sign it!
-	sig, err = d.signFn(accounts.Account{Address: d.Coinbase()}, hash.Bytes())
+	sig, err = d.Sign(&signer.Request{
+		Kind: signer.KindMac,
+		Mac:  mac,
+	})
 
 	return mac, sig, err
 }
@@ -24,6 +24,7 @@ import (
 
 	"bitbucket.org/cpchain/chain/commons/log"
 	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/consensus/dpor/signer"
 	"bitbucket.org/cpchain/chain/database"
 
 	"bitbucket.org/cpchain/chain/types"
@@ -96,7 +97,7 @@ func (d *defaultDporUtil) sigHash(header *types.Header) (hash common.Hash) {
 		header.Dpor.Proposers,
 		header.Dpor.Validators,
 		header.Extra,
-		common.Hash{},
+		header.Dpor.Seed,
 		types.BlockNonce{},
 	}
 	rlp.Encode(hasher, contentToHash)
@@ -234,31 +235,11 @@ func numberToBytes(number uint64) []byte {
 }
 
 func hashBytesWithState(hash []byte, state consensus.State) (signHashBytes []byte, err error) {
-	var (
-		prepreparePrefix = "Prepare"
-	)
-
-	var bytesToSign []byte
-	switch state {
-	case consensus.Prepare, consensus.ImpeachPrepare:
-		bytesToSign = append([]byte(prepreparePrefix), hash...)
-	case consensus.Commit, consensus.ImpeachCommit:
-		bytesToSign = hash
-	default:
+	if _, err := signer.KindOf(state); err != nil {
 		log.Warn("unknown state when signing hash with state", "state", state)
 		// TODO: add new error type here
-		err = nil
 	}
 
-	var signHash common.Hash
-	if len(bytesToSign) > len(hash) {
-		hasher := sha3.NewKeccak256()
-		hasher.Write(bytesToSign)
-		hasher.Sum(signHash[:0])
-	} else {
-		signHash = common.BytesToHash(hash)
-	}
-
-	signHashBytes = signHash.Bytes()
+	signHashBytes = signer.HashWithState(common.BytesToHash(hash), state).Bytes()
 	return
 }
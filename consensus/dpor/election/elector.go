@@ -0,0 +1,273 @@
+// Copyright 2018 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package election
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus/dpor/rpt"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// defaultProposersSeats is the number of seats taken by default proposers in a term
+const defaultProposersSeats = 4
+
+var (
+	errUnknownElector      = errors.New("unknown elector")
+	errInvalidProposersLen = errors.New("invalid length of elected proposers")
+	errNoSeats             = errors.New("seats of election are not available")
+)
+
+// Seats returns the numbers of seats of an election, rpt.RptService implements it.
+// An election fails if they are not available, rpt.RptService returns default ones
+// instead for blocks before the PinnedRpt fork.
+type Seats interface {
+	TotalSeats(number uint64) (int, error)
+	LowRptSeats(number uint64) (int, error)
+	LowRptCount(total int, number uint64) (int, error)
+}
+
+// Context is the input of an election of proposers for a term
+type Context struct {
+	Number           uint64           // block number the election happens at, seats are read in its state
+	Rpts             rpt.RptList      // rpts of candidates
+	Seed             int64            // seed of random numbers, derived from the block hash
+	TermLen          int              // number of proposers to elect
+	DefaultProposers []common.Address // default proposers filling the reserved seats
+	Seats            Seats            // nil if seats are not available
+}
+
+// Elector elects proposers of a term
+type Elector interface {
+	Elect(ctx *Context) ([]common.Address, error)
+}
+
+// ElectorFunc is an adapter to use a function as an Elector
+type ElectorFunc func(ctx *Context) ([]common.Address, error)
+
+// Elect calls f(ctx)
+func (f ElectorFunc) Elect(ctx *Context) ([]common.Address, error) {
+	return f(ctx)
+}
+
+var (
+	electors    = make(map[string]Elector)
+	electorLock sync.RWMutex
+)
+
+func init() {
+	Register(configs.ElectorElect, ElectorFunc(elect1))
+	Register(configs.ElectorElect2, ElectorFunc(elect2))
+}
+
+// Register registers an elector with a name, the name is used in the elector
+// schedule of dpor config. It panics if the name is registered twice.
+func Register(name string, elector Elector) {
+	electorLock.Lock()
+	defer electorLock.Unlock()
+
+	if _, ok := electors[name]; ok {
+		panic(fmt.Sprintf("elector %s is registered twice", name))
+	}
+	electors[name] = elector
+}
+
+// ElectorOf returns the elector registered with the given name
+func ElectorOf(name string) (Elector, error) {
+	electorLock.RLock()
+	defer electorLock.RUnlock()
+
+	elector, ok := electors[name]
+	if !ok {
+		return nil, fmt.Errorf("%v: %s", errUnknownElector, name)
+	}
+	return elector, nil
+}
+
+// ElectAt runs the elector scheduled at the given block number in config
+func ElectAt(config *configs.DporConfig, number uint64, ctx *Context) ([]common.Address, error) {
+	name := config.ElectorOf(number)
+	elector, err := ElectorOf(name)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("elect proposers", "number", number, "elector", name, "seed", ctx.Seed, "termLen", ctx.TermLen)
+
+	proposers, err := elector.Elect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(proposers) != ctx.TermLen {
+		return nil, fmt.Errorf("%v: elector %s, got %d, want %d", errInvalidProposersLen, name, len(proposers), ctx.TermLen)
+	}
+	return proposers, nil
+}
+
+// SeedOf returns the seed of an election run at the given header, it is the
+// election seed carried in the header after the fork, the header hash before.
+func SeedOf(config *configs.DporConfig, header *types.Header) int64 {
+	if config.IsElectionSeed(header.Number.Uint64()) {
+		return header.Dpor.Seed.Big().Int64()
+	}
+	return header.Hash().Big().Int64()
+}
+
+// elect1 elects proposers with Elect, and evenly inserts some default proposers among them
+func elect1(ctx *Context) ([]common.Address, error) {
+	if ctx.TermLen <= defaultProposersSeats {
+		return Elect(ctx.Rpts, ctx.Seed, ctx.TermLen), nil
+	}
+
+	elected := Elect(ctx.Rpts, ctx.Seed, ctx.TermLen-defaultProposersSeats)
+	chosen := choseSomeProposers(ctx.DefaultProposers, ctx.Seed, defaultProposersSeats)
+	return evenlyInsertDefaultProposers(elected, chosen, ctx.Seed, ctx.TermLen), nil
+}
+
+// elect2 elects dynamic seats with Elect2, pads them with default proposers, and
+// evenly inserts some of the other default proposers among them
+func elect2(ctx *Context) ([]common.Address, error) {
+	if ctx.TermLen <= defaultProposersSeats {
+		return Elect(ctx.Rpts, ctx.Seed, ctx.TermLen), nil
+	}
+	if ctx.Seats == nil {
+		return nil, errNoSeats
+	}
+
+	// elect some proposers based on rpts
+	dynamicSeats, err := ctx.Seats.TotalSeats(ctx.Number)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", errNoSeats, err)
+	}
+	lowRptCount, err := ctx.Seats.LowRptCount(ctx.Rpts.Len(), ctx.Number)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", errNoSeats, err)
+	}
+	lowRptSeats, err := ctx.Seats.LowRptSeats(ctx.Number)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", errNoSeats, err)
+	}
+	elected := Elect2(ctx.Rpts, ctx.Seed, dynamicSeats, lowRptCount, lowRptSeats)
+
+	// append default proposers to the end of elected proposers
+	paddingSeats := ctx.TermLen - dynamicSeats - defaultProposersSeats
+	if paddingSeats < 0 || paddingSeats > len(ctx.DefaultProposers) {
+		return nil, fmt.Errorf("%v: %d dynamic seats in a term of %d", errInvalidProposersLen, dynamicSeats, ctx.TermLen)
+	}
+	elected = append(elected, ctx.DefaultProposers[:paddingSeats]...)
+
+	// chose some of the left default proposers
+	left := addressExcept(ctx.DefaultProposers, elected)
+	chosen := choseSomeProposers(left, ctx.Seed, defaultProposersSeats)
+
+	return evenlyInsertDefaultProposers(elected, chosen, ctx.Seed, ctx.TermLen), nil
+}
+
+// choseDefaultProposers choses a batch of proposers from a proposers slice with total count of `defaultProposersNum`
+// by the seed of current snapshot.hash.
+func choseSomeProposers(allProposers []common.Address, seed int64, wantLen int) (defaultProposers []common.Address) {
+
+	var proposers []common.Address
+	for _, p := range allProposers {
+		proposers = append(proposers, p)
+	}
+
+	if len(proposers) > wantLen {
+		randSource := rand.NewSource(seed)
+		myRand := rand.New(randSource)
+
+		for i := 0; i < wantLen; i++ {
+			chosen := myRand.Intn(len(proposers))
+			defaultProposers = append(defaultProposers, proposers[chosen])
+			proposers = append(proposers[:chosen], proposers[chosen+1:]...)
+		}
+		return defaultProposers
+	} else if len(proposers) == wantLen {
+		return proposers
+	}
+	panic("invalid length of given proposer list")
+}
+
+func evenlyInsertDefaultProposers(electedProposers []common.Address, chosenDefaultProposers []common.Address, seed int64, wantLen int) (proposers []common.Address) {
+
+	// panic if length of slices is invalid
+	if len(electedProposers)+len(chosenDefaultProposers) != wantLen {
+		panic("invalid length when evenly inserting default proposers to elected proposers")
+	}
+
+	// generate a random generator
+	randSource := rand.NewSource(seed)
+	myRand := rand.New(randSource)
+
+	slicesNum := len(chosenDefaultProposers)
+
+	if wantLen%slicesNum != 0 {
+		panic("invalid wanted length, not a multiple of 4")
+	}
+
+	step := wantLen / slicesNum
+
+	for i := 0; i < slicesNum; i++ {
+		var slice []common.Address
+
+		// combine two sub slices
+		slice = append(slice, electedProposers[i*(step-1):i*(step-1)+(step-1)]...)
+		slice = append(slice, chosenDefaultProposers[i])
+
+		// get random position of the chosen proposer
+		pos := myRand.Intn(step)
+
+		// swap
+		tmp := slice[len(slice)-1]
+		for j := step - 1; j > pos; j-- {
+			slice[j] = slice[j-1]
+		}
+		slice[pos] = tmp
+
+		// append to proposers
+		proposers = append(proposers, slice...)
+	}
+	return
+}
+
+// addressExcept returns a slice of addresses by remove all addresses in `except` slice from `all` slice
+func addressExcept(all []common.Address, except []common.Address) (result []common.Address) {
+
+	for _, x := range all {
+
+		ready := true
+
+		for _, y := range except {
+			if x == y {
+				ready = false
+				break
+			}
+		}
+
+		if ready {
+			result = append(result, x)
+		}
+	}
+
+	return
+}
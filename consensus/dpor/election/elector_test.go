@@ -0,0 +1,351 @@
+// Copyright 2018 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package election
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus/dpor/rpt"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type fakeSeats struct {
+	total, lowSeats, lowCount int
+	err                       error
+}
+
+func (s *fakeSeats) TotalSeats(uint64) (int, error)       { return s.total, s.err }
+func (s *fakeSeats) LowRptSeats(uint64) (int, error)      { return s.lowSeats, s.err }
+func (s *fakeSeats) LowRptCount(int, uint64) (int, error) { return s.lowCount, s.err }
+
+func newElectionContext(seats Seats) *Context {
+	var (
+		rpts      rpt.RptList
+		proposers []common.Address
+	)
+	for i := 1; i <= 20; i++ {
+		rpts = append(rpts, rpt.Rpt{Address: common.BigToAddress(big.NewInt(int64(i))), Rpt: int64(i * 10)})
+	}
+	for i := 101; i <= 112; i++ {
+		proposers = append(proposers, common.BigToAddress(big.NewInt(int64(i))))
+	}
+	return &Context{Rpts: rpts, Seed: 42, TermLen: 12, DefaultProposers: proposers, Seats: seats}
+}
+
+func TestElectAt(t *testing.T) {
+	config := &configs.DporConfig{
+		TermLen: 12,
+		ViewLen: 3,
+		Forks: &configs.DporForks{
+			Electors: []*configs.ElectorFork{
+				{Block: big.NewInt(0), Name: configs.ElectorElect},
+				{Block: big.NewInt(100), Name: configs.ElectorElect2},
+				{Block: big.NewInt(200), Name: "test-first-candidates"},
+				{Block: big.NewInt(300), Name: "test-unknown"},
+			},
+		},
+	}
+	Register("test-first-candidates", ElectorFunc(func(ctx *Context) ([]common.Address, error) {
+		var proposers []common.Address
+		for _, r := range ctx.Rpts[:ctx.TermLen] {
+			proposers = append(proposers, r.Address)
+		}
+		return proposers, nil
+	}))
+
+	seats := &fakeSeats{total: 6, lowSeats: 2, lowCount: 10}
+	for _, number := range []uint64{1, 100} {
+		proposers, err := ElectAt(config, number, newElectionContext(seats))
+		if err != nil {
+			t.Fatalf("elect at #%d: %v", number, err)
+		}
+		seen := make(map[common.Address]bool)
+		for _, p := range proposers {
+			if seen[p] {
+				t.Errorf("proposer %s is elected twice at #%d", p.Hex(), number)
+			}
+			seen[p] = true
+		}
+	}
+
+	ctx := newElectionContext(seats)
+	proposers, err := ElectAt(config, 200, ctx)
+	if err != nil || !reflect.DeepEqual(proposers[0], ctx.Rpts[0].Address) {
+		t.Errorf("registered elector is not used, got %v, %v", proposers, err)
+	}
+
+	if _, err := ElectAt(config, 300, newElectionContext(seats)); err == nil {
+		t.Error("unknown elector is accepted")
+	}
+	if _, err := ElectAt(config, 100, newElectionContext(nil)); err == nil {
+		t.Error("elect2 is run without seats")
+	}
+	unavailable := &fakeSeats{total: 6, lowSeats: 2, lowCount: 10, err: errors.New("state is not available")}
+	if _, err := ElectAt(config, 100, newElectionContext(unavailable)); err == nil {
+		t.Error("elect2 is run with unavailable seats")
+	}
+}
+
+func TestElectAtInvalidLength(t *testing.T) {
+	config := &configs.DporConfig{Forks: &configs.DporForks{
+		Electors: []*configs.ElectorFork{{Block: big.NewInt(0), Name: "test-short"}},
+	}}
+	Register("test-short", ElectorFunc(func(ctx *Context) ([]common.Address, error) {
+		return nil, nil
+	}))
+	if _, err := ElectAt(config, 1, newElectionContext(nil)); err == nil {
+		t.Error("elected proposers of invalid length are accepted")
+	}
+}
+
+func Test_choseSomeProposers(t *testing.T) {
+	type args struct {
+		proposers []common.Address
+		seed      int64
+		wantLen   int
+	}
+	tests := []struct {
+		name                 string
+		args                 args
+		wantDefaultProposers []common.Address
+	}{
+		// TODO: Add test cases.
+		{
+			name: "1",
+			args: args{
+				proposers: []common.Address{
+					common.HexToAddress("0x0000000000000000000000000000000000000001"),
+					common.HexToAddress("0x0000000000000000000000000000000000000002"),
+				},
+				seed:    0,
+				wantLen: 1,
+			},
+			wantDefaultProposers: []common.Address{
+				common.HexToAddress("0x0000000000000000000000000000000000000001"),
+			},
+		},
+		{
+			name: "2",
+			args: args{
+				proposers: []common.Address{
+					common.HexToAddress("0x0000000000000000000000000000000000000001"),
+					common.HexToAddress("0x0000000000000000000000000000000000000002"),
+				},
+				seed:    0,
+				wantLen: 2,
+			},
+			wantDefaultProposers: []common.Address{
+				common.HexToAddress("0x0000000000000000000000000000000000000001"),
+				common.HexToAddress("0x0000000000000000000000000000000000000002"),
+			},
+		},
+
+		// this will panic, it's correct
+		// {
+		// 	name: "1",
+		// 	args: args{
+		// 		proposers: []common.Address{
+		// 			common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		// 			common.HexToAddress("0x0000000000000000000000000000000000000002"),
+		// 		},
+		// 		seed:    0,
+		// 		wantLen: 3,
+		// 	},
+		// 	wantDefaultProposers: nil,
+		// },
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotDefaultProposers := choseSomeProposers(tt.args.proposers, tt.args.seed, tt.args.wantLen); !reflect.DeepEqual(gotDefaultProposers, tt.wantDefaultProposers) {
+				t.Errorf("choseSomeProposers() = %v, want %v", gotDefaultProposers, tt.wantDefaultProposers)
+			}
+		})
+	}
+}
+
+func Test_choseSomeProposers2(t *testing.T) {
+	proposers := []common.Address{
+		common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		common.HexToAddress("0x0000000000000000000000000000000000000002"),
+	}
+
+	seed := int64(0)
+	wantLen := 1
+
+	chosenProposers := choseSomeProposers(proposers, seed, wantLen)
+
+	fmt.Println("---------------------------")
+	fmt.Println("all proposers")
+	for i, ep := range proposers {
+		fmt.Println("proposer", "idx", i, "addr", ep.Hex())
+	}
+	fmt.Println("---------------------------")
+
+	fmt.Println("---------------------------")
+	fmt.Println("chosen  proposers")
+	for i, ep := range chosenProposers {
+		fmt.Println("proposer", "idx", i, "addr", ep.Hex())
+	}
+	fmt.Println("---------------------------")
+
+}
+
+func Test_evenlyInsertDefaultProposers(t *testing.T) {
+	type args struct {
+		electedProposers       []common.Address
+		chosenDefaultProposers []common.Address
+		seed                   int64
+		wantLen                int
+	}
+	tests := []struct {
+		name          string
+		args          args
+		wantProposers []common.Address
+	}{
+		// TODO: Add test cases.
+
+		{
+			name: "1",
+			args: args{
+				electedProposers: []common.Address{
+					common.HexToAddress("0x0000000000000000000000000000000000000001"),
+					common.HexToAddress("0x0000000000000000000000000000000000000002"),
+					common.HexToAddress("0x0000000000000000000000000000000000000003"),
+					common.HexToAddress("0x0000000000000000000000000000000000000004"),
+					common.HexToAddress("0x0000000000000000000000000000000000000005"),
+					common.HexToAddress("0x0000000000000000000000000000000000000006"),
+					common.HexToAddress("0x0000000000000000000000000000000000000007"),
+					common.HexToAddress("0x0000000000000000000000000000000000000008"),
+				},
+				chosenDefaultProposers: []common.Address{
+					common.HexToAddress("0x0000000000000000000000000000000000000009"),
+					common.HexToAddress("0x0000000000000000000000000000000000000010"),
+					common.HexToAddress("0x0000000000000000000000000000000000000011"),
+					common.HexToAddress("0x0000000000000000000000000000000000000012"),
+				},
+				seed:    0,
+				wantLen: 12,
+			},
+			wantProposers: []common.Address{
+				common.HexToAddress("0x0000000000000000000000000000000000000009"),
+				common.HexToAddress("0x0000000000000000000000000000000000000001"),
+				common.HexToAddress("0x0000000000000000000000000000000000000002"),
+
+				common.HexToAddress("0x0000000000000000000000000000000000000010"),
+				common.HexToAddress("0x0000000000000000000000000000000000000003"),
+				common.HexToAddress("0x0000000000000000000000000000000000000004"),
+
+				common.HexToAddress("0x0000000000000000000000000000000000000005"),
+				common.HexToAddress("0x0000000000000000000000000000000000000011"),
+				common.HexToAddress("0x0000000000000000000000000000000000000006"),
+
+				common.HexToAddress("0x0000000000000000000000000000000000000007"),
+				common.HexToAddress("0x0000000000000000000000000000000000000012"),
+				common.HexToAddress("0x0000000000000000000000000000000000000008"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotProposers := evenlyInsertDefaultProposers(tt.args.electedProposers, tt.args.chosenDefaultProposers, tt.args.seed, tt.args.wantLen); !reflect.DeepEqual(gotProposers, tt.wantProposers) {
+				t.Errorf("evenlyInsertDefaultProposers() = %v, want %v", gotProposers, tt.wantProposers)
+			}
+		})
+	}
+}
+
+func Test_addressExcept(t *testing.T) {
+	type args struct {
+		all    []common.Address
+		except []common.Address
+	}
+	tests := []struct {
+		name       string
+		args       args
+		wantResult []common.Address
+	}{
+		// TODO: Add test cases.
+		{
+
+			name: "1",
+			args: args{
+				all: []common.Address{
+					common.BigToAddress(big.NewInt(1)),
+					common.BigToAddress(big.NewInt(2)),
+				},
+				except: []common.Address{
+					common.BigToAddress(big.NewInt(2)),
+					common.BigToAddress(big.NewInt(3)),
+					common.BigToAddress(big.NewInt(4)),
+				},
+			},
+			wantResult: []common.Address{
+				common.BigToAddress(big.NewInt(1)),
+			},
+		},
+
+		{
+
+			name: "2",
+			args: args{
+				all: []common.Address{
+					common.BigToAddress(big.NewInt(1)),
+					common.BigToAddress(big.NewInt(2)),
+					common.BigToAddress(big.NewInt(3)),
+				},
+				except: []common.Address{
+					common.BigToAddress(big.NewInt(4)),
+				},
+			},
+			wantResult: []common.Address{
+				common.BigToAddress(big.NewInt(1)),
+				common.BigToAddress(big.NewInt(2)),
+				common.BigToAddress(big.NewInt(3)),
+			},
+		},
+
+		{
+
+			name: "3",
+			args: args{
+				all: []common.Address{
+					common.BigToAddress(big.NewInt(1)),
+					common.BigToAddress(big.NewInt(2)),
+					common.BigToAddress(big.NewInt(3)),
+				},
+				except: []common.Address{},
+			},
+			wantResult: []common.Address{
+				common.BigToAddress(big.NewInt(1)),
+				common.BigToAddress(big.NewInt(2)),
+				common.BigToAddress(big.NewInt(3)),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotResult := addressExcept(tt.args.all, tt.args.except); !reflect.DeepEqual(gotResult, tt.wantResult) {
+				t.Errorf("addressExcept() = %v, want %v", gotResult, tt.wantResult)
+			}
+		})
+	}
+}
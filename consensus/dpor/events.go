@@ -0,0 +1,178 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpor
+
+import (
+	"reflect"
+	"sync"
+
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// consensusEvents posts consensus events observed on new chain heads
+type consensusEvents struct {
+	termFeed      event.Feed
+	committeeFeed event.Feed
+	impeachFeed   event.Feed
+	roleFeed      event.Feed
+	scope         event.SubscriptionScope
+
+	lastCommittee *types.CommitteeEvent // the last elected proposers seen
+	lastRole      *types.RoleEvent      // the last role of local node seen
+
+	lock sync.Mutex
+}
+
+// NotifyChainHead posts the consensus events caused by a new canonical chain head
+func (d *Dpor) NotifyChainHead(header *types.Header) {
+	number := header.Number.Uint64()
+	if number == 0 {
+		return
+	}
+
+	var validators []common.Address
+
+	// events are of the snapshot at the header, the current one may be of another block
+	snap, err := d.dh.snapshot(d, d.chain, number, header.Hash(), nil)
+	if err != nil {
+		log.Debug("failed to get snapshot of chain head", "number", number, "hash", header.Hash().Hex(), "err", err)
+		snap = nil
+	}
+	if snap != nil {
+		validators = snap.ValidatorsOf(number)
+	}
+
+	ev := d.events
+	ev.lock.Lock()
+	impeach, term, committee, role := ev.observe(d, header, snap, validators)
+	ev.lock.Unlock()
+
+	// subscribers may be slow, feeds are sent to without holding the lock
+	if impeach != nil {
+		ev.impeachFeed.Send(impeach)
+	}
+	if term != nil {
+		ev.termFeed.Send(term)
+	}
+	if committee != nil {
+		ev.committeeFeed.Send(committee)
+	}
+	if role != nil {
+		ev.roleFeed.Send(role)
+	}
+}
+
+// observe returns the events caused by a new chain head, nil for those not
+// happening, the caller must hold ev.lock.
+func (ev *consensusEvents) observe(d *Dpor, header *types.Header, snap *DporSnapshot, validators []common.Address) (impeach *types.ImpeachEvent, term *types.TermEvent, committee *types.CommitteeEvent, role *types.RoleEvent) {
+	var (
+		number    = header.Number.Uint64()
+		termSize  = d.config.TermLen * d.config.ViewLen
+		proposers = header.Dpor.Proposers
+	)
+
+	// an impeach block is committed in place of the proposer in charge of the view
+	if header.Impeachment() {
+		impeach = &types.ImpeachEvent{
+			Number: number,
+			Hash:   header.Hash(),
+		}
+		if idx := (number - 1) % termSize / d.config.ViewLen; idx < uint64(len(proposers)) {
+			impeach.Proposer = proposers[idx]
+		}
+	}
+
+	// a new term starts
+	if (number-1)%termSize == 0 {
+		term = &types.TermEvent{
+			Term:       (number - 1) / termSize,
+			Number:     number,
+			Proposers:  proposers,
+			Validators: validators,
+		}
+	}
+
+	if snap == nil {
+		return
+	}
+
+	// new proposers are elected for a future term
+	future := snap.FutureTermOf(number)
+	if elected := snap.getRecentProposers(future); len(elected) > 0 {
+		last := ev.lastCommittee
+		if last == nil || last.Term != future || !reflect.DeepEqual(last.Proposers, elected) {
+			ev.lastCommittee = &types.CommitteeEvent{
+				Term:      future,
+				Number:    number,
+				Proposers: elected,
+			}
+			// the first one seen is not known to be newly elected
+			if last != nil {
+				committee = ev.lastCommittee
+			}
+		}
+	}
+
+	// role of local node changes
+	coinbase := d.Coinbase()
+	current := &types.RoleEvent{
+		Number:    number,
+		Term:      (number - 1) / termSize,
+		Address:   coinbase,
+		Proposer:  containsAddress(proposers, coinbase),
+		Validator: containsAddress(validators, coinbase),
+		Candidate: containsAddress(snap.candidates(), coinbase),
+	}
+	last := ev.lastRole
+	ev.lastRole = current
+	if last != nil && (last.Address != current.Address || last.Proposer != current.Proposer || last.Validator != current.Validator || last.Candidate != current.Candidate) {
+		role = current
+	}
+	return
+}
+
+// SubscribeTermEvent subscribes to the start of new terms
+func (d *Dpor) SubscribeTermEvent(ch chan<- *types.TermEvent) event.Subscription {
+	return d.events.scope.Track(d.events.termFeed.Subscribe(ch))
+}
+
+// SubscribeCommitteeEvent subscribes to newly elected proposers
+func (d *Dpor) SubscribeCommitteeEvent(ch chan<- *types.CommitteeEvent) event.Subscription {
+	return d.events.scope.Track(d.events.committeeFeed.Subscribe(ch))
+}
+
+// SubscribeImpeachEvent subscribes to committed impeach blocks
+func (d *Dpor) SubscribeImpeachEvent(ch chan<- *types.ImpeachEvent) event.Subscription {
+	return d.events.scope.Track(d.events.impeachFeed.Subscribe(ch))
+}
+
+// SubscribeRoleEvent subscribes to role changes of local node
+func (d *Dpor) SubscribeRoleEvent(ch chan<- *types.RoleEvent) event.Subscription {
+	return d.events.scope.Track(d.events.roleFeed.Subscribe(ch))
+}
+
+func containsAddress(addrs []common.Address, addr common.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
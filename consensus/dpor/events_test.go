@@ -0,0 +1,103 @@
+package dpor
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestNotifyChainHead(t *testing.T) {
+	var (
+		config     = &configs.DporConfig{TermLen: 2, ViewLen: 1}
+		proposers  = []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}
+		elected    = []common.Address{common.HexToAddress("0x03"), common.HexToAddress("0x04")}
+		validators = []common.Address{common.HexToAddress("0x11"), common.HexToAddress("0x12")}
+	)
+
+	d := New(config, database.NewMemDatabase())
+	d.coinbase = proposers[0]
+
+	snap := newSnapshot(d.config, 0, common.Hash{}, proposers, validators, NormalMode)
+	snap.setCandidates(append(proposers, elected...))
+	snap.setRecentProposers(snap.FutureTermOf(1), proposers)
+
+	// events are of the snapshots at headers, not of the current one
+	helper := &snapshotsHelper{snaps: make(map[common.Hash]*DporSnapshot)}
+	d.dh = helper
+	d.SetCurrentSnap(newSnapshot(d.config, 0, common.Hash{}, nil, nil, NormalMode))
+
+	var (
+		terms       = make(chan *types.TermEvent, 10)
+		committees  = make(chan *types.CommitteeEvent, 10)
+		impeachment = make(chan *types.ImpeachEvent, 10)
+		roles       = make(chan *types.RoleEvent, 10)
+	)
+	defer d.SubscribeTermEvent(terms).Unsubscribe()
+	defer d.SubscribeCommitteeEvent(committees).Unsubscribe()
+	defer d.SubscribeImpeachEvent(impeachment).Unsubscribe()
+	defer d.SubscribeRoleEvent(roles).Unsubscribe()
+
+	newHeader := func(number int64, coinbase common.Address, proposers []common.Address) *types.Header {
+		header := &types.Header{Number: big.NewInt(number), Coinbase: coinbase}
+		header.Dpor.Proposers = proposers
+		helper.snaps[header.Hash()] = snap
+		return header
+	}
+
+	// the first block of term 0, the committee and role are seen for the first time
+	d.NotifyChainHead(newHeader(1, proposers[0], proposers))
+	if ev := <-terms; ev.Term != 0 || ev.Number != 1 || !reflect.DeepEqual(ev.Proposers, proposers) || !reflect.DeepEqual(ev.Validators, validators) {
+		t.Errorf("term event mismatch: got %+v", ev)
+	}
+
+	// an impeach block in place of the second proposer
+	impeachHeader := newHeader(2, common.Address{}, proposers)
+	d.NotifyChainHead(impeachHeader)
+	if ev := <-impeachment; ev.Number != 2 || ev.Hash != impeachHeader.Hash() || ev.Proposer != proposers[1] {
+		t.Errorf("impeach event mismatch: got %+v", ev)
+	}
+
+	// new proposers are elected and local node is not a proposer of term 1
+	snap.setRecentProposers(snap.FutureTermOf(3), elected)
+	d.NotifyChainHead(newHeader(3, elected[0], elected))
+	if ev := <-terms; ev.Term != 1 || ev.Number != 3 {
+		t.Errorf("term event mismatch: got %+v", ev)
+	}
+	if ev := <-committees; ev.Term != snap.FutureTermOf(3) || ev.Number != 3 || !reflect.DeepEqual(ev.Proposers, elected) {
+		t.Errorf("committee event mismatch: got %+v", ev)
+	}
+	if ev := <-roles; ev.Term != 1 || ev.Address != proposers[0] || ev.Proposer || ev.Validator || !ev.Candidate {
+		t.Errorf("role event mismatch: got %+v", ev)
+	}
+
+	select {
+	case ev := <-terms:
+		t.Errorf("unexpected term event %+v", ev)
+	case ev := <-committees:
+		t.Errorf("unexpected committee event %+v", ev)
+	case ev := <-impeachment:
+		t.Errorf("unexpected impeach event %+v", ev)
+	case ev := <-roles:
+		t.Errorf("unexpected role event %+v", ev)
+	default:
+	}
+}
+
+// snapshotsHelper returns the snapshots of known blocks
+type snapshotsHelper struct {
+	defaultDporHelper
+	snaps map[common.Hash]*DporSnapshot
+}
+
+func (h *snapshotsHelper) snapshot(d *Dpor, chain consensus.ChainReader, number uint64, hash common.Hash, parents []*types.Header) (*DporSnapshot, error) {
+	if snap, ok := h.snaps[hash]; ok {
+		return snap, nil
+	}
+	return nil, consensus.ErrUnknownAncestor
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpor
+
+import (
+	"time"
+
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// After the AdaptiveImpeach fork, the impeach timeout of a height backs off after
+// consecutive impeachments and decays after healthy blocks, so that a slow but
+// honest proposer is not impeached repeatedly under network stress.
+//
+// Validators must create the same impeach block for a height, so the back-off
+// level is not observed locally but replayed from the impeach blocks in the chain.
+
+const inMemoryImpeachLevels = 100 // Number of recent impeach back-off levels to keep in memory
+
+// headerGetter retrieves a block header by hash and number
+type headerGetter func(hash common.Hash, number uint64) *types.Header
+
+// impeachLevel returns the back-off level of the impeach timeout of the height
+// after parent.
+//
+// The level is replayed from zero over the last maxLevel*(decayBlocks+1) blocks
+// up to parent after the fork, increased by each impeach block up to maxLevel and
+// decreased after every decayBlocks consecutive healthy blocks.
+func impeachLevel(config *configs.DporConfig, parent *types.Header, getHeader headerGetter) uint64 {
+	maxLevel, decayBlocks := config.ImpeachBackoffLevels()
+	window := maxLevel * (decayBlocks + 1)
+
+	var headers []*types.Header
+	for header := parent; header != nil && uint64(len(headers)) < window; {
+		number := header.Number.Uint64()
+		if number == 0 || !config.IsAdaptiveImpeach(number) {
+			break
+		}
+		headers = append(headers, header)
+		header = getHeader(header.ParentHash, number-1)
+	}
+
+	var level, healthy uint64
+	for i := len(headers) - 1; i >= 0; i-- {
+		if headers[i].Impeachment() {
+			if level < maxLevel {
+				level++
+			}
+			healthy = 0
+			continue
+		}
+
+		healthy++
+		if healthy >= decayBlocks && level > 0 {
+			level--
+			healthy = 0
+		}
+	}
+	return level
+}
+
+// impeachTimeoutAt returns the impeach timeout of the height after parent
+func (d *Dpor) impeachTimeoutAt(parent *types.Header, getHeader headerGetter) time.Duration {
+	if !d.config.IsAdaptiveImpeach(parent.Number.Uint64() + 1) {
+		return d.config.ImpeachTimeout
+	}
+
+	if level, ok := d.impeachLevels.Get(parent.Hash()); ok {
+		return d.config.ImpeachTimeoutOf(level.(uint64))
+	}
+	level := impeachLevel(d.config, parent, getHeader)
+	d.impeachLevels.Add(parent.Hash(), level)
+	return d.config.ImpeachTimeoutOf(level)
+}
+
+// parentsGetter returns a headerGetter looking up the given parents before the chain
+func parentsGetter(chain consensus.ChainReader, parents []*types.Header) headerGetter {
+	return func(hash common.Hash, number uint64) *types.Header {
+		for i := len(parents) - 1; i >= 0; i-- {
+			if parents[i].Hash() == hash {
+				return parents[i]
+			}
+		}
+		if chain == nil {
+			return nil
+		}
+		return chain.GetHeader(hash, number)
+	}
+}
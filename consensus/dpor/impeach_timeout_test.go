@@ -0,0 +1,77 @@
+package dpor
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// newImpeachTestChain returns headers of a chain, blocks marked in impeached are impeach blocks
+func newImpeachTestChain(length int, impeached map[int]bool) ([]*types.Header, headerGetter) {
+	var (
+		headers = []*types.Header{{Number: big.NewInt(0), Time: big.NewInt(0)}}
+		byHash  = make(map[common.Hash]*types.Header)
+	)
+	byHash[headers[0].Hash()] = headers[0]
+
+	for i := 1; i <= length; i++ {
+		header := &types.Header{Number: big.NewInt(int64(i)), ParentHash: headers[i-1].Hash(), Time: big.NewInt(int64(i))}
+		if !impeached[i] {
+			header.Coinbase = common.HexToAddress("0x01")
+		}
+		headers = append(headers, header)
+		byHash[header.Hash()] = header
+	}
+
+	return headers, func(hash common.Hash, number uint64) *types.Header {
+		return byHash[hash]
+	}
+}
+
+func TestImpeachLevel(t *testing.T) {
+	config := &configs.DporConfig{
+		TermLen:        4,
+		ViewLen:        1,
+		ImpeachTimeout: 10 * time.Second,
+		ImpeachBackoff: &configs.ImpeachBackoff{Multiplier: 2, MaxTimeout: 40 * time.Second, DecayBlocks: 3},
+		Forks:          &configs.DporForks{AdaptiveImpeachBlock: big.NewInt(5)},
+	}
+
+	// impeachments at 3, 4 before the fork are not counted
+	headers, getHeader := newImpeachTestChain(30, map[int]bool{3: true, 4: true, 6: true, 7: true, 8: true, 10: true})
+
+	tests := []struct {
+		parent int
+		level  uint64
+	}{
+		{4, 0},
+		{6, 1},
+		{7, 2},
+		{8, 2}, // capped at max level
+		{10, 2},
+		{13, 1}, // decayed after 3 healthy blocks
+		{16, 0},
+		{30, 0},
+	}
+	for _, tt := range tests {
+		if got := impeachLevel(config, headers[tt.parent], getHeader); got != tt.level {
+			t.Errorf("level of height after %d: got %d, want %d", tt.parent, got, tt.level)
+		}
+	}
+
+	d := New(config, database.NewMemDatabase())
+	if got := d.impeachTimeoutAt(headers[3], getHeader); got != config.ImpeachTimeout {
+		t.Errorf("timeout before the fork: got %v, want %v", got, config.ImpeachTimeout)
+	}
+	if got := d.impeachTimeoutAt(headers[7], getHeader); got != 40*time.Second {
+		t.Errorf("timeout after consecutive impeachments: got %v, want %v", got, 40*time.Second)
+	}
+	if got := d.impeachTimeoutAt(headers[13], getHeader); got != 20*time.Second {
+		t.Errorf("timeout after healthy blocks: got %v, want %v", got, 20*time.Second)
+	}
+}
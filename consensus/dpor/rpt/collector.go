@@ -0,0 +1,265 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpt
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus/dpor/backend"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+type rptCalcItemKey struct {
+	num   uint64
+	block common.Hash // hash of the block at num, rpts of a block reorganized out are not reused
+	addrs common.Hash
+}
+
+func newRptDataCacheKey(num uint64, block common.Hash, addrs []common.Address) rptCalcItemKey {
+	hasher := sha3.NewKeccak256()
+	var hash common.Hash
+
+	rlp.Encode(hasher, func(addrs []common.Address) (result []interface{}) {
+		for _, addr := range addrs {
+			result = append(result, addr)
+		}
+		return
+	}(addrs))
+
+	hasher.Sum(hash[:0])
+
+	return rptCalcItemKey{
+		num:   num,
+		block: block,
+		addrs: hash,
+	}
+}
+
+// RptCollectorImpl implements RptCollector and RptExplainer with a formula
+type RptCollectorImpl struct {
+	formula      *Formula
+	chainBackend backend.ChainBackend
+	params       *rptParamsReader
+
+	// inputs and results of rpts of candidates at blocks
+	entries *rptDataCache
+	store   *rptStore
+}
+
+// NewRptCollector creates an RptCollectorImpl calculating rpts with the formula,
+// coefficients and the window size are read from the rpt contract of the
+// formula's version in the state at the block rpts are calculated at, or default
+// ones are used if the contract is nil
+func NewRptCollector(formula *Formula, contract rptParamsContract, chainBackend backend.ChainBackend) *RptCollectorImpl {
+	return newRptCollector(formula, contract, chainBackend, nil, nil, nil)
+}
+
+func newRptCollector(formula *Formula, contract rptParamsContract, chainBackend backend.ChainBackend, config *configs.DporConfig, override *Override, store *rptStore) *RptCollectorImpl {
+	return &RptCollectorImpl{
+		formula:      formula,
+		chainBackend: chainBackend,
+		params:       newRptParamsReader(contract, chainBackend, config, override),
+		entries:      newRptDataCache(),
+		store:        store,
+	}
+}
+
+// RptOf returns the reputation value of a given address among a batch addresses
+func (rc *RptCollectorImpl) RptOf(addr common.Address, addrs []common.Address, num uint64) (Rpt, error) {
+	breakdown, err := rc.BreakdownOf(addr, addrs, num)
+	if err != nil {
+		return Rpt{}, err
+	}
+	return Rpt{Address: addr, Rpt: breakdown.Rpt}, nil
+}
+
+// RptsOf returns reputation values of the addresses, values of all of them are
+// collected concurrently once
+func (rc *RptCollectorImpl) RptsOf(addrs []common.Address, num uint64) (RptList, error) {
+	params, err := rc.params.at(num)
+	if err != nil {
+		return nil, err
+	}
+	key, entry := rc.entryOf(addrs, num, params.windowSize)
+	if entry.calculatedWith(params) {
+		return append(RptList{}, entry.Rpts...), nil
+	}
+
+	rpts := make(RptList, len(addrs))
+	for j, addr := range addrs {
+		rpts[j] = Rpt{Address: addr, Rpt: rc.breakdownOf(addr, j, entry, num, params).Rpt}
+	}
+
+	if key != nil {
+		entry = entry.withRpts(rpts, params)
+		rc.entries.addCache(*key, entry)
+		rc.store.put(*key, entry)
+	}
+	return append(RptList{}, rpts...), nil
+}
+
+// BreakdownOf implements RptExplainer
+func (rc *RptCollectorImpl) BreakdownOf(addr common.Address, addrs []common.Address, num uint64) (*types.RptBreakdown, error) {
+	params, err := rc.params.at(num)
+	if err != nil {
+		return nil, err
+	}
+	_, entry := rc.entryOf(addrs, num, params.windowSize)
+
+	for j, candidate := range addrs {
+		if candidate == addr {
+			return rc.breakdownOf(addr, j, entry, num, params), nil
+		}
+	}
+	return rc.breakdownOf(addr, -1, entry, num, params), nil
+}
+
+// breakdownOf returns the breakdown of the rpt of the j-th candidate of the entry,
+// or of the address not among candidates if j is negative
+func (rc *RptCollectorImpl) breakdownOf(addr common.Address, j int, entry *rptEntry, num uint64, params rptParams) *types.RptBreakdown {
+	start := time.Now()
+	windowFull := offset(num, params.windowSize) > 0
+
+	breakdown := &types.RptBreakdown{
+		Address: addr,
+		Number:  num,
+		Version: rc.formula.Version,
+	}
+
+	rpt := int64(0)
+	for i, component := range rc.formula.Components {
+		var raw float64
+		var failed bool
+		if j >= 0 {
+			raw, failed = entry.Values[i][j], entry.Failed[i][j]
+		} else {
+			var err error
+			raw, err = component.Source.collect(rc.chainBackend, addr, num, params.windowSize)
+			failed = err != nil
+		}
+
+		raw, normalized := rc.valueOf(i, raw, failed, entry, windowFull)
+		weight := component.Weight.of(params)
+
+		breakdown.Components = append(breakdown.Components, &types.RptComponent{
+			Name:       component.Name,
+			Raw:        int64(raw),
+			Normalized: normalized,
+			Weight:     weight,
+		})
+		rpt += weight * normalized
+	}
+
+	if rpt <= minRptScore {
+		rpt = minRptScore
+	}
+	breakdown.Rpt = rpt
+
+	log.Debug("now calculating rpt", "version", rc.formula.Version, "num", num, "addr", addr.Hex(), "elapsed", common.PrettyDuration(time.Now().Sub(start)))
+	return breakdown
+}
+
+// valueOf returns the raw value and the normalized value of the i-th component
+// from the raw value collected, ranked among values of candidates in the entry
+func (rc *RptCollectorImpl) valueOf(i int, raw float64, failed bool, entry *rptEntry, windowFull bool) (float64, int64) {
+	component := rc.formula.Components[i]
+
+	if failed {
+		if component.RankOnError != 0 {
+			return raw, component.Normalize.apply(component.RankOnError, windowFull)
+		}
+		raw = component.OnError
+	}
+
+	if component.Ranking == nil {
+		return raw, component.Normalize.apply(int64(raw), windowFull)
+	}
+
+	rank := component.RankOnError
+	if sorted := entry.sorted[i]; sorted != nil {
+		rank = component.Ranking.rank(raw, sorted)
+	}
+	return raw, component.Normalize.apply(rank, windowFull)
+}
+
+// entryOf returns the entry of values of all components of addrs at the block
+// number, read from the cache or the store, or collected concurrently. The key of
+// the entry is nil if the block is not known, and the entry is not cached then.
+func (rc *RptCollectorImpl) entryOf(addrs []common.Address, num uint64, windowSize int) (*rptCalcItemKey, *rptEntry) {
+	var key *rptCalcItemKey
+	if header, err := rc.chainBackend.HeaderByNumber(context.Background(), new(big.Int).SetUint64(num)); err == nil && header != nil {
+		k := newRptDataCacheKey(num, header.Hash(), addrs)
+		key = &k
+
+		if entry, ok := rc.entries.getCache(k); ok && rc.matches(entry, len(addrs), windowSize) {
+			return key, entry
+		}
+		if entry, ok := rc.store.get(k); ok && rc.matches(entry, len(addrs), windowSize) {
+			entry.sortValues(rc.formula)
+			rc.entries.addCache(k, entry)
+			return key, entry
+		}
+	}
+
+	components := rc.formula.Components
+	entry := &rptEntry{
+		Version:    rc.formula.Version,
+		WindowSize: windowSize,
+		Values:     make([][]float64, len(components)),
+		Failed:     make([][]bool, len(components)),
+	}
+	for i := range components {
+		entry.Values[i] = make([]float64, len(addrs))
+		entry.Failed[i] = make([]bool, len(addrs))
+	}
+
+	start := time.Now()
+	parallel(len(components)*len(addrs), func(n int) {
+		i, j := n/len(addrs), n%len(addrs)
+		value, err := components[i].Source.collect(rc.chainBackend, addrs[j], num, windowSize)
+		entry.Values[i][j], entry.Failed[i][j] = value, err != nil
+	})
+	entry.sortValues(rc.formula)
+	log.Debug("collected rpt values", "version", rc.formula.Version, "num", num, "candidates", len(addrs), "elapsed", common.PrettyDuration(time.Now().Sub(start)))
+
+	if key != nil {
+		rc.entries.addCache(*key, entry)
+		rc.store.put(*key, entry)
+	}
+	return key, entry
+}
+
+// matches returns if the entry is collected for n candidates with the formula
+// and the window size
+func (rc *RptCollectorImpl) matches(entry *rptEntry, n int, windowSize int) bool {
+	if entry.Version != rc.formula.Version || entry.WindowSize != windowSize || len(entry.Values) != len(rc.formula.Components) {
+		return false
+	}
+	for i := range entry.Values {
+		if len(entry.Values[i]) != n || len(entry.Failed[i]) != n {
+			return false
+		}
+	}
+	return entry.Rpts == nil || len(entry.Rpts) == n
+}
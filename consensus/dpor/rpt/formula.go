@@ -0,0 +1,491 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpt
+
+// A formula describes how the rpt of a candidate is calculated. Each of its
+// components collects a raw value of the candidate from the chain, ranks it among
+// all candidates if needed, normalizes it, and weighs it with a coefficient read
+// from the rpt contract. The rpt is the sum of weighted components, no less than
+// minRptScore.
+//
+// Formulas are registered with versions, which are activated at heights by the
+// rpt formula schedule of dpor config. Version 1 reads rpts from the rpt contract
+// and is not a formula. A registered formula must never change once it is used by
+// the chain, a new one is registered with a new version instead.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus/dpor/backend"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// errUnknownFormula is returned if no formula is registered with a version
+	errUnknownFormula = errors.New("unknown rpt formula")
+
+	// errBalanceUnavailable is returned if the balance of a candidate is not available
+	errBalanceUnavailable = errors.New("balance is not available")
+)
+
+// ContractVersion is the version of the rpt contract which coefficients and the
+// window size of a formula are read from
+type ContractVersion int
+
+const (
+	// RptContractV1 is the rpt contract
+	RptContractV1 ContractVersion = iota + 1
+
+	// RptContractV2 is the rpt contract 2
+	RptContractV2
+)
+
+// Coefficient is a coefficient in the rpt contract
+type Coefficient int
+
+const (
+	// Alpha is the coefficient of balance(coin age)
+	Alpha Coefficient = iota
+
+	// Beta is the coefficient of transaction count
+	Beta
+
+	// Gamma is the coefficient of maintenance
+	Gamma
+
+	// Psi is the coefficient of file contribution
+	Psi
+
+	// Omega is the coefficient of proxy information in pdash
+	Omega
+)
+
+func (c Coefficient) of(params rptParams) int64 {
+	switch c {
+	case Alpha:
+		return params.alpha
+	case Beta:
+		return params.beta
+	case Gamma:
+		return params.gamma
+	case Psi:
+		return params.psi
+	case Omega:
+		return params.omega
+	}
+	return 0
+}
+
+// Source collects the raw value of a component of a candidate at a block number,
+// within the window before it
+type Source struct {
+	Name    string
+	collect func(chain backend.ChainBackend, addr common.Address, num uint64, windowSize int) (float64, error)
+}
+
+// Ranking ranks the raw value of a candidate among ones of all candidates, in percentage
+type Ranking struct {
+	Name string
+	sort func(values []float64)
+	rank func(value float64, sorted []float64) int64
+}
+
+// Step maps values below Below to Value
+type Step struct {
+	Below int64
+	Value int64
+}
+
+// Normalization normalizes the rank of a component, or its raw value if it is
+// not ranked. The zero value keeps the value as it is.
+type Normalization struct {
+	// Max caps the value if it is not zero
+	Max int64
+
+	// Steps map the value to the Value of the first step it is below, or to
+	// Otherwise if it is below none of them, if there are any
+	Steps     []Step
+	Otherwise int64
+
+	// BeforeFullWindow is the normalized value if it is not zero and the window
+	// before the block number reaches the genesis
+	BeforeFullWindow int64
+}
+
+func (n Normalization) apply(value int64, windowFull bool) int64 {
+	if n.BeforeFullWindow != 0 && !windowFull {
+		return n.BeforeFullWindow
+	}
+	if n.Max != 0 && value > n.Max {
+		value = n.Max
+	}
+	if len(n.Steps) == 0 {
+		return value
+	}
+	for _, step := range n.Steps {
+		if value < step.Below {
+			return step.Value
+		}
+	}
+	return n.Otherwise
+}
+
+// Component is a component of a formula
+type Component struct {
+	// Name is one of types.RptBalance, types.RptTxs, etc.
+	Name   string
+	Weight Coefficient
+	Source *Source
+
+	// Ranking is nil if raw values are normalized without ranking
+	Ranking *Ranking
+
+	// OnError is the raw value of a candidate if it is not available
+	OnError float64
+
+	// RankOnError is the rank of every candidate if it is not zero and the raw
+	// value of any candidate is not available
+	RankOnError int64
+
+	Normalize Normalization
+}
+
+// Formula is a versioned definition of rpt calculation
+type Formula struct {
+	Version    int
+	Contract   ContractVersion
+	Components []*Component
+}
+
+var (
+	formulas    = make(map[int]*Formula)
+	formulaLock sync.RWMutex
+)
+
+// RegisterFormula registers a formula with its version, it panics if the version
+// is registered twice or is 1
+func RegisterFormula(formula *Formula) {
+	formulaLock.Lock()
+	defer formulaLock.Unlock()
+
+	if formula.Version <= 1 {
+		panic(fmt.Sprintf("rpt formula version %d is reserved", formula.Version))
+	}
+	if _, ok := formulas[formula.Version]; ok {
+		panic(fmt.Sprintf("rpt formula version %d is registered twice", formula.Version))
+	}
+	formulas[formula.Version] = formula
+}
+
+// FormulaOf returns the formula registered with the version
+func FormulaOf(version int) (*Formula, error) {
+	formulaLock.RLock()
+	defer formulaLock.RUnlock()
+
+	formula, ok := formulas[version]
+	if !ok {
+		return nil, errUnknownFormula
+	}
+	return formula, nil
+}
+
+// Formulas returns versions of all registered formulas in order
+func Formulas() []int {
+	formulaLock.RLock()
+	defer formulaLock.RUnlock()
+
+	var versions []int
+	for version := range formulas {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+func init() {
+	for _, formula := range []*Formula{formula2, formula3, formula4, formula5, formula6} {
+		RegisterFormula(formula)
+	}
+}
+
+// sources of raw values
+var (
+	// BalanceInWei is the balance in wei, overflowing uint64 as it did
+	BalanceInWei = &Source{Name: "balance in wei", collect: func(chain backend.ChainBackend, addr common.Address, num uint64, windowSize int) (float64, error) {
+		balance, err := balanceAt(chain, addr, num)
+		if err != nil {
+			return 0, err
+		}
+		return float64(balance.Uint64()), nil
+	}}
+
+	// SignedBalanceInWei is the balance in wei, overflowing int64 as it did
+	SignedBalanceInWei = &Source{Name: "signed balance in wei", collect: func(chain backend.ChainBackend, addr common.Address, num uint64, windowSize int) (float64, error) {
+		balance, err := balanceAt(chain, addr, num)
+		if err != nil {
+			return 0, err
+		}
+		return float64(balance.Int64()), nil
+	}}
+
+	// BalanceInCpc is the balance in cpc
+	BalanceInCpc = &Source{Name: "balance in cpc", collect: func(chain backend.ChainBackend, addr common.Address, num uint64, windowSize int) (float64, error) {
+		balance, err := balanceAt(chain, addr, num)
+		if err != nil {
+			return 0, err
+		}
+		return float64(new(big.Int).Div(balance, big.NewInt(configs.Cpc)).Uint64()), nil
+	}}
+
+	// TxCount is the number of txs sent in the window, 0 if nonces are not available
+	TxCount = &Source{Name: "tx count", collect: func(chain backend.ChainBackend, addr common.Address, num uint64, windowSize int) (float64, error) {
+		nonce, err := chain.NonceAt(context.Background(), addr, new(big.Int).SetUint64(num))
+		if err != nil {
+			return 0, nil
+		}
+		nonce0, err := chain.NonceAt(context.Background(), addr, new(big.Int).SetUint64(offset(num, windowSize)))
+		if err != nil {
+			return 0, nil
+		}
+		return float64(int64(nonce - nonce0)), nil
+	}}
+
+	// ProposedBlocks is the number of blocks proposed in the window
+	ProposedBlocks = &Source{Name: "proposed blocks", collect: func(chain backend.ChainBackend, addr common.Address, num uint64, windowSize int) (float64, error) {
+		mtn := int64(0)
+		for i := offset(num, windowSize); i < num; i++ {
+			header, err := chain.HeaderByNumber(context.Background(), new(big.Int).SetUint64(i))
+			if header == nil || err != nil {
+				continue
+			}
+			if header.Coinbase == addr {
+				mtn++
+			}
+		}
+		return float64(mtn), nil
+	}}
+
+	// Proposals scores 2 for each block proposed in the window and 1 for each
+	// block in whose proposers the candidate is
+	Proposals = &Source{Name: "proposals", collect: func(chain backend.ChainBackend, addr common.Address, num uint64, windowSize int) (float64, error) {
+		mtn := int64(0)
+		for i := offset(num, windowSize); i < num; i++ {
+			header, err := chain.HeaderByNumber(context.Background(), new(big.Int).SetUint64(i))
+			if header == nil || err != nil {
+				continue
+			}
+			switch {
+			case header.Coinbase == addr:
+				mtn += 2
+			case inProposers(addr, header):
+				mtn++
+			}
+		}
+		return float64(mtn), nil
+	}}
+
+	// ProposalsAtHead scores each block in the window by the header at the block
+	// number itself rather than the block, as version 2 did: 100 if the candidate
+	// proposed it, 140 if it is in its proposers, 60 otherwise
+	ProposalsAtHead = &Source{Name: "proposals at head", collect: func(chain backend.ChainBackend, addr common.Address, num uint64, windowSize int) (float64, error) {
+		header, err := chain.HeaderByNumber(context.Background(), new(big.Int).SetUint64(num))
+		if header == nil || err != nil {
+			return 0, nil
+		}
+		score := int64(60)
+		switch {
+		case header.Coinbase == addr:
+			score = 100
+		case inProposers(addr, header):
+			score = 140
+		}
+		return float64(score * int64(num-offset(num, windowSize))), nil
+	}}
+)
+
+// Constant returns a source whose raw value is always the value
+func Constant(value float64) *Source {
+	return &Source{Name: fmt.Sprintf("constant %v", value), collect: func(backend.ChainBackend, common.Address, uint64, int) (float64, error) {
+		return value, nil
+	}}
+}
+
+func balanceAt(chain backend.ChainBackend, addr common.Address, num uint64) (*big.Int, error) {
+	balance, err := chain.BalanceAt(context.Background(), addr, new(big.Int).SetUint64(num))
+	if err != nil {
+		return nil, err
+	}
+	if balance == nil {
+		return nil, errBalanceUnavailable
+	}
+	return balance, nil
+}
+
+func inProposers(addr common.Address, header *types.Header) bool {
+	for _, proposer := range header.Dpor.Proposers {
+		if proposer == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// rankings
+var (
+	// RankAscending ranks by the index found by binary search in ascending values
+	RankAscending = &Ranking{
+		Name: "ascending",
+		sort: func(values []float64) { sort.Float64s(values) },
+		rank: searchRank,
+	}
+
+	// RankDescendingSearch ranks by the index found by binary search in descending
+	// values, as version 2 did, which is not the position of the value in general
+	RankDescendingSearch = &Ranking{
+		Name: "descending search",
+		sort: func(values []float64) { sortAndReverse(values) },
+		rank: searchRank,
+	}
+
+	// RankDescending ranks by the position of the value in descending values, the
+	// higher the value the higher the rank
+	RankDescending = &Ranking{
+		Name: "descending",
+		sort: func(values []float64) { sortAndReverse(values) },
+		rank: getRank,
+	}
+)
+
+// searchRank returns the index found by sort.SearchFloat64s in percentage
+func searchRank(item float64, array []float64) int64 {
+	index := sort.SearchFloat64s(array, item)
+	return int64(float64(index) / float64(len(array)) * 100)
+}
+
+// getRank return the rank of the given item among the array
+// the array is in decreasing order
+func getRank(item float64, array []float64) int64 {
+	len := len(array)
+	index := searchIndex(item, array)
+	rank := int64((1 - float64(index)/float64(len)) * 100)
+	log.Debug("array", "array", array, "rank", rank, "index", index, "len", len)
+	return rank
+}
+
+// sortAndReverse returns an decreasing order of the given array
+func sortAndReverse(array []float64) sort.Float64Slice {
+	sort.Sort(sort.Reverse(sort.Float64Slice(array)))
+	return array
+}
+
+// searchIndex return the index of an item in an array
+func searchIndex(item float64, array []float64) int64 {
+	for i, x := range array {
+		if x == item {
+			return int64(i)
+		}
+	}
+	return int64(len(array))
+}
+
+// normalizations
+var (
+	// Percentage maps ranks to scores from 20 to 100
+	Percentage = Normalization{
+		Steps:     []Step{{20, 20}, {40, 40}, {65, 60}, {85, 70}, {95, 80}, {98, 90}},
+		Otherwise: 100,
+	}
+
+	// balanceScore2 maps balance ranks of version 2 to scores
+	balanceScore2 = Normalization{
+		Steps:     []Step{{2, 100}, {5, 90}, {15, 80}, {35, 70}, {60, 60}, {80, 40}},
+		Otherwise: 20,
+	}
+
+	// maintenanceScore3 maps proposals of version 3 to scores
+	maintenanceScore3 = Normalization{
+		Steps:            []Step{{13, 60}, {24, 80}},
+		Otherwise:        100,
+		BeforeFullWindow: 60,
+	}
+)
+
+// built-in formulas
+var (
+	formula2 = &Formula{
+		Version:  2,
+		Contract: RptContractV1,
+		Components: []*Component{
+			{Name: types.RptBalance, Weight: Alpha, Source: BalanceInWei, Ranking: RankDescendingSearch, RankOnError: defaultRank, Normalize: balanceScore2},
+			{Name: types.RptTxs, Weight: Beta, Source: TxCount, Normalize: Normalization{Max: 100}},
+			{Name: types.RptMaintenance, Weight: Gamma, Source: ProposalsAtHead},
+			{Name: types.RptUpload, Weight: Psi, Source: Constant(0)},
+			{Name: types.RptProxy, Weight: Omega, Source: Constant(0)},
+		},
+	}
+
+	formula3 = &Formula{
+		Version:  3,
+		Contract: RptContractV1,
+		Components: []*Component{
+			{Name: types.RptBalance, Weight: Alpha, Source: BalanceInWei, Ranking: RankAscending, RankOnError: defaultRank, Normalize: Percentage},
+			{Name: types.RptTxs, Weight: Beta, Source: TxCount, Normalize: Normalization{Max: 100}},
+			{Name: types.RptMaintenance, Weight: Gamma, Source: Proposals, Normalize: maintenanceScore3},
+			{Name: types.RptUpload, Weight: Psi, Source: Constant(0)},
+			{Name: types.RptProxy, Weight: Omega, Source: Constant(0)},
+		},
+	}
+
+	formula4 = &Formula{
+		Version:  4,
+		Contract: RptContractV1,
+		Components: []*Component{
+			{Name: types.RptBalance, Weight: Alpha, Source: SignedBalanceInWei, Ranking: RankAscending, OnError: defaultRank, Normalize: Percentage},
+			{Name: types.RptTxs, Weight: Beta, Source: TxCount, Ranking: RankAscending, Normalize: Percentage},
+			{Name: types.RptMaintenance, Weight: Gamma, Source: ProposedBlocks, Ranking: RankAscending, Normalize: Percentage},
+			{Name: types.RptUpload, Weight: Psi, Source: Constant(0), Normalize: Percentage},
+			{Name: types.RptProxy, Weight: Omega, Source: Constant(0), Normalize: Percentage},
+		},
+	}
+
+	formula5 = &Formula{
+		Version:  5,
+		Contract: RptContractV1,
+		Components: []*Component{
+			{Name: types.RptBalance, Weight: Alpha, Source: BalanceInCpc, Ranking: RankDescending},
+			{Name: types.RptTxs, Weight: Beta, Source: TxCount, Ranking: RankDescending},
+			{Name: types.RptMaintenance, Weight: Gamma, Source: ProposedBlocks, Ranking: RankDescending},
+			{Name: types.RptUpload, Weight: Psi, Source: Constant(1)},
+			{Name: types.RptProxy, Weight: Omega, Source: Constant(1)},
+		},
+	}
+
+	// formula6 is formula5 with coefficients in the rpt contract 2
+	formula6 = &Formula{
+		Version:    6,
+		Contract:   RptContractV2,
+		Components: formula5.Components,
+	}
+)
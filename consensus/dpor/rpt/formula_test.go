@@ -0,0 +1,202 @@
+package rpt_test
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus/dpor/rpt"
+	rptContract "bitbucket.org/cpchain/chain/contracts/dpor/rpt"
+	rptContract2 "bitbucket.org/cpchain/chain/contracts/dpor/rpt2"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TestFormulas checks rpts calculated with built-in formulas against ones of
+// collectors each version used to have, with and without the rpt contract and
+// with the balance of a candidate not available
+func TestFormulas(t *testing.T) {
+	tests := []struct {
+		withContract bool
+		broken       bool
+		num          uint64
+		want         map[int][]int64
+	}{
+		{withContract: false, broken: false, num: 5, want: map[int][]int64{
+			2: {8000, 12015, 12015, 8030, 12030, 6045, 12045, 8060, 12060, 8075, 12075, 8090},
+			3: {4600, 3615, 1615, 3630, 1630, 3645, 1645, 4160, 2660, 4175, 2675, 4190},
+			4: {2400, 4900, 3400, 5200, 3700, 5100, 4600, 5600, 4600, 2750, 4750, 2900},
+			5: {6145, 3900, 1800, 4540, 2440, 4775, 2725, 5480, 3380, 6120, 4020, 6655},
+			6: {6145, 3900, 1800, 4540, 2440, 4775, 2725, 5480, 3380, 6120, 4020, 6655},
+		}},
+		{withContract: false, broken: false, num: 50, want: map[int][]int64{
+			2: {75075, 71150, 71225, 75300, 71375, 75450, 71525, 75600, 71675, 75750, 71825, 75900},
+			3: {3675, 4750, 3825, 1900, 3975, 2050, 4625, 2200, 4775, 3350, 4925, 3500},
+			4: {4000, 2000, 4500, 2300, 4800, 3600, 5100, 3600, 5750, 4750, 2750, 4900},
+			5: {3645, 6265, 4300, 1920, 4940, 2575, 5645, 3265, 6300, 3920, 6940, 4575},
+			6: {3645, 6265, 4300, 1920, 4940, 2575, 5645, 3265, 6300, 3920, 6940, 4575},
+		}},
+		{withContract: false, broken: false, num: 100, want: map[int][]int64{
+			2: {145150, 141300, 145450, 141600, 145750, 141900, 142050, 146200, 142350, 146500, 102500, 146500},
+			3: {2750, 4400, 3050, 4700, 4350, 5500, 4650, 2800, 4950, 3100, 5600, 3100},
+			4: {4000, 2000, 4000, 2300, 4800, 2600, 5100, 3600, 5250, 3750, 5750, 4900},
+			5: {2795, 5415, 3450, 6070, 4140, 6775, 4795, 2415, 5450, 3070, 5300, 2935},
+			6: {2795, 5415, 3450, 6070, 4140, 6775, 4795, 2415, 5450, 3070, 5300, 2935},
+		}},
+		{withContract: false, broken: true, num: 5, want: map[int][]int64{
+			2: {8000, 8015, 8015, 8030, 8030, 6045, 8045, 8060, 8060, 8075, 8075, 8090},
+			3: {5600, 5615, 5615, 5630, 5630, 5645, 5645, 5660, 5660, 5675, 5675, 5690},
+			4: {2400, 4900, 3400, 3700, 4700, 5100, 4600, 5600, 4600, 2750, 5250, 2900},
+			5: {6145, 4300, 2200, 2440, 2890, 4775, 3125, 5480, 3780, 6120, 4470, 6655},
+			6: {6145, 4300, 2200, 2440, 2890, 4775, 3125, 5480, 3780, 6120, 4470, 6655},
+		}},
+		{withContract: false, broken: true, num: 50, want: map[int][]int64{
+			2: {71075, 71150, 71225, 71300, 71375, 71450, 71525, 71600, 71675, 71750, 71825, 71900},
+			3: {5675, 5750, 5825, 5900, 5975, 6050, 6125, 6200, 6275, 6350, 6425, 6500},
+			4: {4000, 2000, 4500, 2300, 4800, 3600, 5100, 3600, 5750, 4750, 2750, 4900},
+			5: {3645, 6265, 4300, 1920, 4940, 2575, 5645, 3265, 6300, 3920, 6940, 4575},
+			6: {3645, 6265, 4300, 1920, 4940, 2575, 5645, 3265, 6300, 3920, 6940, 4575},
+		}},
+		{withContract: false, broken: true, num: 100, want: map[int][]int64{
+			2: {141150, 141300, 141450, 141600, 141750, 141900, 142050, 142200, 142350, 142500, 102500, 142500},
+			3: {5750, 5900, 6050, 6200, 6350, 6500, 6650, 6800, 6950, 7100, 7100, 7100},
+			4: {4000, 2000, 4000, 3300, 4800, 2600, 5100, 2600, 5250, 3750, 5750, 4900},
+			5: {3195, 5815, 3900, 2320, 4540, 6775, 5195, 2815, 5900, 3520, 5700, 3335},
+			6: {3195, 5815, 3900, 2320, 4540, 6775, 5195, 2815, 5900, 3520, 5700, 3335},
+		}},
+		{withContract: true, broken: false, num: 5, want: map[int][]int64{
+			2: {14400, 16020, 16020, 14440, 16040, 10460, 16060, 14480, 16080, 14500, 16100, 14520},
+			3: {2800, 2420, 1620, 2440, 1640, 2460, 1660, 2680, 2080, 2700, 2100, 2720},
+			4: {2800, 3800, 3200, 4200, 3600, 3800, 3600, 4000, 3600, 3000, 3800, 3200},
+			5: {4200, 3540, 2700, 4020, 3180, 3680, 2860, 4200, 3360, 4680, 3840, 5020},
+			6: {4200, 3540, 2700, 4020, 3180, 3680, 2860, 4200, 3360, 4680, 3840, 5020},
+		}},
+		{withContract: true, broken: false, num: 50, want: map[int][]int64{
+			2: {90075, 88125, 88200, 90250, 88325, 90375, 88450, 90500, 88575, 90625, 88700, 90750},
+			3: {3575, 4125, 3700, 2750, 3825, 2875, 4700, 3500, 4825, 4125, 4950, 4250},
+			4: {3500, 2500, 3750, 3000, 4250, 4000, 4750, 4000, 5250, 4750, 3750, 5000},
+			5: {4000, 5450, 4625, 3575, 5225, 4200, 5875, 4825, 6500, 5450, 7100, 6075},
+			6: {4000, 5450, 4625, 3575, 5225, 4200, 5875, 4825, 6500, 5450, 7100, 6075},
+		}},
+		{withContract: true, broken: false, num: 100, want: map[int][]int64{
+			2: {129090, 126780, 129270, 126960, 129450, 127140, 127230, 129720, 127410, 129900, 91590, 130080},
+			3: {3690, 4680, 3870, 4860, 4650, 5340, 5430, 4320, 5610, 4500, 6090, 4680},
+			4: {4200, 3000, 4200, 3600, 5100, 4200, 5700, 4800, 6000, 5100, 6300, 6000},
+			5: {4290, 6030, 5040, 6780, 5790, 7560, 6540, 5280, 7290, 6030, 8040, 6810},
+			6: {4290, 6030, 5040, 6780, 5790, 7560, 6540, 5280, 7290, 6030, 8040, 6810},
+		}},
+		{withContract: true, broken: true, num: 5, want: map[int][]int64{
+			2: {14400, 14420, 14420, 14440, 14440, 10460, 14460, 14480, 14480, 14500, 14500, 14520},
+			3: {3200, 3220, 3220, 3240, 3240, 3260, 3260, 3280, 3280, 3300, 3300, 3320},
+			4: {2800, 3800, 3200, 3600, 4000, 3800, 3600, 4000, 3600, 3000, 4000, 3200},
+			5: {4200, 3700, 2860, 3180, 3360, 3680, 3020, 4200, 3520, 4680, 4020, 5020},
+			6: {4200, 3700, 2860, 3180, 3360, 3680, 3020, 4200, 3520, 4680, 4020, 5020},
+		}},
+		{withContract: true, broken: true, num: 50, want: map[int][]int64{
+			2: {88075, 88125, 88200, 88250, 88325, 88375, 88450, 88500, 88575, 88625, 88700, 88750},
+			3: {4575, 4625, 4700, 4750, 4825, 4875, 5450, 5500, 5575, 5625, 5700, 5750},
+			4: {3500, 2500, 3750, 3000, 4250, 4000, 4750, 4000, 5250, 4750, 3750, 5000},
+			5: {4000, 5450, 4625, 3575, 5225, 4200, 5875, 4825, 6500, 5450, 7100, 6075},
+			6: {4000, 5450, 4625, 3575, 5225, 4200, 5875, 4825, 6500, 5450, 7100, 6075},
+		}},
+		{withContract: true, broken: true, num: 100, want: map[int][]int64{
+			2: {126690, 126780, 126870, 126960, 127050, 127140, 127230, 127320, 127410, 127500, 91590, 127680},
+			3: {5490, 5580, 5670, 5760, 5850, 5940, 6630, 6720, 6810, 6900, 6990, 7080},
+			4: {4200, 3000, 4200, 4200, 5100, 4200, 5700, 4200, 6000, 5100, 6300, 6000},
+			5: {4530, 6270, 5310, 4530, 6030, 7560, 6780, 5520, 7560, 6300, 8280, 7050},
+			6: {4530, 6270, 5310, 4530, 6030, 7560, 6780, 5520, 7560, 6300, 8280, 7050},
+		}},
+	}
+
+	for _, tt := range tests {
+		backend := newHistoricalBackend(t, 100)
+		if tt.broken {
+			backend.broken = backend.accounts[3]
+		}
+		addrs := backend.accounts[:12]
+
+		for version, want := range tt.want {
+			formula, err := rpt.FormulaOf(version)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			collector := rpt.NewRptCollector(formula, nil, backend)
+			if tt.withContract {
+				switch formula.Contract {
+				case rpt.RptContractV1:
+					instance, _ := rptContract.NewRpt(common.HexToAddress("0x01"), backend)
+					collector = rpt.NewRptCollector(formula, instance, backend)
+				case rpt.RptContractV2:
+					instance, _ := rptContract2.NewRpt(common.HexToAddress("0x02"), backend)
+					collector = rpt.NewRptCollector(formula, instance, backend)
+				}
+			}
+
+			var got []int64
+			for _, addr := range addrs {
+				r, err := collector.RptOf(addr, addrs, tt.num)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, r.Rpt)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("rpts of version %d at %d with contract %v, broken %v mismatch\ngot:  %v\nwant: %v", version, tt.num, tt.withContract, tt.broken, got, want)
+			}
+		}
+	}
+}
+
+func TestRegisterFormula(t *testing.T) {
+	if _, err := rpt.FormulaOf(1); err == nil {
+		t.Error("version 1 is a formula")
+	}
+
+	formula := &rpt.Formula{
+		Version:  100,
+		Contract: rpt.RptContractV2,
+		Components: []*rpt.Component{
+			{Name: types.RptBalance, Weight: rpt.Alpha, Source: rpt.BalanceInCpc, Ranking: rpt.RankDescending, Normalize: rpt.Percentage},
+			{Name: types.RptTxs, Weight: rpt.Beta, Source: rpt.Constant(1)},
+		},
+	}
+	rpt.RegisterFormula(formula)
+	if f, err := rpt.FormulaOf(100); err != nil || f != formula {
+		t.Fatalf("failed to get registered formula, got %v, %v", f, err)
+	}
+
+	backend := newHistoricalBackend(t, 100)
+	addrs := backend.accounts[:12]
+	breakdown, err := rpt.NewRptCollector(formula, nil, backend).BreakdownOf(addrs[0], addrs, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(breakdown.Components) != 2 || breakdown.Version != 100 {
+		t.Fatalf("unexpected breakdown: %v", breakdown)
+	}
+
+	// balance of addrs[0] at 50 is 5 cpc, the 6th highest among 12 candidates
+	if balance := breakdown.Components[0]; balance.Raw != 5 || balance.Normalized != 60 {
+		t.Errorf("unexpected balance component: %v", balance)
+	}
+	if want := 50*60 + 15*1; breakdown.Rpt != int64(want) {
+		t.Errorf("rpt mismatch: got %d, want %d", breakdown.Rpt, want)
+	}
+
+	// services are not created with unknown formulas scheduled
+	config := &configs.DporConfig{Forks: &configs.DporForks{
+		RptFormulas: []*configs.RptFormulaFork{{Block: big.NewInt(0), Version: 1}, {Block: big.NewInt(10), Version: 101}},
+	}}
+	if _, err := rpt.NewRptService(config, backend, common.HexToAddress("0x01"), common.HexToAddress("0x02")); err == nil {
+		t.Error("rpt service is created with an unknown formula")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("formula version is registered twice")
+		}
+	}()
+	rpt.RegisterFormula(&rpt.Formula{Version: 6})
+}
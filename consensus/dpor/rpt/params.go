@@ -0,0 +1,255 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpt
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"bitbucket.org/cpchain/chain/accounts/abi/bind"
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus/dpor/backend"
+	rptContract "bitbucket.org/cpchain/chain/contracts/dpor/rpt"
+	rptContract2 "bitbucket.org/cpchain/chain/contracts/dpor/rpt2"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const paramsCacheSize = 256
+
+// rptParams are the coefficients and the window size of rpt calculation
+type rptParams struct {
+	alpha int64
+	beta  int64
+	gamma int64
+	psi   int64
+	omega int64
+
+	windowSize int
+}
+
+// weights returns the coefficients of the parameters
+func (p rptParams) weights() []int64 {
+	return []int64{p.alpha, p.beta, p.gamma, p.psi, p.omega}
+}
+
+// defaultRptParams are used if the rpt contract is not available
+var defaultRptParams = rptParams{
+	alpha: 50,
+	beta:  15,
+	gamma: 10,
+	psi:   15,
+	omega: 10,
+
+	windowSize: 100,
+}
+
+// Override replaces values read from the rpt contracts to simulate changes of
+// them, nil fields are read from the contracts as usual
+type Override struct {
+	Alpha      *int64
+	Beta       *int64
+	Gamma      *int64
+	Psi        *int64
+	Omega      *int64
+	WindowSize *int64
+
+	TotalSeats       *int64
+	LowRptSeats      *int64
+	LowRptPercentage *int64
+}
+
+// apply returns the parameters with overridden ones replaced
+func (o *Override) apply(params rptParams) rptParams {
+	if o == nil {
+		return params
+	}
+	for _, item := range []struct {
+		override *int64
+		value    *int64
+	}{
+		{o.Alpha, &params.alpha},
+		{o.Beta, &params.beta},
+		{o.Gamma, &params.gamma},
+		{o.Psi, &params.psi},
+		{o.Omega, &params.omega},
+	} {
+		if item.override != nil {
+			*item.value = *item.override
+		}
+	}
+	if o.WindowSize != nil {
+		params.windowSize = int(*o.WindowSize)
+	}
+	return params
+}
+
+// rptParamsContract is implemented by both versions of the rpt contract
+type rptParamsContract interface {
+	Alpha(opts *bind.CallOpts) (*big.Int, error)
+	Beta(opts *bind.CallOpts) (*big.Int, error)
+	Gamma(opts *bind.CallOpts) (*big.Int, error)
+	Psi(opts *bind.CallOpts) (*big.Int, error)
+	Omega(opts *bind.CallOpts) (*big.Int, error)
+	Window(opts *bind.CallOpts) (*big.Int, error)
+}
+
+// pinned returns if values of the given block number are read in the state at it,
+// which is after the PinnedRpt fork, or always without a config. Before the fork
+// they are read in the latest state, and default or last read ones are used if
+// they can not be read, as rpts and elections of those blocks were.
+func pinned(config *configs.DporConfig, number uint64) bool {
+	return config == nil || config.IsPinnedRpt(number)
+}
+
+// callOptsAt returns call options reading the state at the given block number if
+// it is pinned, so that what is read does not depend on the current head, or the
+// latest state otherwise
+func callOptsAt(config *configs.DporConfig, number uint64) *bind.CallOpts {
+	if !pinned(config, number) {
+		return nil
+	}
+	return &bind.CallOpts{BlockNumber: new(big.Int).SetUint64(number)}
+}
+
+// rptParamsReader reads rpt parameters from the rpt contract in the state at the
+// block rpts are calculated at, parameters are cached by block hash. Before the
+// PinnedRpt fork they are read in the latest state.
+type rptParamsReader struct {
+	contract     rptParamsContract
+	chainBackend backend.ChainBackend
+	cache        *lru.ARCCache
+	override     *Override
+	config       *configs.DporConfig
+
+	latest     rptParams // parameters last read in the latest state
+	latestLock sync.Mutex
+}
+
+func newRptParamsReader(contract rptParamsContract, chainBackend backend.ChainBackend, config *configs.DporConfig, override *Override) *rptParamsReader {
+	// a nil contract instance is not a nil interface
+	switch c := contract.(type) {
+	case *rptContract.Rpt:
+		if c == nil {
+			contract = nil
+		}
+	case *rptContract2.Rpt:
+		if c == nil {
+			contract = nil
+		}
+	}
+
+	cache, _ := lru.NewARC(paramsCacheSize)
+	return &rptParamsReader{
+		contract:     contract,
+		chainBackend: chainBackend,
+		cache:        cache,
+		override:     override,
+		config:       config,
+		latest:       defaultRptParams,
+	}
+}
+
+// at returns the parameters in the state at the given block number, default ones
+// are returned if there is no rpt contract. Rpts must not be calculated with default
+// ones in place of unavailable ones, an error is returned then. Before the PinnedRpt
+// fork the parameters in the latest state are returned instead.
+func (r *rptParamsReader) at(num uint64) (rptParams, error) {
+	if !pinned(r.config, num) {
+		return r.override.apply(r.latestParams()), nil
+	}
+	params, err := r.contractParamsAt(num)
+	if err != nil {
+		return rptParams{}, err
+	}
+	return r.override.apply(params), nil
+}
+
+func (r *rptParamsReader) contractParamsAt(num uint64) (rptParams, error) {
+	if r.contract == nil {
+		return defaultRptParams, nil
+	}
+
+	header, err := r.chainBackend.HeaderByNumber(context.Background(), new(big.Int).SetUint64(num))
+	if err != nil {
+		return rptParams{}, fmt.Errorf("failed to get header to read rpt parameters at #%d: %v", num, err)
+	}
+	if header == nil {
+		return rptParams{}, fmt.Errorf("failed to get header to read rpt parameters at #%d", num)
+	}
+	hash := header.Hash()
+	if params, ok := r.cache.Get(hash); ok {
+		return params.(rptParams), nil
+	}
+
+	params, err := r.read(callOptsAt(r.config, num))
+	if err != nil {
+		return rptParams{}, fmt.Errorf("failed to read rpt parameters at #%d: %v", num, err)
+	}
+	log.Debug("using parameters from contract", "alpha", params.alpha, "beta", params.beta, "gamma", params.gamma, "psi", params.psi, "omega", params.omega, "window", params.windowSize, "num", num)
+
+	r.cache.Add(hash, params)
+	return params, nil
+}
+
+// latestParams returns the parameters in the latest state, the ones read last time
+// or default ones are returned if they can not be read
+func (r *rptParamsReader) latestParams() rptParams {
+	if r.contract == nil {
+		return defaultRptParams
+	}
+
+	r.latestLock.Lock()
+	defer r.latestLock.Unlock()
+
+	params, err := r.read(nil)
+	if err != nil {
+		log.Debug("failed to read rpt parameters from contract, use the last ones", "err", err)
+		return r.latest
+	}
+	r.latest = params
+	return params
+}
+
+func (r *rptParamsReader) read(opts *bind.CallOpts) (rptParams, error) {
+	var params rptParams
+	for _, item := range []struct {
+		read  func(opts *bind.CallOpts) (*big.Int, error)
+		value *int64
+	}{
+		{r.contract.Alpha, &params.alpha},
+		{r.contract.Beta, &params.beta},
+		{r.contract.Gamma, &params.gamma},
+		{r.contract.Psi, &params.psi},
+		{r.contract.Omega, &params.omega},
+	} {
+		v, err := item.read(opts)
+		if err != nil {
+			return rptParams{}, err
+		}
+		*item.value = v.Int64()
+	}
+
+	w, err := r.contract.Window(opts)
+	if err != nil {
+		return rptParams{}, err
+	}
+	params.windowSize = int(w.Int64())
+	return params, nil
+}
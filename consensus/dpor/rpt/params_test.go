@@ -0,0 +1,293 @@
+package rpt_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"bitbucket.org/cpchain/chain"
+	"bitbucket.org/cpchain/chain/accounts/abi"
+	"bitbucket.org/cpchain/chain/accounts/abi/bind"
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus/dpor/election"
+	"bitbucket.org/cpchain/chain/consensus/dpor/rpt"
+	campaign4 "bitbucket.org/cpchain/chain/contracts/dpor/campaign4"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// historicalBackend is a chain whose rpt parameters, candidates, balances and
+// nonces change over blocks, calls without a block number read the state at head
+type historicalBackend struct {
+	bind.ContractBackend
+
+	head       uint64
+	accounts   []common.Address
+	candidates abi.Method
+	broken     common.Address // balance of the account is not available
+	fork       int64          // blocks and balances of another fork if not zero
+	balances   int64          // number of balances read
+}
+
+func newHistoricalBackend(t *testing.T, head uint64) *historicalBackend {
+	parsed, err := abi.JSON(strings.NewReader(campaign4.CampaignABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var accounts []common.Address
+	for i := 1; i <= 30; i++ {
+		accounts = append(accounts, common.BigToAddress(big.NewInt(int64(i))))
+	}
+	return &historicalBackend{head: head, accounts: accounts, candidates: parsed.Methods["candidatesOf"]}
+}
+
+func (b *historicalBackend) numberOf(number *big.Int) uint64 {
+	if number == nil {
+		return b.head
+	}
+	return number.Uint64()
+}
+
+func (b *historicalBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	n := b.numberOf(number)
+	if n > b.head {
+		return nil, errors.New("unknown block")
+	}
+	header := &types.Header{Number: new(big.Int).SetUint64(n), Coinbase: b.accounts[n%uint64(len(b.accounts))], Time: big.NewInt(b.fork)}
+	header.Dpor.Proposers = b.accounts[n/12%2*6 : n/12%2*6+12]
+	return header, nil
+}
+
+func (b *historicalBackend) BalanceAt(ctx context.Context, account common.Address, number *big.Int) (*big.Int, error) {
+	if account == b.broken {
+		return nil, errors.New("balance not available")
+	}
+	atomic.AddInt64(&b.balances, 1)
+	n := b.numberOf(number) + uint64(b.fork)
+	return new(big.Int).Mul(new(big.Int).SetUint64((uint64(account[19])*7+n)%13), big.NewInt(configs.Cpc)), nil
+}
+
+func (b *historicalBackend) NonceAt(ctx context.Context, account common.Address, number *big.Int) (uint64, error) {
+	return uint64(account[19]) * b.numberOf(number) / 10, nil
+}
+
+func (b *historicalBackend) CodeAt(ctx context.Context, contract common.Address, number *big.Int) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+// CallContract returns candidates growing every 20 blocks for candidatesOf, and
+// a value growing every 10 blocks for any parameter
+func (b *historicalBackend) CallContract(ctx context.Context, call cpchain.CallMsg, number *big.Int) ([]byte, error) {
+	n := b.numberOf(number)
+	if string(call.Data[:4]) == string(b.candidates.Id()) {
+		return b.candidates.Outputs.Pack(b.accounts[:10+n/20])
+	}
+	return common.LeftPadBytes(new(big.Int).SetUint64(20+n/10).Bytes(), 32), nil
+}
+
+func TestElectionAtPastBlock(t *testing.T) {
+	config := &configs.DporConfig{
+		TermLen: 12,
+		ViewLen: 1,
+		Forks: &configs.DporForks{
+			RptCalcMethod6Block: big.NewInt(0),
+			Campaign4Block:      big.NewInt(0),
+			Election2Block:      big.NewInt(0),
+			PinnedRptBlock:      big.NewInt(0),
+		},
+	}
+	backend := newHistoricalBackend(t, 100)
+
+	candidateService, _ := rpt.NewCandidateService(config, backend)
+	rptService, _ := rpt.NewRptService(config, backend, common.HexToAddress("0x01"), common.HexToAddress("0x02"))
+
+	elect := func(number uint64) (rpt.RptList, []common.Address) {
+		candidates, err := candidateService.CandidatesOf(config.TermOf(number), number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rpts, err := rptService.CalcRptInfoList(candidates, number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proposers, err := election.ElectAt(config, number, &election.Context{
+			Number:           number,
+			Rpts:             rpts,
+			Seed:             int64(number),
+			TermLen:          int(config.TermLen),
+			DefaultProposers: backend.accounts[20:],
+			Seats:            rptService,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rpts, proposers
+	}
+
+	rpts, proposers := elect(50)
+
+	// rpts of the head are different
+	headRpts, _ := elect(100)
+	if reflect.DeepEqual(rpts[:10], headRpts[:10]) {
+		t.Fatal("rpts do not change over blocks")
+	}
+
+	// recompute the election at 50 after the head moves on
+	backend.head = 200
+	recomputedRpts, recomputedProposers := elect(50)
+	if !reflect.DeepEqual(rpts, recomputedRpts) {
+		t.Errorf("rpts at past block mismatch after head moves\ngot:  %s\nwant: %s", recomputedRpts.FormatString(), rpts.FormatString())
+	}
+	if !reflect.DeepEqual(proposers, recomputedProposers) {
+		t.Errorf("proposers elected at past block mismatch after head moves\ngot:  %v\nwant: %v", recomputedProposers, proposers)
+	}
+}
+
+func TestOverride(t *testing.T) {
+	config := &configs.DporConfig{
+		TermLen: 12,
+		ViewLen: 1,
+		Forks:   &configs.DporForks{RptCalcMethod6Block: big.NewInt(0)},
+	}
+	backend := newHistoricalBackend(t, 100)
+	addrs := backend.accounts[:12]
+
+	one, zero, seats, pct := int64(1), int64(0), int64(3), int64(100)
+	rptService, _ := rpt.NewRptServiceWithOverride(config, backend, common.HexToAddress("0x01"), common.HexToAddress("0x02"), &rpt.Override{
+		Alpha:            &one,
+		Beta:             &zero,
+		Gamma:            &zero,
+		Psi:              &zero,
+		Omega:            &zero,
+		TotalSeats:       &seats,
+		LowRptPercentage: &pct,
+	})
+
+	// only balances count
+	for _, addr := range addrs {
+		breakdown, err := rptService.CalcRptBreakdown(addr, addrs, 50)
+		if err != nil {
+			t.Fatal(err)
+		}
+		balance := breakdown.Components[0]
+		want := balance.Normalized
+		if want < 16 {
+			want = 16
+		}
+		if balance.Weight != 1 || breakdown.Rpt != want {
+			t.Errorf("rpt of %s is not its balance rank: %d, %v", addr.Hex(), breakdown.Rpt, balance)
+		}
+		for _, component := range breakdown.Components[1:] {
+			if component.Weight != 0 {
+				t.Errorf("weight of %s is not overridden: %d", component.Name, component.Weight)
+			}
+		}
+	}
+
+	if total, _ := rptService.TotalSeats(50); total != 3 {
+		t.Errorf("total seats are not overridden, got %d", total)
+	}
+	// overridden values are restricted as ones in the contract
+	if lowRptCount, _ := rptService.LowRptCount(10, 50); lowRptCount != 5 {
+		t.Errorf("low rpt percentage is not restricted, got %d low rpt candidates", lowRptCount)
+	}
+	// values not overridden are read from the contract
+	if lowRptSeats, _ := rptService.LowRptSeats(50); lowRptSeats != 2 {
+		t.Errorf("low rpt seats are not read from the contract, got %d", lowRptSeats)
+	}
+}
+
+func TestUnavailableParams(t *testing.T) {
+	config := &configs.DporConfig{
+		TermLen: 12,
+		ViewLen: 1,
+		Forks:   &configs.DporForks{RptCalcMethod6Block: big.NewInt(0), PinnedRptBlock: big.NewInt(0)},
+	}
+	backend := newHistoricalBackend(t, 40)
+	addrs := backend.accounts[:12]
+	rptService, _ := rpt.NewRptService(config, backend, common.HexToAddress("0x01"), common.HexToAddress("0x02"))
+
+	// rpts are not calculated with default parameters in place of unavailable ones
+	if rpts, err := rptService.CalcRptInfoList(addrs, 50); err == nil {
+		t.Errorf("rpts are calculated without parameters: %s", rpts.FormatString())
+	}
+	if _, err := rptService.CalcRptBreakdown(addrs[0], addrs, 50); err == nil {
+		t.Error("rpt breakdown is explained without parameters")
+	}
+}
+
+// prunedBackend is a chain without code of the rpt contract 2, keeping the state
+// of the latest 128 blocks only
+type prunedBackend struct {
+	*historicalBackend
+	rpt2 common.Address
+}
+
+func (b *prunedBackend) CodeAt(ctx context.Context, contract common.Address, number *big.Int) ([]byte, error) {
+	if contract == b.rpt2 {
+		return nil, nil
+	}
+	return b.historicalBackend.CodeAt(ctx, contract, number)
+}
+
+func (b *prunedBackend) CallContract(ctx context.Context, call cpchain.CallMsg, number *big.Int) ([]byte, error) {
+	if number != nil && number.Uint64()+128 < b.head {
+		return nil, errors.New("missing trie node")
+	}
+	if *call.To == b.rpt2 {
+		return nil, nil
+	}
+	return b.historicalBackend.CallContract(ctx, call, number)
+}
+
+func TestTestnetElectionBeforePinnedRpt(t *testing.T) {
+	mode := configs.GetRunMode()
+	configs.SetRunMode(configs.Testnet)
+	defer configs.SetRunMode(mode)
+
+	// the testnet elects with elect2 and rpt formula 6 after 454700, but has no rpt contract 2
+	config := configs.ChainConfigInfo().Dpor
+	contracts := config.Contracts
+	number := uint64(configs.Election2BlockNumber + 100)
+	backend := &prunedBackend{historicalBackend: newHistoricalBackend(t, number+1000), rpt2: contracts[configs.ContractRpt2]}
+	addrs := backend.accounts[:12]
+
+	elect := func(config *configs.DporConfig) error {
+		rptService, err := rpt.NewRptService(config, backend, contracts[configs.ContractRpt], contracts[configs.ContractRpt2])
+		if err != nil {
+			return err
+		}
+		rpts, err := rptService.CalcRptInfoList(addrs, number)
+		if err != nil {
+			return err
+		}
+		_, err = election.ElectAt(config, number, &election.Context{
+			Number:           number,
+			Rpts:             rpts,
+			Seed:             int64(number),
+			TermLen:          int(config.TermLen),
+			DefaultProposers: backend.accounts[20:],
+			Seats:            rptService,
+		})
+		return err
+	}
+
+	// default parameters and seats are used in place of unavailable ones as before
+	if err := elect(config); err != nil {
+		t.Fatalf("failed to elect at #%d of the testnet: %v", number, err)
+	}
+
+	// elections fail without them after the PinnedRpt fork
+	forks := *config.Forks
+	forks.PinnedRptBlock = big.NewInt(configs.Election2BlockNumber)
+	pinned := *config
+	pinned.Forks = &forks
+	if err := elect(&pinned); err == nil {
+		t.Errorf("elected at #%d without rpt contract 2 after the PinnedRpt fork", number)
+	}
+}
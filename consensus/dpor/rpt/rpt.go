@@ -20,10 +20,13 @@ package rpt
 // then calculates the reputations of candidates.
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"bitbucket.org/cpchain/chain/accounts/abi/bind"
@@ -36,6 +39,8 @@ import (
 	campaign4 "bitbucket.org/cpchain/chain/contracts/dpor/campaign4"
 	rptContract "bitbucket.org/cpchain/chain/contracts/dpor/rpt"
 	rptContract2 "bitbucket.org/cpchain/chain/contracts/dpor/rpt2"
+	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/types"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto/sha3"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -58,6 +63,13 @@ var (
 	extraSeal   = 65 // Fixed number of extra-data suffix bytes reserved for signer seal
 
 	maxRetryGetRpt = 3 // Max times Get Rpt
+
+	rptWorkers = runtime.NumCPU() // Max goroutines calculating rpts concurrently
+)
+
+var (
+	// errNoRptContract is returned if the rpt contract to read values of is not available
+	errNoRptContract = errors.New("rpt contract is not available")
 )
 
 const (
@@ -87,8 +99,6 @@ func RptHash(rpthash RptItems) (hash common.Hash) {
 	return hash
 }
 
-// TODO: @liuq use hash of addrs and block number as key,
-// not just block number like not
 type rptDataCache struct {
 	cache *lru.ARCCache
 }
@@ -100,17 +110,44 @@ func newRptDataCache() *rptDataCache {
 	}
 }
 
-func (bc *rptDataCache) getCache(key interface{}) ([]float64, bool) {
-	if bal, ok := bc.cache.Get(key); ok {
-		if data, ok := bal.([]float64); ok {
-			return data, true
+func (bc *rptDataCache) getCache(key rptCalcItemKey) (*rptEntry, bool) {
+	if value, ok := bc.cache.Get(key); ok {
+		if entry, ok := value.(*rptEntry); ok {
+			return entry, true
 		}
 	}
-	return []float64{}, false
+	return nil, false
 }
 
-func (bc *rptDataCache) addCache(key interface{}, sortedCache []float64) {
-	bc.cache.Add(key, sortedCache)
+func (bc *rptDataCache) addCache(key rptCalcItemKey, entry *rptEntry) {
+	bc.cache.Add(key, entry)
+}
+
+// parallel calls fn with 0 to n-1 in at most rptWorkers goroutines, and returns
+// after all calls return
+func parallel(n int, fn func(i int)) {
+	workers := rptWorkers
+	if n < workers {
+		workers = n
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
 }
 
 // Rpt defines the name and reputation pair.
@@ -153,27 +190,30 @@ func (a RptList) Less(i, j int) bool {
 
 // CandidateService provides methods to obtain all candidates from campaign contract
 type CandidateService interface {
-	CandidatesOf(term uint64) ([]common.Address, error)
+	CandidatesOf(term uint64, number uint64) ([]common.Address, error)
 }
 
 // CandidateServiceImpl is the default candidate list collector
 type CandidateServiceImpl struct {
 	client bind.ContractBackend
+	config *configs.DporConfig
 }
 
 // NewCandidateService creates a concrete candidate service instance.
-func NewCandidateService(backend bind.ContractBackend) (CandidateService, error) {
+func NewCandidateService(config *configs.DporConfig, backend bind.ContractBackend) (CandidateService, error) {
 
 	rs := &CandidateServiceImpl{
 		client: backend,
+		config: config,
 	}
 	return rs, nil
 }
 
-// CandidatesOf implements CandidateService
-func (rs *CandidateServiceImpl) CandidatesOf(term uint64) ([]common.Address, error) {
+// CandidatesOf implements CandidateService, candidates are read in the state at the given block number
+func (rs *CandidateServiceImpl) CandidatesOf(term uint64, number uint64) ([]common.Address, error) {
+	version := rs.config.CampaignVersionOf(term)
 
-	if term < backend.TermOf(configs.Campaign2BlockNumber) {
+	if version < 2 {
 		// old campaign contract address
 		campaignAddr := configs.ChainConfigInfo().Dpor.Contracts[configs.ContractCampaign]
 
@@ -185,7 +225,7 @@ func (rs *CandidateServiceImpl) CandidatesOf(term uint64) ([]common.Address, err
 		}
 
 		// candidates from old campaign contract
-		cds, err := contractInstance.CandidatesOf(nil, new(big.Int).SetUint64(term))
+		cds, err := contractInstance.CandidatesOf(callOptsAt(rs.config, number), new(big.Int).SetUint64(term))
 		if err != nil {
 			log.Debug("error when read candidates from campaign 1", "err", err)
 			return nil, err
@@ -196,7 +236,7 @@ func (rs *CandidateServiceImpl) CandidatesOf(term uint64) ([]common.Address, err
 		return cds, nil
 	}
 
-	if term < backend.TermOf(configs.Campaign3BlockNumber) {
+	if version < 3 {
 
 		// new campaign contract address
 		campaignAddr := configs.ChainConfigInfo().Dpor.Contracts[configs.ContractCampaign2]
@@ -209,7 +249,7 @@ func (rs *CandidateServiceImpl) CandidatesOf(term uint64) ([]common.Address, err
 		}
 
 		// candidates from new campaign contract
-		cds, err := contractInstance.CandidatesOf(nil, new(big.Int).SetUint64(term))
+		cds, err := contractInstance.CandidatesOf(callOptsAt(rs.config, number), new(big.Int).SetUint64(term))
 		if err != nil {
 			log.Debug("error when read candidates from campaign 2", "err", err)
 			return nil, err
@@ -219,7 +259,7 @@ func (rs *CandidateServiceImpl) CandidatesOf(term uint64) ([]common.Address, err
 		return cds, nil
 	}
 
-	if term < backend.TermOf(configs.Campaign4BlockNumber) {
+	if version < 4 {
 
 		// new campaign contract address
 		campaignAddr := configs.ChainConfigInfo().Dpor.Contracts[configs.ContractCampaign3]
@@ -232,7 +272,7 @@ func (rs *CandidateServiceImpl) CandidatesOf(term uint64) ([]common.Address, err
 		}
 
 		// candidates from new campaign contract
-		cds, err := contractInstance.CandidatesOf(nil, new(big.Int).SetUint64(term))
+		cds, err := contractInstance.CandidatesOf(callOptsAt(rs.config, number), new(big.Int).SetUint64(term))
 		if err != nil {
 			log.Debug("error when read candidates from campaign 3", "err", err)
 			return nil, err
@@ -253,7 +293,7 @@ func (rs *CandidateServiceImpl) CandidatesOf(term uint64) ([]common.Address, err
 	}
 
 	// candidates from new campaign contract
-	cds, err := contractInstance.CandidatesOf(nil, new(big.Int).SetUint64(term))
+	cds, err := contractInstance.CandidatesOf(callOptsAt(rs.config, number), new(big.Int).SetUint64(term))
 	if err != nil {
 		log.Debug("error when read candidates from campaign 4", "err", err)
 		return nil, err
@@ -265,21 +305,28 @@ func (rs *CandidateServiceImpl) CandidatesOf(term uint64) ([]common.Address, err
 
 // RptService provides methods to obtain all rpt related information from block txs and contracts.
 type RptService interface {
-	CalcRptInfoList(addresses []common.Address, number uint64) RptList
-	CalcRptInfo(address common.Address, addresses []common.Address, blockNum uint64) Rpt
-	TotalSeats() (int, error)
-	LowRptSeats() (int, error)
-	LowRptCount(total int) int
+	CalcRptInfoList(addresses []common.Address, number uint64) (RptList, error)
+	CalcRptInfo(address common.Address, addresses []common.Address, blockNum uint64) (Rpt, error)
+	CalcRptBreakdown(address common.Address, addresses []common.Address, blockNum uint64) (*types.RptBreakdown, error)
+	TotalSeats(number uint64) (int, error)
+	LowRptSeats(number uint64) (int, error)
+	LowRptCount(total int, number uint64) (int, error)
 }
 
 // RptCollector collects rpts infos of a given candidate
 type RptCollector interface {
-	RptOf(addr common.Address, addrs []common.Address, num uint64) Rpt
+	RptOf(addr common.Address, addrs []common.Address, num uint64) (Rpt, error)
+}
+
+// RptExplainer is implemented by rpt collectors able to explain their rpt values
+type RptExplainer interface {
+	BreakdownOf(addr common.Address, addrs []common.Address, num uint64) (*types.RptBreakdown, error)
 }
 
 // BasicCollector is the default rpt collector
 type RptServiceImpl struct {
 	client bind.ContractBackend
+	config *configs.DporConfig
 
 	rptContractAddr  common.Address
 	rptContractAddr2 common.Address
@@ -289,17 +336,45 @@ type RptServiceImpl struct {
 
 	rptCache *lru.ARCCache
 
-	rptCollector2 RptCollector
-	rptCollector3 RptCollector
-	rptCollector4 RptCollector
-	rptCollector5 RptCollector
-	rptCollector6 RptCollector
+	backend        backend.ChainBackend
+	collectors     map[int]*RptCollectorImpl // collectors of formulas by version
+	collectorsLock sync.Mutex
+
+	override *Override
+	store    *rptStore
 }
 
 // NewRptService creates a concrete RPT service instance.
-func NewRptService(backend backend.ClientBackend, rptContractAddr common.Address, rptContractAddr2 common.Address) (RptService, error) {
+func NewRptService(config *configs.DporConfig, backend backend.ClientBackend, rptContractAddr common.Address, rptContractAddr2 common.Address) (RptService, error) {
+	return newRptService(config, backend, rptContractAddr, rptContractAddr2, nil, nil)
+}
+
+// NewRptServiceWithOverride creates an RPT service instance reading overridden
+// values instead of ones in the rpt contracts, to simulate changes of them.
+func NewRptServiceWithOverride(config *configs.DporConfig, backend backend.ClientBackend, rptContractAddr common.Address, rptContractAddr2 common.Address, override *Override) (RptService, error) {
+	return newRptService(config, backend, rptContractAddr, rptContractAddr2, override, nil)
+}
+
+// NewRptServiceWithDatabase creates an RPT service instance persisting rpts and
+// values they are calculated from in the chain database, so that they are not
+// calculated again after restarts.
+func NewRptServiceWithDatabase(config *configs.DporConfig, backend backend.ClientBackend, rptContractAddr common.Address, rptContractAddr2 common.Address, db database.Database) (RptService, error) {
+	return newRptService(config, backend, rptContractAddr, rptContractAddr2, nil, db)
+}
+
+func newRptService(config *configs.DporConfig, backend backend.ClientBackend, rptContractAddr common.Address, rptContractAddr2 common.Address, override *Override, db database.Database) (RptService, error) {
 	log.Debug("rptContractAddr", "contractAddr", rptContractAddr.Hex())
 
+	// every scheduled formula must be known, rpts are never calculated with another one
+	for _, f := range config.RptFormulaForks() {
+		if f.Version == 1 {
+			continue
+		}
+		if _, err := FormulaOf(f.Version); err != nil {
+			return nil, fmt.Errorf("%v: version %d scheduled at #%d", err, f.Version, f.Block)
+		}
+	}
+
 	rptInstance, err := rptContract.NewRpt(rptContractAddr, backend)
 	if err != nil {
 		log.Error("New rpt contract error")
@@ -312,14 +387,9 @@ func NewRptService(backend backend.ClientBackend, rptContractAddr common.Address
 
 	cache, _ := lru.NewARC(cacheSize)
 
-	newRptCollector2 := NewRptCollectorImpl2(rptInstance, backend)
-	newRptCollector3 := NewRptCollectorImpl3(rptInstance, backend)
-	newRptCollector4 := NewRptCollectorImpl4(rptInstance, backend)
-	newRptCollector5 := NewRptCollectorImpl5(rptInstance, backend)
-	newRptCollector6 := NewRptCollectorImpl6(rptInstance2, backend)
-
 	bc := &RptServiceImpl{
 		client:   backend,
+		config:   config,
 		rptCache: cache,
 
 		rptInstance:  rptInstance,
@@ -328,97 +398,110 @@ func NewRptService(backend backend.ClientBackend, rptContractAddr common.Address
 		rptContractAddr:  rptContractAddr,
 		rptContractAddr2: rptContractAddr2,
 
-		rptCollector2: newRptCollector2,
-		rptCollector3: newRptCollector3,
-		rptCollector4: newRptCollector4,
-		rptCollector5: newRptCollector5,
-		rptCollector6: newRptCollector6,
+		backend:    backend,
+		collectors: make(map[int]*RptCollectorImpl),
+
+		override: override,
+		store:    newRptStore(db, storedRptTerms*config.TermLen*config.ViewLen),
 	}
 	return bc, nil
 }
 
-// TotalSeats returns total dynaimc seats
-func (rs *RptServiceImpl) TotalSeats() (int, error) {
-	if rs.rptInstance2 == nil {
-		log.Error("New rpt contract 2 error")
-		return defaultTotalSeats, nil
-	}
+// collectorOf returns the collector of the formula with the given version
+func (rs *RptServiceImpl) collectorOf(version int) (*RptCollectorImpl, error) {
+	rs.collectorsLock.Lock()
+	defer rs.collectorsLock.Unlock()
 
-	instance := rs.rptInstance2
-	ts, err := instance.TotalSeats(nil)
-	if err != nil {
-		log.Error("Get total seats error", "error", err)
-		return defaultTotalSeats, err
+	if collector, ok := rs.collectors[version]; ok {
+		return collector, nil
 	}
 
-	// some restrictions to avoid some unnecessary errors
-	if ts.Int64() <= 0 {
-		return 0, nil
+	formula, err := FormulaOf(version)
+	if err != nil {
+		return nil, err
 	}
 
-	if ts.Int64() >= defaultTotalSeats {
-		return defaultTotalSeats, nil
+	var contract rptParamsContract = rs.rptInstance
+	if formula.Contract == RptContractV2 {
+		contract = rs.rptInstance2
 	}
-
-	return int(ts.Int64()), nil
+	collector := newRptCollector(formula, contract, rs.backend, rs.config, rs.override, rs.store)
+	rs.collectors[version] = collector
+	return collector, nil
 }
 
-// LowRptSeats returns low rpt seats
-func (rs *RptServiceImpl) LowRptSeats() (int, error) {
-	if rs.rptInstance2 == nil {
-		log.Error("New rpt contract 2 error")
-		return defaultLowRptSeats, nil
-	}
-
-	instance := rs.rptInstance2
-	lrs, err := instance.LowRptSeats(nil)
-	if err != nil {
-		log.Error("Get low rpt seats error", "error", err)
-		return defaultLowRptSeats, err
+// TotalSeats returns total dynaimc seats in the state at the given block number
+func (rs *RptServiceImpl) TotalSeats(number uint64) (int, error) {
+	if rs.override != nil && rs.override.TotalSeats != nil {
+		return restrict(*rs.override.TotalSeats, defaultTotalSeats), nil
 	}
+	return rs.seatsAt(number, "total seats", defaultTotalSeats, func(opts *bind.CallOpts) (*big.Int, error) {
+		return rs.rptInstance2.TotalSeats(opts)
+	})
+}
 
-	// some restrictions to avoid some unnecessary errors
-	if lrs.Int64() <= 0 {
-		return 0, nil
+// LowRptSeats returns low rpt seats in the state at the given block number
+func (rs *RptServiceImpl) LowRptSeats(number uint64) (int, error) {
+	if rs.override != nil && rs.override.LowRptSeats != nil {
+		return restrict(*rs.override.LowRptSeats, defaultLowRptSeats), nil
 	}
+	return rs.seatsAt(number, "low rpt seats", defaultLowRptSeats, func(opts *bind.CallOpts) (*big.Int, error) {
+		return rs.rptInstance2.LowRptSeats(opts)
+	})
+}
 
-	if lrs.Int64() >= defaultLowRptSeats {
-		return defaultLowRptSeats, nil
+// LowRptPercentage returns low rpt percentage among all rpt list in the state at the given block number
+func (rs *RptServiceImpl) LowRptPercentage(number uint64) (int, error) {
+	if rs.override != nil && rs.override.LowRptPercentage != nil {
+		return restrict(*rs.override.LowRptPercentage, defaultLowRptPct), nil
 	}
-
-	return int(lrs.Int64()), nil
+	return rs.seatsAt(number, "low rpt percentage", defaultLowRptPct, func(opts *bind.CallOpts) (*big.Int, error) {
+		return rs.rptInstance2.LowRptPercentage(opts)
+	})
 }
 
-// LowRptPercentage returns low rpt percentage among all rpt list
-func (rs *RptServiceImpl) LowRptPercentage() (int, error) {
+// seatsAt reads a value of seats from the rpt contract 2 for the given block number,
+// restricted to [0, max]. Before the PinnedRpt fork, max is returned if it can not
+// be read, as elections of those blocks used it, e.g. on networks without the contract.
+func (rs *RptServiceImpl) seatsAt(number uint64, what string, max int, read func(opts *bind.CallOpts) (*big.Int, error)) (int, error) {
 	if rs.rptInstance2 == nil {
-		log.Error("New rpt contract 2 error")
-		return defaultLowRptPct, nil
+		if !pinned(rs.config, number) {
+			return max, nil
+		}
+		return 0, errNoRptContract
 	}
 
-	instance := rs.rptInstance2
-	lrp, err := instance.LowRptPercentage(nil)
+	value, err := read(callOptsAt(rs.config, number))
 	if err != nil {
-		log.Error("Get low rpt percentage error", "error", err)
-		return defaultLowRptPct, err
+		if !pinned(rs.config, number) {
+			log.Debug("failed to read seats, use the default", "seats", what, "number", number, "default", max, "error", err)
+			return max, nil
+		}
+		log.Error("Get seats error", "seats", what, "number", number, "error", err)
+		return 0, err
 	}
 
-	// some restrictions to avoid some unnecessary errors
-	if lrp.Int64() <= 0 {
-		return 0, nil
-	}
+	return restrict(value.Int64(), max), nil
+}
 
-	if lrp.Int64() >= defaultLowRptPct {
-		return defaultLowRptPct, nil
+// restrict restricts seats read from the contract to [0, max], to avoid some unnecessary errors
+func restrict(value int64, max int) int {
+	if value <= 0 {
+		return 0
 	}
-
-	return int(lrp.Int64()), nil
+	if value >= int64(max) {
+		return max
+	}
+	return int(value)
 }
 
 // LowRptCount returns LowRptCount
-func (rs *RptServiceImpl) LowRptCount(total int) int {
-	pct, _ := rs.LowRptPercentage()
-	return PctCount(pct, total)
+func (rs *RptServiceImpl) LowRptCount(total int, number uint64) (int, error) {
+	pct, err := rs.LowRptPercentage(number)
+	if err != nil {
+		return 0, err
+	}
+	return PctCount(pct, total), nil
 }
 
 // PctCount calcs #pct percentage of #total
@@ -428,53 +511,73 @@ func PctCount(pct int, total int) int {
 
 // CalcRptInfoList returns reputation of
 // the given addresses.
-func (rs *RptServiceImpl) CalcRptInfoList(addresses []common.Address, number uint64) RptList {
+func (rs *RptServiceImpl) CalcRptInfoList(addresses []common.Address, number uint64) (RptList, error) {
 	tstart := time.Now()
+	defer func() {
+		log.Debug("calculate rpt from chain backend", "number", number, "elapsed", common.PrettyDuration(time.Now().Sub(tstart)))
+	}()
 
-	rpts := RptList{}
-	for _, address := range addresses {
-		tistart := time.Now()
-		rpts = append(rpts, rs.CalcRptInfo(address, addresses, number))
-		log.Debug("calculate rpt for", "addr", address.Hex(), "number", number, "elapsed", common.PrettyDuration(time.Now().Sub(tistart)))
+	version := rs.config.RptCalcMethod(number)
+	if version > 1 {
+		collector, err := rs.collectorOf(version)
+		if err != nil {
+			return nil, err
+		}
+		return collector.RptsOf(addresses, number)
 	}
 
-	log.Debug("calculate rpt from chain backend", "number", number, "elapsed", common.PrettyDuration(time.Now().Sub(tstart)))
-
-	return rpts
+	var (
+		rpts = make(RptList, len(addresses))
+		errs = make([]error, len(addresses))
+	)
+	parallel(len(addresses), func(i int) {
+		rpts[i], errs[i] = rs.CalcRptInfo(addresses[i], addresses, number)
+	})
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rpts, nil
 }
 
 // CalcRptInfo return the Rpt of the candidate address
-func (rs *RptServiceImpl) CalcRptInfo(address common.Address, addresses []common.Address, number uint64) Rpt {
-	if number < configs.RptCalcMethod2BlockNumber {
+func (rs *RptServiceImpl) CalcRptInfo(address common.Address, addresses []common.Address, number uint64) (Rpt, error) {
+	version := rs.config.RptCalcMethod(number)
+	if version == 1 {
 		log.Debug("now calc rpt for with old rpt method", "addr", address.Hex(), "number", number)
 		return rs.calcRptInfo(address, number)
 	}
 
-	if number < configs.RptCalcMethod3BlockNumber {
-		log.Debug("now calc rpt for with rpt method 2", "addr", address.Hex(), "number", number)
-		return rs.rptCollector2.RptOf(address, addresses, number)
+	collector, err := rs.collectorOf(version)
+	if err != nil {
+		return Rpt{}, err
 	}
 
-	if number < configs.RptCalcMethod4BlockNumber {
-		log.Debug("now calc rpt for with rpt method 3", "addr", address.Hex(), "number", number)
-		return rs.rptCollector3.RptOf(address, addresses, number)
-	}
+	log.Debug("now calc rpt for with rpt formula", "version", version, "addr", address.Hex(), "number", number)
+	return collector.RptOf(address, addresses, number)
+}
 
-	if number < configs.RptCalcMethod5BlockNumber {
-		log.Debug("now calc rpt for with rpt method 4", "addr", address.Hex(), "number", number)
-		return rs.rptCollector4.RptOf(address, addresses, number)
+// CalcRptBreakdown returns how the Rpt of the candidate address is calculated,
+// only the total is returned with the old rpt method
+func (rs *RptServiceImpl) CalcRptBreakdown(address common.Address, addresses []common.Address, number uint64) (*types.RptBreakdown, error) {
+	version := rs.config.RptCalcMethod(number)
+	if version > 1 {
+		collector, err := rs.collectorOf(version)
+		if err != nil {
+			return nil, err
+		}
+		return collector.BreakdownOf(address, addresses, number)
 	}
 
-	if number < configs.RptCalcMethod6BlockNumber {
-		log.Debug("now calc rpt for with rpt method 5", "addr", address.Hex(), "number", number)
-		return rs.rptCollector5.RptOf(address, addresses, number)
+	rpt, err := rs.CalcRptInfo(address, addresses, number)
+	if err != nil {
+		return nil, err
 	}
-
-	log.Debug("now calc rpt for with rpt method 6", "addr", address.Hex(), "number", number)
-	return rs.rptCollector6.RptOf(address, addresses, number)
+	return &types.RptBreakdown{Address: address, Number: number, Version: version, Rpt: rpt.Rpt}, nil
 }
 
-func (rs *RptServiceImpl) calcRptInfo(address common.Address, blockNum uint64) Rpt {
+func (rs *RptServiceImpl) calcRptInfo(address common.Address, blockNum uint64) (Rpt, error) {
 	log.Debug("now calculating rpt", "CalcRptInfo", "old", "num", blockNum, "addr", address.Hex())
 
 	if rs.rptInstance == nil {
@@ -484,10 +587,10 @@ func (rs *RptServiceImpl) calcRptInfo(address common.Address, blockNum uint64) R
 	instance := rs.rptInstance
 
 	rpt := int64(0)
-	windowSize, err := instance.Window(nil)
+	windowSize, err := instance.Window(callOptsAt(rs.config, blockNum))
 	if err != nil {
 		log.Error("Get windowSize error", "error", err)
-		return Rpt{Address: address, Rpt: minRptScore}
+		return Rpt{}, err
 	}
 	blockInWindow := int64(blockNum) - windowSize.Int64()
 	log.Debug("blockInWindow", "blockInWindow", blockInWindow, "blockNum", blockNum)
@@ -497,7 +600,7 @@ func (rs *RptServiceImpl) calcRptInfo(address common.Address, blockNum uint64) R
 		if !exists {
 			// try get rpt ${maxRetryGetRpt} times
 			for tryIndex := 0; tryIndex <= maxRetryGetRpt; tryIndex++ {
-				rptInfo, err := instance.GetRpt(nil, address, new(big.Int).SetInt64(i))
+				rptInfo, err := instance.GetRpt(callOptsAt(rs.config, blockNum), address, new(big.Int).SetInt64(i))
 				if err == nil {
 					log.Debug("GetRpt ok", "tryIndex", tryIndex, "hash", hash.Hex(), "blockNum", blockNum, "i", i)
 					rs.rptCache.Add(hash, Rpt{Address: address, Rpt: rptInfo.Int64()})
@@ -512,7 +615,7 @@ func (rs *RptServiceImpl) calcRptInfo(address common.Address, blockNum uint64) R
 				}
 				// get rpt failed
 				log.Error("GetRpt failed", "tryIndex", tryIndex, "hash", hash.Hex(), "blockNum", blockNum, "i", i)
-				return Rpt{Address: address, Rpt: minRptScore}
+				return Rpt{}, err
 			}
 
 		} else {
@@ -525,5 +628,5 @@ func (rs *RptServiceImpl) calcRptInfo(address common.Address, blockNum uint64) R
 	if rpt <= minRptScore {
 		rpt = minRptScore
 	}
-	return Rpt{Address: address, Rpt: rpt}
+	return Rpt{Address: address, Rpt: rpt}, nil
 }
@@ -207,10 +207,14 @@ func TestRptServiceImpl_CalcRptInfoList(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			rs, _ := rpt.NewRptService(tt.fields.Client, tt.fields.RptContract, tt.fields.RptContract)
+			rs, _ := rpt.NewRptService(configs.ChainConfigInfo().Dpor, tt.fields.Client, tt.fields.RptContract, tt.fields.RptContract)
 			tt.prepare()
-			log.Printf("Testcase [%s], RPT: %v", tt.name, rs.CalcRptInfoList(tt.args.addresses, tt.args.number)[0].Rpt)
-			if got := rs.CalcRptInfoList(tt.args.addresses, tt.args.number); !reflect.DeepEqual(got, tt.want) {
+			got, err := rs.CalcRptInfoList(tt.args.addresses, tt.args.number)
+			if err != nil {
+				t.Fatal(err)
+			}
+			log.Printf("Testcase [%s], RPT: %v", tt.name, got[0].Rpt)
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("RptServiceImpl.CalcRptInfoList() = %+v, want %+v", got, tt.want)
 			}
 		})
@@ -342,9 +346,10 @@ func benchRptOf(b *testing.B, numAccount int) {
 	addrs := generateABatchAccounts(numAccount)
 	fc := newFakeChainBackendForRptCollector(1000)
 
-	rptCollector := rpt.NewRptCollectorImpl4(nil, fc)
+	formula, _ := rpt.FormulaOf(4)
+	rptCollector := rpt.NewRptCollector(formula, nil, fc)
 	for i, addr := range addrs {
-		rpt := rptCollector.RptOf(addr, addrs, 500)
+		rpt, _ := rptCollector.RptOf(addr, addrs, 500)
 		b.Log("idx", i, "rpt", rpt.Rpt, "addr", addr.Hex())
 	}
 }
@@ -356,9 +361,13 @@ func TestRptOf4(t *testing.T) {
 	accounts := generateABatchAccounts(numAccount)
 	fc := newFakeChainBackendForRptCollectorWithBalances(numBlocks, accounts)
 
-	rptCollector := rpt.NewRptCollectorImpl4(nil, fc)
+	formula, _ := rpt.FormulaOf(4)
+	rptCollector := rpt.NewRptCollector(formula, nil, fc)
 	for i, addr := range accounts {
-		rpt := rptCollector.RptOf(addr, accounts, 500)
+		rpt, err := rptCollector.RptOf(addr, accounts, 500)
+		if err != nil {
+			t.Fatal(err)
+		}
 		t.Log("idx", i, "rpt", rpt.Rpt, "addr", addr.Hex())
 	}
 
@@ -370,14 +379,54 @@ func TestRptOf5(t *testing.T) {
 	accounts := generateABatchAccounts(numAccount)
 	fc := newFakeChainBackendForRptCollectorWithBalances(numBlocks, accounts)
 
-	rptCollector := rpt.NewRptCollectorImpl5(nil, fc)
+	formula, _ := rpt.FormulaOf(5)
+	rptCollector := rpt.NewRptCollector(formula, nil, fc)
 	for i, addr := range accounts {
-		rpt := rptCollector.RptOf(addr, accounts, 500)
+		rpt, err := rptCollector.RptOf(addr, accounts, 500)
+		if err != nil {
+			t.Fatal(err)
+		}
 		t.Log("idx", i, "rpt", rpt.Rpt, "addr", addr.Hex())
 	}
 
 }
 
+func TestBreakdownOf6(t *testing.T) {
+
+	numAccount := 30
+	numBlocks := 200
+	accounts := generateABatchAccounts(numAccount)
+	fc := newFakeChainBackendForRptCollectorWithBalances(numBlocks, accounts)
+
+	formula, _ := rpt.FormulaOf(6)
+	rptCollector := rpt.NewRptCollector(formula, nil, fc)
+	for i, addr := range accounts {
+		breakdown, err := rptCollector.BreakdownOf(addr, accounts, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(breakdown.Components) != 5 || breakdown.Components[0].Name != types.RptBalance {
+			t.Fatalf("unexpected components: %v", breakdown.Components)
+		}
+
+		// balances are allocated as i/3 cpc
+		if balance := breakdown.Components[0].Raw; balance != int64(i/3) {
+			t.Errorf("balance of %s: got %d, want %d", addr.Hex(), balance, i/3)
+		}
+
+		sum := int64(0)
+		for _, component := range breakdown.Components {
+			sum += component.Weight * component.Normalized
+		}
+		if sum != breakdown.Rpt {
+			t.Errorf("rpt of %s is not the sum of its components: %d != %d", addr.Hex(), breakdown.Rpt, sum)
+		}
+		if rpt, _ := rptCollector.RptOf(addr, accounts, 100); rpt.Rpt != breakdown.Rpt {
+			t.Errorf("rpt of %s mismatch: %d != %d", addr.Hex(), rpt.Rpt, breakdown.Rpt)
+		}
+	}
+}
+
 func Test_PctCount(t *testing.T) {
 	type args struct {
 		pct   int
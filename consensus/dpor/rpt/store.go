@@ -0,0 +1,224 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpt
+
+import (
+	"encoding/json"
+	"sync"
+
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/database"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// storedRptTerms is the number of terms rpt entries are kept in the database for
+const storedRptTerms = 16
+
+var (
+	// rptEntryPrefix + block hash + hash of candidates -> rptEntry
+	rptEntryPrefix = []byte("dpor-rpt-")
+
+	// rptIndexKey -> keys of stored entries by block number, to prune them
+	rptIndexKey = []byte("dpor-rpts-index")
+)
+
+// rptEntry holds inputs and results of rpts of candidates at a block
+type rptEntry struct {
+	Version    int         `json:"version"`    // version of the formula
+	WindowSize int         `json:"windowSize"` // window size the values are collected over
+	Values     [][]float64 `json:"values"`     // raw value of each component of each candidate
+	Failed     [][]bool    `json:"failed"`     // whether the raw value is not available
+	Rpts       RptList     `json:"rpts"`       // rpts of the candidates, nil if not calculated
+	Weights    []int64     `json:"weights"`    // parameters read for the rpts, nil if they are not read
+
+	sorted [][]float64 // sorted values of each ranked component, nil if not ranked
+}
+
+// complete returns if all values and parameters are available, entries with
+// unavailable ones are not persisted as the state may be available later
+func (e *rptEntry) complete() bool {
+	if e.Rpts != nil && e.Weights == nil {
+		return false
+	}
+	for _, failed := range e.Failed {
+		for _, f := range failed {
+			if f {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sortValues sorts values of components ranking candidates, values of a component
+// are not sorted if any of them is not available and the component ranks all
+// candidates the same in that case
+func (e *rptEntry) sortValues(formula *Formula) {
+	e.sorted = make([][]float64, len(formula.Components))
+	for i, component := range formula.Components {
+		if component.Ranking == nil {
+			continue
+		}
+
+		values := make([]float64, 0, len(e.Values[i]))
+		for j, value := range e.Values[i] {
+			if e.Failed[i][j] {
+				if component.RankOnError != 0 {
+					values = nil
+					break
+				}
+				value = component.OnError
+			}
+			values = append(values, value)
+		}
+		if values != nil {
+			component.Ranking.sort(values)
+		}
+		e.sorted[i] = values
+	}
+}
+
+// withRpts returns a copy of the entry with rpts calculated with the parameters
+func (e *rptEntry) withRpts(rpts RptList, params rptParams) *rptEntry {
+	cpy := *e
+	cpy.Rpts = rpts
+	cpy.Weights = params.weights()
+	return &cpy
+}
+
+// calculatedWith returns if rpts of the entry are calculated with the parameters,
+// rpts of entries stored without parameters are calculated again
+func (e *rptEntry) calculatedWith(params rptParams) bool {
+	if e.Rpts == nil {
+		return false
+	}
+	weights := params.weights()
+	if len(e.Weights) != len(weights) {
+		return false
+	}
+	for i := range weights {
+		if e.Weights[i] != weights[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// rptStore persists rpt entries in the chain database, keyed by the hash of the
+// block they are calculated at and the hash of candidates, so that entries of a
+// block reorganized out of the chain are never read for the block replacing it.
+// Entries more than retention blocks below the latest stored one are pruned.
+type rptStore struct {
+	db        database.Database
+	retention uint64 // zero keeps all entries
+
+	lock sync.Mutex // protects the index
+}
+
+func newRptStore(db database.Database, retention uint64) *rptStore {
+	if db == nil {
+		return nil
+	}
+	return &rptStore{db: db, retention: retention}
+}
+
+func rptEntryKey(key rptCalcItemKey) []byte {
+	return append(append(append([]byte{}, rptEntryPrefix...), key.block[:]...), key.addrs[:]...)
+}
+
+// get returns the entry of the key, false if it is not stored
+func (s *rptStore) get(key rptCalcItemKey) (*rptEntry, bool) {
+	if s == nil {
+		return nil, false
+	}
+
+	blob, err := s.db.Get(rptEntryKey(key))
+	if err != nil {
+		return nil, false
+	}
+	entry := new(rptEntry)
+	if err := json.Unmarshal(blob, entry); err != nil {
+		log.Warn("failed to decode stored rpts", "number", key.num, "hash", key.block.Hex(), "err", err)
+		return nil, false
+	}
+	return entry, true
+}
+
+// put stores the entry of the key if all its values are available
+func (s *rptStore) put(key rptCalcItemKey, entry *rptEntry) {
+	if s == nil || !entry.complete() {
+		return
+	}
+
+	blob, err := json.Marshal(entry)
+	if err != nil {
+		log.Warn("failed to encode rpts", "number", key.num, "hash", key.block.Hex(), "err", err)
+		return
+	}
+	if err := s.db.Put(rptEntryKey(key), blob); err != nil {
+		log.Warn("failed to store rpts", "number", key.num, "hash", key.block.Hex(), "err", err)
+		return
+	}
+	s.index(key)
+}
+
+// index adds the key to the index of stored entries, and prunes entries more than
+// retention blocks below it
+func (s *rptStore) index(key rptCalcItemKey) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	index := make(map[uint64][]hexutil.Bytes)
+	if blob, err := s.db.Get(rptIndexKey); err == nil {
+		if err := json.Unmarshal(blob, &index); err != nil {
+			log.Warn("failed to decode index of stored rpts", "err", err)
+		}
+	}
+
+	entryKey := rptEntryKey(key)
+	indexed := false
+	for _, k := range index[key.num] {
+		if string(k) == string(entryKey) {
+			indexed = true
+			break
+		}
+	}
+	if !indexed {
+		index[key.num] = append(index[key.num], entryKey)
+	}
+
+	batch := s.db.NewBatch()
+	for num, keys := range index {
+		if s.retention == 0 || num+s.retention >= key.num {
+			continue
+		}
+		for _, k := range keys {
+			batch.Delete(k)
+		}
+		delete(index, num)
+	}
+
+	blob, err := json.Marshal(index)
+	if err != nil {
+		log.Warn("failed to encode index of stored rpts", "err", err)
+		return
+	}
+	batch.Put(rptIndexKey, blob)
+	if err := batch.Write(); err != nil {
+		log.Warn("failed to prune stored rpts", "number", key.num, "err", err)
+	}
+}
@@ -0,0 +1,115 @@
+package rpt_test
+
+import (
+	"math/big"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus/dpor/rpt"
+	"bitbucket.org/cpchain/chain/database"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestPersistentRpts(t *testing.T) {
+	config := &configs.DporConfig{
+		TermLen: 12,
+		ViewLen: 1,
+		Forks:   &configs.DporForks{RptCalcMethod6Block: big.NewInt(0)},
+	}
+	db := database.NewMemDatabase()
+	newService := func(backend *historicalBackend) rpt.RptService {
+		rptService, _ := rpt.NewRptServiceWithDatabase(config, backend, common.HexToAddress("0x01"), common.HexToAddress("0x02"), db)
+		return rptService
+	}
+
+	backend := newHistoricalBackend(t, 100)
+	addrs := backend.accounts[:12]
+	rpts, err := newService(backend).CalcRptInfoList(addrs, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rpts calculated concurrently are the same as ones calculated one by one
+	single, _ := rpt.NewRptService(config, backend, common.HexToAddress("0x01"), common.HexToAddress("0x02"))
+	for i, addr := range addrs {
+		if r, _ := single.CalcRptInfo(addr, addrs, 50); r != rpts[i] {
+			t.Errorf("rpt of %s mismatch, got %d, want %d", addr.Hex(), rpts[i].Rpt, r.Rpt)
+		}
+	}
+
+	// rpts are read from the database after restarts
+	backend = newHistoricalBackend(t, 100)
+	restarted := newService(backend)
+	if got, _ := restarted.CalcRptInfoList(addrs, 50); !reflect.DeepEqual(got, rpts) {
+		t.Errorf("stored rpts mismatch\ngot:  %s\nwant: %s", got.FormatString(), rpts.FormatString())
+	}
+	if backend.balances != 0 {
+		t.Errorf("stored rpts are calculated again, %d balances read", backend.balances)
+	}
+	// and so are values explaining them
+	if breakdown, _ := restarted.CalcRptBreakdown(addrs[0], addrs, 50); breakdown.Rpt != rpts[0].Rpt || backend.balances != 0 {
+		t.Errorf("stored values are collected again, rpt %d, %d balances read", breakdown.Rpt, backend.balances)
+	}
+
+	// other candidates are calculated
+	if restarted.CalcRptInfoList(addrs[1:], 50); backend.balances == 0 {
+		t.Error("rpts of other candidates are read from the database")
+	}
+
+	// the block is replaced after a reorg
+	backend = newHistoricalBackend(t, 100)
+	backend.fork = 5
+	reorged, _ := newService(backend).CalcRptInfoList(addrs, 50)
+	if atomic.LoadInt64(&backend.balances) == 0 || reflect.DeepEqual(reorged, rpts) {
+		t.Errorf("rpts of the block reorganized out are used\ngot:  %s\nwant other than: %s", reorged.FormatString(), rpts.FormatString())
+	}
+
+	// values not available are not persisted
+	backend = newHistoricalBackend(t, 100)
+	backend.broken = addrs[3]
+	newService(backend).CalcRptInfoList(addrs, 60)
+	backend.broken, backend.balances = common.Address{}, 0
+	if newService(backend).CalcRptInfoList(addrs, 60); backend.balances == 0 {
+		t.Error("rpts with values not available are stored")
+	}
+	backend.balances = 0
+	if newService(backend).CalcRptInfoList(addrs, 60); backend.balances != 0 {
+		t.Errorf("rpts calculated after values are available are not stored, %d balances read", backend.balances)
+	}
+}
+
+func TestPrunedRpts(t *testing.T) {
+	config := &configs.DporConfig{
+		TermLen: 12,
+		ViewLen: 1,
+		Forks:   &configs.DporForks{RptCalcMethod6Block: big.NewInt(0)},
+	}
+	db := database.NewMemDatabase()
+	newService := func(backend *historicalBackend) rpt.RptService {
+		rptService, _ := rpt.NewRptServiceWithDatabase(config, backend, common.HexToAddress("0x01"), common.HexToAddress("0x02"), db)
+		return rptService
+	}
+
+	backend := newHistoricalBackend(t, 300)
+	addrs := backend.accounts[:12]
+	newService(backend).CalcRptInfoList(addrs, 50)
+	newService(backend).CalcRptInfoList(addrs, 100)
+
+	// entries of 16 terms are kept
+	backend.balances = 0
+	if newService(backend).CalcRptInfoList(addrs, 50); backend.balances != 0 {
+		t.Errorf("entries in 16 terms are pruned, %d balances read", backend.balances)
+	}
+
+	// older ones are pruned once rpts of a later block are stored
+	newService(backend).CalcRptInfoList(addrs, 250)
+	backend.balances = 0
+	if newService(backend).CalcRptInfoList(addrs, 100); backend.balances != 0 {
+		t.Errorf("entries in 16 terms are pruned, %d balances read", backend.balances)
+	}
+	if newService(backend).CalcRptInfoList(addrs, 50); backend.balances == 0 {
+		t.Error("entries more than 16 terms ago are not pruned")
+	}
+}
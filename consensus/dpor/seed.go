@@ -0,0 +1,146 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpor
+
+import (
+	"errors"
+
+	"bitbucket.org/cpchain/chain/commons/crypto/blskey"
+	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// The election seed replaces the block hash as the seed of proposer elections
+// after the ElectionSeed fork, as the proposer of a checkpoint block is able to
+// grind its hash.
+//
+// The proposer of a block signs the seed of the parent with its registered bls
+// key, carries the signature in SeedSig, and the seed of the block is the hash of
+// the signature. A bls signature is unique to its signer and the message, so the
+// signature is a verifiable random function of the parent's seed: no one but the
+// proposer is able to compute it in advance, and the proposer has exactly one seed
+// to carry. The signature is mandatory, a block without it is invalid, so the only
+// choice left to the proposer is to withhold its block, which costs its reward and
+// gets it impeached. The seed of an impeach block is chained from the parent's
+// seed only, as there is no proposer to contribute to it.
+//
+// Proposers must register bls keys in the genesis as validators do to propose
+// blocks after the fork.
+
+var (
+	// errInvalidSeed is returned if the election seed in a header is not the hash of its signature
+	errInvalidSeed = errors.New("invalid election seed")
+
+	// errInvalidSeedSig is returned if the signature contributing to an election seed is invalid
+	errInvalidSeedSig = errors.New("invalid signature of election seed")
+)
+
+// seedPrefix separates messages of seed signatures from headers signed with bls keys
+var seedPrefix = []byte("election seed")
+
+// seedMessage returns the message a proposer signs to contribute to the seed of a block on the parent
+func seedMessage(parent *types.Header) []byte {
+	return crypto.Keccak256(seedPrefix, parent.Number.Bytes(), parent.Dpor.Seed.Bytes())
+}
+
+// chainSeed returns the election seed chained from the parent's seed only
+func chainSeed(parentSeed common.Hash) common.Hash {
+	return crypto.Keccak256Hash(parentSeed.Bytes())
+}
+
+// signedSeed returns the election seed of a seed signature
+func signedSeed(sig []byte) common.Hash {
+	return crypto.Keccak256Hash(sig)
+}
+
+// prepareSeed sets the election seed of a header proposed on the parent with the
+// signature of the local bls key. A node without a bls key is refused if it is a
+// proposer of the header, as its block would be invalid.
+func (d *Dpor) prepareSeed(snap *DporSnapshot, header, parent *types.Header) error {
+	number := header.Number.Uint64()
+	if !d.config.IsElectionSeed(number) {
+		return nil
+	}
+
+	d.coinbaseLock.RLock()
+	key := d.blsKey
+	d.coinbaseLock.RUnlock()
+
+	if key == nil {
+		if isProposer, _ := snap.IsProposerOf(header.Coinbase, number); isProposer {
+			return errNoBLSKey
+		}
+		return nil
+	}
+
+	header.Dpor.SeedSig = key.Sign(seedMessage(parent)).Bytes()
+	header.Dpor.Seed = signedSeed(header.Dpor.SeedSig)
+	return nil
+}
+
+// impeachSeed returns the election seed of an impeach block on the parent
+func (d *Dpor) impeachSeed(parent *types.Header) common.Hash {
+	if !d.config.IsElectionSeed(parent.Number.Uint64() + 1) {
+		return common.Hash{}
+	}
+	return chainSeed(parent.Dpor.Seed)
+}
+
+// verifySeed verifies the election seed of a header and the signature of its proposer contributing to it
+func (dh *defaultDporHelper) verifySeed(dpor *Dpor, chain consensus.ChainReader, header *types.Header, parent *types.Header, parents []*types.Header) error {
+	number := header.Number.Uint64()
+
+	if !dpor.config.IsElectionSeed(number) {
+		if header.Dpor.Seed != (common.Hash{}) || len(header.Dpor.SeedSig) != 0 {
+			return errInvalidSeed
+		}
+		return nil
+	}
+
+	if header.Impeachment() {
+		if len(header.Dpor.SeedSig) != 0 {
+			return errInvalidSeedSig
+		}
+		if header.Dpor.Seed != chainSeed(parent.Dpor.Seed) {
+			return errInvalidSeed
+		}
+		return nil
+	}
+
+	// the seed is contributed by the proposer of the block in its view
+	snap, err := dh.snapshot(dpor, chain, number-1, header.ParentHash, parents)
+	if err != nil {
+		return err
+	}
+	if isProposer, _ := snap.IsProposerOf(header.Coinbase, number); !isProposer {
+		return errInvalidSeedSig
+	}
+	pk, ok := dpor.blsKeys[header.Coinbase]
+	if !ok {
+		return errInvalidSeedSig
+	}
+	sig, err := blskey.SignatureFromBytes(header.Dpor.SeedSig)
+	if err != nil || !pk.Verify(seedMessage(parent), sig) {
+		return errInvalidSeedSig
+	}
+	if header.Dpor.Seed != signedSeed(header.Dpor.SeedSig) {
+		return errInvalidSeed
+	}
+	return nil
+}
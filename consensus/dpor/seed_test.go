@@ -0,0 +1,182 @@
+package dpor
+
+import (
+	"math/big"
+	"testing"
+
+	"bitbucket.org/cpchain/chain/commons/crypto/blskey"
+	"bitbucket.org/cpchain/chain/consensus/dpor/election"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func newSeedTestDpor(t *testing.T, seedBlock int64) (*Dpor, []*blskey.SecretKey, []common.Address, *types.Header) {
+	d, keys, validators := newBLSTestDpor(t)
+	d.config.Forks.ElectionSeedBlock = big.NewInt(seedBlock)
+
+	parent := &types.Header{Number: big.NewInt(1), Coinbase: validators[0], Time: big.NewInt(0)}
+	parent.Dpor.Seed = common.HexToHash("0x01")
+	d.SetCurrentSnap(newSnapshot(d.config, 1, parent.Hash(), validators, validators, NormalMode))
+	return d, keys, validators, parent
+}
+
+func TestElectionSeed(t *testing.T) {
+	d, keys, validators, parent := newSeedTestDpor(t, 0)
+	dh := d.dh.(*defaultDporHelper)
+
+	// validators[1] is the proposer of block 2
+	newHeader := func(coinbase common.Address) *types.Header {
+		return &types.Header{Number: big.NewInt(2), ParentHash: parent.Hash(), Coinbase: coinbase, Time: big.NewInt(0)}
+	}
+
+	d.SetBLSKey(keys[1])
+	header := newHeader(validators[1])
+	if err := d.prepareSeed(d.CurrentSnap(), header, parent); err != nil {
+		t.Fatalf("failed to prepare seed: %v", err)
+	}
+	if len(header.Dpor.SeedSig) == 0 || header.Dpor.Seed != signedSeed(header.Dpor.SeedSig) {
+		t.Fatalf("seed is not the hash of the proposer's signature, got %v", header.Dpor)
+	}
+	if err := dh.verifySeed(d, nil, header, parent, nil); err != nil {
+		t.Fatalf("failed to verify seed: %v", err)
+	}
+	if got := election.SeedOf(d.config, header); got != header.Dpor.Seed.Big().Int64() {
+		t.Errorf("election seed mismatch, got %d", got)
+	}
+
+	// the proposer has only one seed to carry
+	again := newHeader(validators[1])
+	if err := d.prepareSeed(d.CurrentSnap(), again, parent); err != nil || again.Dpor.Seed != header.Dpor.Seed {
+		t.Errorf("seed of the same proposer on the same parent changes, got %x, %v", again.Dpor.Seed, err)
+	}
+
+	// the seed is in the hash of header
+	hash := header.Hash()
+	header.Dpor.Seed[0] ^= 0xff
+	if header.Hash() == hash {
+		t.Error("seed is not in the hash of header")
+	}
+	if err := dh.verifySeed(d, nil, header, parent, nil); err != errInvalidSeed {
+		t.Errorf("tampered seed is accepted, got %v", err)
+	}
+	header.Dpor.Seed[0] ^= 0xff
+
+	// the signature is mandatory
+	header.Dpor.SeedSig, header.Dpor.Seed = nil, chainSeed(parent.Dpor.Seed)
+	if err := dh.verifySeed(d, nil, header, parent, nil); err != errInvalidSeedSig {
+		t.Errorf("seed without the proposer's signature is accepted, got %v", err)
+	}
+
+	// the signature is bound to the proposer of the block and the parent's seed
+	d.SetBLSKey(keys[0])
+	header = newHeader(validators[1])
+	if err := d.prepareSeed(d.CurrentSnap(), header, parent); err != nil {
+		t.Fatal(err)
+	}
+	if err := dh.verifySeed(d, nil, header, parent, nil); err != errInvalidSeedSig {
+		t.Errorf("seed signed by another key is accepted, got %v", err)
+	}
+	header.Coinbase = validators[0]
+	if err := dh.verifySeed(d, nil, header, parent, nil); err != errInvalidSeedSig {
+		t.Errorf("seed signed by a proposer out of its view is accepted, got %v", err)
+	}
+
+	d.SetBLSKey(keys[1])
+	other := types.CopyHeader(parent)
+	other.Dpor.Seed = common.HexToHash("0x02")
+	header = newHeader(validators[1])
+	if err := d.prepareSeed(d.CurrentSnap(), header, other); err != nil {
+		t.Fatal(err)
+	}
+	if err := dh.verifySeed(d, nil, header, parent, nil); err != errInvalidSeedSig {
+		t.Errorf("seed signed on another parent's seed is accepted, got %v", err)
+	}
+}
+
+func TestElectionSeedWithoutKey(t *testing.T) {
+	d, keys, validators, _ := newSeedTestDpor(t, 0)
+	dh := d.dh.(*defaultDporHelper)
+
+	// the proposer is refused to propose without a bls key, others do not care
+	parent := &types.Header{Number: big.NewInt(3), Coinbase: validators[2], Time: big.NewInt(0)}
+	d.SetCurrentSnap(newSnapshot(d.config, 3, parent.Hash(), validators, validators, NormalMode))
+	header := &types.Header{Number: big.NewInt(4), ParentHash: parent.Hash(), Coinbase: validators[3], Time: big.NewInt(0)}
+	if err := d.prepareSeed(d.CurrentSnap(), header, parent); err != errNoBLSKey {
+		t.Errorf("proposer without a bls key prepares a seed, got %v", err)
+	}
+	header.Coinbase = validators[0]
+	if err := d.prepareSeed(d.CurrentSnap(), header, parent); err != nil || len(header.Dpor.SeedSig) != 0 {
+		t.Errorf("failed to prepare a header not to propose, got %v", err)
+	}
+
+	// validators[3], the proposer of block 4, has no valid registered key
+	d.SetBLSKey(keys[3])
+	header.Coinbase = validators[3]
+	if err := d.prepareSeed(d.CurrentSnap(), header, parent); err != nil {
+		t.Fatal(err)
+	}
+	if err := dh.verifySeed(d, nil, header, parent, nil); err != errInvalidSeedSig {
+		t.Errorf("seed signed by an unregistered key is accepted, got %v", err)
+	}
+}
+
+func TestElectionSeedImpeach(t *testing.T) {
+	d, keys, validators, parent := newSeedTestDpor(t, 0)
+	dh := d.dh.(*defaultDporHelper)
+
+	impeach := &types.Header{Number: big.NewInt(2), ParentHash: parent.Hash(), Time: big.NewInt(0)}
+	impeach.Dpor.Seed = d.impeachSeed(parent)
+	if err := dh.verifySeed(d, nil, impeach, parent, nil); err != nil {
+		t.Fatalf("failed to verify seed of impeach block: %v", err)
+	}
+
+	impeach.Dpor.Seed[0] ^= 0xff
+	if err := dh.verifySeed(d, nil, impeach, parent, nil); err != errInvalidSeed {
+		t.Errorf("impeach block with a wrong seed is accepted, got %v", err)
+	}
+	impeach.Dpor.Seed[0] ^= 0xff
+
+	impeach.Dpor.SeedSig = []byte{1}
+	if err := dh.verifySeed(d, nil, impeach, parent, nil); err != errInvalidSeedSig {
+		t.Errorf("impeach block with a seed signature is accepted, got %v", err)
+	}
+	impeach.Dpor.SeedSig = nil
+
+	// the proposer of a block on an impeach block signs its seed
+	d.SetBLSKey(keys[2])
+	header := &types.Header{Number: big.NewInt(3), ParentHash: impeach.Hash(), Coinbase: validators[2], Time: big.NewInt(0)}
+	if err := d.prepareSeed(d.CurrentSnap(), header, impeach); err != nil {
+		t.Fatal(err)
+	}
+	d.SetCurrentSnap(newSnapshot(d.config, 2, impeach.Hash(), validators, validators, NormalMode))
+	if err := dh.verifySeed(d, nil, header, impeach, nil); err != nil {
+		t.Errorf("failed to verify seed of block on impeach block: %v", err)
+	}
+}
+
+func TestElectionSeedBeforeFork(t *testing.T) {
+	d, keys, validators, parent := newSeedTestDpor(t, 10)
+	dh := d.dh.(*defaultDporHelper)
+
+	d.SetBLSKey(keys[1])
+	header := &types.Header{Number: big.NewInt(2), ParentHash: parent.Hash(), Coinbase: validators[1], Time: big.NewInt(0)}
+	if err := d.prepareSeed(d.CurrentSnap(), header, parent); err != nil || header.Dpor.Seed != (common.Hash{}) || len(header.Dpor.SeedSig) != 0 {
+		t.Fatalf("seed is prepared before the fork, got %x, %v", header.Dpor.Seed, err)
+	}
+	if err := dh.verifySeed(d, nil, header, parent, nil); err != nil {
+		t.Errorf("failed to verify header without seed: %v", err)
+	}
+	if got := election.SeedOf(d.config, header); got != header.Hash().Big().Int64() {
+		t.Errorf("election seed before the fork is not the hash, got %d", got)
+	}
+
+	header.Dpor.Seed = common.HexToHash("0x01")
+	if err := dh.verifySeed(d, nil, header, parent, nil); err != errInvalidSeed {
+		t.Errorf("seed before the fork is accepted, got %v", err)
+	}
+	header.Dpor.Seed = common.Hash{}
+	header.Dpor.SeedSig = []byte{1}
+	if err := dh.verifySeed(d, nil, header, parent, nil); err != errInvalidSeed {
+		t.Errorf("seed signature before the fork is accepted, got %v", err)
+	}
+}
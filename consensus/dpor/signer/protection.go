@@ -0,0 +1,110 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package signer
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/database"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// ErrDoubleSign is returned if a different header was signed at the same height in the same state.
+	ErrDoubleSign = errors.New("refuse to sign a different header at the same height and state")
+
+	// ErrDoubleImpeach is returned if an impeach block on a different parent was signed at the same height in the same state.
+	ErrDoubleImpeach = errors.New("refuse to sign an impeach block on a different parent at the same height and state")
+
+	// ErrNoParent is returned if an impeach signing request has no parent hash to protect it with.
+	ErrNoParent = errors.New("no parent hash in impeach signing request")
+)
+
+var (
+	signedPrefix = []byte("dpor-signer-signed-")
+)
+
+// SlashingProtection records what an account signed at each height, and refuses
+// to sign a different header at the same height in the same state, which would
+// be an equivocation evidence against the account.
+//
+// A validator may sign the failback impeach blocks at the same height with different
+// timestamps, so impeach blocks are protected by their parent instead, an impeach
+// block on a different parent at the same height is refused. Macs are not protected,
+// they do not sign any block.
+type SlashingProtection struct {
+	db   database.Database
+	lock sync.Mutex
+}
+
+// NewSlashingProtection creates a SlashingProtection persisting records into the given database
+func NewSlashingProtection(db database.Database) *SlashingProtection {
+	return &SlashingProtection{db: db}
+}
+
+// Check checks the request against signed records, and records it if it is allowed to sign.
+// A nil SlashingProtection allows all requests.
+func (p *SlashingProtection) Check(req *Request) error {
+	if p == nil {
+		return nil
+	}
+	// the hash a header is recorded with, and the error of signing a different one
+	var (
+		hash    common.Hash
+		refused error
+	)
+	switch req.Kind {
+	case KindSeal, KindPrepare, KindCommit:
+		hash, refused = req.Hash, ErrDoubleSign
+	case KindImpeachPrepare, KindImpeachCommit:
+		if req.Parent == (common.Hash{}) {
+			return ErrNoParent
+		}
+		hash, refused = req.Parent, ErrDoubleImpeach
+	default:
+		return nil
+	}
+
+	key := signedKey(req.Account, req.Kind, req.Number)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if signed, _ := p.db.Get(key); len(signed) != 0 {
+		if common.BytesToHash(signed) == hash {
+			return nil
+		}
+		log.Warn("refused to double sign", "account", req.Account.Hex(), "kind", req.Kind, "number", req.Number,
+			"signed", common.BytesToHash(signed).Hex(), "hash", hash.Hex())
+		return refused
+	}
+	return p.db.Put(key, hash.Bytes())
+}
+
+func signedKey(account common.Address, kind Kind, number uint64) []byte {
+	key := make([]byte, 0, len(signedPrefix)+common.AddressLength+1+8)
+	key = append(key, signedPrefix...)
+	key = append(key, account.Bytes()...)
+	key = append(key, byte(kind))
+
+	var enc [8]byte
+	binary.BigEndian.PutUint64(enc[:], number)
+	return append(key, enc[:]...)
+}
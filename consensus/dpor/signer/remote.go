@@ -0,0 +1,96 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package signer
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/cpchain/chain/api/rpc"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	// Namespace is the rpc namespace of the signing service
+	Namespace = "signer"
+
+	remoteSignTimeout = 2 * time.Second
+)
+
+// PublicSignerAPI exposes a Signer as the signing service over rpc
+type PublicSignerAPI struct {
+	signer Signer
+}
+
+// NewPublicSignerAPI creates a PublicSignerAPI signing with given signer
+func NewPublicSignerAPI(signer Signer) *PublicSignerAPI {
+	return &PublicSignerAPI{signer: signer}
+}
+
+// Sign signs the consensus msg of the request
+func (api *PublicSignerAPI) Sign(req Request) (hexutil.Bytes, error) {
+	return api.signer.Sign(&req)
+}
+
+// APIs returns the rpc apis of the signing service backed by given signer
+func APIs(signer Signer) []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: Namespace,
+			Version:   "1.0",
+			Service:   NewPublicSignerAPI(signer),
+			Public:    true,
+		},
+	}
+}
+
+// RemoteSigner delegates signing requests to an external signing service over rpc
+type RemoteSigner struct {
+	client *rpc.Client
+}
+
+// DialRemote connects to the signing service at given url, which is either a
+// http(s) or ws(s) url, or an ipc endpoint path
+func DialRemote(url string) (*RemoteSigner, error) {
+	client, err := rpc.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return NewRemoteSigner(client), nil
+}
+
+// NewRemoteSigner creates a RemoteSigner with a connected rpc client
+func NewRemoteSigner(client *rpc.Client) *RemoteSigner {
+	return &RemoteSigner{client: client}
+}
+
+// Sign implements Signer
+func (s *RemoteSigner) Sign(req *Request) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteSignTimeout)
+	defer cancel()
+
+	var sig hexutil.Bytes
+	if err := s.client.CallContext(ctx, &sig, Namespace+"_sign", req); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// Close closes the connection to the signing service
+func (s *RemoteSigner) Close() {
+	s.client.Close()
+}
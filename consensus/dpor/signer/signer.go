@@ -0,0 +1,175 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package signer implements signers of dpor consensus msgs, i.e. proposer seals
+// and validator signatures, either with a local account or delegated to an
+// external signing service.
+package signer
+
+import (
+	"errors"
+	"strings"
+
+	"bitbucket.org/cpchain/chain/accounts"
+	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/consensus/dpor/backend"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// ErrUnknownKind is returned if the kind of a signing request is unknown.
+	ErrUnknownKind = errors.New("unknown kind of signing request")
+
+	// ErrInvalidMac is returned if the mac to sign is not a dpor handshake mac.
+	ErrInvalidMac = errors.New("invalid mac to sign")
+)
+
+const (
+	preparePrefix = "Prepare"
+	macPrefix     = "cpchain|"
+)
+
+// Kind is the kind of consensus msg a signing request is for
+type Kind uint8
+
+const (
+	// KindSeal is the proposer's seal of a block
+	KindSeal Kind = iota
+
+	// KindPrepare is a validator's prepare signature of a block
+	KindPrepare
+
+	// KindCommit is a validator's commit signature of a block
+	KindCommit
+
+	// KindImpeachPrepare is a validator's prepare signature of an impeach block
+	KindImpeachPrepare
+
+	// KindImpeachCommit is a validator's commit signature of an impeach block
+	KindImpeachCommit
+
+	// KindMac is the mac exchanged in dpor handshake
+	KindMac
+)
+
+var (
+	kindName = map[Kind]string{
+		KindSeal:           "seal",
+		KindPrepare:        "prepare",
+		KindCommit:         "commit",
+		KindImpeachPrepare: "impeachPrepare",
+		KindImpeachCommit:  "impeachCommit",
+		KindMac:            "mac",
+	}
+)
+
+func (k Kind) String() string {
+	if name, ok := kindName[k]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// KindOf returns the kind of a validator signature in given state
+func KindOf(state consensus.State) (Kind, error) {
+	switch state {
+	case consensus.Prepare:
+		return KindPrepare, nil
+	case consensus.Commit:
+		return KindCommit, nil
+	case consensus.ImpeachPrepare:
+		return KindImpeachPrepare, nil
+	case consensus.ImpeachCommit:
+		return KindImpeachCommit, nil
+	default:
+		return 0, ErrUnknownKind
+	}
+}
+
+// Request is a request to sign a consensus msg.
+// Hash is the sig hash of the header for block signatures, Parent is the hash of
+// its parent for impeach block signatures, Mac is the mac for KindMac.
+// The signer derives what is actually signed by itself, so that a request cannot
+// trick it into signing a msg of another kind.
+type Request struct {
+	Account common.Address `json:"account"`
+	Kind    Kind           `json:"kind"`
+	Number  uint64         `json:"number"`
+	Hash    common.Hash    `json:"hash"`
+	Parent  common.Hash    `json:"parent,omitempty"`
+	Mac     string         `json:"mac,omitempty"`
+}
+
+// Digest returns the hash to sign for the request
+func (req *Request) Digest() (common.Hash, error) {
+	switch req.Kind {
+	case KindSeal, KindCommit, KindImpeachCommit:
+		return req.Hash, nil
+	case KindPrepare, KindImpeachPrepare:
+		return crypto.Keccak256Hash([]byte(preparePrefix), req.Hash.Bytes()), nil
+	case KindMac:
+		if !strings.HasPrefix(req.Mac, macPrefix) {
+			return common.Hash{}, ErrInvalidMac
+		}
+		return crypto.Keccak256Hash([]byte(req.Mac)), nil
+	default:
+		return common.Hash{}, ErrUnknownKind
+	}
+}
+
+// HashWithState returns the hash a validator signs for a header's sig hash in given state
+func HashWithState(hash common.Hash, state consensus.State) common.Hash {
+	kind, err := KindOf(state)
+	if err != nil {
+		return hash
+	}
+	digest, _ := (&Request{Kind: kind, Hash: hash}).Digest()
+	return digest
+}
+
+// Signer signs consensus msgs on behalf of a proposer or validator
+type Signer interface {
+	// Sign returns the signature of the request's digest by the request's account
+	Sign(req *Request) ([]byte, error)
+}
+
+// LocalSigner signs requests with a sign function backed by local accounts,
+// optionally checked by a slashing protection.
+type LocalSigner struct {
+	signFn     backend.SignFn
+	protection *SlashingProtection
+}
+
+// NewLocalSigner creates a LocalSigner, protection can be nil to sign without slashing protection
+func NewLocalSigner(signFn backend.SignFn, protection *SlashingProtection) *LocalSigner {
+	return &LocalSigner{
+		signFn:     signFn,
+		protection: protection,
+	}
+}
+
+// Sign implements Signer
+func (s *LocalSigner) Sign(req *Request) ([]byte, error) {
+	digest, err := req.Digest()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.protection.Check(req); err != nil {
+		return nil, err
+	}
+	return s.signFn(accounts.Account{Address: req.Account}, digest.Bytes())
+}
@@ -0,0 +1,159 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package signer
+
+import (
+	"testing"
+
+	"bitbucket.org/cpchain/chain/accounts"
+	"bitbucket.org/cpchain/chain/api/rpc"
+	"bitbucket.org/cpchain/chain/consensus"
+	"bitbucket.org/cpchain/chain/database"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func newTestSigner(t *testing.T, db database.Database) (common.Address, *LocalSigner) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	signFn := func(account accounts.Account, hash []byte) ([]byte, error) {
+		if account.Address != addr {
+			return nil, accounts.ErrUnknownAccount
+		}
+		return crypto.Sign(hash, key)
+	}
+	return addr, NewLocalSigner(signFn, NewSlashingProtection(db))
+}
+
+func TestHashWithState(t *testing.T) {
+	hash := common.HexToHash("0x1234")
+	prepare := crypto.Keccak256Hash(append([]byte("Prepare"), hash.Bytes()...))
+
+	tests := []struct {
+		state consensus.State
+		want  common.Hash
+	}{
+		{consensus.Prepare, prepare},
+		{consensus.ImpeachPrepare, prepare},
+		{consensus.Commit, hash},
+		{consensus.ImpeachCommit, hash},
+		{consensus.Idle, hash},
+	}
+	for _, tt := range tests {
+		if got := HashWithState(hash, tt.state); got != tt.want {
+			t.Errorf("HashWithState(%v) = %x, want %x", tt.state, got, tt.want)
+		}
+	}
+}
+
+func TestSlashingProtection(t *testing.T) {
+	db := database.NewMemDatabase()
+	addr, s := newTestSigner(t, db)
+
+	first := &Request{Account: addr, Kind: KindPrepare, Number: 10, Hash: common.HexToHash("0x01")}
+	second := &Request{Account: addr, Kind: KindPrepare, Number: 10, Hash: common.HexToHash("0x02")}
+
+	sig, err := s.Sign(first)
+	if err != nil {
+		t.Fatalf("Signing the first header should succeed, got %v", err)
+	}
+	digest, _ := first.Digest()
+	if pub, err := crypto.SigToPub(digest.Bytes(), sig); err != nil || crypto.PubkeyToAddress(*pub) != addr {
+		t.Fatalf("Signature should be recovered to the account, got %v", err)
+	}
+
+	// signing the same header again is allowed
+	if _, err := s.Sign(first); err != nil {
+		t.Fatalf("Signing the same header again should succeed, got %v", err)
+	}
+	if _, err := s.Sign(second); err != ErrDoubleSign {
+		t.Fatalf("Signing a different header should fail with %v, got %v", ErrDoubleSign, err)
+	}
+
+	// other states and heights are not affected
+	for _, req := range []*Request{
+		{Account: addr, Kind: KindCommit, Number: 10, Hash: second.Hash},
+		{Account: addr, Kind: KindPrepare, Number: 11, Hash: second.Hash},
+		{Account: addr, Kind: KindImpeachPrepare, Number: 10, Hash: common.HexToHash("0x03"), Parent: common.HexToHash("0x0a")},
+		{Account: addr, Kind: KindImpeachPrepare, Number: 10, Hash: common.HexToHash("0x04"), Parent: common.HexToHash("0x0a")},
+	} {
+		if _, err := s.Sign(req); err != nil {
+			t.Errorf("Signing %v at %d should succeed, got %v", req.Kind, req.Number, err)
+		}
+	}
+
+	// but not impeach blocks on another parent, or without one
+	if _, err := s.Sign(&Request{Account: addr, Kind: KindImpeachPrepare, Number: 10, Hash: common.HexToHash("0x05"), Parent: common.HexToHash("0x0b")}); err != ErrDoubleImpeach {
+		t.Errorf("Signing an impeach block on another parent should fail with %v, got %v", ErrDoubleImpeach, err)
+	}
+	if _, err := s.Sign(&Request{Account: addr, Kind: KindImpeachCommit, Number: 10, Hash: common.HexToHash("0x05")}); err != ErrNoParent {
+		t.Errorf("Signing an impeach block without parent should fail with %v, got %v", ErrNoParent, err)
+	}
+
+	// records are persisted
+	_, s = newTestSigner(t, db)
+	second.Account = addr
+	if err := s.protection.Check(second); err != ErrDoubleSign {
+		t.Fatalf("Persisted records should be checked, got %v", err)
+	}
+}
+
+func TestSignMac(t *testing.T) {
+	addr, s := newTestSigner(t, database.NewMemDatabase())
+
+	if _, err := s.Sign(&Request{Account: addr, Kind: KindMac, Mac: "cpchain|2019-02-26T16:22:21+08:00"}); err != nil {
+		t.Fatalf("Signing a mac should succeed, got %v", err)
+	}
+	if _, err := s.Sign(&Request{Account: addr, Kind: KindMac, Mac: "other"}); err != ErrInvalidMac {
+		t.Fatalf("Signing an invalid mac should fail with %v, got %v", ErrInvalidMac, err)
+	}
+	if _, err := s.Sign(&Request{Account: addr, Kind: Kind(100)}); err != ErrUnknownKind {
+		t.Fatalf("Signing an unknown kind should fail with %v, got %v", ErrUnknownKind, err)
+	}
+}
+
+func TestRemoteSigner(t *testing.T) {
+	addr, local := newTestSigner(t, database.NewMemDatabase())
+
+	server := rpc.NewServer()
+	for _, api := range APIs(local) {
+		if err := server.RegisterName(api.Namespace, api.Service); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer server.Stop()
+
+	remote := NewRemoteSigner(rpc.DialInProc(server))
+	defer remote.Close()
+
+	req := &Request{Account: addr, Kind: KindSeal, Number: 1, Hash: common.HexToHash("0x01")}
+	sig, err := remote.Sign(req)
+	if err != nil {
+		t.Fatalf("Remote signing should succeed, got %v", err)
+	}
+	if pub, err := crypto.SigToPub(req.Hash.Bytes(), sig); err != nil || crypto.PubkeyToAddress(*pub) != addr {
+		t.Fatalf("Remote signature should be recovered to the account, got %v", err)
+	}
+
+	req.Hash = common.HexToHash("0x02")
+	if _, err := remote.Sign(req); err == nil || err.Error() != ErrDoubleSign.Error() {
+		t.Fatalf("Remote signing a different header should fail with %v, got %v", ErrDoubleSign, err)
+	}
+}
@@ -0,0 +1,143 @@
+package dpor
+
+import (
+	"errors"
+	"fmt"
+
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus/dpor/election"
+	"bitbucket.org/cpchain/chain/consensus/dpor/rpt"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// A simulator replays elections of proposers against a local chain with the same
+// code a snapshot elects with, but with a dpor config and rpt services whose
+// parameters may be overridden, so that the effect of changing them is seen
+// before they are changed on chain.
+
+var (
+	// errTermTooEarly is returned if a term to simulate is elected before the genesis
+	errTermTooEarly = errors.New("term is elected before the genesis")
+
+	// errNoElection is returned if proposers of a term are not elected but default ones
+	errNoElection = errors.New("proposers of the term are not elected")
+
+	// errElectionFailed is returned if an election panics with overridden parameters
+	errElectionFailed = errors.New("election failed")
+)
+
+// HeaderReader reads headers of the local chain
+type HeaderReader interface {
+	GetHeaderByNumber(number uint64) *types.Header
+}
+
+// SimulatedElection is the result of a simulated election of proposers of a term
+type SimulatedElection struct {
+	Term      uint64
+	Number    uint64 // block number the election happens at
+	Elector   string
+	Rpts      rpt.RptList
+	Proposers []common.Address // proposers elected in the simulation
+	Actual    []common.Address // proposers of the term on chain, nil if not available
+}
+
+// Diff returns the seats whose simulated proposers differ from actual ones
+func (e *SimulatedElection) Diff() []int {
+	var seats []int
+	for i := 0; i < len(e.Proposers) || i < len(e.Actual); i++ {
+		if i >= len(e.Proposers) || i >= len(e.Actual) || e.Proposers[i] != e.Actual[i] {
+			seats = append(seats, i)
+		}
+	}
+	return seats
+}
+
+// Simulator simulates elections of proposers
+type Simulator struct {
+	config *configs.DporConfig // config to elect with
+	actual *configs.DporConfig // config of the chain
+
+	headers          HeaderReader
+	candidateService rpt.CandidateService
+	rptService       rpt.RptService
+}
+
+// NewSimulator creates a simulator electing with config on the chain of the actual
+// config, rpt parameters and seats are overridden in the given services if needed
+func NewSimulator(config, actual *configs.DporConfig, headers HeaderReader, candidateService rpt.CandidateService, rptService rpt.RptService) *Simulator {
+	return &Simulator{
+		config:           config,
+		actual:           actual,
+		headers:          headers,
+		candidateService: candidateService,
+		rptService:       rptService,
+	}
+}
+
+// Comparable returns if simulated committees are comparable with actual ones,
+// which is not the case if terms of the config differ from ones of the chain
+func (s *Simulator) Comparable() bool {
+	return s.config.TermLen*s.config.ViewLen == s.actual.TermLen*s.actual.ViewLen
+}
+
+// ElectionNumberOf returns the checkpoint block number proposers of the term are
+// elected at
+func (s *Simulator) ElectionNumberOf(term uint64) (uint64, error) {
+	if term <= TermDistBetweenElectionAndMining {
+		return 0, fmt.Errorf("%v: %d", errTermTooEarly, term)
+	}
+	return (term - TermDistBetweenElectionAndMining) * s.config.TermLen * s.config.ViewLen, nil
+}
+
+// Simulate replays the election of proposers of the term
+func (s *Simulator) Simulate(term uint64) (result *SimulatedElection, err error) {
+	// electors may panic with parameters they never run with on chain
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("%v: %v", errElectionFailed, r)
+		}
+	}()
+
+	number, err := s.ElectionNumberOf(term)
+	if err != nil {
+		return nil, err
+	}
+	header := s.headers.GetHeaderByNumber(number)
+	if header == nil {
+		return nil, fmt.Errorf("%v: %d", errUnknownBlock, number)
+	}
+
+	snap := newSnapshot(s.config, number, header.Hash(), nil, nil, NormalMode)
+	if !snap.isStartElection() {
+		return nil, fmt.Errorf("%v: %d", errNoElection, term)
+	}
+
+	// same as updating the snapshot with the checkpoint header
+	if err := snap.updateCandidates(s.candidateService); err != nil {
+		return nil, err
+	}
+	rpts, err := snap.updateRpts(s.rptService)
+	if err != nil {
+		return nil, err
+	}
+	if err := snap.updateProposers(rpts, election.SeedOf(s.config, header), s.rptService); err != nil {
+		return nil, err
+	}
+
+	result = &SimulatedElection{
+		Term:      term,
+		Number:    number,
+		Elector:   s.config.ElectorOf(number),
+		Rpts:      rpts,
+		Proposers: snap.getRecentProposers(snap.FutureTermOf(number)),
+	}
+
+	// proposers of a term are carried in headers of the term
+	if s.Comparable() {
+		if first := s.headers.GetHeaderByNumber(term*s.actual.TermLen*s.actual.ViewLen + 1); first != nil {
+			result.Actual = first.Dpor.Proposers
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,120 @@
+package dpor
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/consensus/dpor/election"
+	"bitbucket.org/cpchain/chain/consensus/dpor/rpt"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type simulatedHeaders map[uint64]*types.Header
+
+func (h simulatedHeaders) GetHeaderByNumber(number uint64) *types.Header {
+	return h[number]
+}
+
+type simulatedCandidates []common.Address
+
+func (c simulatedCandidates) CandidatesOf(term uint64, number uint64) ([]common.Address, error) {
+	return c, nil
+}
+
+// simulatedRpts ranks candidates in order with fixed seats
+type simulatedRpts struct {
+	rpt.RptService
+	totalSeats int
+}
+
+func (r *simulatedRpts) CalcRptInfoList(addresses []common.Address, number uint64) (rpt.RptList, error) {
+	var rpts rpt.RptList
+	for i, addr := range addresses {
+		rpts = append(rpts, rpt.Rpt{Address: addr, Rpt: int64(100 * (i + 1))})
+	}
+	return rpts, nil
+}
+
+func (r *simulatedRpts) TotalSeats(number uint64) (int, error)  { return r.totalSeats, nil }
+func (r *simulatedRpts) LowRptSeats(number uint64) (int, error) { return 1, nil }
+func (r *simulatedRpts) LowRptCount(total int, number uint64) (int, error) {
+	return total / 2, nil
+}
+
+func TestSimulator(t *testing.T) {
+	config := &configs.DporConfig{
+		TermLen:            12,
+		ViewLen:            1,
+		MaxInitBlockNumber: 24,
+		Forks:              &configs.DporForks{Election2Block: big.NewInt(0)},
+	}
+
+	headers := make(simulatedHeaders)
+	for i := uint64(0); i <= 100; i++ {
+		headers[i] = &types.Header{Number: new(big.Int).SetUint64(i), Time: new(big.Int).SetUint64(i)}
+	}
+	var candidates simulatedCandidates
+	for i := 1; i <= 16; i++ {
+		candidates = append(candidates, common.BigToAddress(big.NewInt(int64(i))))
+	}
+
+	// proposers of term 4 on chain are elected at #24 with 6 dynamic seats
+	rpts, _ := (&simulatedRpts{}).CalcRptInfoList(candidates, 24)
+	actual, err := election.ElectAt(config, 24, &election.Context{
+		Number:           24,
+		Rpts:             rpts,
+		Seed:             headers[24].Hash().Big().Int64(),
+		TermLen:          12,
+		DefaultProposers: configs.Proposers(),
+		Seats:            &simulatedRpts{totalSeats: 6},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers[4*12+1].Dpor.Proposers = actual
+
+	// the same parameters elect the same proposers
+	simulator := NewSimulator(config, config, headers, candidates, &simulatedRpts{totalSeats: 6})
+	result, err := simulator.Simulate(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Number != 24 || result.Elector != configs.ElectorElect2 || !reflect.DeepEqual(result.Proposers, actual) || len(result.Diff()) != 0 {
+		t.Fatalf("simulated election mismatch: #%d %s, got %v, want %v", result.Number, result.Elector, result.Proposers, actual)
+	}
+
+	// less dynamic seats elect different proposers
+	simulator = NewSimulator(config, config, headers, candidates, &simulatedRpts{totalSeats: 4})
+	result, err = simulator.Simulate(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Proposers) != 12 || len(result.Diff()) == 0 {
+		t.Errorf("overridden seats elect the same proposers: %v", result.Proposers)
+	}
+
+	// terms of a different length are not comparable with ones on chain
+	overridden := *config
+	overridden.TermLen = 8
+	simulator = NewSimulator(&overridden, config, headers, candidates, &simulatedRpts{totalSeats: 2})
+	result, err = simulator.Simulate(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if simulator.Comparable() || result.Number != 16 || len(result.Proposers) != 8 || result.Actual != nil {
+		t.Errorf("terms of a different length are compared, elected at #%d", result.Number)
+	}
+
+	// elect2 is not able to elect proposers of a term of 10
+	overridden.TermLen = 10
+	if _, err := simulator.Simulate(4); err == nil {
+		t.Error("election with an invalid term length succeeded")
+	}
+
+	if _, err := simulator.Simulate(2); err == nil {
+		t.Error("term elected before the genesis is simulated")
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"math/rand"
 	"sync"
 
 	"bitbucket.org/cpchain/chain/commons/log"
@@ -30,8 +29,6 @@ const (
 	MaxSizeOfRecentProposers = 200
 )
 
-const defaultProposersSeats = 4
-
 var (
 	errValidatorNotInCommittee = errors.New("not a member in validators committee")
 	errProposerNotInCommittee  = errors.New("not a member in proposers committee")
@@ -332,15 +329,11 @@ func (s *DporSnapshot) applyHeader(header *types.Header, ifUpdateCommittee bool,
 		// If in checkpoint, run election
 		if backend.IsCheckPoint(s.number(), s.config.TermLen, s.config.ViewLen) {
 			log.Debug("update proposers committee", "number", s.number())
-			seed := header.Hash().Big().Int64()
-			if s.number() < configs.Election2BlockNumber {
-				log.Debug("update proposers with updateProposers 1", "number", s.number())
-				s.updateProposers(rpts, seed)
-			} else {
-				log.Debug("update proposers with updateProposers 2", "number", s.number())
-				s.updateProposers2(rpts, seed, rptService)
+			seed := election.SeedOf(s.config, header)
+			if err := s.updateProposers(rpts, seed, rptService); err != nil {
+				log.Warn("err when update proposers", "err", err)
+				return err
 			}
-
 		}
 
 	}
@@ -365,7 +358,7 @@ func (s *DporSnapshot) updateCandidates(candidateService rpt.CandidateService) e
 
 		// Read candidates from the contract instance
 		term := s.TermOf(s.Number)
-		cds, err := candidateService.CandidatesOf(term)
+		cds, err := candidateService.CandidatesOf(term, s.number())
 		if err != nil {
 			log.Error("read Candidates error, use default candidates instead", "err", err)
 			// use default candidates instead
@@ -408,7 +401,10 @@ func (s *DporSnapshot) updateRpts(rptService rpt.RptService) (rpt.RptList, error
 
 	switch {
 	case s.Mode == NormalMode && s.isStartElection() && rptService != nil:
-		rpts := rptService.CalcRptInfoList(s.candidates(), s.number())
+		rpts, err := rptService.CalcRptInfoList(s.candidates(), s.number())
+		if err != nil {
+			return nil, err
+		}
 		log.Debug("rpt result", "rpts", rpts.FormatString())
 		return rpts, nil
 	default:
@@ -440,100 +436,8 @@ func (s *DporSnapshot) isAboutToCampaign() bool {
 	return s.number() >= s.config.MaxInitBlockNumber-((TermDistBetweenElectionAndMining+2)*s.config.TermLen*s.config.ViewLen)
 }
 
-// updateProposer uses rpt and election result to get new proposers committee
-func (s *DporSnapshot) updateProposers(rpts rpt.RptList, seed int64) {
-	// Elect proposers
-	if s.isStartElection() {
-
-		// some logs about rpt infos
-		log.Debug("---------------------------")
-		log.Debug("start election")
-		log.Debug("rpts list:")
-		for idx, r := range rpts {
-			log.Debug("rpt:", "idx", idx, "addr", r.Address.Hex(), "value", r.Rpt)
-		}
-		log.Debug("seed", "seed", seed)
-		log.Debug("term length", "term", int(s.config.TermLen))
-		log.Debug("---------------------------")
-
-		// run the election algorithm
-		var proposers []common.Address
-		if int(s.config.TermLen) > defaultProposersSeats {
-			electedProposers := election.Elect(rpts, seed, int(s.config.TermLen)-defaultProposersSeats)
-
-			log.Debug("---------------------------")
-			log.Debug("elected 8 proposers")
-			for i, ep := range electedProposers {
-				log.Debug("proposer", "idx", i, "addr", ep.Hex())
-			}
-			log.Debug("---------------------------")
-
-			chosenProposers := choseSomeProposers(configs.Proposers(), seed, defaultProposersSeats)
-
-			log.Debug("---------------------------")
-			log.Debug("chosen 4 proposers")
-			for i, ep := range chosenProposers {
-				log.Debug("proposer", "idx", i, "addr", ep.Hex())
-			}
-			log.Debug("---------------------------")
-
-			log.Debug("---------------------------")
-			log.Debug("default 12 proposers")
-			for i, ep := range configs.Proposers() {
-				log.Debug("proposer", "idx", i, "addr", ep.Hex())
-			}
-			log.Debug("---------------------------")
-
-			proposers = evenlyInsertDefaultProposers(electedProposers, chosenProposers, seed, int(s.config.TermLen))
-
-			log.Debug("---------------------------")
-			log.Debug("evenly spared 12 proposers")
-			for i, ep := range proposers {
-				log.Debug("proposer", "idx", i, "addr", ep.Hex())
-			}
-			log.Debug("---------------------------")
-
-		} else {
-			proposers = election.Elect(rpts, seed, int(s.config.TermLen))
-		}
-
-		if len(proposers) != int(s.config.TermLen) {
-			panic("invalid length of prepared proposer list")
-		}
-
-		// save to cache
-		term := s.FutureTermOf(s.number())
-		s.setRecentProposers(term, proposers)
-
-		// some logs about elected proposers
-		log.Debug("---------------------------")
-		log.Debug("elected proposers:")
-		for idx, s := range proposers {
-			log.Debug("proposer", "idx", idx, "addr", s.Hex())
-		}
-		log.Debug("current number", "number", s.number())
-		log.Debug("future term(election term)", "term", term)
-		log.Debug("---------------------------")
-	}
-
-	// Set default proposer if it is in initial stage
-	if s.isUseDefaultProposers() {
-		// set default proposers
-		proposers := configs.Proposers()
-		s.setRecentProposers(s.Term()+1, proposers)
-
-		// some logs about default proposer in initialization state
-		log.Debug("use default proposers for term", "term", s.Term()+1, "proposers", len(proposers))
-		for i, p := range proposers {
-			log.Debug(fmt.Sprintf("proposer #%d details", i), "address", p.Hex())
-		}
-	}
-
-	return
-}
-
-// updateProposer uses rpt and election result to get new proposers committee
-func (s *DporSnapshot) updateProposers2(rpts rpt.RptList, seed int64, rptService rpt.RptService) {
+// updateProposers uses rpt and the elector scheduled at current block to get new proposers committee
+func (s *DporSnapshot) updateProposers(rpts rpt.RptList, seed int64, rptService rpt.RptService) error {
 	// Elect proposers
 	if s.isStartElection() {
 
@@ -545,49 +449,19 @@ func (s *DporSnapshot) updateProposers2(rpts rpt.RptList, seed int64, rptService
 		log.Debug("term length", "term", int(s.config.TermLen))
 		log.Debug("---------------------------")
 
-		// run the election algorithm
-		var proposers []common.Address
-		if int(s.config.TermLen) > defaultProposersSeats {
-
-			logOutAddrs("default 12 proposers", "proposer", configs.Proposers())
-
-			// elect some proposers based on rpts
-			dynamicSeats, _ := rptService.TotalSeats()
-			lowRptCount := rptService.LowRptCount(rpts.Len())
-			lowRptSeats, _ := rptService.LowRptSeats()
-			electedProposers := election.Elect2(rpts, seed, dynamicSeats, lowRptCount, lowRptSeats)
-
-			logOutAddrs("elected proposers", "proposers", electedProposers)
-
-			// append default proposers to the end of electedProposers
-			paddingSeats := int(s.config.TermLen) - dynamicSeats - defaultProposersSeats
-			for _, addr := range configs.Proposers()[:paddingSeats] {
-				electedProposers = append(electedProposers, addr)
-			}
-
-			logOutAddrs("elected proposers after padding", "proposers", electedProposers)
-
-			// remove elected and padded proposers from all default proposers
-			leftDefaultProposers := addressExcept(configs.Proposers(), electedProposers)
-
-			logOutAddrs("left default proposer after election and padding", "proposers", leftDefaultProposers)
-
-			// chose some default proposers
-			chosenProposers := choseSomeProposers(leftDefaultProposers, seed, defaultProposersSeats)
-
-			logOutAddrs("chosen 4 proposers", "proposers", chosenProposers)
-
-			// combine together
-			proposers = evenlyInsertDefaultProposers(electedProposers, chosenProposers, seed, int(s.config.TermLen))
-
-			logOutAddrs("evenly spared 12 proposers", "proposer", proposers)
-
-		} else {
-			proposers = election.Elect(rpts, seed, int(s.config.TermLen))
+		ctx := &election.Context{
+			Number:           s.number(),
+			Rpts:             rpts,
+			Seed:             seed,
+			TermLen:          int(s.config.TermLen),
+			DefaultProposers: configs.Proposers(),
+			Seats:            rptService,
 		}
 
-		if len(proposers) != int(s.config.TermLen) {
-			panic("invalid length of prepared proposer list")
+		// run the election algorithm
+		proposers, err := election.ElectAt(s.config, s.number(), ctx)
+		if err != nil {
+			return err
 		}
 
 		// save to cache
@@ -607,7 +481,7 @@ func (s *DporSnapshot) updateProposers2(rpts rpt.RptList, seed int64, rptService
 
 	}
 
-	return
+	return nil
 }
 
 // Term returns the term index of current block number, which is 0-based
@@ -711,95 +585,6 @@ func (s *DporSnapshot) StartBlockNumberOfTerm(term uint64) uint64 {
 	return s.config.ViewLen * s.config.TermLen * term
 }
 
-// choseDefaultProposers choses a batch of proposers from a proposers slice with total count of `defaultProposersNum`
-// by the seed of current snapshot.hash.
-func choseSomeProposers(allProposers []common.Address, seed int64, wantLen int) (defaultProposers []common.Address) {
-
-	var proposers []common.Address
-	for _, p := range allProposers {
-		proposers = append(proposers, p)
-	}
-
-	if len(proposers) > wantLen {
-		randSource := rand.NewSource(seed)
-		myRand := rand.New(randSource)
-
-		for i := 0; i < wantLen; i++ {
-			chosen := myRand.Intn(len(proposers))
-			defaultProposers = append(defaultProposers, proposers[chosen])
-			proposers = append(proposers[:chosen], proposers[chosen+1:]...)
-		}
-		return defaultProposers
-	} else if len(proposers) == wantLen {
-		return proposers
-	}
-	panic("invalid length of given proposer list")
-}
-
-func evenlyInsertDefaultProposers(electedProposers []common.Address, chosenDefaultProposers []common.Address, seed int64, wantLen int) (proposers []common.Address) {
-
-	// panic if length of slices is invalid
-	if len(electedProposers)+len(chosenDefaultProposers) != wantLen {
-		panic("invalid length when evenly inserting default proposers to elected proposers")
-	}
-
-	// generate a random generator
-	randSource := rand.NewSource(seed)
-	myRand := rand.New(randSource)
-
-	slicesNum := len(chosenDefaultProposers)
-
-	if wantLen%slicesNum != 0 {
-		panic("invalid wanted length, not a multiple of 4")
-	}
-
-	step := wantLen / slicesNum
-
-	for i := 0; i < slicesNum; i++ {
-		var slice []common.Address
-
-		// combine two sub slices
-		slice = append(slice, electedProposers[i*(step-1):i*(step-1)+(step-1)]...)
-		slice = append(slice, chosenDefaultProposers[i])
-
-		// get random position of the chosen proposer
-		pos := myRand.Intn(step)
-
-		// swap
-		tmp := slice[len(slice)-1]
-		for j := step - 1; j > pos; j-- {
-			slice[j] = slice[j-1]
-		}
-		slice[pos] = tmp
-
-		// append to proposers
-		proposers = append(proposers, slice...)
-	}
-	return
-}
-
-// addressExcept returns a slice of addresses by remove all addresses in `except` slice from `all` slice
-func addressExcept(all []common.Address, except []common.Address) (result []common.Address) {
-
-	for _, x := range all {
-
-		ready := true
-
-		for _, y := range except {
-			if x == y {
-				ready = false
-				break
-			}
-		}
-
-		if ready {
-			result = append(result, x)
-		}
-	}
-
-	return
-}
-
 func logOutAddrs(title string, prefix string, addrs []common.Address) {
 	log.Debug("---------------------------")
 	log.Debug(title)
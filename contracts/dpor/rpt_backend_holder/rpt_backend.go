@@ -87,9 +87,10 @@ func (cc *RptApiClient) HeaderByNumber(ctx context.Context, number *big.Int) (*t
 	return cc.ChainBackend.HeaderByNumber(ctx, rpc.BlockNumber(number.Uint64()))
 }
 
+// CodeAt returns the code of the account in the state at the given block number,
+// or the latest one if it is nil.
 func (cc *RptApiClient) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
-	blockNr := rpc.LatestBlockNumber
-	state, _, err := cc.ChainBackend.StateAndHeaderByNumber(ctx, blockNr, false)
+	state, _, err := cc.ChainBackend.StateAndHeaderByNumber(ctx, toBlockNumber(blockNumber), false)
 	if state == nil || err != nil {
 		return nil, err
 	}
@@ -97,13 +98,25 @@ func (cc *RptApiClient) CodeAt(ctx context.Context, account common.Address, bloc
 	return code, state.Error()
 }
 
+// CallContract executes a call in the state at the given block number, or the
+// latest one if it is nil.
 func (cc *RptApiClient) CallContract(ctx context.Context, call cpchain.CallMsg, blockNumber *big.Int) ([]byte, error) {
-	result, err := cc.ContractBackend.Call(ctx, toCallArg(call), rpc.LatestBlockNumber)
+	result, err := cc.ContractBackend.Call(ctx, toCallArg(call), toBlockNumber(blockNumber))
 	if err != nil {
-		log.Fatal("CallContract using PublicBlockChainAPI is error ", "error is ", err)
+		// the state of a past block may be pruned, let callers fall back
+		log.Error("CallContract using PublicBlockChainAPI is error ", "error is ", err, "number", blockNumber)
 	}
 	return result, err
 }
+
+// toBlockNumber returns the rpc block number of a block number, the latest one if it is nil
+func toBlockNumber(number *big.Int) rpc.BlockNumber {
+	if number == nil {
+		return rpc.LatestBlockNumber
+	}
+	return rpc.BlockNumber(number.Int64())
+}
+
 func toCallArg(msg cpchain.CallMsg) cpcapi.CallArgs {
 	arg := cpcapi.CallArgs{
 		From: msg.From,
@@ -157,6 +157,7 @@ type BlockChain struct {
 	chainHeadFeed   event.Feed
 	chainLatestFeed event.Feed
 	logsFeed        event.Feed
+	privLogsFeed    event.Feed
 	scope           event.SubscriptionScope
 	genesisBlock    *types.Block
 
@@ -168,6 +169,8 @@ type BlockChain struct {
 	currentBlock     atomic.Value // Current head of the block chain
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
 
+	currentFinalizedBlock atomic.Value // Current head of the blocks carrying a commit certificate
+
 	stateCache       state.Database // State database to reuse between imports (contains state cache)
 	bodyCache        *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache     *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
@@ -377,12 +380,25 @@ func (bc *BlockChain) loadLastState() error {
 		}
 	}
 
+	// Restore the last known finalized block, it never goes beyond the head block
+	bc.currentFinalizedBlock.Store(bc.genesisBlock)
+	if head := rawdb.ReadHeadFinalizedBlockHash(bc.db); head != (common.Hash{}) {
+		if block := bc.GetBlockByHash(head); block != nil {
+			if block.NumberU64() > currentBlock.NumberU64() {
+				block = currentBlock
+			}
+			bc.currentFinalizedBlock.Store(block)
+		}
+	}
+
 	// Issue a status log for the user
 	currentFastBlock := bc.CurrentFastBlock()
+	currentFinalizedBlock := bc.CurrentFinalizedBlock()
 
 	log.Info("Loaded most recent local header", "number", currentHeader.Number, "hash", currentHeader.Hash().Hex())
 	log.Info("Loaded most recent local full block", "number", currentBlock.Number(), "hash", currentBlock.Hash().Hex())
 	log.Info("Loaded most recent local fast block", "number", currentFastBlock.Number(), "hash", currentFastBlock.Hash().Hex())
+	log.Info("Loaded most recent local finalized block", "number", currentFinalizedBlock.Number(), "hash", currentFinalizedBlock.Hash().Hex())
 
 	return nil
 }
@@ -441,6 +457,11 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	rawdb.WriteHeadBlockHash(bc.db, currentBlock.Hash())
 	rawdb.WriteHeadFastBlockHash(bc.db, currentFastBlock.Hash())
 
+	// Ancestors of a finalized block are final too, so is the rewound head
+	if currentFinalizedBlock, ok := bc.currentFinalizedBlock.Load().(*types.Block); ok && currentFinalizedBlock.NumberU64() > currentBlock.NumberU64() {
+		rawdb.WriteHeadFinalizedBlockHash(bc.db, currentBlock.Hash())
+	}
+
 	return bc.loadLastState()
 }
 
@@ -486,6 +507,13 @@ func (bc *BlockChain) CurrentFastBlock() *types.Block {
 	return bc.currentFastBlock.Load().(*types.Block)
 }
 
+// CurrentFinalizedBlock retrieves the latest block of the canonical chain that
+// is final, i.e. it carries a commit certificate or it is an impeach block. The
+// block is retrieved from the blockchain's internal cache.
+func (bc *BlockChain) CurrentFinalizedBlock() *types.Block {
+	return bc.currentFinalizedBlock.Load().(*types.Block)
+}
+
 // SetProcessor sets the processor required for making state modifications.
 func (bc *BlockChain) SetProcessor(processor Processor) {
 	bc.procmu.Lock()
@@ -534,6 +562,15 @@ func (bc *BlockChain) StatePrivAt(root common.Hash) (*state.StateDB, error) {
 	return state.New(GetPrivateStateRoot(bc.db, root), bc.privateStateCache)
 }
 
+// HeldStatePrivAt is like StatePrivAt, but returns ErrNoPrivateState if this node
+// does not hold the private state of the given point in time, instead of an empty one.
+func (bc *BlockChain) HeldStatePrivAt(root common.Hash) (*state.StateDB, error) {
+	if !SupportPrivateTx(bc) || !HasPrivateStateRoot(bc.db, root) {
+		return nil, ErrNoPrivateState
+	}
+	return bc.StatePrivAt(root)
+}
+
 // Reset purges the entire blockchain, restoring it to its genesis state.
 func (bc *BlockChain) Reset() error {
 	return bc.ResetWithGenesisBlock(bc.genesisBlock)
@@ -558,6 +595,7 @@ func (bc *BlockChain) ResetWithGenesisBlock(genesis *types.Block) error {
 	bc.hc.SetGenesis(bc.genesisBlock.Header())
 	bc.hc.SetCurrentHeader(bc.genesisBlock.Header())
 	bc.currentFastBlock.Store(bc.genesisBlock)
+	bc.currentFinalizedBlock.Store(bc.genesisBlock)
 
 	return nil
 }
@@ -634,6 +672,28 @@ func (bc *BlockChain) insert(block *types.Block) {
 
 		bc.currentFastBlock.Store(block)
 	}
+
+	// A certified block is final, so are all its ancestors
+	if isFinalized(block) {
+		rawdb.WriteHeadFinalizedBlockHash(bc.db, block.Hash())
+
+		bc.currentFinalizedBlock.Store(block)
+	}
+}
+
+// isFinalized reports whether the block is final once it is in the chain. The
+// genesis block, blocks carrying the validators' commit certificate and impeach
+// blocks are final, the certificate itself is verified by the engine on insertion.
+func isFinalized(block *types.Block) bool {
+	if block.NumberU64() == 0 || block.Impeachment() || block.Header().Dpor.Aggregated() {
+		return true
+	}
+	for _, sig := range block.Header().Dpor.Sigs {
+		if !sig.IsEmpty() {
+			return true
+		}
+	}
+	return false
 }
 
 // Genesis retrieves the chain's genesis block.
@@ -911,6 +971,11 @@ func (bc *BlockChain) Rollback(chain []common.Hash) {
 			bc.currentBlock.Store(newBlock)
 			rawdb.WriteHeadBlockHash(bc.db, newBlock.Hash())
 		}
+		if currentFinalizedBlock := bc.CurrentFinalizedBlock(); currentFinalizedBlock.Hash() == hash {
+			newFinalizedBlock := bc.GetBlock(currentFinalizedBlock.ParentHash(), currentFinalizedBlock.NumberU64()-1)
+			bc.currentFinalizedBlock.Store(newFinalizedBlock)
+			rawdb.WriteHeadFinalizedBlockHash(bc.db, newFinalizedBlock.Hash())
+		}
 	}
 }
 
@@ -1128,6 +1193,9 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, pubReceipts []*typ
 	for _, r := range privReceipts {
 		WritePrivateReceipt(r, r.TxHash, bc.db)
 	}
+	if err := WritePrivateLogs(batch, block.Hash(), block.NumberU64(), privReceipts); err != nil {
+		return NonStatTy, err
+	}
 
 	currentBlock := bc.CurrentBlock()
 	localHeight := currentBlock.Number()
@@ -1496,6 +1564,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			coalescedLogs = append(coalescedLogs, logs...)
 			blockInsertTimer.UpdateSince(bstart)
 			events = append(events, ChainEvent{block, block.Hash(), logs})
+			if privLogs := privateLogs(privReceipts); len(privLogs) > 0 {
+				events = append(events, PrivateLogsEvent{privLogs})
+			}
 			lastCanon = block
 
 			// Only count canonical blocks for GC processing time
@@ -1597,10 +1668,17 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		commonBlock *types.Block
 		deletedTxs  types.Transactions
 		deletedLogs []*types.Log
+		// deletedPrivLogs are the deleted logs of private transactions
+		deletedPrivLogs []*types.Log
 		// collectLogs collects the logs that were generated during the
 		// processing of the block that corresponds with the given hash.
 		// These logs are later announced as deleted.
 		collectLogs = func(hash common.Hash) {
+			for _, log := range ReadPrivateLogs(bc.db, hash) {
+				log.Removed = true
+				deletedPrivLogs = append(deletedPrivLogs, log)
+			}
+
 			// Coalesce logs and set 'Removed'.
 			number := bc.hc.GetBlockNumber(hash)
 			if number == nil {
@@ -1688,8 +1766,8 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	}
 	batch.Write()
 
-	if len(deletedLogs) > 0 {
-		go bc.rmLogsFeed.Send(RemovedLogsEvent{deletedLogs})
+	if len(deletedLogs) > 0 || len(deletedPrivLogs) > 0 {
+		go bc.rmLogsFeed.Send(RemovedLogsEvent{Logs: deletedLogs, PrivateLogs: deletedPrivLogs})
 	}
 	if len(oldChain) > 0 {
 		go func() {
@@ -1723,6 +1801,9 @@ func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
 
 		case ChainLatestEvent:
 			bc.chainLatestFeed.Send(ev)
+
+		case PrivateLogsEvent:
+			bc.privLogsFeed.Send(ev)
 		}
 	}
 }
@@ -1916,6 +1997,18 @@ func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscript
 	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
 }
 
+// SubscribePrivateLogsEvent registers a subscription of the logs of private
+// transactions this node participates in.
+func (bc *BlockChain) SubscribePrivateLogsEvent(ch chan<- PrivateLogsEvent) event.Subscription {
+	return bc.scope.Track(bc.privLogsFeed.Subscribe(ch))
+}
+
+// GetPrivateLogs retrieves the logs of private transactions in a block which
+// this node participates in.
+func (bc *BlockChain) GetPrivateLogs(hash common.Hash) []*types.Log {
+	return ReadPrivateLogs(bc.db, hash)
+}
+
 // RemoteDB returns remote database if it has, otherwise return nil.
 func (bc *BlockChain) RemoteDB() database.RemoteDatabase {
 	return bc.remoteDB
@@ -30,6 +30,7 @@ import (
 	"bitbucket.org/cpchain/chain/core/state"
 	"bitbucket.org/cpchain/chain/core/vm"
 	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/private"
 	"bitbucket.org/cpchain/chain/types"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -169,6 +170,78 @@ func TestLastBlock(t *testing.T) {
 	}
 }
 
+// Tests that the finalized head follows certified and impeach blocks, and that
+// it is restored and rewound with the chain.
+func TestFinalizedBlock(t *testing.T) {
+	db := database.NewMemDatabase()
+	blockchain, err := newCanonical(fakeDpor(db), 0, db)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+
+	if finalized := blockchain.CurrentFinalizedBlock(); finalized.Hash() != blockchain.Genesis().Hash() {
+		t.Fatalf("finalized head mismatch: have #%d, want genesis", finalized.NumberU64())
+	}
+
+	// only block #3 carries a commit certificate
+	blocks := makeBlockChain(blockchain.CurrentBlock(), 4, fakeDpor(db), blockchain.db, canonicalSeed)
+	blocks[2].RefHeader().Dpor.Sigs = []types.DporSignature{{}, {1}}
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert blocks: %v", err)
+	}
+	if finalized := blockchain.CurrentFinalizedBlock(); finalized.Hash() != blocks[2].Hash() {
+		t.Fatalf("finalized head mismatch: have #%d, want #3", finalized.NumberU64())
+	}
+	if hash := rawdb.ReadHeadFinalizedBlockHash(db); hash != blocks[2].Hash() {
+		t.Fatalf("stored finalized head mismatch: have %x, want %x", hash, blocks[2].Hash())
+	}
+
+	// the finalized head survives a restart
+	blockchain.Stop()
+	blockchain, err = NewBlockChain(db, nil, configs.ChainConfigInfo(), fakeDpor(db), vm.Config{}, blockchain.remoteDB, nil)
+	if err != nil {
+		t.Fatalf("failed to restart chain: %v", err)
+	}
+	defer blockchain.Stop()
+	if finalized := blockchain.CurrentFinalizedBlock(); finalized.Hash() != blocks[2].Hash() {
+		t.Fatalf("restored finalized head mismatch: have #%d, want #3", finalized.NumberU64())
+	}
+
+	// rewinding below the finalized head moves it to the new head
+	if err := blockchain.SetHead(2); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if finalized := blockchain.CurrentFinalizedBlock(); finalized.Hash() != blocks[1].Hash() {
+		t.Fatalf("rewound finalized head mismatch: have #%d, want #2", finalized.NumberU64())
+	}
+}
+
+func TestHeldStatePrivAt(t *testing.T) {
+	supportPrivate := private.SupportPrivateTx
+	defer func() { private.SupportPrivateTx = supportPrivate }()
+
+	db := database.NewMemDatabase()
+	blockchain, err := newCanonical(fakeDpor(db), 2, db)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+	root := blockchain.CurrentBlock().StateRoot()
+
+	private.SupportPrivateTx = "true"
+	if _, err := blockchain.HeldStatePrivAt(root); err != nil {
+		t.Fatalf("failed to retrieve private state: %v", err)
+	}
+	if _, err := blockchain.HeldStatePrivAt(common.HexToHash("0x01")); err != ErrNoPrivateState {
+		t.Fatalf("unknown private state error mismatch: have %v, want %v", err, ErrNoPrivateState)
+	}
+
+	private.SupportPrivateTx = "false"
+	if _, err := blockchain.HeldStatePrivAt(root); err != ErrNoPrivateState {
+		t.Fatalf("unsupported private state error mismatch: have %v, want %v", err, ErrNoPrivateState)
+	}
+}
+
 // Tests that given a starting canonical chain of a given size, it can be extended
 // with various length chains.
 func TestExtendCanonicalBlocks(t *testing.T) {
@@ -34,4 +34,8 @@ var (
 	ErrNonceTooHigh = errors.New("nonce too high")
 
 	ErrInvalidChain = errors.New("hash chain is invalid")
+
+	// ErrNoPrivateState is returned if the private state of a block is not held
+	// by this node, e.g. it does not participate in private transactions.
+	ErrNoPrivateState = errors.New("private state not available")
 )
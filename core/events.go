@@ -38,8 +38,17 @@ type PendingStateEvent struct{}
 // NewMinedBlockEvent is posted when a block has been imported.
 type NewMinedBlockEvent struct{ Block *types.Block }
 
-// RemovedLogsEvent is posted when a reorg happens
-type RemovedLogsEvent struct{ Logs []*types.Log }
+// RemovedLogsEvent is posted when a reorg happens. PrivateLogs are the logs of
+// private transactions this node participates in, they are only delivered to
+// the privacy group API.
+type RemovedLogsEvent struct {
+	Logs        []*types.Log
+	PrivateLogs []*types.Log
+}
+
+// PrivateLogsEvent is posted when a block carrying private transactions this
+// node participates in is inserted into the canonical chain.
+type PrivateLogsEvent struct{ Logs []*types.Log }
 
 type ChainEvent struct {
 	Block *types.Block
@@ -157,35 +157,40 @@ func SetupGenesisBlock(db database.Database, genesis *Genesis) (*configs.ChainCo
 		// Get the existing chain configuration.
 		storedCfg := rawdb.ReadChainConfig(db, stored)
 		newCfg := genesis.configOrDefault(stored)
-		var finalCfg *configs.ChainConfig
 		if genesis != nil {
 			// Check whether the genesis block is already written.
 			hash := genesis.ToBlock(nil).Hash()
 			if hash != stored {
 				return genesis.Config, hash, &GenesisMismatchError{stored, hash}
 			}
-			finalCfg = updateChainConfig(storedCfg, newCfg, db, stored)
-		} else {
+		} else if stored != MainnetGenesisHash {
 			// Special case: don't change the existing config of a non-mainnet chain if no new
 			// config is supplied. These chains would get AllProtocolChanges (and a compat error)
 			// if we just continued here.
-			if stored != MainnetGenesisHash {
-				return storedCfg, stored, nil
-			} else {
-				finalCfg = updateChainConfig(storedCfg, newCfg, db, stored)
-			}
+			return storedCfg, stored, nil
 		}
-		return finalCfg, stored, nil
+		return updateChainConfig(storedCfg, newCfg, db, stored)
 	}
 }
 
-func updateChainConfig(storedcfg *configs.ChainConfig, newcfg *configs.ChainConfig, db database.Database, stored common.Hash) *configs.ChainConfig {
+// updateChainConfig writes the new chain configuration if it is compatible with the
+// stored one, i.e. it does not reschedule a fork the local head has already passed.
+func updateChainConfig(storedcfg *configs.ChainConfig, newcfg *configs.ChainConfig, db database.Database, stored common.Hash) (*configs.ChainConfig, common.Hash, error) {
 	if storedcfg == nil {
 		log.Warn("Found genesis block without chain config")
+		rawdb.WriteChainConfig(db, stored, newcfg)
+		return newcfg, stored, nil
+	}
+	height := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadHeaderHash(db))
+	if height == nil {
+		return newcfg, stored, fmt.Errorf("missing block number for head header hash")
+	}
+	compatErr := storedcfg.CheckCompatible(newcfg, *height)
+	if compatErr != nil && *height != 0 && compatErr.RewindTo != 0 {
+		return newcfg, stored, compatErr
 	}
-	// NOTICE: in future we may need to check the compatibility of old and new configurations.
 	rawdb.WriteChainConfig(db, stored, newcfg)
-	return newcfg
+	return newcfg, stored, nil
 }
 
 // OpenGenesisBlock opens genesis block and returns its chain configuration and hash.
@@ -24,6 +24,7 @@ import (
 	"bitbucket.org/cpchain/chain/configs"
 	"bitbucket.org/cpchain/chain/core/rawdb"
 	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/types"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -102,6 +103,30 @@ func TestSetupGenesis(t *testing.T) {
 			wantHash:   customghash,
 			wantConfig: customg.Config,
 		},
+		{
+			name: "incompatible config in DB",
+			fn: func(db database.Database) (*configs.ChainConfig, common.Hash, error) {
+				// Commit the 'old' genesis block with an empty fork schedule and
+				// pretend the chain has advanced past the fork of the new one.
+				oldcustomg.Config = &configs.ChainConfig{Dpor: &configs.DporConfig{TermLen: 4, ViewLen: 3, Forks: &configs.DporForks{}}}
+				genesis := oldcustomg.MustCommit(db)
+				header := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(10), Time: big.NewInt(0)}
+				rawdb.WriteHeader(db, header)
+				rawdb.WriteHeadHeaderHash(db, header.Hash())
+
+				// Then upgrade the config with a fork scheduled before the head.
+				customg.Config = &configs.ChainConfig{Dpor: &configs.DporConfig{TermLen: 4, ViewLen: 3, Forks: &configs.DporForks{Election2Block: big.NewInt(2)}}}
+				return SetupGenesisBlock(db, &customg)
+			},
+			wantHash:   customghash,
+			wantConfig: &configs.ChainConfig{Dpor: &configs.DporConfig{TermLen: 4, ViewLen: 3, Forks: &configs.DporForks{Election2Block: big.NewInt(2)}}},
+			wantErr: &configs.ConfigCompatError{
+				What:         "Election2 fork block",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(2),
+				RewindTo:     1,
+			},
+		},
 	}
 
 	for _, test := range tests {
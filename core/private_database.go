@@ -32,6 +32,7 @@ import (
 var (
 	privateRootPrefix    = []byte("Priv")
 	privateReceiptPrefix = []byte("PrivR")
+	privateLogsPrefix    = []byte("PrivL")
 )
 
 // GetPrivateStateRoot gets the root(hash) for private state associated with the root of Merkle tree in public chain.
@@ -44,6 +45,12 @@ func GetPrivateStateRoot(db database.Database, blockRoot common.Hash) common.Has
 	return common.Hash{}
 }
 
+// HasPrivateStateRoot checks if the root(hash) for private state associated with the root of Merkle tree in public chain exists.
+func HasPrivateStateRoot(db database.Database, blockRoot common.Hash) bool {
+	exist, _ := db.Has(append(privateRootPrefix, blockRoot[:]...))
+	return exist
+}
+
 // WritePrivateStateRoot writes the root(hash) for private state associated with the root of Merkle tree in public chain.
 func WritePrivateStateRoot(db database.Database, blockRoot, root common.Hash) error {
 	return db.Put(append(privateRootPrefix, blockRoot[:]...), root[:])
@@ -92,3 +99,49 @@ func getPrivateReceiptKey(txHash common.Hash) common.Hash {
 	hashBytes := hasher.Sum(nil)
 	return common.BytesToHash(hashBytes)
 }
+
+// WritePrivateLogs writes the logs of private receipts in a block, they are
+// indexed separately from public receipts so that public blooms never reveal them.
+func WritePrivateLogs(db database.Putter, blockHash common.Hash, number uint64, receipts []*types.Receipt) error {
+	var logs []*types.LogForStorage
+	for _, l := range privateLogs(receipts) {
+		l.BlockNumber, l.BlockHash = number, blockHash
+		logs = append(logs, (*types.LogForStorage)(l))
+	}
+	if len(logs) == 0 {
+		return nil
+	}
+	data, err := rlp.EncodeToBytes(logs)
+	if err != nil {
+		return err
+	}
+	return db.Put(append(privateLogsPrefix, blockHash[:]...), data)
+}
+
+// ReadPrivateLogs reads the logs of private receipts in a block, it returns nil
+// if the node does not participate in any private transaction of the block.
+func ReadPrivateLogs(db database.Database, blockHash common.Hash) []*types.Log {
+	data, _ := db.Get(append(privateLogsPrefix, blockHash[:]...))
+	if len(data) == 0 {
+		return nil
+	}
+	var storageLogs []*types.LogForStorage
+	if err := rlp.DecodeBytes(data, &storageLogs); err != nil {
+		log.Error("Invalid private logs RLP", "hash", blockHash, "err", err)
+		return nil
+	}
+	logs := make([]*types.Log, len(storageLogs))
+	for i, l := range storageLogs {
+		logs[i] = (*types.Log)(l)
+	}
+	return logs
+}
+
+// privateLogs flattens the logs of private receipts.
+func privateLogs(receipts []*types.Receipt) []*types.Log {
+	var logs []*types.Log
+	for _, receipt := range receipts {
+		logs = append(logs, receipt.Logs...)
+	}
+	return logs
+}
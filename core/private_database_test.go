@@ -236,3 +236,34 @@ func getTestReceipt() *types.Receipt {
 func getTestDB() *database.MemDatabase {
 	return database.NewMemDatabase()
 }
+
+func TestWritePrivateLogs(t *testing.T) {
+	db := getTestDB()
+	blockHash := common.HexToHash("0x01")
+
+	receipts := []*types.Receipt{
+		{TxHash: common.HexToHash("0x02"), Logs: []*types.Log{{Address: common.HexToAddress("0x03"), Topics: []common.Hash{}, Data: []byte{}, TxIndex: 1}}},
+		{TxHash: common.HexToHash("0x04")},
+		{TxHash: common.HexToHash("0x05"), Logs: []*types.Log{{Address: common.HexToAddress("0x06"), Topics: []common.Hash{{}}, Data: []byte{0x09}, TxIndex: 3}}},
+	}
+	if err := WritePrivateLogs(db, blockHash, 7, receipts); err != nil {
+		t.Fatalf("WritePrivateLogs() error = %v", err)
+	}
+
+	logs := ReadPrivateLogs(db, blockHash)
+	if len(logs) != 2 {
+		t.Fatalf("ReadPrivateLogs() returned %d logs, want 2", len(logs))
+	}
+	for i, l := range []*types.Log{receipts[0].Logs[0], receipts[2].Logs[0]} {
+		if l.BlockNumber != 7 {
+			t.Errorf("log %d has block number %d, want 7", i, l.BlockNumber)
+		}
+		if !reflect.DeepEqual(logs[i], l) {
+			t.Errorf("log %d mismatch: got %v, want %v", i, logs[i], l)
+		}
+	}
+
+	if logs := ReadPrivateLogs(db, common.HexToHash("0x08")); logs != nil {
+		t.Errorf("ReadPrivateLogs() of a block without private logs = %v, want nil", logs)
+	}
+}
@@ -108,6 +108,22 @@ func WriteHeadFastBlockHash(db DatabaseWriter, hash common.Hash) {
 	}
 }
 
+// ReadHeadFinalizedBlockHash retrieves the hash of the current finalized head block.
+func ReadHeadFinalizedBlockHash(db DatabaseReader) common.Hash {
+	data, _ := db.Get(headFinalizedBlockKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteHeadFinalizedBlockHash stores the hash of the current finalized head block.
+func WriteHeadFinalizedBlockHash(db DatabaseWriter, hash common.Hash) {
+	if err := db.Put(headFinalizedBlockKey, hash.Bytes()); err != nil {
+		log.Fatal("Failed to store last finalized block's hash", "err", err)
+	}
+}
+
 // ReadFastTrieProgress retrieves the number of tries nodes fast synced to allow
 // reporting correct numbers across restarts.
 func ReadFastTrieProgress(db DatabaseReader) uint64 {
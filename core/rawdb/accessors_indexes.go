@@ -105,3 +105,30 @@ func WriteBloomBits(db DatabaseWriter, bit uint, section uint64, head common.Has
 		log.Fatal("Failed to store bloom bits", "err", err)
 	}
 }
+
+// ReadParticipation retrieves the participation stats of the terms covered by
+// the given section.
+func ReadParticipation(db DatabaseReader, section uint64) []*types.TermParticipation {
+	data, _ := db.Get(participationKey(section))
+	if len(data) == 0 {
+		return nil
+	}
+	var stats []*types.TermParticipation
+	if err := rlp.DecodeBytes(data, &stats); err != nil {
+		log.Error("Invalid participation stats RLP", "section", section, "err", err)
+		return nil
+	}
+	return stats
+}
+
+// WriteParticipation stores the participation stats of the terms covered by
+// the given section.
+func WriteParticipation(db DatabaseWriter, section uint64, stats []*types.TermParticipation) {
+	data, err := rlp.EncodeToBytes(stats)
+	if err != nil {
+		log.Fatal("Failed to encode participation stats", "err", err)
+	}
+	if err := db.Put(participationKey(section), data); err != nil {
+		log.Fatal("Failed to store participation stats", "err", err)
+	}
+}
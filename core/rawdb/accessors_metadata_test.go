@@ -0,0 +1,56 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/database"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that chain configs are stored as they are, without a fork schedule
+// following the default one.
+func TestChainConfigForks(t *testing.T) {
+	db := database.NewMemDatabase()
+
+	hash := common.Hash{1}
+	written := &configs.ChainConfig{ChainID: big.NewInt(1), Dpor: &configs.DporConfig{TermLen: 4}}
+	WriteChainConfig(db, hash, written)
+
+	data, _ := db.Get(configKey(hash))
+	var stored struct {
+		Dpor map[string]json.RawMessage `json:"dpor"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stored.Dpor["forks"]; ok {
+		t.Fatalf("fork schedule is written into a config without one: %s", data)
+	}
+
+	cfg := ReadChainConfig(db, hash)
+	if cfg.Dpor.Forks != nil {
+		t.Fatalf("fork schedule is added to a config without one: %v", cfg.Dpor.Forks)
+	}
+	if cfg.Dpor.RptCalcMethod(configs.RptCalcMethod6BlockNumber) != 6 || cfg.Dpor.IsBLS(configs.RptCalcMethod6BlockNumber) {
+		t.Fatalf("config without a schedule does not follow the default one")
+	}
+}
@@ -97,13 +97,12 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, sta
 		if privReceipt != nil {
 			privReceipts = append(privReceipts, privReceipt)
 		}
-		allLogs = append(allLogs, pubReceipt.Logs...) // not include private receipt's logs.
-		// TODO: if need to add private receipt's logs to allLogs variable.
+		// private receipt's logs are indexed separately by WritePrivateLogs and never enter public blooms.
+		allLogs = append(allLogs, pubReceipt.Logs...)
 	}
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
 	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), []*types.Header{}, pubReceipts)
 
-	// TODO: if return private logs separately or merge them together as a whole logs collection?
 	return pubReceipts, privReceipts, allLogs, *usedGas, nil
 }
 
@@ -160,6 +159,11 @@ func ApplyTransaction(config *configs.ChainConfig, bc ChainContext, author *comm
 			if err == NoPermissionError {
 				log.Info("No permission to process the transaction.")
 				return pubReceipt, privReceipt, gas, nil
+			} else if err == private.ErrInvalidSealedPayload || err == private.ErrUnsupportedPayloadVersion {
+				// The sealed payload fails authentication, e.g. it is replayed by another sender, or it is
+				// a legacy one after the PrivatePayloadV1 fork, skip it rather than reject the block.
+				log.Warn("Skip the private transaction with invalid sealed payload.", "hash", tx.Hash())
+				return pubReceipt, nil, gas, nil
 			} else {
 				log.Error("Cannot process the transaction.", err)
 				return pubReceipt, privReceipt, 0, err
@@ -182,7 +186,8 @@ func tryApplyPrivateTx(config *configs.ChainConfig, bc ChainContext, author *com
 		return nil, RemoteDBAbsenceError
 	}
 
-	payload, hasPermission, err := private.RetrieveAndDecryptPayload(tx.Data(), tx.Nonce(), remoteDB, accm)
+	allowLegacy := !config.Dpor.IsPrivatePayloadV1(header.Number.Uint64())
+	payload, hasPermission, err := private.RetrieveAndDecryptPayload(tx.Data(), tx.Nonce(), msg.From(), config.ChainID, allowLegacy, remoteDB, accm)
 	if err != nil {
 		return nil, err
 	}
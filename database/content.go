@@ -0,0 +1,51 @@
+// Copyright 2019 The cpchain authors
+
+package database
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var (
+	// ErrContentMismatch is returned if the retrieved data does not hash to its key.
+	ErrContentMismatch = errors.New("content does not match its key")
+
+	// ErrInvalidContentKey is returned if the key is not a content hash.
+	ErrInvalidContentKey = errors.New("invalid content key")
+
+	// ErrBlobTooLarge is returned if a blob store responds a blob over the size limit.
+	ErrBlobTooLarge = errors.New("blob too large")
+)
+
+// contentKeyLength is the length of a hex encoded sha256 hash with 0x prefix.
+const contentKeyLength = 2 + 2*sha256.Size
+
+// ContentKey returns the content address of the value, i.e. the hex encoded
+// sha256 hash of it.
+func ContentKey(value []byte) []byte {
+	hash := sha256.Sum256(value)
+	return []byte(hexutil.Encode(hash[:]))
+}
+
+// VerifyContent checks that the value is addressed by the key.
+func VerifyContent(key, value []byte) error {
+	if string(ContentKey(value)) != string(key) {
+		return ErrContentMismatch
+	}
+	return nil
+}
+
+// validContentKey checks if the key is a hex encoded sha256 hash, which is also
+// safe to be used as a file name or an url path.
+func validContentKey(key []byte) error {
+	if len(key) != contentKeyLength {
+		return ErrInvalidContentKey
+	}
+	if _, err := hexutil.Decode(string(key)); err != nil {
+		return ErrInvalidContentKey
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2019 The cpchain authors
+
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	httpBlobTimeout = 3 * time.Second
+
+	// httpBlobPath is the url path prefix of blobs in the blob store protocol.
+	httpBlobPath = "/blobs/"
+
+	// maxBlobSize limits the size of a blob accepted by the blob store handler,
+	// and of a blob read from a blob store.
+	maxBlobSize = 16 * 1024 * 1024
+)
+
+// HttpBlobDatabase is a content-addressed remote database talking to a blob store over HTTP.
+//
+// The blob store protocol is:
+//
+//	PUT    <url>/blobs/<key>  stores the request body, key is the hex encoded sha256 hash of the body
+//	GET    <url>/blobs/<key>  responds the stored body, or 404 if not found
+//	HEAD   <url>/blobs/<key>  responds 200 if the blob exists, or 404 if not found
+//	DELETE <url>/blobs/<key>  discards the blob
+//
+// Keys are computed locally and retrieved blobs are checked against their keys,
+// so the blob store does not need to be trusted for integrity.
+type HttpBlobDatabase struct {
+	url    string
+	client *http.Client
+}
+
+// NewHttpBlobDB creates a new HttpBlobDatabase instance with given blob store url.
+func NewHttpBlobDB(url string) *HttpBlobDatabase {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = "http://" + url
+	}
+	return &HttpBlobDatabase{
+		url: strings.TrimRight(url, "/"),
+		// In our case, the retrieving of data should be fast, otherwise we regard it as 'not exist'.
+		client: &http.Client{Timeout: httpBlobTimeout},
+	}
+}
+
+func (db *HttpBlobDatabase) blobURL(key []byte) string {
+	return db.url + httpBlobPath + string(key)
+}
+
+func (db *HttpBlobDatabase) do(method string, key []byte, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, db.blobURL(key), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return db.client.Do(req)
+}
+
+// Get retrieves data from the blob store with given key and checks it matches the key.
+func (db *HttpBlobDatabase) Get(key []byte) ([]byte, error) {
+	if err := validContentKey(key); err != nil {
+		return nil, err
+	}
+
+	resp, err := db.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrPathNotFound
+	default:
+		return nil, fmt.Errorf("blob store responded %s", resp.Status)
+	}
+
+	value, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBlobSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(value) > maxBlobSize {
+		return nil, ErrBlobTooLarge
+	}
+	if err := VerifyContent(key, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Put saves data to the blob store and returns its content hash as key.
+func (db *HttpBlobDatabase) Put(value []byte) ([]byte, error) {
+	key := ContentKey(value)
+
+	resp, err := db.do(http.MethodPut, key, value)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("blob store responded %s", resp.Status)
+	}
+	return key, nil
+}
+
+// Discard discards data with given key from the blob store.
+func (db *HttpBlobDatabase) Discard(key []byte) error {
+	if err := validContentKey(key); err != nil {
+		return err
+	}
+
+	resp, err := db.do(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("blob store responded %s", resp.Status)
+	}
+	return nil
+}
+
+// Has checks if the data specified by given key exists in the blob store.
+func (db *HttpBlobDatabase) Has(key []byte) bool {
+	if err := validContentKey(key); err != nil {
+		return false
+	}
+
+	resp, err := db.do(http.MethodHead, key, nil)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
+
+// NewBlobStoreHandler returns an HTTP handler implementing the blob store protocol
+// of HttpBlobDatabase on top of a content-addressed remote database, such as
+// LocalContentDatabase.
+func NewBlobStoreHandler(db RemoteDatabase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, httpBlobPath) {
+			http.NotFound(w, r)
+			return
+		}
+		key := []byte(strings.TrimPrefix(r.URL.Path, httpBlobPath))
+		if err := validContentKey(key); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			value, err := db.Get(key)
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(value)
+
+		case http.MethodHead:
+			if !db.Has(key) {
+				w.WriteHeader(http.StatusNotFound)
+			}
+
+		case http.MethodPut:
+			value, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBlobSize))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err := VerifyContent(key, value); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			stored, err := db.Put(value)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !bytes.Equal(stored, key) {
+				http.Error(w, "backend is not content addressed", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+
+		case http.MethodDelete:
+			if err := db.Discard(key); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+// Copyright 2019 The cpchain authors
+
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// LocalContentDatabase is a content-addressed remote database backed by the local
+// filesystem. Data is saved to a file named after its sha256 hash, so that it is
+// deduplicated and its integrity is checked on retrieving.
+type LocalContentDatabase struct {
+	dir string
+	// rwlock is a read-write lock for avoiding race condition.
+	rwlock sync.RWMutex
+}
+
+// NewLocalContentDB creates a new LocalContentDatabase instance saving data into the given directory.
+func NewLocalContentDB(dir string) (*LocalContentDatabase, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &LocalContentDatabase{
+		dir: dir,
+	}, nil
+}
+
+// path returns the file path of data with given key, files are sharded into
+// sub directories by the first byte of the key.
+func (db *LocalContentDatabase) path(key []byte) string {
+	k := string(key[2:])
+	return filepath.Join(db.dir, k[:2], k[2:])
+}
+
+// Get retrieves data with given key and checks it matches the key.
+func (db *LocalContentDatabase) Get(key []byte) ([]byte, error) {
+	if err := validContentKey(key); err != nil {
+		return nil, err
+	}
+
+	db.rwlock.RLock()
+	defer db.rwlock.RUnlock()
+
+	value, err := ioutil.ReadFile(db.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrPathNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyContent(key, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Put saves data to a file and returns its content hash as key.
+func (db *LocalContentDatabase) Put(value []byte) ([]byte, error) {
+	key := ContentKey(value)
+	path := db.path(key)
+
+	db.rwlock.Lock()
+	defer db.rwlock.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		return key, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	// write to a temporary file first, so that a crash never leaves a partial file behind the key
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "tmp-")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return key, nil
+}
+
+// Discard removes the file of data with given key.
+func (db *LocalContentDatabase) Discard(key []byte) error {
+	if err := validContentKey(key); err != nil {
+		return err
+	}
+
+	db.rwlock.Lock()
+	defer db.rwlock.Unlock()
+
+	if err := os.Remove(db.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Has checks if the data specified by given key exists.
+func (db *LocalContentDatabase) Has(key []byte) bool {
+	if err := validContentKey(key); err != nil {
+		return false
+	}
+
+	db.rwlock.RLock()
+	defer db.rwlock.RUnlock()
+
+	_, err := os.Stat(db.path(key))
+	return err == nil
+}
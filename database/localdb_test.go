@@ -0,0 +1,143 @@
+// Copyright 2019 The cpchain authors
+
+package database
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func newTestLocalContentDB(t *testing.T) (*LocalContentDatabase, func()) {
+	dir, err := ioutil.TempDir("", "localcontentdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewLocalContentDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, func() { os.RemoveAll(dir) }
+}
+
+func testRemoteDatabase(t *testing.T, db RemoteDatabase) {
+	key, err := db.Put(normalContent)
+	if err != nil {
+		t.Fatalf("Normal put operation should succeed, got %v", err)
+	}
+	if string(key) != string(ContentKey(normalContent)) {
+		t.Fatalf("Key %s is not the content hash", key)
+	}
+	if !db.Has(key) {
+		t.Fatalf("Saved content should exist")
+	}
+
+	value, err := db.Get(key)
+	if err != nil {
+		t.Fatalf("Getting a successfully saved content should not return any error, got %v", err)
+	}
+	if string(value) != string(normalContent) {
+		t.Errorf("Retrieved content %v does not equal to original value %v", value, normalContent)
+	}
+
+	// putting the same content again returns the same key
+	if again, err := db.Put(normalContent); err != nil || string(again) != string(key) {
+		t.Errorf("Putting the same content should return the same key, got %s, %v", again, err)
+	}
+
+	// empty content works as normal
+	emptyKey, err := db.Put([]byte{})
+	if err != nil {
+		t.Fatalf("Putting empty content should succeed, got %v", err)
+	}
+	if value, err := db.Get(emptyKey); err != nil || len(value) != 0 {
+		t.Errorf("Getting empty content should succeed, got %v, %v", value, err)
+	}
+
+	if err := db.Discard(key); err != nil {
+		t.Fatalf("Discarding saved content should succeed, got %v", err)
+	}
+	if db.Has(key) {
+		t.Errorf("Discarded content should not exist")
+	}
+	if _, err := db.Get(key); err == nil {
+		t.Errorf("Getting discarded content should fail")
+	}
+
+	if _, err := db.Get([]byte("../../etc/passwd")); err != ErrInvalidContentKey {
+		t.Errorf("Getting with an invalid key should fail with %v, got %v", ErrInvalidContentKey, err)
+	}
+}
+
+func TestLocalContentDB(t *testing.T) {
+	db, cleanup := newTestLocalContentDB(t)
+	defer cleanup()
+
+	testRemoteDatabase(t, db)
+}
+
+func TestLocalContentDBIntegrity(t *testing.T) {
+	db, cleanup := newTestLocalContentDB(t)
+	defer cleanup()
+
+	key, err := db.Put(normalContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tamper the stored file
+	if err := ioutil.WriteFile(db.path(key), []byte("tampered"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Get(key); err != ErrContentMismatch {
+		t.Errorf("Getting tampered content should fail with %v, got %v", ErrContentMismatch, err)
+	}
+}
+
+func TestHttpBlobDB(t *testing.T) {
+	local, cleanup := newTestLocalContentDB(t)
+	defer cleanup()
+
+	server := httptest.NewServer(NewBlobStoreHandler(local))
+	defer server.Close()
+
+	testRemoteDatabase(t, NewHttpBlobDB(server.URL))
+}
+
+func TestHttpBlobDBIntegrity(t *testing.T) {
+	// a malicious blob store serving other content under the key
+	store := NewIpfsDbWithAdapter(NewFakeIpfsAdapter())
+	server := httptest.NewServer(NewBlobStoreHandler(store))
+	defer server.Close()
+
+	db := NewHttpBlobDB(server.URL)
+	key, err := db.Put(normalContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.adapter.(*FakeIpfsAdapter).store[string(key)] = []byte("tampered")
+
+	if _, err := db.Get(key); err != ErrContentMismatch {
+		t.Errorf("Getting tampered content should fail with %v, got %v", ErrContentMismatch, err)
+	}
+}
+
+func TestHttpBlobDBTooLarge(t *testing.T) {
+	// a blob store streaming an endless body
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunk := make([]byte, 1024*1024)
+		for i := 0; i <= maxBlobSize/len(chunk); i++ {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	db := NewHttpBlobDB(server.URL)
+	if _, err := db.Get(ContentKey(normalContent)); err != ErrBlobTooLarge {
+		t.Errorf("Getting an oversized blob should fail with %v, got %v", ErrBlobTooLarge, err)
+	}
+}
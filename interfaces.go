@@ -105,6 +105,8 @@ type CallMsg struct {
 	GasPrice *big.Int        // wei <-> gas exchange ratio
 	Value    *big.Int        // amount of wei sent along with the call
 	Data     []byte          // input data, usually an ABI-encoded contract method invocation
+
+	IsPrivate bool // executes on the private state, only available on nodes participating in private transactions
 }
 
 // A ContractCaller provides contract calls, essentially transactions that are executed by
@@ -20,6 +20,7 @@ import (
 	"bitbucket.org/cpchain/chain/configs"
 	"bitbucket.org/cpchain/chain/core"
 	"bitbucket.org/cpchain/chain/core/rawdb"
+	"bitbucket.org/cpchain/chain/core/state"
 	"bitbucket.org/cpchain/chain/core/vm"
 	"bitbucket.org/cpchain/chain/private"
 	"bitbucket.org/cpchain/chain/types"
@@ -709,8 +710,21 @@ type CallArgs struct {
 
 func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config, timeout time.Duration) ([]byte, uint64, bool, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
-	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr, args.IsPrivate)
-
+	if args.IsPrivate {
+		if supportPrivate, _ := s.b.SupportPrivateTx(ctx); !supportPrivate {
+			return nil, 0, false, NotSupportPrivateTxErr
+		}
+	}
+	var (
+		state  *state.StateDB
+		header *types.Header
+		err    error
+	)
+	if args.IsPrivate {
+		state, header, err = s.b.HeldPrivateStateAndHeaderByNumber(ctx, blockNr)
+	} else {
+		state, header, err = s.b.StateAndHeaderByNumber(ctx, blockNr, false)
+	}
 	if state == nil || err != nil {
 		return nil, 0, false, err
 	}
@@ -772,6 +786,8 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
+// If args.IsPrivate is set, it executes on the private state, which is only available on
+// nodes participating in private transactions.
 func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
 	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
 	return (hexutil.Bytes)(result), err
@@ -779,6 +795,7 @@ func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr r
 
 // EstimateGas returns an estimate of the amount of gas needed to execute the
 // given transaction against the current pending block.
+// If args.IsPrivate is set, it pre-flights a private transaction against the latest private state.
 func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (hexutil.Uint64, error) {
 	// Binary search the gas requirement, as it may be higher than the amount used
 	var (
@@ -798,6 +815,13 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	}
 	cap = hi
 
+	// Fail fast if the private state is not held here, rather than reporting an always failing transaction
+	if args.IsPrivate {
+		if _, _, _, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{}, 0); err == NotSupportPrivateTxErr || err == core.ErrNoPrivateState {
+			return 0, err
+		}
+	}
+
 	// Create a helper to check if a gas allowance results in an executable transaction
 	executable := func(gas uint64) bool {
 		args.Gas = hexutil.Uint64(gas)
@@ -1367,8 +1391,9 @@ type SendTxArgs struct {
 	Input *hexutil.Bytes `json:"input"`
 
 	// Private Tx Implementation
-	IsPrivate    bool     `json:"isPrivate"`
-	Participants []string `json:"participants"`
+	IsPrivate    bool         `json:"isPrivate"`
+	Participants []string     `json:"participants"`
+	PrivacyGroup *common.Hash `json:"privacyGroup"` // seals the private tx for all members of the group instead of Participants
 }
 
 // setDefaults is a helper function that fills in default values for unspecified tx fields.
@@ -1496,18 +1521,27 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 
 	if args.IsPrivate {
 		// If args.Data is nil, it must be the transaction of transferring tokens, that should be always public.
-		if len(args.Participants) == 0 || args.Data == nil {
+		if (len(args.Participants) == 0 && args.PrivacyGroup == nil) || args.Data == nil {
 			return common.Hash{}, InvalidPrivateTxErr
 		}
 
-		payloadReplace, err := private.SealPrivatePayload(([]byte)(*args.Data), (uint64)(*args.Nonce), args.Participants, s.b.RemoteDB())
+		var payloadReplace private.PayloadReplacement
+		if args.PrivacyGroup != nil {
+			payloadReplace, err = private.SealPrivatePayloadForGroup(([]byte)(*args.Data), args.From, s.b.ChainConfig().ChainID,
+				s.b.PrivacyGroups(), *args.PrivacyGroup, s.b.RemoteDB())
+		} else {
+			payloadReplace, err = private.SealPrivatePayload(([]byte)(*args.Data), args.From, s.b.ChainConfig().ChainID, args.Participants, s.b.RemoteDB())
+		}
 		if err != nil {
 			return common.Hash{}, err
 		}
 		log.Info("Payload replacement for private transaction", "payloadReplace", payloadReplace)
 
 		// Replace original content with security one.
-		replaceData, _ := rlp.EncodeToBytes(payloadReplace)
+		replaceData, err := rlp.EncodeToBytes(payloadReplace)
+		if err != nil {
+			return common.Hash{}, err
+		}
 		args.Data = (*hexutil.Bytes)(&replaceData)
 	}
 
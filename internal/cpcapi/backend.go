@@ -28,6 +28,7 @@ import (
 	"bitbucket.org/cpchain/chain/core/state"
 	"bitbucket.org/cpchain/chain/core/vm"
 	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/private"
 	"bitbucket.org/cpchain/chain/protocols/cpc/syncer"
 	"bitbucket.org/cpchain/chain/types"
 	"github.com/ethereum/go-ethereum/common"
@@ -50,6 +51,7 @@ type Backend interface {
 	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
 	BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error)
 	StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber, isPrivate bool) (*state.StateDB, *types.Header, error)
+	HeldPrivateStateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error)
 	GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
 	GetPrivateReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
@@ -85,6 +87,10 @@ type Backend interface {
 
 	// Private API
 	SupportPrivateTx(ctx context.Context) (bool, error)
+	PrivacyGroups() *private.GroupStore
+	GetPrivateLogs(ctx context.Context, blockHash common.Hash) ([]*types.Log, error)
+	SubscribePrivateLogsEvent(ch chan<- core.PrivateLogsEvent) event.Subscription
+	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
 }
 
 func GetAPIs(apiBackend Backend) []rpc.API {
@@ -129,6 +135,11 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 			Version:   "1.0",
 			Service:   NewPrivateAccountAPI(apiBackend, nonceLock),
 			Public:    false,
+		}, {
+			Namespace: "priv",
+			Version:   "1.0",
+			Service:   NewPrivatePrivacyGroupAPI(apiBackend, nonceLock),
+			Public:    false,
 		},
 	}
 }
@@ -0,0 +1,254 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package cpcapi
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bitbucket.org/cpchain/chain/api/rpc"
+	"bitbucket.org/cpchain/chain/core"
+	"bitbucket.org/cpchain/chain/core/rawdb"
+	"bitbucket.org/cpchain/chain/core/vm"
+	"bitbucket.org/cpchain/chain/private"
+	"bitbucket.org/cpchain/chain/protocols/cpc/filters"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// privateLogsChanSize is the size of channels receiving private logs of subscriptions.
+const privateLogsChanSize = 10
+
+var (
+	// NotGroupMemberErr is returned if none of the local accounts is a member of the privacy group.
+	NotGroupMemberErr = errors.New("not a member of the privacy group")
+	// NotInGroupTxErr is returned if the transaction is not a private transaction of the privacy group.
+	NotInGroupTxErr = errors.New("transaction is not in the privacy group")
+)
+
+// PrivatePrivacyGroupAPI provides an API to manage privacy groups, and query private
+// transactions and state scoped to a privacy group.
+type PrivatePrivacyGroupAPI struct {
+	b      Backend
+	chain  *PublicBlockChainAPI
+	txPool *PublicTransactionPoolAPI
+}
+
+// NewPrivatePrivacyGroupAPI creates a new privacy group API.
+func NewPrivatePrivacyGroupAPI(b Backend, nonceLock *AddrLocker) *PrivatePrivacyGroupAPI {
+	return &PrivatePrivacyGroupAPI{
+		b:      b,
+		chain:  NewPublicBlockChainAPI(b),
+		txPool: NewPublicTransactionPoolAPI(b, nonceLock),
+	}
+}
+
+// CreatePrivacyGroup creates a privacy group with given name and members' public keys.
+func (s *PrivatePrivacyGroupAPI) CreatePrivacyGroup(name string, members []string) (*private.PrivacyGroup, error) {
+	return s.b.PrivacyGroups().Create(name, members)
+}
+
+// GetPrivacyGroup returns the privacy group with given id.
+func (s *PrivatePrivacyGroupAPI) GetPrivacyGroup(id common.Hash) (*private.PrivacyGroup, error) {
+	return s.b.PrivacyGroups().Get(id)
+}
+
+// ListPrivacyGroups returns all privacy groups.
+func (s *PrivatePrivacyGroupAPI) ListPrivacyGroups() ([]*private.PrivacyGroup, error) {
+	return s.b.PrivacyGroups().List()
+}
+
+// AddToPrivacyGroup adds members' public keys to the privacy group.
+func (s *PrivatePrivacyGroupAPI) AddToPrivacyGroup(id common.Hash, members []string) (*private.PrivacyGroup, error) {
+	return s.b.PrivacyGroups().AddMembers(id, members)
+}
+
+// RemoveFromPrivacyGroup removes members' public keys from the privacy group.
+func (s *PrivatePrivacyGroupAPI) RemoveFromPrivacyGroup(id common.Hash, members []string) (*private.PrivacyGroup, error) {
+	return s.b.PrivacyGroups().RemoveMembers(id, members)
+}
+
+// DeletePrivacyGroup deletes the privacy group.
+func (s *PrivatePrivacyGroupAPI) DeletePrivacyGroup(id common.Hash) (bool, error) {
+	if err := s.b.PrivacyGroups().Delete(id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetTransactionReceipt returns the private receipt of the transaction, if all its participants
+// are members of the privacy group.
+func (s *PrivatePrivacyGroupAPI) GetTransactionReceipt(ctx context.Context, id common.Hash, hash common.Hash) (map[string]interface{}, error) {
+	group, err := s.b.PrivacyGroups().Get(id)
+	if err != nil {
+		return nil, err
+	}
+	tx, _, _, _ := rawdb.ReadTransaction(s.b.ChainDb(), hash)
+	if tx == nil {
+		return nil, nil
+	}
+	if err := checkGroupTx(group, tx); err != nil {
+		return nil, err
+	}
+	return s.txPool.GetTransactionReceipt(ctx, hash)
+}
+
+// Call executes the given transaction on the private state for the given block number, if a
+// local account is a member of the privacy group.
+func (s *PrivatePrivacyGroupAPI) Call(ctx context.Context, id common.Hash, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
+	if _, err := s.memberGroup(id); err != nil {
+		return nil, err
+	}
+	args.IsPrivate = true
+	result, _, _, err := s.chain.doCall(ctx, args, blockNr, vm.Config{}, 5*time.Second)
+	return (hexutil.Bytes)(result), err
+}
+
+// GetLogs returns the logs of private transactions of the privacy group matching the
+// given criteria, if a local account is a member of the privacy group.
+func (s *PrivatePrivacyGroupAPI) GetLogs(ctx context.Context, id common.Hash, crit filters.FilterCriteria) ([]*types.Log, error) {
+	group, err := s.memberGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := filters.PrivateLogs(ctx, s.b, crit)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupLogs(group, logs), nil
+}
+
+// Logs creates a subscription that fires for each log of private transactions of the
+// privacy group matching the given criteria, if a local account is a member of the
+// privacy group. Logs of blocks removed by a reorg are sent again marked removed.
+func (s *PrivatePrivacyGroupAPI) Logs(ctx context.Context, id common.Hash, crit filters.FilterCriteria) (*rpc.Subscription, error) {
+	group, err := s.memberGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		var (
+			logsCh    = make(chan core.PrivateLogsEvent, privateLogsChanSize)
+			rmLogsCh  = make(chan core.RemovedLogsEvent, privateLogsChanSize)
+			logsSub   = s.b.SubscribePrivateLogsEvent(logsCh)
+			rmLogsSub = s.b.SubscribeRemovedLogsEvent(rmLogsCh)
+		)
+		defer logsSub.Unsubscribe()
+		defer rmLogsSub.Unsubscribe()
+
+		for {
+			var logs []*types.Log
+			select {
+			case ev := <-logsCh:
+				logs = ev.Logs
+			case ev := <-rmLogsCh:
+				logs = ev.PrivateLogs
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+			for _, l := range s.groupLogs(group, filters.FilterLogs(logs, crit)) {
+				notifier.Notify(rpcSub.ID, l)
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// memberGroup returns the privacy group with given id, if a local account is a member of it.
+func (s *PrivatePrivacyGroupAPI) memberGroup(id common.Hash) (*private.PrivacyGroup, error) {
+	group, err := s.b.PrivacyGroups().Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !s.isMember(group) {
+		return nil, NotGroupMemberErr
+	}
+	return group, nil
+}
+
+// groupLogs returns the logs of private transactions of the privacy group.
+func (s *PrivatePrivacyGroupAPI) groupLogs(group *private.PrivacyGroup, logs []*types.Log) []*types.Log {
+	var (
+		result  = []*types.Log{}
+		inGroup = make(map[common.Hash]bool)
+	)
+	for _, l := range logs {
+		ok, checked := inGroup[l.TxHash]
+		if !checked {
+			ok = s.logInGroup(group, l)
+			inGroup[l.TxHash] = ok
+		}
+		if ok {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+// logInGroup checks if the log is of a private transaction of the privacy group. The
+// transaction is read from the block of the log, which may be removed by a reorg.
+func (s *PrivatePrivacyGroupAPI) logInGroup(group *private.PrivacyGroup, l *types.Log) bool {
+	body := rawdb.ReadBody(s.b.ChainDb(), l.BlockHash, l.BlockNumber)
+	if body == nil {
+		return false
+	}
+	for _, tx := range body.Transactions {
+		if tx.Hash() == l.TxHash {
+			return checkGroupTx(group, tx) == nil
+		}
+	}
+	return false
+}
+
+// checkGroupTx checks if the transaction is a private transaction whose participants
+// are all members of the privacy group.
+func checkGroupTx(group *private.PrivacyGroup, tx *types.Transaction) error {
+	if !tx.IsPrivate() {
+		return NotInGroupTxErr
+	}
+	replacement := private.PayloadReplacement{}
+	if err := rlp.DecodeBytes(tx.Data(), &replacement); err != nil {
+		return err
+	}
+	if !group.Contains(replacement.Participants) {
+		return NotInGroupTxErr
+	}
+	return nil
+}
+
+// isMember checks if any local account is a member of the privacy group.
+func (s *PrivatePrivacyGroupAPI) isMember(group *private.PrivacyGroup) bool {
+	for _, m := range group.Members {
+		if ok, _, _ := s.b.AccountManager().CanDecrypt(m); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -16,11 +16,27 @@
 
 package private
 
+import (
+	"errors"
+	"fmt"
+
+	"bitbucket.org/cpchain/chain/database"
+)
+
 const (
 	DefaultIpfsUrl = "3.0.198.89:5001"
 	Dummy          = "dummy"
 	IPFS           = "ipfs"
 	Swarm          = "swarm"
+	LocalFS        = "localfs"
+	HTTP           = "http"
+
+	// DefaultLocalFSDir is the directory of the local content-addressed store, relative to the data directory
+	DefaultLocalFSDir = "privatepayloads"
+)
+
+var (
+	ErrUnsupportedRemoteDB = errors.New("unsupported remote database type")
 )
 
 var (
@@ -28,6 +44,12 @@ var (
 	SupportPrivateTx string
 )
 
+// Config is the configuration of the remote database saving private tx payloads.
+// RemoteDBParams depends on RemoteDBType:
+//
+//	ipfs:    the IPFS node's API url
+//	localfs: the directory of the store, relative paths are resolved against the data directory
+//	http:    the url of the blob store
 type Config struct {
 	RemoteDBParams string
 	RemoteDBType   string
@@ -39,3 +61,32 @@ func DefaultConfig() Config {
 		RemoteDBParams: DefaultIpfsUrl,
 	}
 }
+
+// NewRemoteDatabase creates the remote database selected by the config, resolvePath
+// resolves relative paths of the local filesystem store.
+func NewRemoteDatabase(config Config, resolvePath func(string) string) (database.RemoteDatabase, error) {
+	switch config.RemoteDBType {
+	case IPFS:
+		return database.NewIpfsDB(config.RemoteDBParams), nil
+	case Dummy:
+		return new(database.DummyDatabase), nil
+	case LocalFS:
+		dir := config.RemoteDBParams
+		if dir == "" || dir == DefaultIpfsUrl {
+			dir = DefaultLocalFSDir
+		}
+		if resolvePath != nil {
+			dir = resolvePath(dir)
+		}
+		return database.NewLocalContentDB(dir)
+	case HTTP:
+		if config.RemoteDBParams == "" {
+			return nil, fmt.Errorf("%s remote database requires the blob store url", HTTP)
+		}
+		return database.NewHttpBlobDB(config.RemoteDBParams), nil
+	case Swarm:
+		return nil, fmt.Errorf("%v: %s", ErrUnsupportedRemoteDB, Swarm)
+	default:
+		return nil, fmt.Errorf("%v: %q", ErrUnsupportedRemoteDB, config.RemoteDBType)
+	}
+}
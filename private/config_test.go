@@ -0,0 +1,72 @@
+// Copyright 2018 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package private
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bitbucket.org/cpchain/chain/database"
+)
+
+func TestNewRemoteDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remotedb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	resolvePath := func(path string) string {
+		return filepath.Join(dir, path)
+	}
+
+	tests := []struct {
+		config  Config
+		want    interface{}
+		wantErr bool
+	}{
+		{Config{RemoteDBType: Dummy}, &database.DummyDatabase{}, false},
+		{Config{RemoteDBType: IPFS, RemoteDBParams: DefaultIpfsUrl}, &database.IpfsDatabase{}, false},
+		{Config{RemoteDBType: LocalFS, RemoteDBParams: "payloads"}, &database.LocalContentDatabase{}, false},
+		{Config{RemoteDBType: HTTP, RemoteDBParams: "127.0.0.1:8600"}, &database.HttpBlobDatabase{}, false},
+		{Config{RemoteDBType: HTTP}, nil, true},
+		{Config{RemoteDBType: Swarm}, nil, true},
+		{Config{RemoteDBType: "ipfs2"}, nil, true},
+		{Config{}, nil, true},
+	}
+
+	for _, tt := range tests {
+		db, err := NewRemoteDatabase(tt.config, resolvePath)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewRemoteDatabase(%s) error = %v, wantErr %v", tt.config.RemoteDBType, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if have, want := fmt.Sprintf("%T", db), fmt.Sprintf("%T", tt.want); have != want {
+			t.Errorf("NewRemoteDatabase(%s) = %s, want %s", tt.config.RemoteDBType, have, want)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "payloads")); err != nil {
+		t.Errorf("local filesystem store is not created under the data directory: %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package private
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+
+	"bitbucket.org/cpchain/chain/database"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	// ErrGroupNotFound is returned if the privacy group does not exist.
+	ErrGroupNotFound = errors.New("privacy group not found")
+
+	// ErrGroupExists is returned if a privacy group with the same name and members already exists.
+	ErrGroupExists = errors.New("privacy group already exists")
+
+	// ErrEmptyGroup is returned if a privacy group would have no member.
+	ErrEmptyGroup = errors.New("privacy group has no member")
+)
+
+var (
+	privacyGroupPrefix   = []byte("privacy-group-")
+	privacyGroupIndexKey = []byte("privacy-groups")
+)
+
+// PrivacyGroup is a named set of participants' public keys, a private transaction targeting
+// the group is sealed for all of its members.
+type PrivacyGroup struct {
+	ID      common.Hash `json:"id"`
+	Name    string      `json:"name"`
+	Members []string    `json:"members"`
+}
+
+// Contains checks if all the participants are members of the group.
+func (g *PrivacyGroup) Contains(participants []string) bool {
+	members := make(map[string]bool, len(g.Members))
+	for _, m := range g.Members {
+		members[m] = true
+	}
+	for _, p := range participants {
+		if !members[normalizeMember(p)] {
+			return false
+		}
+	}
+	return true
+}
+
+// GroupStore persists privacy groups in local database. Groups are never shared with other
+// nodes, each participant manages its own view of the groups it takes part in.
+type GroupStore struct {
+	db database.Database
+	mu sync.Mutex
+}
+
+// NewGroupStore creates a new GroupStore instance saving groups into the given database.
+func NewGroupStore(db database.Database) *GroupStore {
+	return &GroupStore{db: db}
+}
+
+// Create creates a new privacy group with given name and members' public keys.
+// The group id is derived from the name and the initial members, so that the participants
+// creating the same group on their nodes share the same id.
+func (s *GroupStore) Create(name string, members []string) (*PrivacyGroup, error) {
+	members, err := normalizeMembers(members)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, ErrEmptyGroup
+	}
+
+	enc, err := rlp.EncodeToBytes([]interface{}{name, members})
+	if err != nil {
+		return nil, err
+	}
+	group := &PrivacyGroup{
+		ID:      crypto.Keccak256Hash(enc),
+		Name:    name,
+		Members: members,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ok, _ := s.db.Has(groupKey(group.ID)); ok {
+		return nil, ErrGroupExists
+	}
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.write(group); err != nil {
+		return nil, err
+	}
+	return group, s.writeIds(append(ids, group.ID))
+}
+
+// Get returns the privacy group with given id.
+func (s *GroupStore) Get(id common.Hash) (*PrivacyGroup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read(id)
+}
+
+// List returns all privacy groups in the order they are created.
+func (s *GroupStore) List() ([]*PrivacyGroup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]*PrivacyGroup, 0, len(ids))
+	for _, id := range ids {
+		group, err := s.read(id)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// AddMembers adds members' public keys to the privacy group.
+func (s *GroupStore) AddMembers(id common.Hash, members []string) (*PrivacyGroup, error) {
+	members, err := normalizeMembers(members)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		if !group.Contains([]string{m}) {
+			group.Members = append(group.Members, m)
+		}
+	}
+	return group, s.write(group)
+}
+
+// RemoveMembers removes members' public keys from the privacy group. The last member cannot be removed.
+func (s *GroupStore) RemoveMembers(id common.Hash, members []string) (*PrivacyGroup, error) {
+	members, err := normalizeMembers(members)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	removed := &PrivacyGroup{Members: members}
+	remains := make([]string, 0, len(group.Members))
+	for _, m := range group.Members {
+		if !removed.Contains([]string{m}) {
+			remains = append(remains, m)
+		}
+	}
+	if len(remains) == 0 {
+		return nil, ErrEmptyGroup
+	}
+	group.Members = remains
+	return group, s.write(group)
+}
+
+// Delete deletes the privacy group.
+func (s *GroupStore) Delete(id common.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, err := s.ids()
+	if err != nil {
+		return err
+	}
+	for i, gid := range ids {
+		if gid == id {
+			if err := s.writeIds(append(ids[:i], ids[i+1:]...)); err != nil {
+				return err
+			}
+			return s.db.Delete(groupKey(id))
+		}
+	}
+	return ErrGroupNotFound
+}
+
+// Participants returns the members' public keys of the privacy group.
+func (s *GroupStore) Participants(id common.Hash) ([]string, error) {
+	group, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return group.Members, nil
+}
+
+func (s *GroupStore) read(id common.Hash) (*PrivacyGroup, error) {
+	data, _ := s.db.Get(groupKey(id))
+	if len(data) == 0 {
+		return nil, ErrGroupNotFound
+	}
+	group := new(PrivacyGroup)
+	if err := rlp.DecodeBytes(data, group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (s *GroupStore) write(group *PrivacyGroup) error {
+	data, err := rlp.EncodeToBytes(group)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(groupKey(group.ID), data)
+}
+
+func (s *GroupStore) ids() ([]common.Hash, error) {
+	data, _ := s.db.Get(privacyGroupIndexKey)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var ids []common.Hash
+	if err := rlp.DecodeBytes(data, &ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (s *GroupStore) writeIds(ids []common.Hash) error {
+	data, err := rlp.EncodeToBytes(ids)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(privacyGroupIndexKey, data)
+}
+
+func groupKey(id common.Hash) []byte {
+	return append(privacyGroupPrefix, id[:]...)
+}
+
+// normalizeMember returns the lower case 0x prefixed form of a member's public key.
+func normalizeMember(member string) string {
+	member = strings.ToLower(member)
+	if !strings.HasPrefix(member, "0x") {
+		member = "0x" + member
+	}
+	return member
+}
+
+// normalizeMembers validates and normalizes members' public keys, dropping duplicates.
+func normalizeMembers(members []string) ([]string, error) {
+	if _, err := stringsToPublicKeys(members); err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(members))
+	seen := make(map[string]bool, len(members))
+	for _, m := range members {
+		m = normalizeMember(m)
+		if !seen[m] {
+			seen[m] = true
+			result = append(result, m)
+		}
+	}
+	return result, nil
+}
+
+// SealPrivatePayloadForGroup seals private tx's payload like SealPrivatePayload, for all members of the privacy group.
+func SealPrivatePayloadForGroup(payload []byte, sender common.Address, chainID *big.Int, groups *GroupStore, id common.Hash,
+	remoteDB database.RemoteDatabase) (PayloadReplacement, error) {
+	participants, err := groups.Participants(id)
+	if err != nil {
+		return PayloadReplacement{}, err
+	}
+	return SealPrivatePayload(payload, sender, chainID, participants, remoteDB)
+}
@@ -0,0 +1,124 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package private
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"bitbucket.org/cpchain/chain/database"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestGroupStore(t *testing.T) {
+	db := database.NewMemDatabase()
+	store := NewGroupStore(db)
+
+	member1 := getTestParticipants()[0]
+	member2 := getOtherParticipants()[0]
+
+	// duplicated members are dropped and keys are normalized
+	group, err := store.Create("group", []string{member1, strings.ToUpper(member1[2:])})
+	if err != nil {
+		t.Fatalf("Creating a group should succeed, got %v", err)
+	}
+	if !reflect.DeepEqual(group.Members, []string{member1}) {
+		t.Fatalf("Group members mismatch: got %v", group.Members)
+	}
+	if _, err := store.Create("group", []string{member1}); err != ErrGroupExists {
+		t.Fatalf("Creating the same group should fail with %v, got %v", ErrGroupExists, err)
+	}
+	if _, err := store.Create("invalid", []string{"0x04ca"}); err == nil {
+		t.Fatal("Creating a group with invalid member should fail")
+	}
+	if _, err := store.Create("empty", nil); err != ErrEmptyGroup {
+		t.Fatalf("Creating an empty group should fail with %v, got %v", ErrEmptyGroup, err)
+	}
+	other, err := store.Create("other", []string{member2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if group, err = store.AddMembers(group.ID, []string{member2}); err != nil {
+		t.Fatalf("Adding members should succeed, got %v", err)
+	}
+	if !group.Contains([]string{member1, member2}) {
+		t.Fatalf("Group should contain both members, got %v", group.Members)
+	}
+
+	// groups are persisted
+	store = NewGroupStore(db)
+	groups, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || !reflect.DeepEqual(groups[0], group) || !reflect.DeepEqual(groups[1], other) {
+		t.Fatalf("Listed groups mismatch: got %v", groups)
+	}
+
+	if group, err = store.RemoveMembers(group.ID, []string{member1}); err != nil {
+		t.Fatalf("Removing members should succeed, got %v", err)
+	}
+	if group.Contains([]string{member1}) {
+		t.Fatalf("Removed member should not be in group, got %v", group.Members)
+	}
+	if _, err := store.RemoveMembers(group.ID, []string{member2}); err != ErrEmptyGroup {
+		t.Fatalf("Removing the last member should fail with %v, got %v", ErrEmptyGroup, err)
+	}
+
+	if err := store.Delete(other.ID); err != nil {
+		t.Fatalf("Deleting group should succeed, got %v", err)
+	}
+	if _, err := store.Get(other.ID); err != ErrGroupNotFound {
+		t.Fatalf("Getting deleted group should fail with %v, got %v", ErrGroupNotFound, err)
+	}
+	if err := store.Delete(other.ID); err != ErrGroupNotFound {
+		t.Fatalf("Deleting deleted group should fail with %v, got %v", ErrGroupNotFound, err)
+	}
+	if groups, _ := store.List(); len(groups) != 1 {
+		t.Fatalf("There should be 1 group left, got %d", len(groups))
+	}
+}
+
+func TestSealPrivatePayloadForGroup(t *testing.T) {
+	store := NewGroupStore(database.NewMemDatabase())
+	remoteDB := database.NewIpfsDbWithAdapter(database.NewFakeIpfsAdapter())
+
+	group, err := store.Create("group", getTestParticipants())
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacement, err := SealPrivatePayloadForGroup(getExpectedPayload(), senderForTest, chainIDForTest, store, group.ID, remoteDB)
+	if err != nil {
+		t.Fatalf("Sealing for group should succeed, got %v", err)
+	}
+	if !reflect.DeepEqual(replacement.Participants, group.Members) {
+		t.Fatalf("Participants mismatch: got %v, want %v", replacement.Participants, group.Members)
+	}
+
+	data, _ := rlp.EncodeToBytes(replacement)
+	payload, hasPermission, err := RetrieveAndDecryptPayload(data, txNonceForTest, senderForTest, chainIDForTest, false, remoteDB, getDecryptor())
+	if err != nil || !hasPermission || !reflect.DeepEqual(payload, getExpectedPayload()) {
+		t.Fatalf("Group member should decrypt the payload, got %s, %v, %v", payload, hasPermission, err)
+	}
+
+	group.ID[0]++
+	if _, err := SealPrivatePayloadForGroup(getExpectedPayload(), senderForTest, chainIDForTest, store, group.ID, remoteDB); err != ErrGroupNotFound {
+		t.Fatalf("Sealing for unknown group should fail with %v, got %v", ErrGroupNotFound, err)
+	}
+}
@@ -19,16 +19,21 @@ package private
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"encoding/binary"
+	"math/big"
 
 	"bitbucket.org/cpchain/chain/accounts"
 	"bitbucket.org/cpchain/chain/database"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // Read tx's payload replacement, retrieve encrypted payload from IPFS and decrypt it.
+// The tx nonce is used by legacy payloads, while the sender and chain id authenticate current ones.
+// Legacy payloads are only accepted if allowLegacy is set, otherwise ErrUnsupportedPayloadVersion is returned.
 // Return decrypted payload, a flag indicating if the node has enough permission and error if there is.
-func RetrieveAndDecryptPayload(data []byte, txNonce uint64, remoteDB database.RemoteDatabase, decryptor accounts.AccountRsaDecryptor) (payload []byte, hasPermission bool, error error) {
+// If the node has permission but the payload cannot be decrypted, ErrInvalidSealedPayload is returned.
+func RetrieveAndDecryptPayload(data []byte, txNonce uint64, sender common.Address, chainID *big.Int, allowLegacy bool, remoteDB database.RemoteDatabase,
+	decryptor accounts.AccountRsaDecryptor) (payload []byte, hasPermission bool, error error) {
 	replacement := PayloadReplacement{}
 	err := rlp.DecodeBytes(data, &replacement)
 	if err != nil {
@@ -42,17 +47,24 @@ func RetrieveAndDecryptPayload(data []byte, txNonce uint64, remoteDB database.Re
 		return []byte{}, false, err
 	}
 
-	sp := SealedPrivatePayload{}
-	err = rlp.DecodeBytes(sealed, &sp)
+	sp, err := decodeSealedPayload(sealed, allowLegacy)
 	if err != nil {
 		return []byte{}, false, err
 	}
 	for i, k := range replacement.Participants {
 		canDecrypt, wallet, acc := decryptor.CanDecrypt(k)
 		if canDecrypt {
-			encryptedKey := sp.SymmetricKeys[i]
-			symKey, _ := decryptor.Decrypt(encryptedKey, wallet, acc)
-			decrypted, _ := decryptPayload(sp.Payload, symKey, txNonce)
+			if i >= len(sp.SymmetricKeys) {
+				return []byte{}, true, ErrInvalidSealedPayload
+			}
+			symKey, err := decryptor.Decrypt(sp.SymmetricKeys[i], wallet, acc)
+			if err != nil {
+				return []byte{}, true, ErrInvalidSealedPayload
+			}
+			decrypted, err := sp.open(symKey, txNonce, sender, chainID)
+			if err != nil {
+				return []byte{}, true, ErrInvalidSealedPayload
+			}
 			return decrypted, true, nil
 		}
 	}
@@ -68,21 +80,22 @@ func getDataFromRemote(ipfsAddress []byte, remoteDB database.RemoteDatabase) ([]
 	return content, nil
 }
 
-// Decrypt payload with the given symmetric key.
+// Decrypt payload with the given symmetric key, gcm nonce and additional data.
 // Returns decrypted payload and error if exists.
-func decryptPayload(cipherdata []byte, skey []byte, txNonce uint64) ([]byte, error) {
-	// use tx's nonce as gcm nonce
-	nonce := make([]byte, 12)
-	binary.BigEndian.PutUint64(nonce, txNonce)
-	binary.BigEndian.PutUint32(nonce[8:], uint32(txNonce))
-
+func decryptPayload(cipherdata []byte, skey []byte, nonce []byte, additionalData []byte) ([]byte, error) {
 	block, err := aes.NewCipher(skey)
 	if err != nil {
 		return []byte{}, err
 	}
 
 	aesgcm, err := cipher.NewGCM(block)
-	data, err := aesgcm.Open(nil, nonce, cipherdata, nil)
+	if err != nil {
+		return []byte{}, err
+	}
+	if len(nonce) != aesgcm.NonceSize() {
+		return []byte{}, ErrInvalidSealedPayload
+	}
+	data, err := aesgcm.Open(nil, nonce, cipherdata, additionalData)
 	if err != nil {
 		return []byte{}, err
 	}
@@ -17,6 +17,8 @@
 package private
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"math/big"
 	"reflect"
 	"testing"
@@ -26,6 +28,7 @@ import (
 	"bitbucket.org/cpchain/chain/commons/crypto/ecieskey"
 	"bitbucket.org/cpchain/chain/database"
 	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto/ecies"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -33,6 +36,11 @@ import (
 
 const txNonceForTest uint64 = 100
 
+var (
+	senderForTest  = common.HexToAddress("0xe94b7b6c5a0e526a4d97f9768ad6097bde25c62a")
+	chainIDForTest = big.NewInt(42)
+)
+
 // TestRetrieveAndDecryptPayload tests retrieving and decrypting payload.
 func TestRetrieveAndDecryptPayload(t *testing.T) {
 	// Prepare fake IPFS for testing.
@@ -44,6 +52,8 @@ func TestRetrieveAndDecryptPayload(t *testing.T) {
 	type args struct {
 		data                  []byte
 		txNonce               uint64
+		sender                common.Address
+		allowLegacy           bool
 		remoteDb              database.RemoteDatabase
 		accountBasedDecryptor accounts.AccountRsaDecryptor
 	}
@@ -59,6 +69,7 @@ func TestRetrieveAndDecryptPayload(t *testing.T) {
 			args: args{
 				data:                  preparePrvTxDataForTesting(ipfsDb),
 				txNonce:               txNonceForTest,
+				sender:                senderForTest,
 				remoteDb:              ipfsDb,
 				accountBasedDecryptor: dec,
 			},
@@ -72,6 +83,7 @@ func TestRetrieveAndDecryptPayload(t *testing.T) {
 			args: args{
 				data:                  []byte{2, 3, 3, 3, 3, 3, 3, 3, 3},
 				txNonce:               txNonceForTest,
+				sender:                senderForTest,
 				remoteDb:              ipfsDb,
 				accountBasedDecryptor: dec,
 			},
@@ -84,6 +96,7 @@ func TestRetrieveAndDecryptPayload(t *testing.T) {
 			args: args{
 				data:                  preparePrvTxPretendedLostDataInIpfs(),
 				txNonce:               txNonceForTest,
+				sender:                senderForTest,
 				remoteDb:              ipfsDb,
 				accountBasedDecryptor: dec,
 			},
@@ -96,6 +109,35 @@ func TestRetrieveAndDecryptPayload(t *testing.T) {
 			args: args{
 				data:                  preparePrvTxInvalidIpfsData(ipfsDb),
 				txNonce:               txNonceForTest,
+				sender:                senderForTest,
+				remoteDb:              ipfsDb,
+				accountBasedDecryptor: dec,
+			},
+			wantPayload:       []byte{},
+			wantHasPermission: false,
+			wantErr:           true,
+		},
+		{
+			name: "TestLegacyPayload",
+			args: args{
+				data:                  prepareLegacyPrvTx(ipfsDb),
+				txNonce:               txNonceForTest,
+				sender:                senderForTest,
+				allowLegacy:           true,
+				remoteDb:              ipfsDb,
+				accountBasedDecryptor: dec,
+			},
+			wantPayload:       getExpectedPayload(),
+			wantHasPermission: true,
+			wantErr:           false,
+		},
+		{
+			// Simulate that a legacy payload is sent after the PrivatePayloadV1 fork.
+			name: "TestLegacyPayloadAfterFork",
+			args: args{
+				data:                  prepareLegacyPrvTx(ipfsDb),
+				txNonce:               txNonceForTest,
+				sender:                senderForTest,
 				remoteDb:              ipfsDb,
 				accountBasedDecryptor: dec,
 			},
@@ -103,11 +145,26 @@ func TestRetrieveAndDecryptPayload(t *testing.T) {
 			wantHasPermission: false,
 			wantErr:           true,
 		},
+		{
+			// Simulate that another sender replays the sealed payload.
+			name: "TestReplayedByOtherSender",
+			args: args{
+				data:                  preparePrvTxDataForTesting(ipfsDb),
+				txNonce:               txNonceForTest,
+				sender:                common.HexToAddress("0xc05302acebd0730e3a18a058d7d1cb1204c4a092"),
+				remoteDb:              ipfsDb,
+				accountBasedDecryptor: dec,
+			},
+			wantPayload:       []byte{},
+			wantHasPermission: true,
+			wantErr:           true,
+		},
 		{
 			name: "TestUnauthorizedPrivateTx",
 			args: args{
 				data:                  prepareUnauthorizedPrvTx(ipfsDb),
 				txNonce:               txNonceForTest,
+				sender:                senderForTest,
 				remoteDb:              ipfsDb,
 				accountBasedDecryptor: dec,
 			},
@@ -118,7 +175,7 @@ func TestRetrieveAndDecryptPayload(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotPayload, gotHasPermission, err := RetrieveAndDecryptPayload(tt.args.data, tt.args.txNonce, tt.args.remoteDb, tt.args.accountBasedDecryptor)
+			gotPayload, gotHasPermission, err := RetrieveAndDecryptPayload(tt.args.data, tt.args.txNonce, tt.args.sender, chainIDForTest, tt.args.allowLegacy, tt.args.remoteDb, tt.args.accountBasedDecryptor)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("RetrieveAndDecryptPayload() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -184,7 +241,7 @@ func Test_decryptPayload(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := decryptPayload(tt.args.cipherdata, tt.args.skey, tt.args.txNonce)
+			got, err := decryptPayload(tt.args.cipherdata, tt.args.skey, legacyNonce(tt.args.txNonce), nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("decryptPayload() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -201,11 +258,36 @@ func Test_decryptPayload(t *testing.T) {
 // 2. Save it to IPFS
 // 3. Return tx payload replacement generated by returned URI of data in IPFS.
 func preparePrvTxDataForTesting(remoteDB database.RemoteDatabase) []byte {
-	p, _ := SealPrivatePayload(getExpectedPayload(), txNonceForTest, getTestParticipants(), remoteDB)
+	p, _ := SealPrivatePayload(getExpectedPayload(), senderForTest, chainIDForTest, getTestParticipants(), remoteDB)
 	data, _ := rlp.EncodeToBytes(p)
 	return data
 }
 
+// prepareLegacyPrvTx prepares a private tx sealed in version 0 format, whose payload is encrypted with a nonce
+// derived from tx nonce and sealed without version and nonce.
+func prepareLegacyPrvTx(remoteDB database.RemoteDatabase) []byte {
+	symKey, _ := generateSymmetricKey()
+	block, _ := aes.NewCipher(symKey)
+	aesgcm, _ := cipher.NewGCM(block)
+	encrypted := aesgcm.Seal(nil, legacyNonce(txNonceForTest), getExpectedPayload(), nil)
+
+	pubKeys, _ := stringsToPublicKeys(getTestParticipants())
+	symKeys, _ := sealSymmetricKey(symKey, pubKeys)
+	sealed := NewSealedPrivatePayload(encrypted, nil, symKeys, pubKeys)
+	content, _ := rlp.EncodeToBytes(legacySealedPrivatePayload{
+		Payload:       sealed.Payload,
+		SymmetricKeys: sealed.SymmetricKeys,
+		Participants:  sealed.Participants,
+	})
+	hash, _ := remoteDB.Put(content)
+
+	data, _ := rlp.EncodeToBytes(PayloadReplacement{
+		TxPayload:    hash,
+		Participants: getTestParticipants(),
+	})
+	return data
+}
+
 // preparePrvTxPretendedLostDataInIpfs pretends the situation where data in IPFS is lost and returns a replacement with the
 // URI linking to the lost data.
 func preparePrvTxPretendedLostDataInIpfs() []byte {
@@ -228,7 +310,7 @@ func preparePrvTxInvalidIpfsData(remoteDB database.RemoteDatabase) []byte {
 }
 
 func prepareUnauthorizedPrvTx(remoteDB database.RemoteDatabase) []byte {
-	p, _ := SealPrivatePayload(getExpectedPayload(), txNonceForTest, getOtherParticipants(), remoteDB)
+	p, _ := SealPrivatePayload(getExpectedPayload(), senderForTest, chainIDForTest, getOtherParticipants(), remoteDB)
 	data, _ := rlp.EncodeToBytes(p)
 	return data
 }
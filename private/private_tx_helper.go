@@ -22,18 +22,47 @@ import (
 	"crypto/ecdsa"
 	"crypto/rand"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
+	"math/big"
+	"strings"
 
 	"bitbucket.org/cpchain/chain/commons/crypto/ecieskey"
-	"bitbucket.org/cpchain/chain/commons/log"
 	"bitbucket.org/cpchain/chain/database"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto/ecies"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
+const (
+	// PayloadVersion0 is the legacy payload format. The payload is encrypted with a nonce derived from
+	// the tx nonce and without associated data, it is only kept for reading old private transactions.
+	PayloadVersion0 uint = 0
+	// PayloadVersion1 encrypts the payload with a random nonce and binds it to the sender and chain id
+	// as associated data.
+	PayloadVersion1 uint = 1
+
+	// CurrentPayloadVersion is the version of newly sealed payloads.
+	CurrentPayloadVersion = PayloadVersion1
+)
+
+var (
+	// ErrUnsupportedPayloadVersion is returned if the sealed payload is of an unknown version.
+	ErrUnsupportedPayloadVersion = errors.New("unsupported private payload version")
+
+	// ErrInvalidSealedPayload is returned if the sealed payload is malformed, or it cannot be decrypted
+	// or authenticated with the symmetric key sealed for this node.
+	ErrInvalidSealedPayload = errors.New("invalid sealed private payload")
+)
+
 // SealedPrivatePayload represents a sealed payload entity in IPFS.
 type SealedPrivatePayload struct {
+	// Version represents the format of the sealed payload.
+	Version uint
+	// Nonce represents the random AES-GCM nonce used to encrypt the payload.
+	Nonce []byte
 	// Payload represents the encrypted payload with a random symmetric key.
 	Payload []byte
 	// For public keys, the order is consistent in SymmetricKeys and Participants.
@@ -43,14 +72,23 @@ type SealedPrivatePayload struct {
 	Participants [][]byte
 }
 
-// NewSealedPrivatePayload creates new SealedPrivatePayload instance with given parameters.
-func NewSealedPrivatePayload(encryptedPayload []byte, symmetricKey [][]byte, participants []*ecdsa.PublicKey) SealedPrivatePayload {
+// legacySealedPrivatePayload represents a sealed payload of version 0, which has neither version nor nonce.
+type legacySealedPrivatePayload struct {
+	Payload       []byte
+	SymmetricKeys [][]byte
+	Participants  [][]byte
+}
+
+// NewSealedPrivatePayload creates new SealedPrivatePayload instance of current version with given parameters.
+func NewSealedPrivatePayload(encryptedPayload []byte, nonce []byte, symmetricKey [][]byte, participants []*ecdsa.PublicKey) SealedPrivatePayload {
 	keysToStore := make([][]byte, len(participants))
 	for i, key := range participants {
 		keysToStore[i] = ecieskey.EncodeEcdsaPubKey(key)
 	}
 
 	return SealedPrivatePayload{
+		Version:       CurrentPayloadVersion,
+		Nonce:         nonce,
 		Payload:       encryptedPayload,
 		SymmetricKeys: symmetricKey,
 		Participants:  keysToStore,
@@ -62,6 +100,75 @@ func (sealed *SealedPrivatePayload) toBytes() ([]byte, error) {
 	return rlp.EncodeToBytes(sealed)
 }
 
+// decodeSealedPayload decodes a sealed payload of any supported version. Legacy payloads are
+// told apart by their number of fields, as they are not prefixed by a version, and are rejected
+// unless allowLegacy is set.
+func decodeSealedPayload(data []byte, allowLegacy bool) (*SealedPrivatePayload, error) {
+	var fields []rlp.RawValue
+	if err := rlp.DecodeBytes(data, &fields); err != nil {
+		return nil, err
+	}
+
+	if len(fields) == 3 {
+		if !allowLegacy {
+			return nil, ErrUnsupportedPayloadVersion
+		}
+		var legacy legacySealedPrivatePayload
+		if err := rlp.DecodeBytes(data, &legacy); err != nil {
+			return nil, err
+		}
+		return &SealedPrivatePayload{
+			Version:       PayloadVersion0,
+			Payload:       legacy.Payload,
+			SymmetricKeys: legacy.SymmetricKeys,
+			Participants:  legacy.Participants,
+		}, nil
+	}
+
+	sealed := new(SealedPrivatePayload)
+	if err := rlp.DecodeBytes(data, sealed); err != nil {
+		return nil, err
+	}
+	if sealed.Version != PayloadVersion1 {
+		return nil, ErrUnsupportedPayloadVersion
+	}
+	return sealed, nil
+}
+
+// open decrypts the payload with the given symmetric key. The sender and chain id are checked
+// against the associated data for current version, and the tx nonce is used for legacy version.
+func (sealed *SealedPrivatePayload) open(symKey []byte, txNonce uint64, sender common.Address, chainID *big.Int) ([]byte, error) {
+	switch sealed.Version {
+	case PayloadVersion0:
+		return decryptPayload(sealed.Payload, symKey, legacyNonce(txNonce), nil)
+	case PayloadVersion1:
+		aad, err := associatedData(sealed.Version, sender, chainID)
+		if err != nil {
+			return nil, err
+		}
+		return decryptPayload(sealed.Payload, symKey, sealed.Nonce, aad)
+	default:
+		return nil, ErrUnsupportedPayloadVersion
+	}
+}
+
+// associatedData returns the data authenticated along with the encrypted payload, so that a
+// sealed payload cannot be replayed by another sender or on another chain.
+func associatedData(version uint, sender common.Address, chainID *big.Int) ([]byte, error) {
+	if chainID == nil {
+		chainID = new(big.Int)
+	}
+	return rlp.EncodeToBytes([]interface{}{version, sender, chainID})
+}
+
+// legacyNonce derives the gcm nonce from tx's nonce as the legacy version does.
+func legacyNonce(txNonce uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, txNonce)
+	binary.BigEndian.PutUint32(nonce[8:], uint32(txNonce))
+	return nonce
+}
+
 // PayloadReplacement represents the replacement data which substitute the private tx payload.
 type PayloadReplacement struct {
 	// Participants represents a list of public keys which belongs to defined participants. They are used for encryption of symmetric key.
@@ -71,25 +178,38 @@ type PayloadReplacement struct {
 }
 
 // SealPrivatePayload encrypts private tx's payload and sends it to IPFS, then replaces the payload with the address in IPFS.
+// The encrypted payload is bound to the sender and chain id of the private tx.
 // Returns an address which could be used to retrieve original payload from IPFS.
-func SealPrivatePayload(payload []byte, txNonce uint64, participants []string, remoteDB database.RemoteDatabase) (PayloadReplacement, error) {
-	// Encrypt payload
-	// use tx's nonce as gcm nonce
-	nonce := make([]byte, 12)
-	binary.BigEndian.PutUint64(nonce, txNonce)
-	binary.BigEndian.PutUint32(nonce[8:], uint32(txNonce))
-	encryptedPayload, symKey, _ := encryptPayload(payload, nonce)
+func SealPrivatePayload(payload []byte, sender common.Address, chainID *big.Int, participants []string, remoteDB database.RemoteDatabase) (PayloadReplacement, error) {
+	pubKeys, err := stringsToPublicKeys(participants)
+	if err != nil {
+		return PayloadReplacement{}, err
+	}
 
-	pubKeys, _ := stringsToPublicKeys(participants)
+	// Encrypt payload with a random nonce, authenticating the sender and chain id.
+	aad, err := associatedData(CurrentPayloadVersion, sender, chainID)
+	if err != nil {
+		return PayloadReplacement{}, err
+	}
+	encryptedPayload, nonce, symKey, err := encryptPayload(payload, aad)
+	if err != nil {
+		return PayloadReplacement{}, err
+	}
 
 	// Encrypt symmetric keys for participants with related public key.
-	symKeys := sealSymmetricKey(symKey, pubKeys)
+	symKeys, err := sealSymmetricKey(symKey, pubKeys)
+	if err != nil {
+		return PayloadReplacement{}, err
+	}
 
 	// Seal the payload by encrypting payload and appending symmetric key and participants.
-	sealed := NewSealedPrivatePayload(encryptedPayload, symKeys, pubKeys)
+	sealed := NewSealedPrivatePayload(encryptedPayload, nonce, symKeys, pubKeys)
 
 	// Put to IPFS
-	bytesToPut, _ := sealed.toBytes()
+	bytesToPut, err := sealed.toBytes()
+	if err != nil {
+		return PayloadReplacement{}, err
+	}
 	remoteDataId, err := remoteDB.Put(bytesToPut)
 	if err != nil {
 		return PayloadReplacement{}, err
@@ -108,13 +228,16 @@ func stringsToPublicKeys(keys []string) ([]*ecdsa.PublicKey, error) {
 	pubKeys := make([]*ecdsa.PublicKey, len(keys))
 
 	for i, p := range keys {
-		if p[:2] != "0x" {
+		if !strings.HasPrefix(p, "0x") {
 			p = "0x" + p
 		}
-		keyBuf, _ := hexutil.Decode(p)
+		keyBuf, err := hexutil.Decode(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid participant public key at index %d: %v", i, err)
+		}
 		pubKey, err := ecieskey.DecodeEcdsaPubKeyFrom(keyBuf)
 		if err != nil {
-			log.Error("Decode Ecdsa pub key failed", "err", err)
+			return nil, fmt.Errorf("invalid participant public key at index %d: %v", i, err)
 		}
 		pubKeys[i] = pubKey
 	}
@@ -122,34 +245,53 @@ func stringsToPublicKeys(keys []string) ([]*ecdsa.PublicKey, error) {
 }
 
 // sealSymmetricKey sealed symmetric key by encrypting it with participant's public keys one by one.
-func sealSymmetricKey(symKey []byte, keys []*ecdsa.PublicKey) [][]byte {
+func sealSymmetricKey(symKey []byte, keys []*ecdsa.PublicKey) ([][]byte, error) {
 	result := make([][]byte, len(keys))
 	for i, key := range keys {
 		eciesKey := ecies.ImportECDSAPublic(key)
-		encryptedKey, _ := ecieskey.Encrypt(eciesKey, symKey)
+		encryptedKey, err := ecieskey.Encrypt(eciesKey, symKey)
+		if err != nil {
+			return nil, err
+		}
 		result[i] = encryptedKey
 	}
 
-	return result
+	return result, nil
 }
 
 const keyLength = 32
 
 // generateSymmetricKey generate a random symmetric key.
-func generateSymmetricKey() []byte {
+func generateSymmetricKey() ([]byte, error) {
 	key := make([]byte, keyLength)
-	io.ReadFull(rand.Reader, key)
-	return key
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
 }
 
-// encryptPayload encrypts payload with a random symmetric key and returns encrypted payload and the random symmetric key.
-func encryptPayload(payload []byte, nonce []byte) (encryptedPayload []byte, symmetricKey []byte, err error) {
-	symKey := generateSymmetricKey()
+// encryptPayload encrypts payload with a random symmetric key and a random nonce, authenticating the additional data.
+// Returns encrypted payload, the random nonce and the random symmetric key.
+func encryptPayload(payload []byte, additionalData []byte) (encryptedPayload []byte, nonce []byte, symmetricKey []byte, err error) {
+	symKey, err := generateSymmetricKey()
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-	block, _ := aes.NewCipher(symKey)
+	block, err := aes.NewCipher(symKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	aesgcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-	aesgcm, _ := cipher.NewGCM(block)
+	nonce = make([]byte, aesgcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, nil, err
+	}
 
-	encrypted := aesgcm.Seal(nil, nonce, payload, nil)
-	return encrypted, symKey, nil
+	encrypted := aesgcm.Seal(nil, nonce, payload, additionalData)
+	return encrypted, nonce, symKey, nil
 }
@@ -17,6 +17,7 @@
 package private
 
 import (
+	"bytes"
 	"crypto/rsa"
 	"crypto/x509"
 	"errors"
@@ -62,14 +63,13 @@ func TestGetPrivateKeyForAccount(t *testing.T) {
 // TestSealPrivatePayloadWithFaultIPFS tests the SealPrivatePayload function with fault IPFS server.
 func TestSealPrivatePayloadWithFaultIPFS(t *testing.T) {
 	payload := []byte("This is a payload plaintext.")
-	txNonce := uint64(10000)
 
 	parties := []string{"0x04ca634cae0d49acb401d8a4c6b6fe8c55b70d115bf400769cc1400f3258cd31387574077f301b421bc84df7266c44e9e6d569fc56be00812904767bf5ccd1fc7f",
 		"0x04ca634cae0d49acb401d8a4c6b6fe8c55b70d115bf400769cc1400f3258cd31387574077f301b421bc84df7266c44e9e6d569fc56be00812904767bf5ccd1fc7f"}
 
 	adapter := FaultIpfsAdapter{}
 	ipfsDb := database.NewIpfsDbWithAdapter(&adapter)
-	_, err := SealPrivatePayload(payload, txNonce, parties, ipfsDb)
+	_, err := SealPrivatePayload(payload, senderForTest, chainIDForTest, parties, ipfsDb)
 	if err == nil {
 		t.Fatal("It should return an error when IPFS is in fault.")
 	}
@@ -77,12 +77,11 @@ func TestSealPrivatePayloadWithFaultIPFS(t *testing.T) {
 
 func callSealPrivatePayload(t *testing.T, remoteDB database.RemoteDatabase) {
 	payload := []byte("This is a payload plaintext.")
-	txNonce := uint64(10000)
 
 	parties := []string{"0x04ca634cae0d49acb401d8a4c6b6fe8c55b70d115bf400769cc1400f3258cd31387574077f301b421bc84df7266c44e9e6d569fc56be00812904767bf5ccd1fc7f",
 		"0x04ca634cae0d49acb401d8a4c6b6fe8c55b70d115bf400769cc1400f3258cd31387574077f301b421bc84df7266c44e9e6d569fc56be00812904767bf5ccd1fc7f"}
 
-	replacement, err := SealPrivatePayload(payload, txNonce, parties, remoteDB)
+	replacement, err := SealPrivatePayload(payload, senderForTest, chainIDForTest, parties, remoteDB)
 	if err != nil {
 		t.Fatal("It should return expected IPFS address without any error.")
 	}
@@ -109,6 +108,31 @@ func callSealPrivatePayload(t *testing.T, remoteDB database.RemoteDatabase) {
 	if len(sealedPayload.Payload) == 0 {
 		t.Fatal("The payload should not be empty.")
 	}
+	if sealedPayload.Version != CurrentPayloadVersion || len(sealedPayload.Nonce) != 12 {
+		t.Fatalf("The sealed payload should be of version %d with a nonce, got version %d, nonce %x", CurrentPayloadVersion, sealedPayload.Version, sealedPayload.Nonce)
+	}
+
+	// sealing the same payload again uses another random nonce
+	another, err := SealPrivatePayload(payload, senderForTest, chainIDForTest, parties, remoteDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ = remoteDB.Get(another.TxPayload)
+	anotherPayload := SealedPrivatePayload{}
+	rlp.DecodeBytes(content, &anotherPayload)
+	if bytes.Equal(anotherPayload.Nonce, sealedPayload.Nonce) {
+		t.Fatal("The nonce should be random for each sealed payload.")
+	}
+}
+
+// TestSealPrivatePayloadWithInvalidParticipant tests that an invalid participant public key is reported.
+func TestSealPrivatePayloadWithInvalidParticipant(t *testing.T) {
+	ipfsDb := database.NewIpfsDbWithAdapter(database.NewFakeIpfsAdapter())
+	for _, parties := range [][]string{{"0x04ca"}, {"0xzz"}, {""}} {
+		if _, err := SealPrivatePayload([]byte("payload"), senderForTest, chainIDForTest, parties, ipfsDb); err == nil {
+			t.Errorf("Sealing for invalid participant %q should fail.", parties[0])
+		}
+	}
 }
 
 // TODO: Below code is temporary and just for testing. It will be removed later.
@@ -30,6 +30,7 @@ import (
 	"bitbucket.org/cpchain/chain/core/state"
 	"bitbucket.org/cpchain/chain/core/vm"
 	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/private"
 	"bitbucket.org/cpchain/chain/protocols/cpc/gasprice"
 	"bitbucket.org/cpchain/chain/protocols/cpc/syncer"
 	"bitbucket.org/cpchain/chain/types"
@@ -69,6 +70,10 @@ func (b *APIBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber
 	if blockNr == rpc.LatestBlockNumber {
 		return b.cpc.blockchain.CurrentBlock().Header(), nil
 	}
+	// Certified blocks are never reverted, the safe head is the finalized one
+	if blockNr == rpc.FinalizedBlockNumber || blockNr == rpc.SafeBlockNumber {
+		return b.cpc.blockchain.CurrentFinalizedBlock().Header(), nil
+	}
 	return b.cpc.blockchain.GetHeaderByNumber(uint64(blockNr)), nil
 }
 
@@ -82,6 +87,9 @@ func (b *APIBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber)
 	if blockNr == rpc.LatestBlockNumber {
 		return b.cpc.blockchain.CurrentBlock(), nil
 	}
+	if blockNr == rpc.FinalizedBlockNumber || blockNr == rpc.SafeBlockNumber {
+		return b.cpc.blockchain.CurrentFinalizedBlock(), nil
+	}
 	return b.cpc.blockchain.GetBlockByNumber(uint64(blockNr)), nil
 }
 
@@ -105,6 +113,20 @@ func (b *APIBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.Blo
 	return stateDb, header, err
 }
 
+func (b *APIBackend) HeldPrivateStateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	// Pending state is only known by the miner, which does not track private state,
+	// so it falls back to the latest block.
+	if blockNr == rpc.PendingBlockNumber {
+		blockNr = rpc.LatestBlockNumber
+	}
+	header, err := b.HeaderByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		return nil, nil, err
+	}
+	stateDb, err := b.cpc.BlockChain().HeldStatePrivAt(header.StateRoot)
+	return stateDb, header, err
+}
+
 func (b *APIBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
 	return b.cpc.blockchain.GetBlockByHash(hash), nil
 }
@@ -140,6 +162,10 @@ func (b *APIBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.
 	return logs, nil
 }
 
+func (b *APIBackend) GetPrivateLogs(ctx context.Context, hash common.Hash) ([]*types.Log, error) {
+	return core.ReadPrivateLogs(b.cpc.chainDb, hash), nil
+}
+
 func (b *APIBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
 	state.SetBalance(msg.From(), math.MaxBig256)
 	vmError := func() error { return nil }
@@ -168,6 +194,10 @@ func (b *APIBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscripti
 	return b.cpc.BlockChain().SubscribeLogsEvent(ch)
 }
 
+func (b *APIBackend) SubscribePrivateLogsEvent(ch chan<- core.PrivateLogsEvent) event.Subscription {
+	return b.cpc.BlockChain().SubscribePrivateLogsEvent(ch)
+}
+
 func (b *APIBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
 	return b.cpc.txPool.AddLocal(signedTx)
 }
@@ -304,6 +334,10 @@ func (b *APIBackend) Validators(blockNr rpc.BlockNumber) ([]common.Address, erro
 	return api[0].Service.(*dpor.API).GetValidators(blockNr)
 }
 
+func (b *APIBackend) PrivacyGroups() *private.GroupStore {
+	return b.cpc.privacyGroups
+}
+
 func (b *APIBackend) SupportPrivateTx(ctx context.Context) (bool, error) {
 	return core.SupportPrivateTx(b.cpc.blockchain), nil
 }
@@ -27,10 +27,12 @@ import (
 	"bitbucket.org/cpchain/chain/accounts"
 	"bitbucket.org/cpchain/chain/admission"
 	"bitbucket.org/cpchain/chain/api/rpc"
+	"bitbucket.org/cpchain/chain/commons/crypto/blskey"
 	"bitbucket.org/cpchain/chain/commons/log"
 	"bitbucket.org/cpchain/chain/configs"
 	"bitbucket.org/cpchain/chain/consensus"
 	"bitbucket.org/cpchain/chain/consensus/dpor"
+	"bitbucket.org/cpchain/chain/consensus/dpor/signer"
 	"bitbucket.org/cpchain/chain/contracts/dpor/primitive_register"
 	"bitbucket.org/cpchain/chain/contracts/dpor/rpt_backend_holder"
 	"bitbucket.org/cpchain/chain/core"
@@ -89,6 +91,8 @@ type CpchainService struct {
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // LogsBloom indexer operating during block imports
 
+	participationIndexer *core.ChainIndexer // Dpor committee participation indexer operating during block imports
+
 	// chain service backend
 	APIBackend          *APIBackend
 	AdmissionApiBackend admission.ApiBackend
@@ -103,6 +107,10 @@ type CpchainService struct {
 	lock sync.RWMutex // Protects the variadic fields (e.g. gas price and coinbase)
 
 	remoteDB database.RemoteDatabase // remoteDB represents an remote distributed database.
+
+	privacyGroups *private.GroupStore // privacyGroups persists the privacy groups of private transactions.
+
+	remoteSigner *signer.RemoteSigner // remoteSigner signs seals and signatures instead of local keystore if configured.
 }
 
 func (s *CpchainService) AddLesServer(ls LesServer) {
@@ -124,18 +132,11 @@ func New(ctx *node.ServiceContext, config *Config) (*CpchainService, error) {
 	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
-	var remoteDB database.RemoteDatabase
-	switch config.PrivateTx.RemoteDBType {
-	case private.IPFS:
-		remoteDB = database.NewIpfsDB(config.PrivateTx.RemoteDBParams)
-		log.Info("Initialize remote database", "database", "IPFS")
-	case private.Dummy:
-		remoteDB = new(database.DummyDatabase)
-		log.Info("Initialize remote database", "database", "Dummy")
-	default:
-		remoteDB = database.NewIpfsDB(private.DefaultIpfsUrl)
-		log.Info("Initialize remote database", "database", "IPFS")
+	remoteDB, err := private.NewRemoteDatabase(config.PrivateTx, ctx.ResolvePath)
+	if err != nil {
+		return nil, err
 	}
+	log.Info("Initialize remote database", "database", config.PrivateTx.RemoteDBType, "params", config.PrivateTx.RemoteDBParams)
 
 	cpc := &CpchainService{
 		config:         config,
@@ -150,6 +151,14 @@ func New(ctx *node.ServiceContext, config *Config) (*CpchainService, error) {
 		bloomRequests:  make(chan chan *bloombits.Retrieval),
 		bloomIndexer:   NewBloomIndexer(chainDb, configs.BloomBitsBlocks),
 		remoteDB:       remoteDB,
+		privacyGroups:  private.NewGroupStore(chainDb),
+	}
+
+	if config.Signer != "" {
+		if cpc.remoteSigner, err = signer.DialRemote(config.Signer); err != nil {
+			return nil, err
+		}
+		log.Info("Initialize remote signer", "url", config.Signer)
 	}
 
 	cpc.engine = cpc.CreateConsensusEngine(ctx, chainConfig, chainDb)
@@ -214,6 +223,18 @@ func New(ctx *node.ServiceContext, config *Config) (*CpchainService, error) {
 	}
 	cpc.bloomIndexer.Start(cpc.blockchain)
 
+	if chainConfig.Dpor != nil {
+		var (
+			validators   = cpc.blockchain.Genesis().Header().Dpor.Validators
+			validatorsOf func(number uint64) ([]common.Address, error)
+		)
+		if dpor, ok := cpc.engine.(*dpor.Dpor); ok {
+			validatorsOf = dpor.ValidatorsOf
+		}
+		cpc.participationIndexer = NewParticipationIndexer(chainDb, chainConfig.Dpor, validators, validatorsOf)
+		cpc.participationIndexer.Start(cpc.blockchain)
+	}
+
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
@@ -263,19 +284,47 @@ func (s *CpchainService) CreateConsensusEngine(ctx *node.ServiceContext, chainCo
 	if chainConfig.Dpor != nil {
 		// TODO: fix this. @liuq
 		dpor := dpor.New(chainConfig.Dpor, db)
+		if s.config.ConsensusTrace != "" {
+			dpor.SetConsensusTrace(ctx.ResolvePath(s.config.ConsensusTrace))
+		}
+		if s.config.BLSKey != "" {
+			key, err := blskey.LoadSecretKey(ctx.ResolvePath(s.config.BLSKey))
+			if err != nil {
+				log.Error("Failed to load bls key", "file", s.config.BLSKey, "err", err)
+				return nil
+			}
+			dpor.SetBLSKey(key)
+		}
 		if eb != (common.Address{}) {
-			wallet, err := s.accountManager.Find(accounts.Account{Address: eb})
-			if wallet == nil || err != nil {
-				log.Error("Etherbase account unavailable locally", "err", err)
+			if err := s.authorize(dpor, eb); err != nil {
 				return nil
 			}
-			dpor.Authorize(eb, wallet.SignHash)
 		}
 		return dpor
 	}
 	return nil
 }
 
+// authorize authorizes the dpor engine to sign with coinbase, by the remote signer
+// if it is configured, or by the local wallet of coinbase otherwise.
+func (s *CpchainService) authorize(engine *dpor.Dpor, coinbase common.Address) error {
+	if s.remoteSigner != nil {
+		engine.AuthorizeSigner(coinbase, s.remoteSigner)
+		return nil
+	}
+
+	wallet, err := s.accountManager.Find(accounts.Account{Address: coinbase})
+	if wallet == nil || err != nil {
+		log.Error("Etherbase account unavailable locally", "err", err)
+		if err == nil {
+			err = accounts.ErrUnknownAccount
+		}
+		return err
+	}
+	engine.Authorize(coinbase, wallet.SignHash)
+	return nil
+}
+
 // APIs return the collection of RPC services the cpc package offers.
 // NOTE, some of these services probably need to be moved to somewhere else.
 func (s *CpchainService) APIs() []rpc.API {
@@ -287,6 +336,16 @@ func (s *CpchainService) APIs() []rpc.API {
 	// Append any APIs exposed explicitly by the admission control
 	apis = append(apis, s.AdmissionApiBackend.Apis()...)
 
+	// Append the participation stats of dpor committees
+	if s.participationIndexer != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "dpor",
+			Version:   "1.0",
+			Service:   NewPublicParticipationAPI(s.chainDb, s.participationIndexer),
+			Public:    true,
+		})
+	}
+
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -397,12 +456,9 @@ func (s *CpchainService) StartMining(local bool) error {
 		}
 
 		if dpor.Coinbase() != coinbase {
-			wallet, err := s.accountManager.Find(accounts.Account{Address: coinbase})
-			if wallet == nil || err != nil {
-				log.Error("Etherbase account unavailable locally", "err", err)
+			if err := s.authorize(dpor, coinbase); err != nil {
 				return nil
 			}
-			dpor.Authorize(coinbase, wallet.SignHash)
 		}
 
 		log.Debug("server.nodeid", "enode", s.server.NodeInfo().Enode)
@@ -500,6 +556,11 @@ func (s *CpchainService) Start(srvr *p2p.Server) error {
 	// Start the bloom bits servicing goroutines
 	s.startBloomHandlers()
 
+	// Start posting consensus events of new blocks
+	if dpor, ok := s.engine.(*dpor.Dpor); ok {
+		go s.consensusEventLoop(dpor)
+	}
+
 	// Start the RPC service
 	s.netRPCService = cpcapi.NewPublicNetAPI(srvr, s.NetVersion())
 
@@ -525,10 +586,32 @@ func (s *CpchainService) Start(srvr *p2p.Server) error {
 	return nil
 }
 
+// consensusEventLoop notifies the dpor engine of every new canonical block to
+// post consensus events.
+func (s *CpchainService) consensusEventLoop(engine *dpor.Dpor) {
+	events := make(chan core.ChainEvent, 10)
+	sub := s.blockchain.SubscribeChainEvent(events)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-events:
+			engine.NotifyChainHead(ev.Block.Header())
+		case <-sub.Err():
+			return
+		case <-s.shutdownChan:
+			return
+		}
+	}
+}
+
 // Stop implements node.Service, terminating all internal goroutines used by the
 // cpchain protocol.
 func (s *CpchainService) Stop() error {
 	s.bloomIndexer.Close()
+	if s.participationIndexer != nil {
+		s.participationIndexer.Close()
+	}
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
 	if s.lesServer != nil {
@@ -538,6 +621,10 @@ func (s *CpchainService) Stop() error {
 	s.miner.Stop()
 	s.eventMux.Stop()
 
+	if s.remoteSigner != nil {
+		s.remoteSigner.Close()
+	}
+
 	s.chainDb.Close()
 	close(s.shutdownChan)
 
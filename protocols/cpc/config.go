@@ -86,6 +86,15 @@ type Config struct {
 	ExtraData    []byte         `toml:",omitempty"`
 	GasPrice     *big.Int
 
+	// Url of the external signer to sign seals and signatures with, instead of the local keystore
+	Signer string `toml:",omitempty"`
+
+	// File to record consensus msgs and state transitions into, no trace if empty
+	ConsensusTrace string `toml:",omitempty"`
+
+	// File of the bls key to sign with as a validator after the BLS fork
+	BLSKey string `toml:",omitempty"`
+
 	// Transaction pool options
 	TxPool core.TxPoolConfig
 
@@ -96,19 +96,12 @@ func New(backend Backend, begin, end int64, addresses []common.Address, topics [
 // first block that contains matches, updating the start of the filter accordingly.
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	// Figure out the limits of the filter range
-	header, _ := f.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
-	if header == nil {
+	begin, end, ok := blockRange(ctx, f.backend, f.begin, f.end)
+	if !ok {
 		return nil, nil
 	}
-	head := header.Number.Uint64()
+	f.begin = begin
 
-	if f.begin == -1 {
-		f.begin = int64(head)
-	}
-	end := uint64(f.end)
-	if f.end == -1 {
-		end = head
-	}
 	// Gather all indexed logs, and finish with non indexed ones
 	var (
 		logs []*types.Log
@@ -130,6 +123,48 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	return logs, err
 }
 
+// headerReader retrieves headers by block number or tag.
+type headerReader interface {
+	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
+}
+
+// blockRange resolves the tags of a block range to block numbers, ok is false if
+// there is no header to resolve them to yet.
+func blockRange(ctx context.Context, headers headerReader, begin, end int64) (int64, uint64, bool) {
+	header, _ := headers.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if header == nil {
+		return 0, 0, false
+	}
+	head := header.Number.Uint64()
+
+	// Resolve the finality tags to the finalized head
+	if isFinalityTag(begin) || isFinalityTag(end) {
+		finalized, _ := headers.HeaderByNumber(ctx, rpc.FinalizedBlockNumber)
+		if finalized == nil {
+			return 0, 0, false
+		}
+		if isFinalityTag(begin) {
+			begin = finalized.Number.Int64()
+		}
+		if isFinalityTag(end) {
+			end = finalized.Number.Int64()
+		}
+	}
+
+	if begin == -1 {
+		begin = int64(head)
+	}
+	if end == -1 {
+		return begin, head, true
+	}
+	return begin, uint64(end), true
+}
+
+// isFinalityTag reports whether the block number is the "finalized" or "safe" tag.
+func isFinalityTag(number int64) bool {
+	return number == rpc.FinalizedBlockNumber.Int64() || number == rpc.SafeBlockNumber.Int64()
+}
+
 // indexedLogs returns the logs matching the filter criteria based on the bloom
 // bits indexed available locally or via the network.
 func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
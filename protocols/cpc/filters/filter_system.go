@@ -208,6 +208,10 @@ func (es *EventSystem) SubscribeLogs(crit cpchain.FilterQuery, logs chan []*type
 		to = rpc.BlockNumber(crit.ToBlock.Int64())
 	}
 
+	// finality tags are only meaningful for a one shot query
+	if from < rpc.PendingBlockNumber || to < rpc.PendingBlockNumber {
+		return nil, fmt.Errorf("finalized and safe block tags are not supported in log subscriptions")
+	}
 	// only interested in pending logs
 	if from == rpc.PendingBlockNumber && to == rpc.PendingBlockNumber {
 		return es.subscribePendingLogs(crit, logs), nil
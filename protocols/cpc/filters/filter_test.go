@@ -23,6 +23,7 @@ import (
 	"os"
 	"testing"
 
+	"bitbucket.org/cpchain/chain/api/rpc"
 	"bitbucket.org/cpchain/chain/configs"
 	"bitbucket.org/cpchain/chain/consensus/dpor"
 	"bitbucket.org/cpchain/chain/core"
@@ -238,4 +239,85 @@ func TestFilters(t *testing.T) {
 	if len(logs) != 0 {
 		t.Error("expected 0 log, got", len(logs))
 	}
+
+	// only blocks up to the finalized head are searched
+	rawdb.WriteHeadFinalizedBlockHash(db, chain[2].Hash())
+	filter = New(backend, 0, rpc.FinalizedBlockNumber.Int64(), []common.Address{addr}, [][]common.Hash{{hash1, hash2, hash3, hash4}})
+
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 2 {
+		t.Error("expected 2 log, got", len(logs))
+	}
+
+	filter = New(backend, rpc.SafeBlockNumber.Int64(), -1, []common.Address{addr}, [][]common.Hash{{hash3}})
+
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 1 {
+		t.Error("expected 1 log, got", len(logs))
+	}
+}
+
+func TestPrivateLogs(t *testing.T) {
+	var (
+		db      = database.NewMemDatabase()
+		backend = &testBackend{new(event.TypeMux), db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed)}
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key1.PublicKey)
+
+		pubHash  = common.BytesToHash([]byte("public"))
+		privHash = common.BytesToHash([]byte("private"))
+	)
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+
+	config := configs.ChainConfigInfo().Dpor
+	d := dpor.NewFaker(config, db)
+	chain, receipts := core.GenerateChain(configs.TestChainConfig, genesis, d, db, database.NewIpfsDbWithAdapter(database.NewFakeIpfsAdapter()), 10, func(i int, gen *core.BlockGen) {
+		if i == 2 {
+			receipt := types.NewReceipt(nil, false, 0)
+			receipt.Logs = []*types.Log{{Address: addr, Topics: []common.Hash{pubHash}, BlockNumber: 3}}
+			gen.AddUncheckedReceipt(receipt)
+		}
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+
+		// blocks 2 and 4 carry a private transaction emitting a log
+		if block.NumberU64() == 2 || block.NumberU64() == 4 {
+			receipt := types.NewReceipt(nil, false, 0)
+			receipt.Logs = []*types.Log{{Address: addr, Topics: []common.Hash{privHash}}}
+			if err := core.WritePrivateLogs(db, block.Hash(), block.NumberU64(), []*types.Receipt{receipt}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if types.BloomLookup(block.Header().LogsBloom, privHash) {
+			t.Fatalf("private log leaked into the bloom of block %d", block.NumberU64())
+		}
+	}
+
+	// public filters never return private logs
+	filter := New(backend, 0, -1, []common.Address{addr}, nil)
+	logs, _ := filter.Logs(context.Background())
+	if len(logs) != 1 || logs[0].Topics[0] != pubHash {
+		t.Fatal("expected 1 public log, got", len(logs))
+	}
+
+	logs, err := PrivateLogs(context.Background(), backend, FilterCriteria{FromBlock: big.NewInt(0), Topics: [][]common.Hash{{privHash}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 {
+		t.Fatal("expected 2 private logs, got", len(logs))
+	}
+	for i, number := range []uint64{2, 4} {
+		if logs[i].BlockNumber != number || logs[i].BlockHash != chain[number-1].Hash() {
+			t.Errorf("log %d: have block %d %x, want block %d %x", i, logs[i].BlockNumber, logs[i].BlockHash, number, chain[number-1].Hash())
+		}
+	}
+	logs, _ = PrivateLogs(context.Background(), backend, FilterCriteria{FromBlock: big.NewInt(3), ToBlock: big.NewInt(3)})
+	if len(logs) != 0 {
+		t.Error("expected no private logs in block 3, got", len(logs))
+	}
 }
@@ -0,0 +1,68 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"math/big"
+
+	"bitbucket.org/cpchain/chain/api/rpc"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PrivateBackend retrieves the logs of private transactions this node participates
+// in. They are indexed separately from public logs so that public blooms never
+// reveal them, and are only served by the privacy group API.
+type PrivateBackend interface {
+	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
+	GetPrivateLogs(ctx context.Context, blockHash common.Hash) ([]*types.Log, error)
+}
+
+// PrivateLogs returns the logs of private transactions matching the given criteria.
+// They are never added into blooms, so every block in range is checked.
+func PrivateLogs(ctx context.Context, backend PrivateBackend, crit FilterCriteria) ([]*types.Log, error) {
+	if crit.FromBlock == nil {
+		crit.FromBlock = big.NewInt(rpc.LatestBlockNumber.Int64())
+	}
+	if crit.ToBlock == nil {
+		crit.ToBlock = big.NewInt(rpc.LatestBlockNumber.Int64())
+	}
+	begin, end, ok := blockRange(ctx, backend, crit.FromBlock.Int64(), crit.ToBlock.Int64())
+	if !ok {
+		return nil, nil
+	}
+
+	var logs []*types.Log
+	for ; begin <= int64(end); begin++ {
+		header, err := backend.HeaderByNumber(ctx, rpc.BlockNumber(begin))
+		if header == nil || err != nil {
+			return logs, err
+		}
+		unfiltered, err := backend.GetPrivateLogs(ctx, header.Hash())
+		if err != nil {
+			return logs, err
+		}
+		logs = append(logs, filterLogs(unfiltered, nil, nil, crit.Addresses, crit.Topics)...)
+	}
+	return logs, nil
+}
+
+// FilterLogs returns the logs matching the given criteria.
+func FilterLogs(logs []*types.Log, crit FilterCriteria) []*types.Log {
+	return filterLogs(logs, crit.FromBlock, crit.ToBlock, crit.Addresses, crit.Topics)
+}
@@ -32,6 +32,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		MinerThreads            int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
+		Signer                  string `toml:",omitempty"`
+		ConsensusTrace          string `toml:",omitempty"`
+		BLSKey                  string `toml:",omitempty"`
 		TxPool                  core.TxPoolConfig
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
@@ -53,6 +56,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.MinerThreads = c.MinerThreads
 	enc.ExtraData = c.ExtraData
 	enc.GasPrice = c.GasPrice
+	enc.Signer = c.Signer
+	enc.ConsensusTrace = c.ConsensusTrace
+	enc.BLSKey = c.BLSKey
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
@@ -78,6 +84,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		MinerThreads            *int            `toml:",omitempty"`
 		ExtraData               *hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
+		Signer                  *string `toml:",omitempty"`
+		ConsensusTrace          *string `toml:",omitempty"`
+		BLSKey                  *string `toml:",omitempty"`
 		TxPool                  *core.TxPoolConfig
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
@@ -130,6 +139,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.GasPrice != nil {
 		c.GasPrice = dec.GasPrice
 	}
+	if dec.Signer != nil {
+		c.Signer = *dec.Signer
+	}
+	if dec.ConsensusTrace != nil {
+		c.ConsensusTrace = *dec.ConsensusTrace
+	}
+	if dec.BLSKey != nil {
+		c.BLSKey = *dec.BLSKey
+	}
 	if dec.TxPool != nil {
 		c.TxPool = *dec.TxPool
 	}
@@ -0,0 +1,188 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package cpc
+
+import (
+	"errors"
+	"time"
+
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/core"
+	"bitbucket.org/cpchain/chain/core/rawdb"
+	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// participationConfirms is the number of confirmation blocks before a term
+	// section is indexed. Dpor blocks are final once inserted, one is enough.
+	participationConfirms = 1
+
+	// participationThrottling is the time to wait between processing two consecutive
+	// index sections.
+	participationThrottling = 100 * time.Millisecond
+)
+
+var (
+	errTermNotIndexed = errors.New("the term is not indexed yet")
+)
+
+// ParticipationIndexer implements a core.ChainIndexer, collecting per term which
+// proposers produced or were impeached and which validators' signatures are missing.
+//
+// A section is as long as a term, but since block 0 is the genesis, the last block
+// of term n falls into section n+1. So a section holds the stats of two terms.
+//
+// Impeach blocks only carry the signatures of f+1 validators, so they are not
+// counted in the signing stats of validators.
+type ParticipationIndexer struct {
+	termSize     uint64                                        // number of blocks in a term
+	viewLen      uint64                                        // number of blocks a proposer proposes in a term
+	validatorsOf func(number uint64) ([]common.Address, error) // validators committee of a block, the order is that of the signatures in headers
+	validators   []common.Address                              // last known validators committee, used if validatorsOf does not know the committee of a block
+
+	db      database.Database          // database instance to write index data into
+	section uint64                     // section number being processed currently
+	stats   []*types.TermParticipation // stats of the terms in current section
+}
+
+// NewParticipationIndexer returns a chain indexer that collects the participation
+// stats of the dpor committee for each term. Validators of a block are read with
+// validatorsOf, the given validators are used until it knows any committee.
+func NewParticipationIndexer(db database.Database, config *configs.DporConfig, validators []common.Address, validatorsOf func(number uint64) ([]common.Address, error)) *core.ChainIndexer {
+	backend := &ParticipationIndexer{
+		termSize:     config.TermLen * config.ViewLen,
+		viewLen:      config.ViewLen,
+		validatorsOf: validatorsOf,
+		validators:   validators,
+		db:           db,
+	}
+	table := database.NewTable(db, string(rawdb.ParticipationIndexPrefix))
+
+	return core.NewChainIndexer(db, table, backend, backend.termSize, participationConfirms, participationThrottling, "participation")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new participation section.
+func (b *ParticipationIndexer) Reset(section uint64, lastSectionHead common.Hash) error {
+	b.section, b.stats = section, nil
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the participation of a new
+// header into the stats of its term.
+func (b *ParticipationIndexer) Process(header *types.Header) {
+	number := header.Number.Uint64()
+	if number == 0 {
+		return
+	}
+
+	term := (number - 1) / b.termSize
+	if len(b.stats) == 0 || b.stats[len(b.stats)-1].Term != term {
+		b.stats = append(b.stats, &types.TermParticipation{Term: term})
+	}
+	stats := b.stats[len(b.stats)-1]
+	stats.Blocks++
+
+	if header.Impeachment() {
+		// impeach blocks are generated in place of the proposer in charge of the view
+		idx := int((number - 1) % b.termSize / b.viewLen)
+		if idx < len(header.Dpor.Proposers) {
+			stats.Proposer(header.Dpor.Proposers[idx]).Impeached++
+		}
+		return
+	}
+	stats.Proposer(header.Coinbase).Proposed++
+
+	for i, validator := range b.committeeOf(number) {
+		validatorStats := stats.Validator(validator)
+		if header.Dpor.SignedBy(i) {
+			validatorStats.Signed++
+		} else {
+			validatorStats.Missed++
+		}
+	}
+}
+
+// committeeOf returns the validators committee of the given block number, falling
+// back to the last known one if validatorsOf does not know it.
+func (b *ParticipationIndexer) committeeOf(number uint64) []common.Address {
+	if b.validatorsOf != nil {
+		if validators, err := b.validatorsOf(number); err == nil && len(validators) != 0 {
+			b.validators = validators
+		}
+	}
+	return b.validators
+}
+
+// Commit implements core.ChainIndexerBackend, writing the stats of the section
+// into the database.
+func (b *ParticipationIndexer) Commit() error {
+	batch := b.db.NewBatch()
+	rawdb.WriteParticipation(batch, b.section, b.stats)
+	return batch.Write()
+}
+
+// PublicParticipationAPI provides the participation stats of dpor committees.
+type PublicParticipationAPI struct {
+	db      database.Database
+	indexer *core.ChainIndexer
+}
+
+// NewPublicParticipationAPI creates a new PublicParticipationAPI reading stats
+// indexed by the given participation indexer.
+func NewPublicParticipationAPI(db database.Database, indexer *core.ChainIndexer) *PublicParticipationAPI {
+	return &PublicParticipationAPI{db: db, indexer: indexer}
+}
+
+// GetProposerStats returns how many blocks each proposer produced or was
+// impeached for in the given term.
+func (api *PublicParticipationAPI) GetProposerStats(term uint64) ([]*types.ProposerStats, error) {
+	stats, err := api.termParticipation(term)
+	if err != nil {
+		return nil, err
+	}
+	return stats.Proposers, nil
+}
+
+// GetValidatorParticipation returns how many blocks each validator signed or
+// missed in the given term.
+func (api *PublicParticipationAPI) GetValidatorParticipation(term uint64) ([]*types.ValidatorParticipation, error) {
+	stats, err := api.termParticipation(term)
+	if err != nil {
+		return nil, err
+	}
+	return stats.Validators, nil
+}
+
+// termParticipation merges the stats of the term from the sections covering it.
+func (api *PublicParticipationAPI) termParticipation(term uint64) (*types.TermParticipation, error) {
+	sections, _, _ := api.indexer.Sections()
+
+	stats := &types.TermParticipation{Term: term}
+	for section := term; section <= term+1 && section < sections; section++ {
+		for _, s := range rawdb.ReadParticipation(api.db, section) {
+			if s.Term == term {
+				stats.Merge(s)
+			}
+		}
+	}
+	if stats.Blocks == 0 {
+		return nil, errTermNotIndexed
+	}
+	return stats, nil
+}
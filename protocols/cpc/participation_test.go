@@ -0,0 +1,125 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package cpc
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"bitbucket.org/cpchain/chain/configs"
+	"bitbucket.org/cpchain/chain/core"
+	"bitbucket.org/cpchain/chain/core/rawdb"
+	"bitbucket.org/cpchain/chain/database"
+	"bitbucket.org/cpchain/chain/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestParticipationIndexer(t *testing.T) {
+	var (
+		db         = database.NewMemDatabase()
+		proposers  = []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}
+		validators = []common.Address{common.HexToAddress("0x11"), common.HexToAddress("0x12"), common.HexToAddress("0x13")}
+		elected    = []common.Address{common.HexToAddress("0x12"), common.HexToAddress("0x13"), common.HexToAddress("0x14")}
+		config     = &configs.DporConfig{TermLen: 2, ViewLen: 1}
+	)
+
+	newHeader := func(number int64, coinbase common.Address, signed ...int) *types.Header {
+		header := &types.Header{Number: big.NewInt(number), Coinbase: coinbase}
+		header.Dpor.Proposers = proposers
+		header.Dpor.Sigs = make([]types.DporSignature, len(validators))
+		for _, i := range signed {
+			header.Dpor.Sigs[i][0] = 1
+		}
+		return header
+	}
+
+	// the committee of term 0 is unknown, the genesis one is used, term 1 is of another committee
+	validatorsOf := func(number uint64) ([]common.Address, error) {
+		if config.TermOf(number) == 1 {
+			return elected, nil
+		}
+		return nil, nil
+	}
+	backend := &ParticipationIndexer{termSize: 2, viewLen: 1, validatorsOf: validatorsOf, validators: validators, db: db}
+	indexer := core.NewChainIndexer(db, database.NewTable(db, string(rawdb.ParticipationIndexPrefix)), backend, config.TermLen*config.ViewLen, participationConfirms, participationThrottling, "participation")
+	defer indexer.Close()
+
+	// term 0 is block 1 and 2, block 2 is an impeach block generated in place of the second proposer
+	sections := [][]*types.Header{
+		{newHeader(0, common.Address{}), newHeader(1, proposers[0], 0, 1)},
+		{newHeader(2, common.Address{}, 0, 1, 2), newHeader(3, proposers[0], 2)},
+	}
+	for section, headers := range sections {
+		if err := backend.Reset(uint64(section), common.Hash{}); err != nil {
+			t.Fatal(err)
+		}
+		for _, header := range headers {
+			backend.Process(header)
+		}
+		if err := backend.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		indexer.AddKnownSectionHead(uint64(section), headers[len(headers)-1].Hash())
+	}
+
+	api := NewPublicParticipationAPI(db, indexer)
+
+	proposerStats, err := api.GetProposerStats(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantProposers := []*types.ProposerStats{
+		{Address: proposers[0], Proposed: 1},
+		{Address: proposers[1], Impeached: 1},
+	}
+	if !reflect.DeepEqual(proposerStats, wantProposers) {
+		t.Errorf("proposer stats mismatch: got %+v, want %+v", proposerStats, wantProposers)
+	}
+
+	participation, err := api.GetValidatorParticipation(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the impeach block only carries f+1 signatures, it is not counted
+	wantValidators := []*types.ValidatorParticipation{
+		{Address: validators[0], Signed: 1},
+		{Address: validators[1], Signed: 1},
+		{Address: validators[2], Missed: 1},
+	}
+	if !reflect.DeepEqual(participation, wantValidators) {
+		t.Errorf("validator participation mismatch: got %+v, want %+v", participation, wantValidators)
+	}
+
+	// term 1 is partially indexed, block 4 is in the next section
+	participation, err = api.GetValidatorParticipation(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantValidators = []*types.ValidatorParticipation{
+		{Address: elected[0], Missed: 1},
+		{Address: elected[1], Missed: 1},
+		{Address: elected[2], Signed: 1},
+	}
+	if !reflect.DeepEqual(participation, wantValidators) {
+		t.Errorf("validator participation of a partial term mismatch: got %+v, want %+v", participation, wantValidators)
+	}
+
+	if _, err := api.GetProposerStats(2); err != errTermNotIndexed {
+		t.Errorf("stats of an unindexed term should fail with %v, got %v", errTermNotIndexed, err)
+	}
+}
@@ -0,0 +1,24 @@
+package syncer
+
+import (
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// Metrics are registered on first use rather than at package initialization,
+// which happens before the command line enables the metrics system.
+
+func syncStartMeter() metrics.Meter {
+	return metrics.GetOrRegisterMeter("cpc/syncer/start", nil)
+}
+
+func syncFailMeter() metrics.Meter {
+	return metrics.GetOrRegisterMeter("cpc/syncer/fail", nil)
+}
+
+func syncTimer() metrics.Timer {
+	return metrics.GetOrRegisterTimer("cpc/syncer/duration", nil)
+}
+
+func syncBlocksMeter() metrics.Meter {
+	return metrics.GetOrRegisterMeter("cpc/syncer/blocks/in", nil)
+}
@@ -284,8 +284,11 @@ func (s *Synchronizer) RemovePeer(peer string) error {
 
 func (s *Synchronizer) Synchronise(p SyncPeer, head common.Hash, height *big.Int, mode SyncMode) (err error) {
 	s.mux.Post(StartEvent{})
+	syncStartMeter().Mark(1)
+	defer syncTimer().UpdateSince(time.Now())
 	defer func() {
 		if err != nil {
+			syncFailMeter().Mark(1)
 			s.mux.Post(FailedEvent{err})
 		} else {
 			s.mux.Post(DoneEvent{})
@@ -364,11 +367,13 @@ func (s *Synchronizer) blocksHandler(errCh chan error, successCh chan struct{},
 		s.progressLock.Unlock()
 
 		if mode == FullSync {
-			if _, err := s.blockchain.InsertChain(task.data.(types.Blocks)); err != nil {
+			blocks := task.data.(types.Blocks)
+			if _, err := s.blockchain.InsertChain(blocks); err != nil {
 				log.Debug("insert chain", "err", err)
 				errCh <- err
 				return
 			}
+			syncBlocksMeter().Mark(int64(len(blocks)))
 		} else {
 			var start = time.Now()
 			data := task.data.(blocksWithReceipts)
@@ -388,6 +393,7 @@ func (s *Synchronizer) blocksHandler(errCh chan error, successCh chan struct{},
 				errCh <- err
 				return
 			}
+			syncBlocksMeter().Mark(int64(len(blocksNew)))
 			// send metrics msg to monitor(prometheus)
 			if chainmetrics.NeedMetrics() {
 				go chainmetrics.ReportBlockNumberGauge("blocknumber", float64(s.blockchain.CurrentFastBlock().NumberU64()))
@@ -76,6 +76,38 @@ macro fsm(v, inputType, input) begin
             v.commitSig := {};
             v.state := 9;
         end if;
+
+    or  \* idle, prepare or commit state
+    \* transfer to impeach prepare state given an impeach block
+        await v.state \in {0, 1, 2};
+        await inputType = "impeachBlock";
+        v.impeachPrepareSig := {v.sig};
+        v.state := 3;
+
+    or  \* impeach prepare state
+        await v.state = 3;
+        await inputType = "impeachPrepareMsg";
+        \* accumulate impeach prepare signatures
+        v.impeachPrepareSig := v.impeachPrepareSig \union input.impeachPrepareSig;
+        if impeachPrepareCertificate(v)
+        then
+        \* transfer to impeach commit state if collect a certificate
+            v.impeachPrepareSig := {};
+            v.impeachCommitSig := {v.sig};
+            v.state := 4;
+        end if;
+
+    or  \* impeach commit state
+        await v.state = 4;
+        await inputType = "impeachCommitMsg";
+        \* accumulate impeach commit signatures
+        v.impeachCommitSig := v.impeachCommitSig \union input.impeachCommitSig;
+        if impeachCommitCertificate(v)
+        then
+        \* transfer to idle state in next height given the certificate
+            v.impeachCommitSig := {};
+            v.state := 9;
+        end if;
     end either
 end macro;
 
@@ -142,18 +174,34 @@ Lbl_1 == /\ pc = "Lbl_1"
                /\ validators' = [validators EXCEPT ![1].prepareSig = (validators[1]).prepareSig \union "".prepareSig]
                /\ IF prepareCertificate((validators'[1]))
                      THEN /\ pc' = "Lbl_3"
-                     ELSE /\ pc' = "Lbl_8"
+                     ELSE /\ pc' = "Lbl_14"
             \/ /\ (validators[1]).state = 2
                /\ "block" = "commitMsg"
                /\ validators' = [validators EXCEPT ![1].commitSig = (validators[1]).commitSig \union "".commitSig]
                /\ IF commitCertificate((validators'[1]))
                      THEN /\ pc' = "Lbl_6"
-                     ELSE /\ pc' = "Lbl_8"
+                     ELSE /\ pc' = "Lbl_14"
+            \/ /\ (validators[1]).state \in {0, 1, 2}
+               /\ "block" = "impeachBlock"
+               /\ validators' = [validators EXCEPT ![1].impeachPrepareSig = {(validators[1]).sig}]
+               /\ pc' = "Lbl_8"
+            \/ /\ (validators[1]).state = 3
+               /\ "block" = "impeachPrepareMsg"
+               /\ validators' = [validators EXCEPT ![1].impeachPrepareSig = (validators[1]).impeachPrepareSig \union "".impeachPrepareSig]
+               /\ IF impeachPrepareCertificate((validators'[1]))
+                     THEN /\ pc' = "Lbl_9"
+                     ELSE /\ pc' = "Lbl_14"
+            \/ /\ (validators[1]).state = 4
+               /\ "block" = "impeachCommitMsg"
+               /\ validators' = [validators EXCEPT ![1].impeachCommitSig = (validators[1]).impeachCommitSig \union "".impeachCommitSig]
+               /\ IF impeachCommitCertificate((validators'[1]))
+                     THEN /\ pc' = "Lbl_12"
+                     ELSE /\ pc' = "Lbl_14"
          /\ UNCHANGED proposers
 
 Lbl_2 == /\ pc = "Lbl_2"
          /\ validators' = [validators EXCEPT ![1].state = 1]
-         /\ pc' = "Lbl_8"
+         /\ pc' = "Lbl_14"
          /\ UNCHANGED proposers
 
 Lbl_3 == /\ pc = "Lbl_3"
@@ -168,7 +216,7 @@ Lbl_4 == /\ pc = "Lbl_4"
 
 Lbl_5 == /\ pc = "Lbl_5"
          /\ validators' = [validators EXCEPT ![1].state = 2]
-         /\ pc' = "Lbl_8"
+         /\ pc' = "Lbl_14"
          /\ UNCHANGED proposers
 
 Lbl_6 == /\ pc = "Lbl_6"
@@ -178,152 +226,320 @@ Lbl_6 == /\ pc = "Lbl_6"
 
 Lbl_7 == /\ pc = "Lbl_7"
          /\ validators' = [validators EXCEPT ![1].state = 9]
-         /\ pc' = "Lbl_8"
+         /\ pc' = "Lbl_14"
          /\ UNCHANGED proposers
 
 Lbl_8 == /\ pc = "Lbl_8"
-         /\ \/ /\ (validators[2]).state = 0
-               /\ "block" = "block"
-               /\ validators' = [validators EXCEPT ![2].prepareSig = {(validators[2]).sig}]
-               /\ pc' = "Lbl_9"
-            \/ /\ (validators[2]).state = 1
-               /\ "block" = "prepareMsg"
-               /\ validators' = [validators EXCEPT ![2].prepareSig = (validators[2]).prepareSig \union "".prepareSig]
-               /\ IF prepareCertificate((validators'[2]))
-                     THEN /\ pc' = "Lbl_10"
-                     ELSE /\ pc' = "Lbl_15"
-            \/ /\ (validators[2]).state = 2
-               /\ "block" = "commitMsg"
-               /\ validators' = [validators EXCEPT ![2].commitSig = (validators[2]).commitSig \union "".commitSig]
-               /\ IF commitCertificate((validators'[2]))
-                     THEN /\ pc' = "Lbl_13"
-                     ELSE /\ pc' = "Lbl_15"
+         /\ validators' = [validators EXCEPT ![1].state = 3]
+         /\ pc' = "Lbl_14"
          /\ UNCHANGED proposers
 
 Lbl_9 == /\ pc = "Lbl_9"
-         /\ validators' = [validators EXCEPT ![2].state = 1]
-         /\ pc' = "Lbl_15"
+         /\ validators' = [validators EXCEPT ![1].impeachPrepareSig = {}]
+         /\ pc' = "Lbl_10"
          /\ UNCHANGED proposers
 
 Lbl_10 == /\ pc = "Lbl_10"
-          /\ validators' = [validators EXCEPT ![2].prepareSig = {}]
+          /\ validators' = [validators EXCEPT ![1].impeachCommitSig = {(validators[1]).sig}]
           /\ pc' = "Lbl_11"
           /\ UNCHANGED proposers
 
 Lbl_11 == /\ pc = "Lbl_11"
-          /\ validators' = [validators EXCEPT ![2].commitSig = {(validators[2]).sig}]
-          /\ pc' = "Lbl_12"
+          /\ validators' = [validators EXCEPT ![1].state = 4]
+          /\ pc' = "Lbl_14"
           /\ UNCHANGED proposers
 
 Lbl_12 == /\ pc = "Lbl_12"
-          /\ validators' = [validators EXCEPT ![2].state = 2]
-          /\ pc' = "Lbl_15"
+          /\ validators' = [validators EXCEPT ![1].impeachCommitSig = {}]
+          /\ pc' = "Lbl_13"
           /\ UNCHANGED proposers
 
 Lbl_13 == /\ pc = "Lbl_13"
-          /\ validators' = [validators EXCEPT ![2].commitSig = {}]
+          /\ validators' = [validators EXCEPT ![1].state = 9]
           /\ pc' = "Lbl_14"
           /\ UNCHANGED proposers
 
 Lbl_14 == /\ pc = "Lbl_14"
-          /\ validators' = [validators EXCEPT ![2].state = 9]
-          /\ pc' = "Lbl_15"
+          /\ \/ /\ (validators[2]).state = 0
+                /\ "block" = "block"
+                /\ validators' = [validators EXCEPT ![2].prepareSig = {(validators[2]).sig}]
+                /\ pc' = "Lbl_15"
+             \/ /\ (validators[2]).state = 1
+                /\ "block" = "prepareMsg"
+                /\ validators' = [validators EXCEPT ![2].prepareSig = (validators[2]).prepareSig \union "".prepareSig]
+                /\ IF prepareCertificate((validators'[2]))
+                      THEN /\ pc' = "Lbl_16"
+                      ELSE /\ pc' = "Lbl_27"
+             \/ /\ (validators[2]).state = 2
+                /\ "block" = "commitMsg"
+                /\ validators' = [validators EXCEPT ![2].commitSig = (validators[2]).commitSig \union "".commitSig]
+                /\ IF commitCertificate((validators'[2]))
+                      THEN /\ pc' = "Lbl_19"
+                      ELSE /\ pc' = "Lbl_27"
+             \/ /\ (validators[2]).state \in {0, 1, 2}
+                /\ "block" = "impeachBlock"
+                /\ validators' = [validators EXCEPT ![2].impeachPrepareSig = {(validators[2]).sig}]
+                /\ pc' = "Lbl_21"
+             \/ /\ (validators[2]).state = 3
+                /\ "block" = "impeachPrepareMsg"
+                /\ validators' = [validators EXCEPT ![2].impeachPrepareSig = (validators[2]).impeachPrepareSig \union "".impeachPrepareSig]
+                /\ IF impeachPrepareCertificate((validators'[2]))
+                      THEN /\ pc' = "Lbl_22"
+                      ELSE /\ pc' = "Lbl_27"
+             \/ /\ (validators[2]).state = 4
+                /\ "block" = "impeachCommitMsg"
+                /\ validators' = [validators EXCEPT ![2].impeachCommitSig = (validators[2]).impeachCommitSig \union "".impeachCommitSig]
+                /\ IF impeachCommitCertificate((validators'[2]))
+                      THEN /\ pc' = "Lbl_25"
+                      ELSE /\ pc' = "Lbl_27"
           /\ UNCHANGED proposers
 
 Lbl_15 == /\ pc = "Lbl_15"
+          /\ validators' = [validators EXCEPT ![2].state = 1]
+          /\ pc' = "Lbl_27"
+          /\ UNCHANGED proposers
+
+Lbl_16 == /\ pc = "Lbl_16"
+          /\ validators' = [validators EXCEPT ![2].prepareSig = {}]
+          /\ pc' = "Lbl_17"
+          /\ UNCHANGED proposers
+
+Lbl_17 == /\ pc = "Lbl_17"
+          /\ validators' = [validators EXCEPT ![2].commitSig = {(validators[2]).sig}]
+          /\ pc' = "Lbl_18"
+          /\ UNCHANGED proposers
+
+Lbl_18 == /\ pc = "Lbl_18"
+          /\ validators' = [validators EXCEPT ![2].state = 2]
+          /\ pc' = "Lbl_27"
+          /\ UNCHANGED proposers
+
+Lbl_19 == /\ pc = "Lbl_19"
+          /\ validators' = [validators EXCEPT ![2].commitSig = {}]
+          /\ pc' = "Lbl_20"
+          /\ UNCHANGED proposers
+
+Lbl_20 == /\ pc = "Lbl_20"
+          /\ validators' = [validators EXCEPT ![2].state = 9]
+          /\ pc' = "Lbl_27"
+          /\ UNCHANGED proposers
+
+Lbl_21 == /\ pc = "Lbl_21"
+          /\ validators' = [validators EXCEPT ![2].state = 3]
+          /\ pc' = "Lbl_27"
+          /\ UNCHANGED proposers
+
+Lbl_22 == /\ pc = "Lbl_22"
+          /\ validators' = [validators EXCEPT ![2].impeachPrepareSig = {}]
+          /\ pc' = "Lbl_23"
+          /\ UNCHANGED proposers
+
+Lbl_23 == /\ pc = "Lbl_23"
+          /\ validators' = [validators EXCEPT ![2].impeachCommitSig = {(validators[2]).sig}]
+          /\ pc' = "Lbl_24"
+          /\ UNCHANGED proposers
+
+Lbl_24 == /\ pc = "Lbl_24"
+          /\ validators' = [validators EXCEPT ![2].state = 4]
+          /\ pc' = "Lbl_27"
+          /\ UNCHANGED proposers
+
+Lbl_25 == /\ pc = "Lbl_25"
+          /\ validators' = [validators EXCEPT ![2].impeachCommitSig = {}]
+          /\ pc' = "Lbl_26"
+          /\ UNCHANGED proposers
+
+Lbl_26 == /\ pc = "Lbl_26"
+          /\ validators' = [validators EXCEPT ![2].state = 9]
+          /\ pc' = "Lbl_27"
+          /\ UNCHANGED proposers
+
+Lbl_27 == /\ pc = "Lbl_27"
           /\ \/ /\ (validators[3]).state = 0
                 /\ "block" = "block"
                 /\ validators' = [validators EXCEPT ![3].prepareSig = {(validators[3]).sig}]
-                /\ pc' = "Lbl_16"
+                /\ pc' = "Lbl_28"
              \/ /\ (validators[3]).state = 1
                 /\ "block" = "prepareMsg"
                 /\ validators' = [validators EXCEPT ![3].prepareSig = (validators[3]).prepareSig \union "".prepareSig]
                 /\ IF prepareCertificate((validators'[3]))
-                      THEN /\ pc' = "Lbl_17"
-                      ELSE /\ pc' = "Lbl_22"
+                      THEN /\ pc' = "Lbl_29"
+                      ELSE /\ pc' = "Lbl_40"
              \/ /\ (validators[3]).state = 2
                 /\ "block" = "commitMsg"
                 /\ validators' = [validators EXCEPT ![3].commitSig = (validators[3]).commitSig \union "".commitSig]
                 /\ IF commitCertificate((validators'[3]))
-                      THEN /\ pc' = "Lbl_20"
-                      ELSE /\ pc' = "Lbl_22"
+                      THEN /\ pc' = "Lbl_32"
+                      ELSE /\ pc' = "Lbl_40"
+             \/ /\ (validators[3]).state \in {0, 1, 2}
+                /\ "block" = "impeachBlock"
+                /\ validators' = [validators EXCEPT ![3].impeachPrepareSig = {(validators[3]).sig}]
+                /\ pc' = "Lbl_34"
+             \/ /\ (validators[3]).state = 3
+                /\ "block" = "impeachPrepareMsg"
+                /\ validators' = [validators EXCEPT ![3].impeachPrepareSig = (validators[3]).impeachPrepareSig \union "".impeachPrepareSig]
+                /\ IF impeachPrepareCertificate((validators'[3]))
+                      THEN /\ pc' = "Lbl_35"
+                      ELSE /\ pc' = "Lbl_40"
+             \/ /\ (validators[3]).state = 4
+                /\ "block" = "impeachCommitMsg"
+                /\ validators' = [validators EXCEPT ![3].impeachCommitSig = (validators[3]).impeachCommitSig \union "".impeachCommitSig]
+                /\ IF impeachCommitCertificate((validators'[3]))
+                      THEN /\ pc' = "Lbl_38"
+                      ELSE /\ pc' = "Lbl_40"
           /\ UNCHANGED proposers
 
-Lbl_16 == /\ pc = "Lbl_16"
+Lbl_28 == /\ pc = "Lbl_28"
           /\ validators' = [validators EXCEPT ![3].state = 1]
-          /\ pc' = "Lbl_22"
+          /\ pc' = "Lbl_40"
           /\ UNCHANGED proposers
 
-Lbl_17 == /\ pc = "Lbl_17"
+Lbl_29 == /\ pc = "Lbl_29"
           /\ validators' = [validators EXCEPT ![3].prepareSig = {}]
-          /\ pc' = "Lbl_18"
+          /\ pc' = "Lbl_30"
           /\ UNCHANGED proposers
 
-Lbl_18 == /\ pc = "Lbl_18"
+Lbl_30 == /\ pc = "Lbl_30"
           /\ validators' = [validators EXCEPT ![3].commitSig = {(validators[3]).sig}]
-          /\ pc' = "Lbl_19"
+          /\ pc' = "Lbl_31"
           /\ UNCHANGED proposers
 
-Lbl_19 == /\ pc = "Lbl_19"
+Lbl_31 == /\ pc = "Lbl_31"
           /\ validators' = [validators EXCEPT ![3].state = 2]
-          /\ pc' = "Lbl_22"
+          /\ pc' = "Lbl_40"
           /\ UNCHANGED proposers
 
-Lbl_20 == /\ pc = "Lbl_20"
+Lbl_32 == /\ pc = "Lbl_32"
           /\ validators' = [validators EXCEPT ![3].commitSig = {}]
-          /\ pc' = "Lbl_21"
+          /\ pc' = "Lbl_33"
           /\ UNCHANGED proposers
 
-Lbl_21 == /\ pc = "Lbl_21"
+Lbl_33 == /\ pc = "Lbl_33"
           /\ validators' = [validators EXCEPT ![3].state = 9]
-          /\ pc' = "Lbl_22"
+          /\ pc' = "Lbl_40"
           /\ UNCHANGED proposers
 
-Lbl_22 == /\ pc = "Lbl_22"
+Lbl_34 == /\ pc = "Lbl_34"
+          /\ validators' = [validators EXCEPT ![3].state = 3]
+          /\ pc' = "Lbl_40"
+          /\ UNCHANGED proposers
+
+Lbl_35 == /\ pc = "Lbl_35"
+          /\ validators' = [validators EXCEPT ![3].impeachPrepareSig = {}]
+          /\ pc' = "Lbl_36"
+          /\ UNCHANGED proposers
+
+Lbl_36 == /\ pc = "Lbl_36"
+          /\ validators' = [validators EXCEPT ![3].impeachCommitSig = {(validators[3]).sig}]
+          /\ pc' = "Lbl_37"
+          /\ UNCHANGED proposers
+
+Lbl_37 == /\ pc = "Lbl_37"
+          /\ validators' = [validators EXCEPT ![3].state = 4]
+          /\ pc' = "Lbl_40"
+          /\ UNCHANGED proposers
+
+Lbl_38 == /\ pc = "Lbl_38"
+          /\ validators' = [validators EXCEPT ![3].impeachCommitSig = {}]
+          /\ pc' = "Lbl_39"
+          /\ UNCHANGED proposers
+
+Lbl_39 == /\ pc = "Lbl_39"
+          /\ validators' = [validators EXCEPT ![3].state = 9]
+          /\ pc' = "Lbl_40"
+          /\ UNCHANGED proposers
+
+Lbl_40 == /\ pc = "Lbl_40"
           /\ \/ /\ (validators[4]).state = 0
                 /\ "block" = "block"
                 /\ validators' = [validators EXCEPT ![4].prepareSig = {(validators[4]).sig}]
-                /\ pc' = "Lbl_23"
+                /\ pc' = "Lbl_41"
              \/ /\ (validators[4]).state = 1
                 /\ "block" = "prepareMsg"
                 /\ validators' = [validators EXCEPT ![4].prepareSig = (validators[4]).prepareSig \union "".prepareSig]
                 /\ IF prepareCertificate((validators'[4]))
-                      THEN /\ pc' = "Lbl_24"
+                      THEN /\ pc' = "Lbl_42"
                       ELSE /\ pc' = "Done"
              \/ /\ (validators[4]).state = 2
                 /\ "block" = "commitMsg"
                 /\ validators' = [validators EXCEPT ![4].commitSig = (validators[4]).commitSig \union "".commitSig]
                 /\ IF commitCertificate((validators'[4]))
-                      THEN /\ pc' = "Lbl_27"
+                      THEN /\ pc' = "Lbl_45"
+                      ELSE /\ pc' = "Done"
+             \/ /\ (validators[4]).state \in {0, 1, 2}
+                /\ "block" = "impeachBlock"
+                /\ validators' = [validators EXCEPT ![4].impeachPrepareSig = {(validators[4]).sig}]
+                /\ pc' = "Lbl_47"
+             \/ /\ (validators[4]).state = 3
+                /\ "block" = "impeachPrepareMsg"
+                /\ validators' = [validators EXCEPT ![4].impeachPrepareSig = (validators[4]).impeachPrepareSig \union "".impeachPrepareSig]
+                /\ IF impeachPrepareCertificate((validators'[4]))
+                      THEN /\ pc' = "Lbl_48"
+                      ELSE /\ pc' = "Done"
+             \/ /\ (validators[4]).state = 4
+                /\ "block" = "impeachCommitMsg"
+                /\ validators' = [validators EXCEPT ![4].impeachCommitSig = (validators[4]).impeachCommitSig \union "".impeachCommitSig]
+                /\ IF impeachCommitCertificate((validators'[4]))
+                      THEN /\ pc' = "Lbl_51"
                       ELSE /\ pc' = "Done"
           /\ UNCHANGED proposers
 
-Lbl_23 == /\ pc = "Lbl_23"
+Lbl_41 == /\ pc = "Lbl_41"
           /\ validators' = [validators EXCEPT ![4].state = 1]
           /\ pc' = "Done"
           /\ UNCHANGED proposers
 
-Lbl_24 == /\ pc = "Lbl_24"
+Lbl_42 == /\ pc = "Lbl_42"
           /\ validators' = [validators EXCEPT ![4].prepareSig = {}]
-          /\ pc' = "Lbl_25"
+          /\ pc' = "Lbl_43"
           /\ UNCHANGED proposers
 
-Lbl_25 == /\ pc = "Lbl_25"
+Lbl_43 == /\ pc = "Lbl_43"
           /\ validators' = [validators EXCEPT ![4].commitSig = {(validators[4]).sig}]
-          /\ pc' = "Lbl_26"
+          /\ pc' = "Lbl_44"
           /\ UNCHANGED proposers
 
-Lbl_26 == /\ pc = "Lbl_26"
+Lbl_44 == /\ pc = "Lbl_44"
           /\ validators' = [validators EXCEPT ![4].state = 2]
           /\ pc' = "Done"
           /\ UNCHANGED proposers
 
-Lbl_27 == /\ pc = "Lbl_27"
+Lbl_45 == /\ pc = "Lbl_45"
           /\ validators' = [validators EXCEPT ![4].commitSig = {}]
-          /\ pc' = "Lbl_28"
+          /\ pc' = "Lbl_46"
           /\ UNCHANGED proposers
 
-Lbl_28 == /\ pc = "Lbl_28"
+Lbl_46 == /\ pc = "Lbl_46"
+          /\ validators' = [validators EXCEPT ![4].state = 9]
+          /\ pc' = "Done"
+          /\ UNCHANGED proposers
+
+Lbl_47 == /\ pc = "Lbl_47"
+          /\ validators' = [validators EXCEPT ![4].state = 3]
+          /\ pc' = "Done"
+          /\ UNCHANGED proposers
+
+Lbl_48 == /\ pc = "Lbl_48"
+          /\ validators' = [validators EXCEPT ![4].impeachPrepareSig = {}]
+          /\ pc' = "Lbl_49"
+          /\ UNCHANGED proposers
+
+Lbl_49 == /\ pc = "Lbl_49"
+          /\ validators' = [validators EXCEPT ![4].impeachCommitSig = {(validators[4]).sig}]
+          /\ pc' = "Lbl_50"
+          /\ UNCHANGED proposers
+
+Lbl_50 == /\ pc = "Lbl_50"
+          /\ validators' = [validators EXCEPT ![4].state = 4]
+          /\ pc' = "Done"
+          /\ UNCHANGED proposers
+
+Lbl_51 == /\ pc = "Lbl_51"
+          /\ validators' = [validators EXCEPT ![4].impeachCommitSig = {}]
+          /\ pc' = "Lbl_52"
+          /\ UNCHANGED proposers
+
+Lbl_52 == /\ pc = "Lbl_52"
           /\ validators' = [validators EXCEPT ![4].state = 9]
           /\ pc' = "Done"
           /\ UNCHANGED proposers
@@ -332,6 +548,10 @@ Next == Lbl_1 \/ Lbl_2 \/ Lbl_3 \/ Lbl_4 \/ Lbl_5 \/ Lbl_6 \/ Lbl_7
            \/ Lbl_8 \/ Lbl_9 \/ Lbl_10 \/ Lbl_11 \/ Lbl_12 \/ Lbl_13 \/ Lbl_14
            \/ Lbl_15 \/ Lbl_16 \/ Lbl_17 \/ Lbl_18 \/ Lbl_19 \/ Lbl_20 \/ Lbl_21
            \/ Lbl_22 \/ Lbl_23 \/ Lbl_24 \/ Lbl_25 \/ Lbl_26 \/ Lbl_27 \/ Lbl_28
+           \/ Lbl_29 \/ Lbl_30 \/ Lbl_31 \/ Lbl_32 \/ Lbl_33 \/ Lbl_34 \/ Lbl_35
+           \/ Lbl_36 \/ Lbl_37 \/ Lbl_38 \/ Lbl_39 \/ Lbl_40 \/ Lbl_41 \/ Lbl_42
+           \/ Lbl_43 \/ Lbl_44 \/ Lbl_45 \/ Lbl_46 \/ Lbl_47 \/ Lbl_48 \/ Lbl_49
+           \/ Lbl_50 \/ Lbl_51 \/ Lbl_52
            \/ (* Disjunct to prevent deadlock on termination *)
               (pc = "Done" /\ UNCHANGED vars)
 
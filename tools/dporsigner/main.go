@@ -0,0 +1,126 @@
+// Copyright 2019 The cpchain authors
+// This file is part of cpchain.
+//
+// cpchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// cpchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with cpchain. If not, see <http://www.gnu.org/licenses/>.
+
+// dporsigner runs a reference signing service for dpor proposer seals and
+// validator signatures, with slashing protection.
+//
+// Run it with the coinbase's keystore file, then start cpchain with
+// --signer <url> pointing to its http address or ipc path. It listens on the
+// ipc endpoint in its data directory by default, http endpoints without a host
+// are bound to localhost and only accept requests to localhost.
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"bitbucket.org/cpchain/chain/accounts"
+	"bitbucket.org/cpchain/chain/accounts/keystore"
+	"bitbucket.org/cpchain/chain/api/rpc"
+	"bitbucket.org/cpchain/chain/commons/log"
+	"bitbucket.org/cpchain/chain/consensus/dpor/signer"
+	"bitbucket.org/cpchain/chain/database"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func main() {
+	var (
+		keyFile      = flag.String("keyfile", "", "keystore file of the account to sign with")
+		passwordFile = flag.String("password", "", "file containing the password of the keystore file")
+		dataDir      = flag.String("datadir", "dporsigner", "directory of the slashing protection database")
+		httpAddr     = flag.String("http", "", "http listening address, e.g. 127.0.0.1:8600, the host defaults to localhost")
+		httpVhosts   = flag.String("http.vhosts", "localhost", "comma separated virtual hostnames accepted by the http endpoint")
+		ipcPath      = flag.String("ipcpath", "", "ipc endpoint path, defaults to dporsigner.ipc in the data directory")
+	)
+	flag.Parse()
+
+	if *keyFile == "" {
+		log.Fatal("Use -keyfile to specify the keystore file")
+	}
+	if *httpAddr == "" && *ipcPath == "" {
+		*ipcPath = filepath.Join(*dataDir, "dporsigner.ipc")
+	}
+
+	keyJSON, err := ioutil.ReadFile(*keyFile)
+	if err != nil {
+		log.Fatalf("-keyfile: %v", err)
+	}
+	var password string
+	if *passwordFile != "" {
+		text, err := ioutil.ReadFile(*passwordFile)
+		if err != nil {
+			log.Fatalf("-password: %v", err)
+		}
+		password = strings.TrimRight(string(text), "\r\n")
+	}
+	key, err := keystore.DecryptKey(keyJSON, password)
+	if err != nil {
+		log.Fatalf("Failed to decrypt key: %v", err)
+	}
+
+	db, err := database.NewLDBDatabase(*dataDir, 16, 16)
+	if err != nil {
+		log.Fatalf("Failed to open slashing protection database: %v", err)
+	}
+	defer db.Close()
+
+	signFn := func(account accounts.Account, hash []byte) ([]byte, error) {
+		if account.Address != key.Address {
+			return nil, accounts.ErrUnknownAccount
+		}
+		return crypto.Sign(hash, key.PrivateKey)
+	}
+	apis := signer.APIs(signer.NewLocalSigner(signFn, signer.NewSlashingProtection(db)))
+
+	if *httpAddr != "" {
+		host, port, err := net.SplitHostPort(*httpAddr)
+		if err != nil {
+			log.Fatalf("-http: %v", err)
+		}
+		if host == "" {
+			host = "localhost"
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			log.Warn("The signing service is exposed beyond localhost, keep it behind a firewall", "host", host)
+		}
+		// no cors headers are sent, browsers may not call the service from other origins
+		listener, _, err := rpc.StartHTTPEndpoint(net.JoinHostPort(host, port), apis, nil, nil, strings.Split(*httpVhosts, ","))
+		if err != nil {
+			log.Fatalf("Failed to start http endpoint: %v", err)
+		}
+		defer listener.Close()
+		log.Info("HTTP endpoint opened", "url", "http://"+listener.Addr().String())
+	}
+	if *ipcPath != "" {
+		listener, _, err := rpc.StartIPCEndpoint(*ipcPath, apis)
+		if err != nil {
+			log.Fatalf("Failed to start ipc endpoint: %v", err)
+		}
+		defer listener.Close()
+		log.Info("IPC endpoint opened", "path", *ipcPath)
+	}
+	log.Info("Signing with account", "address", key.Address.Hex())
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt)
+	<-sigc
+	log.Info("Got interrupt, shutting down...")
+}
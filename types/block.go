@@ -120,6 +120,75 @@ type DporSnap struct {
 	Sigs       []DporSignature  `json:"sigs"`       // the signatures of validators to endorse the block
 	Proposers  []common.Address `json:"proposers"`  // current proposers committee
 	Validators []common.Address `json:"validators"` // updated validator committee in next epoch if it is not nil. Keep the same to current if it is nil.
+
+	// fields below are only encoded after the election seed fork
+	Seed    common.Hash   `json:"seed"`              // election seed, the hash of SeedSig
+	SeedSig hexutil.Bytes `json:"seedSig,omitempty"` // bls signature of the proposer on the parent's seed, empty in impeach blocks
+
+	// fields below are only encoded after the bls fork
+	AggSig    hexutil.Bytes `json:"aggSig,omitempty"`    // bls aggregate commit signature of validators in place of Sigs
+	AggBitmap hexutil.Bytes `json:"aggBitmap,omitempty"` // bitmap of positions of validators in the aggregate signature
+}
+
+// EncodeRLP implements rlp.Encoder, the election seed and the aggregate signatures
+// are omitted if they are empty to keep the encoding of blocks before the forks.
+func (d DporSnap) EncodeRLP(w io.Writer) error {
+	switch {
+	case len(d.AggSig) != 0 || len(d.AggBitmap) != 0:
+		return rlp.Encode(w, []interface{}{d.Seal, d.Sigs, d.Proposers, d.Validators, d.Seed, d.SeedSig, d.AggSig, d.AggBitmap})
+	case d.Seed != (common.Hash{}) || len(d.SeedSig) != 0:
+		return rlp.Encode(w, []interface{}{d.Seal, d.Sigs, d.Proposers, d.Validators, d.Seed, d.SeedSig})
+	}
+	return rlp.Encode(w, []interface{}{d.Seal, d.Sigs, d.Proposers, d.Validators})
+}
+
+// DecodeRLP implements rlp.Decoder
+func (d *DporSnap) DecodeRLP(s *rlp.Stream) error {
+	if _, err := s.List(); err != nil {
+		return err
+	}
+	var snap DporSnap
+	for _, field := range []interface{}{&snap.Seal, &snap.Sigs, &snap.Proposers, &snap.Validators} {
+		if err := s.Decode(field); err != nil {
+			return err
+		}
+	}
+	switch err := s.Decode(&snap.Seed); err {
+	case nil:
+		if err := s.Decode(&snap.SeedSig); err != nil {
+			return err
+		}
+	case rlp.EOL:
+		*d = snap
+		return s.ListEnd()
+	default:
+		return err
+	}
+	switch err := s.Decode(&snap.AggSig); err {
+	case nil:
+		if err := s.Decode(&snap.AggBitmap); err != nil {
+			return err
+		}
+	case rlp.EOL:
+	default:
+		return err
+	}
+	*d = snap
+	return s.ListEnd()
+}
+
+// Aggregated returns if the validators' signatures are aggregated into AggSig
+func (d *DporSnap) Aggregated() bool {
+	return len(d.AggSig) != 0
+}
+
+// SignedBy returns if the validator in the given position signed the block,
+// either in Sigs or in the aggregate signature
+func (d *DporSnap) SignedBy(pos int) bool {
+	if d.Aggregated() {
+		return pos >= 0 && pos/8 < len(d.AggBitmap) && d.AggBitmap[pos/8]&(1<<uint(pos%8)) != 0
+	}
+	return pos >= 0 && pos < len(d.Sigs) && !d.Sigs[pos].IsEmpty()
 }
 
 func (d *DporSnap) SigsFormatText() string {
@@ -180,7 +249,7 @@ func sigHash(header *Header) (hash common.Hash) {
 		header.Dpor.Proposers,
 		header.Dpor.Validators,
 		header.Extra,
-		common.Hash{},
+		header.Dpor.Seed, // in place of the unused mix digest, empty before the election seed fork
 		BlockNonce{},
 	})
 	if err != nil {
@@ -216,6 +285,7 @@ func (h *Header) Size() common.StorageSize {
 	dporSize := common.StorageSize(len(h.Dpor.Proposers))*common.StorageSize(unsafe.Sizeof(common.Address{})) +
 		common.StorageSize(len(h.Dpor.Sigs))*common.StorageSize(unsafe.Sizeof(DporSignature{})) +
 		common.StorageSize(len(h.Dpor.Validators))*common.StorageSize(unsafe.Sizeof(common.Address{})) +
+		common.StorageSize(len(h.Dpor.AggSig)+len(h.Dpor.AggBitmap)+len(h.Dpor.SeedSig)) +
 		common.StorageSize(unsafe.Sizeof(h.Dpor.Seal))
 
 	return common.StorageSize(unsafe.Sizeof(*h)) + common.StorageSize(len(h.Extra)+(h.Number.BitLen()+h.Time.BitLen())/8) + dporSize
@@ -363,6 +433,18 @@ func CopyDporSnap(d *DporSnap) *DporSnap {
 	copy(cpy.Seal[:], d.Seal[:])
 	// copy DporSnap.Validators
 	cpy.Validators = d.CopyValidators()
+	// copy DporSnap.Seed and DporSnap.SeedSig
+	cpy.Seed = d.Seed
+	if len(d.SeedSig) > 0 {
+		cpy.SeedSig = common.CopyBytes(d.SeedSig)
+	}
+	// copy DporSnap.AggSig and DporSnap.AggBitmap
+	if len(d.AggSig) > 0 {
+		cpy.AggSig = common.CopyBytes(d.AggSig)
+	}
+	if len(d.AggBitmap) > 0 {
+		cpy.AggBitmap = common.CopyBytes(d.AggBitmap)
+	}
 	return cpy
 }
 
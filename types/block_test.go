@@ -156,6 +156,86 @@ func TestBlockDporRlp(t *testing.T) {
 	fmt.Println(dp)
 }
 
+func TestDporSnapSeedRlp(t *testing.T) {
+	header := &Header{Number: big.NewInt(1), Time: big.NewInt(0)}
+	header.Dpor.Proposers = []common.Address{addr1, addr2}
+	header.Dpor.Sigs = []DporSignature{sig1, sig2}
+	hash := header.Hash()
+
+	// without a seed, the encoding is the same as the one before the fork
+	legacy, _ := rlp.EncodeToBytes([]interface{}{header.Dpor.Seal, header.Dpor.Sigs, header.Dpor.Proposers, header.Dpor.Validators})
+	enc, err := rlp.EncodeToBytes(&header.Dpor)
+	assert.Nil(t, err)
+	assert.Equal(t, legacy, enc)
+
+	var dpor DporSnap
+	assert.Nil(t, rlp.DecodeBytes(enc, &dpor))
+	assert.Equal(t, common.Hash{}, dpor.Seed)
+
+	// with a seed
+	header.Dpor.Seed = common.HexToHash("0x01")
+	assert.NotEqual(t, hash, header.Hash())
+
+	enc, err = rlp.EncodeToBytes(header)
+	assert.Nil(t, err)
+	var decoded Header
+	assert.Nil(t, rlp.DecodeBytes(enc, &decoded))
+	assert.Equal(t, header.Dpor.Seed, decoded.Dpor.Seed)
+	assert.Equal(t, header.Hash(), decoded.Hash())
+
+	// with the signature of the proposer, which is not in the hash but bound by the seed
+	hash = header.Hash()
+	header.Dpor.SeedSig = []byte{4, 5, 6}
+	assert.Equal(t, hash, header.Hash())
+
+	enc, err = rlp.EncodeToBytes(header)
+	assert.Nil(t, err)
+	decoded = Header{}
+	assert.Nil(t, rlp.DecodeBytes(enc, &decoded))
+	assert.Equal(t, header.Dpor.SeedSig, decoded.Dpor.SeedSig)
+
+	cpy := CopyDporSnap(&decoded.Dpor)
+	cpy.SeedSig[0] = 0
+	assert.Equal(t, header.Dpor.SeedSig, decoded.Dpor.SeedSig)
+}
+
+func TestDporSnapAggregateRlp(t *testing.T) {
+	header := &Header{Number: big.NewInt(1), Time: big.NewInt(0)}
+	header.Dpor.Proposers = []common.Address{addr1, addr2}
+	header.Dpor.AggSig = []byte{1, 2, 3}
+	header.Dpor.AggBitmap = []byte{0x0b}
+	hash := header.Hash()
+
+	enc, err := rlp.EncodeToBytes(header)
+	assert.Nil(t, err)
+	var decoded Header
+	assert.Nil(t, rlp.DecodeBytes(enc, &decoded))
+	assert.Equal(t, hash, decoded.Hash())
+	assert.Equal(t, header.Dpor.AggSig, decoded.Dpor.AggSig)
+	assert.Equal(t, header.Dpor.AggBitmap, decoded.Dpor.AggBitmap)
+
+	assert.True(t, decoded.Dpor.Aggregated())
+	assert.True(t, decoded.Dpor.SignedBy(0))
+	assert.False(t, decoded.Dpor.SignedBy(2))
+	assert.True(t, decoded.Dpor.SignedBy(3))
+	assert.False(t, decoded.Dpor.SignedBy(8))
+
+	cpy := CopyDporSnap(&decoded.Dpor)
+	cpy.AggBitmap[0] = 0
+	assert.True(t, decoded.Dpor.SignedBy(0))
+
+	// with the election seed as well
+	header.Dpor.Seed = common.HexToHash("0x01")
+	header.Dpor.SeedSig = []byte{4, 5, 6}
+	enc, err = rlp.EncodeToBytes(header)
+	assert.Nil(t, err)
+	decoded = Header{}
+	assert.Nil(t, rlp.DecodeBytes(enc, &decoded))
+	assert.Equal(t, header.Hash(), decoded.Hash())
+	assert.Equal(t, header.Dpor.SeedSig, decoded.Dpor.SeedSig)
+	assert.Equal(t, header.Dpor.AggSig, decoded.Dpor.AggSig)
+}
+
 func TestDporSignatureJsonEncoding(t *testing.T) {
 	sig := HexToDporSig("0xc9efd3956760d72613081c50294ad582d0e36bea45878f3570cc9e8525b997472120d0ef25f88c3b64122b967bd5063633b744bc4e3ae3afc316bb4e5c7edc1d00")
 	jsonBytes, err := json.Marshal(sig)
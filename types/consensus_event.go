@@ -0,0 +1,53 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TermEvent is posted when the first block of a new term is committed.
+type TermEvent struct {
+	Term       uint64           `json:"term"`
+	Number     uint64           `json:"number"` // number of the first block of the term
+	Proposers  []common.Address `json:"proposers"`
+	Validators []common.Address `json:"validators"`
+}
+
+// CommitteeEvent is posted when a new proposer list is elected for a future term.
+type CommitteeEvent struct {
+	Term      uint64           `json:"term"`   // the term the proposers are elected for
+	Number    uint64           `json:"number"` // number of the block at which the proposers are elected
+	Proposers []common.Address `json:"proposers"`
+}
+
+// ImpeachEvent is posted when an impeach block is committed.
+type ImpeachEvent struct {
+	Number   uint64         `json:"number"`
+	Hash     common.Hash    `json:"hash"`
+	Proposer common.Address `json:"proposer"` // the proposer impeached for not proposing in its view
+}
+
+// RoleEvent is posted when the role of the local node in the committee changes.
+type RoleEvent struct {
+	Number    uint64         `json:"number"` // number of the block at which the role changes
+	Term      uint64         `json:"term"`
+	Address   common.Address `json:"address"`
+	Proposer  bool           `json:"proposer"`  // the node is a proposer of the term
+	Validator bool           `json:"validator"` // the node is a validator of the term
+	Candidate bool           `json:"candidate"` // the node is a proposer candidate
+}
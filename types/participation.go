@@ -0,0 +1,83 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ProposerStats is the block production statistics of a proposer in a term.
+type ProposerStats struct {
+	Address   common.Address `json:"address"`
+	Proposed  uint64         `json:"proposed"`  // number of blocks proposed
+	Impeached uint64         `json:"impeached"` // number of impeach blocks generated in place of the proposer
+}
+
+// ValidatorParticipation is the signing statistics of a validator in a term.
+type ValidatorParticipation struct {
+	Address common.Address `json:"address"`
+	Signed  uint64         `json:"signed"` // number of blocks carrying the validator's signature
+	Missed  uint64         `json:"missed"` // number of blocks missing the validator's signature
+}
+
+// TermParticipation is the participation statistics of the committee in a term,
+// collected from the indexed blocks of the term.
+type TermParticipation struct {
+	Term       uint64
+	Blocks     uint64 // number of indexed blocks of the term
+	Proposers  []*ProposerStats
+	Validators []*ValidatorParticipation
+}
+
+// Proposer returns the stats of the given proposer, adding an empty one if absent.
+func (p *TermParticipation) Proposer(addr common.Address) *ProposerStats {
+	for _, s := range p.Proposers {
+		if s.Address == addr {
+			return s
+		}
+	}
+	s := &ProposerStats{Address: addr}
+	p.Proposers = append(p.Proposers, s)
+	return s
+}
+
+// Validator returns the stats of the given validator, adding an empty one if absent.
+func (p *TermParticipation) Validator(addr common.Address) *ValidatorParticipation {
+	for _, s := range p.Validators {
+		if s.Address == addr {
+			return s
+		}
+	}
+	s := &ValidatorParticipation{Address: addr}
+	p.Validators = append(p.Validators, s)
+	return s
+}
+
+// Merge adds the stats of other to p.
+func (p *TermParticipation) Merge(other *TermParticipation) {
+	p.Blocks += other.Blocks
+	for _, s := range other.Proposers {
+		stats := p.Proposer(s.Address)
+		stats.Proposed += s.Proposed
+		stats.Impeached += s.Impeached
+	}
+	for _, s := range other.Validators {
+		stats := p.Validator(s.Address)
+		stats.Signed += s.Signed
+		stats.Missed += s.Missed
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2019 The cpchain authors
+// This file is part of the cpchain library.
+//
+// The cpchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The cpchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the cpchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// names of the components of a reputation value
+const (
+	RptBalance     = "balance"     // rank of balance among candidates
+	RptTxs         = "txs"         // rank of tx count in the window among candidates
+	RptMaintenance = "maintenance" // rank of blocks proposed in the window among candidates
+	RptUpload      = "upload"      // file contribution, not collected yet
+	RptProxy       = "proxy"       // proxy information in pdash, not collected yet
+)
+
+// RptComponent is a weighted component of a reputation value.
+type RptComponent struct {
+	Name       string `json:"name"`
+	Raw        int64  `json:"raw"`        // value collected from the chain, e.g. balance in cpc
+	Normalized int64  `json:"normalized"` // value normalized among candidates
+	Weight     int64  `json:"weight"`     // coefficient of the normalized value
+}
+
+// RptBreakdown explains how the reputation value of a candidate is calculated at a block.
+type RptBreakdown struct {
+	Address    common.Address  `json:"address"`
+	Number     uint64          `json:"number"`
+	Version    int             `json:"version"`    // version of the rpt collector used at the block
+	Components []*RptComponent `json:"components"` // empty if the collector does not break its value down
+	Rpt        int64           `json:"rpt"`        // sum of weighted components, at least the min rpt score
+}